@@ -9,16 +9,35 @@
 //
 // Usage:
 //
-//	dougless [flags] [script.js]
+//	dougless [flags] [script.js] [script args...]
+//
+// Flags must come before the script path. Everything after the script path is
+// passed to the script untouched and shows up in process.argv:
+//
+//	process.argv = ['/path/to/dougless', 'script.js', ...script args]
 //
 // Flags:
 //
-//	--allow-read[=path]       Grant read access (optionally to specific paths)
+//	--allow-read[=path]       Grant read access (optionally to specific paths,
+//	                          or file patterns like /data:*.json)
 //	--allow-write[=path]      Grant write access (optionally to specific paths)
 //	--allow-net[=host]        Grant network access (optionally to specific hosts)
 //	--allow-env[=var]         Grant environment variable access
 //	--allow-run[=program]     Grant subprocess execution access
+//	--allow-sys[=name]        Grant system information access (os.hostname, ...)
 //	--allow-all               Grant all permissions (for development)
+//	--prompt                  Always prompt for missing permissions
+//	--no-prompt               Never prompt; missing permissions fail fast (even on a TTY)
+//	--trace-permissions       Log every permission check and its outcome to stderr
+//	--target=es5|es2015|es2017|esnext
+//	                          Transpile target (default es2017; esnext skips downleveling)
+//	--user-agent=value        User-Agent for outbound HTTP (default Dougless/<version>)
+//	--preserve-symlinks       Identify required files by symlink path, not real path
+//	--env-file=path           Load environment variables from a file; reading them
+//	                          with process.env or env.get still needs --allow-env
+//	--expose-gc               Define a global gc() that forces a garbage collection
+//	--profile                 Count calls and time spent per function, printing a
+//	                          table sorted by time to stderr when the script exits
 //
 // Examples:
 //
@@ -45,7 +64,13 @@ import (
 )
 
 func main() {
-	permManager, remainingArgs, err := permissions.ParseFlags(os.Args[1:])
+	opts, args, err := runtime.ParseFlags(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing flags: %v\n", err)
+		os.Exit(1)
+	}
+
+	permManager, remainingArgs, err := permissions.ParseFlags(args)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error parsing flags: %v\n", err)
 		os.Exit(1)
@@ -53,7 +78,30 @@ func main() {
 
 	permissions.SetGlobalManager(permManager)
 
-	rt := runtime.New(os.Args)
+	// before the runtime starts, so process.env sees the loaded variables too
+	if opts.EnvFile != "" {
+		if err := runtime.LoadEnvFile(opts.EnvFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// process.argv is the executable followed by the script and its own args;
+	// permission flags are consumed here and never reach the script
+	argv := append([]string{os.Args[0]}, remainingArgs...)
+	rt := runtime.New(argv)
+	if err := rt.SetTarget(opts.Target); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if opts.UserAgent != "" {
+		rt.SetUserAgent(opts.UserAgent)
+	}
+	rt.SetPreserveSymlinks(opts.PreserveSymlinks)
+	rt.SetExposeGC(opts.ExposeGC)
+	if opts.Profile {
+		rt.SetProfile(os.Stderr)
+	}
 
 	// go into repl mode if no args
 	if len(remainingArgs) == 0 {
@@ -65,7 +113,7 @@ func main() {
 		return
 	}
 
-	// the only other accepted arg are .js files
+	// the first positional is the script; remainingArgs[1:] are its argv
 	scriptPath := remainingArgs[0]
 	if err := rt.ExecuteFile(scriptPath); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -1,4 +1,5 @@
 // Test process module
+// Run with --allow-env=HOME,USER,PATH (or --allow-env) to read process.env
 console.log('Testing process module...');
 
 // Test process.env
@@ -0,0 +1,194 @@
+package event
+
+import (
+	"sync"
+	"time"
+)
+
+// manualClock is the virtual clock of a deterministic loop. Time only moves
+// when RunUntilIdle or Advance moves it, and timers due by then are
+// scheduled in deadline order.
+type manualClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	seq    uint64 // orders timers with the same deadline by creation
+	timers map[*manualTimer]struct{}
+}
+
+type manualTimer struct {
+	at   time.Time
+	seq  uint64
+	task Task
+}
+
+// deterministicEpoch is where a deterministic loop's clock starts.
+var deterministicEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// NewDeterministicLoop creates a loop for tests whose timers run on a
+// virtual clock. Tasks, microtasks and immediates run as usual on the loop
+// goroutine; timers set with AfterFunc fire only when RunUntilIdle or
+// Advance moves the clock, so tests needn't sleep for them.
+//
+//	loop := event.NewDeterministicLoop()
+//	loop.Start()
+//	loop.AfterFunc(time.Hour, event.Task{Callback: func() { fired = true }})
+//	loop.RunUntilIdle() // fired, without waiting an hour
+//
+// Work done on other goroutines (network or file I/O) isn't tracked: only
+// what has been handed to the loop counts towards idleness.
+func NewDeterministicLoop() *Loop {
+	l := NewLoop()
+	l.clock = &manualClock{
+		now:    deterministicEpoch,
+		timers: make(map[*manualTimer]struct{}),
+	}
+	l.idle = sync.NewCond(&l.idleMu)
+	return l
+}
+
+// Deterministic reports whether the loop was created by
+// NewDeterministicLoop.
+func (l *Loop) Deterministic() bool {
+	return l.clock != nil
+}
+
+// Now returns the loop's current time: the virtual clock's for a
+// deterministic loop, the wall clock's otherwise.
+func (l *Loop) Now() time.Time {
+	if l.clock == nil {
+		return time.Now()
+	}
+	l.clock.mu.Lock()
+	defer l.clock.mu.Unlock()
+	return l.clock.now
+}
+
+// AfterFunc schedules task to run on the loop once d has passed on the
+// loop's clock. The returned stop function cancels it, reporting false if
+// it has already been scheduled.
+func (l *Loop) AfterFunc(d time.Duration, task Task) (stop func() bool) {
+	if l.clock == nil {
+		timer := time.AfterFunc(d, func() { l.Schedule(task) })
+		return timer.Stop
+	}
+
+	c := l.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if d < 0 {
+		d = 0
+	}
+	c.seq++
+	timer := &manualTimer{at: c.now.Add(d), seq: c.seq, task: task}
+	c.timers[timer] = struct{}{}
+
+	return func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if _, pending := c.timers[timer]; !pending {
+			return false
+		}
+		delete(c.timers, timer)
+		return true
+	}
+}
+
+// RunUntilIdle runs everything queued on a deterministic loop, moving the
+// clock forward to each pending timer in turn, until there is nothing left
+// to run. An interval that is never cleared keeps it going forever; use
+// Advance for those. It must not be called from the loop goroutine.
+func (l *Loop) RunUntilIdle() {
+	l.runClock(time.Time{})
+}
+
+// Advance moves a deterministic loop's clock forward by d, running the
+// timers that fall due on the way and everything they queue, then waits
+// until the loop is idle. It must not be called from the loop goroutine.
+func (l *Loop) Advance(d time.Duration) {
+	l.runClock(l.Now().Add(d))
+}
+
+// runClock fires timers due by until (all of them when until is zero),
+// waiting for the loop to go idle before each one.
+func (l *Loop) runClock(until time.Time) {
+	if l.clock == nil {
+		panic("event: RunUntilIdle and Advance need a loop from NewDeterministicLoop")
+	}
+
+	for l.waitIdle() {
+		due := l.clock.next(until)
+		if due == nil {
+			break
+		}
+		l.Schedule(due.task)
+	}
+
+	if !until.IsZero() {
+		l.clock.mu.Lock()
+		if until.After(l.clock.now) {
+			l.clock.now = until
+		}
+		l.clock.mu.Unlock()
+	}
+}
+
+// next removes and returns the earliest timer due by until (any timer when
+// until is zero), moving the clock to its deadline.
+func (c *manualClock) next(until time.Time) *manualTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var first *manualTimer
+	for timer := range c.timers {
+		if !until.IsZero() && timer.at.After(until) {
+			continue
+		}
+		if first == nil || timer.at.Before(first.at) || (timer.at.Equal(first.at) && timer.seq < first.seq) {
+			first = timer
+		}
+	}
+	if first == nil {
+		return nil
+	}
+
+	delete(c.timers, first)
+	if first.at.After(c.now) {
+		c.now = first.at
+	}
+	return first
+}
+
+// track counts work handed to a deterministic loop, waking waitIdle when
+// none is left.
+func (l *Loop) track(delta int) {
+	if l.clock == nil {
+		return
+	}
+	l.idleMu.Lock()
+	l.outstanding += delta
+	if l.outstanding == 0 {
+		l.idle.Broadcast()
+	}
+	l.idleMu.Unlock()
+}
+
+// waitIdle blocks until the loop has run everything handed to it, reporting
+// false if the loop was stopped instead.
+func (l *Loop) waitIdle() bool {
+	l.idleMu.Lock()
+	defer l.idleMu.Unlock()
+	for l.outstanding > 0 && !l.isStopped() {
+		l.idle.Wait()
+	}
+	return !l.isStopped()
+}
+
+// isStopped reports whether Stop has been called.
+func (l *Loop) isStopped() bool {
+	select {
+	case <-l.stop:
+		return true
+	default:
+		return false
+	}
+}
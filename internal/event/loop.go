@@ -0,0 +1,328 @@
+// Package event provides the event loop that serializes work onto the
+// goroutine that owns the JavaScript VM.
+//
+// Goja runtimes are not safe for concurrent use, so background goroutines
+// (network handlers, websocket readers, etc.) hand their VM work to the loop
+// as Tasks instead of touching the VM directly.
+//
+// Nothing enforces that rule at compile time, and breaking it usually works
+// until it corrupts the VM under load. The race detector catches it, so the
+// required check for any change to the runtime or its modules is the suite
+// run with -race, which must report no races:
+//
+//	go test -race ./tests/
+//
+// Example:
+//
+//	loop := event.NewLoop()
+//	loop.Start()
+//	loop.Schedule(event.Task{Name: "greet", Callback: func() {
+//	    fmt.Println("running on the loop goroutine")
+//	}})
+package event
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultSlowTaskThreshold is how long a task may block the loop before a
+// warning is printed.
+const DefaultSlowTaskThreshold = 100 * time.Millisecond
+
+// Task is a unit of work executed on the loop goroutine.
+type Task struct {
+	Name     string // Optional label used in diagnostics (e.g. slow-task warnings)
+	Callback func() // Work to run on the loop goroutine
+	Untimed  bool   // Exempt from slow-task warnings (e.g. the main script)
+}
+
+// Loop runs scheduled tasks one at a time on a dedicated goroutine.
+//
+// After each task the loop drains the microtask queue, then runs the
+// immediates that were queued before that point, draining microtasks after
+// each one. This matches Node's ordering: promise reactions and
+// queueMicrotask callbacks run before the next setImmediate, and
+// setImmediate callbacks run once the current task finishes, ahead of any
+// later task such as a timer.
+type Loop struct {
+	tasks         chan Task     // Pending tasks in FIFO order
+	wake          chan struct{} // Signalled when microtasks or immediates are queued
+	queueMu       sync.Mutex    // Protects microtasks and immediates
+	microtasks    []func()      // Run after the current task, in FIFO order
+	immediates    []Task        // Run after microtasks, in FIFO order
+	stop          chan struct{} // Closed by Stop to end the loop
+	stopped       chan struct{} // Closed once the loop goroutine has exited
+	startOnce     sync.Once
+	stopOnce      sync.Once
+	mu            sync.Mutex    // Protects the diagnostics settings below
+	slowThreshold time.Duration // Tasks running longer than this are reported (0 disables)
+	warnOutput    io.Writer     // Destination for slow-task warnings
+
+	// set by NewDeterministicLoop
+	clock       *manualClock // virtual time for AfterFunc (nil uses real time)
+	idleMu      sync.Mutex   // Protects outstanding
+	idle        *sync.Cond   // Broadcast when outstanding drops to zero or the loop stops
+	outstanding int          // Tasks, microtasks and immediates queued or running
+}
+
+// NewLoop creates a loop with the default slow-task threshold.
+// The loop does not process tasks until Start is called.
+func NewLoop() *Loop {
+	return &Loop{
+		tasks:         make(chan Task, 100),
+		wake:          make(chan struct{}, 1),
+		stop:          make(chan struct{}),
+		stopped:       make(chan struct{}),
+		slowThreshold: DefaultSlowTaskThreshold,
+		warnOutput:    os.Stderr,
+	}
+}
+
+// Start launches the loop goroutine. Calling Start more than once is a no-op.
+func (l *Loop) Start() {
+	l.startOnce.Do(func() {
+		go l.run()
+	})
+}
+
+// Schedule queues a task to run on the loop goroutine.
+// Tasks scheduled after Stop are dropped.
+func (l *Loop) Schedule(task Task) {
+	l.track(1)
+	select {
+	case l.tasks <- task:
+	case <-l.stop:
+		l.track(-1)
+	}
+}
+
+// QueueMicrotask queues fn to run once the current task (and any microtasks
+// ahead of it) finishes, before the next immediate or task. It may be
+// called from any goroutine.
+func (l *Loop) QueueMicrotask(fn func()) {
+	l.track(1)
+	l.queueMu.Lock()
+	l.microtasks = append(l.microtasks, fn)
+	l.queueMu.Unlock()
+	l.signal()
+}
+
+// ScheduleImmediate queues a task to run after the current task and its
+// microtasks, ahead of tasks queued with Schedule. Immediates queued while
+// immediates are running wait for the next round, as in Node.
+func (l *Loop) ScheduleImmediate(task Task) {
+	l.track(1)
+	l.queueMu.Lock()
+	l.immediates = append(l.immediates, task)
+	l.queueMu.Unlock()
+	l.signal()
+}
+
+// Run schedules fn as a task and blocks until it has returned. It reports
+// false, without running fn, if the loop is stopped first. Run must not be
+// called from the loop goroutine.
+func (l *Loop) Run(name string, fn func()) bool {
+	done := make(chan struct{})
+	task := Task{Name: name, Untimed: true, Callback: func() {
+		defer close(done)
+		fn()
+	}}
+
+	l.track(1)
+	select {
+	case l.tasks <- task:
+	case <-l.stop:
+		l.track(-1)
+		return false
+	}
+
+	select {
+	case <-done:
+		return true
+	case <-l.stopped:
+		select {
+		case <-done: // finished just before the loop exited
+			return true
+		default:
+			return false
+		}
+	}
+}
+
+// Drain blocks until the tasks, microtasks and immediates queued so far have
+// run, along with any they queue in turn, so work handed to the loop isn't
+// lost when it is stopped. It gives up, reporting false, once timeout has
+// passed (e.g. a task that keeps rescheduling itself) or if the loop is
+// stopped first. Timers that haven't fired yet are not waited for. Drain
+// must not be called from the loop goroutine.
+func (l *Loop) Drain(timeout time.Duration) bool {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		// everything queued before the marker has run once it does; checking
+		// for more from the loop goroutine also counts what those queued
+		done := make(chan struct{})
+		var more bool
+		marker := Task{Name: "drain", Untimed: true, Callback: func() {
+			more = l.pending()
+			close(done)
+		}}
+
+		l.track(1)
+		select {
+		case l.tasks <- marker:
+		case <-l.stop:
+			l.track(-1)
+			return false
+		case <-deadline.C:
+			l.track(-1)
+			return false
+		}
+
+		select {
+		case <-done:
+		case <-l.stopped:
+			return false
+		case <-deadline.C:
+			return false
+		}
+
+		if !more {
+			return true
+		}
+	}
+}
+
+// pending reports whether any tasks, microtasks or immediates are queued.
+func (l *Loop) pending() bool {
+	l.queueMu.Lock()
+	defer l.queueMu.Unlock()
+	return len(l.tasks) > 0 || len(l.microtasks) > 0 || len(l.immediates) > 0
+}
+
+// signal wakes the loop goroutine if it is idle.
+func (l *Loop) signal() {
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Stop ends the loop after the currently running task (if any) finishes.
+// Pending tasks are discarded; call Drain first to run them. Calling Stop
+// more than once is a no-op.
+func (l *Loop) Stop() {
+	l.stopOnce.Do(func() {
+		close(l.stop)
+		if l.idle != nil {
+			l.idleMu.Lock()
+			l.idle.Broadcast() // releases RunUntilIdle
+			l.idleMu.Unlock()
+		}
+	})
+}
+
+// Wait blocks until the loop goroutine has exited after Stop.
+func (l *Loop) Wait() {
+	<-l.stopped
+}
+
+// SetSlowTaskThreshold sets how long a task may run before a warning is
+// printed. A zero or negative duration disables the warnings.
+func (l *Loop) SetSlowTaskThreshold(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.slowThreshold = d
+}
+
+// SetWarningOutput redirects slow-task warnings (stderr by default).
+func (l *Loop) SetWarningOutput(w io.Writer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.warnOutput = w
+}
+
+func (l *Loop) run() {
+	defer close(l.stopped)
+
+	for {
+		select {
+		case task := <-l.tasks:
+			l.runTask(task)
+			l.track(-1)
+		case <-l.wake:
+		case <-l.stop:
+			return
+		}
+
+		l.runMicrotasks()
+		l.runImmediates()
+	}
+}
+
+// runMicrotasks drains the microtask queue, including microtasks queued by
+// the ones it runs.
+func (l *Loop) runMicrotasks() {
+	for {
+		l.queueMu.Lock()
+		if len(l.microtasks) == 0 {
+			l.queueMu.Unlock()
+			return
+		}
+		fn := l.microtasks[0]
+		l.microtasks[0] = nil
+		l.microtasks = l.microtasks[1:]
+		l.queueMu.Unlock()
+
+		fn()
+		l.track(-1)
+	}
+}
+
+// runImmediates runs the immediates queued so far, draining microtasks after
+// each one. Immediates they queue run on the next pass.
+func (l *Loop) runImmediates() {
+	l.queueMu.Lock()
+	immediates := l.immediates
+	l.immediates = nil
+	l.queueMu.Unlock()
+
+	for _, task := range immediates {
+		l.runTask(task)
+		l.track(-1)
+		l.runMicrotasks()
+	}
+}
+
+// runTask executes a single task and reports it if it blocked the loop
+// for longer than the slow-task threshold.
+func (l *Loop) runTask(task Task) {
+	start := time.Now()
+	task.Callback()
+	elapsed := time.Since(start)
+	if task.Untimed {
+		return
+	}
+
+	l.mu.Lock()
+	threshold := l.slowThreshold
+	out := l.warnOutput
+	l.mu.Unlock()
+
+	if threshold <= 0 || elapsed <= threshold {
+		return
+	}
+
+	name := task.Name
+	if name == "" {
+		name = "anonymous"
+	}
+
+	fmt.Fprintf(out, "Warning: slow task %q blocked the event loop for %s (threshold %s)\n",
+		name, elapsed.Round(time.Millisecond), threshold)
+}
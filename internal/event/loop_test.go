@@ -0,0 +1,264 @@
+package event
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer safe for use from the loop goroutine
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// runAndWait schedules a task and blocks until the loop is done with it,
+// including the slow-task warning it may print after the callback returns
+func runAndWait(t *testing.T, loop *Loop, task Task) {
+	t.Helper()
+
+	done := make(chan struct{})
+	loop.Schedule(task)
+	loop.Schedule(Task{Callback: func() { close(done) }})
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("task %q did not run", task.Name)
+	}
+}
+
+func TestLoopRunsTasksInOrder(t *testing.T) {
+	loop := NewLoop()
+	loop.Start()
+	defer loop.Stop()
+
+	var order []int
+	for i := 0; i < 5; i++ {
+		n := i
+		loop.Schedule(Task{Callback: func() { order = append(order, n) }})
+	}
+	runAndWait(t, loop, Task{Callback: func() {}})
+
+	for i, n := range order {
+		if n != i {
+			t.Fatalf("tasks ran out of order: %v", order)
+		}
+	}
+	if len(order) != 5 {
+		t.Errorf("expected 5 tasks to run, got %d", len(order))
+	}
+}
+
+func TestLoopSlowTaskWarning(t *testing.T) {
+	loop := NewLoop()
+	out := &syncBuffer{}
+	loop.SetWarningOutput(out)
+	loop.SetSlowTaskThreshold(10 * time.Millisecond)
+	loop.Start()
+	defer loop.Stop()
+
+	runAndWait(t, loop, Task{Name: "fast work", Callback: func() {}})
+	if got := out.String(); got != "" {
+		t.Errorf("fast task should not warn, got %q", got)
+	}
+
+	runAndWait(t, loop, Task{Name: "heavy sync work", Callback: func() {
+		time.Sleep(30 * time.Millisecond)
+	}})
+
+	got := out.String()
+	if !strings.Contains(got, "slow task") || !strings.Contains(got, `"heavy sync work"`) {
+		t.Errorf("expected slow-task warning naming the task, got %q", got)
+	}
+
+	runAndWait(t, loop, Task{Callback: func() {
+		time.Sleep(30 * time.Millisecond)
+	}})
+	if !strings.Contains(out.String(), `"anonymous"`) {
+		t.Errorf("expected unnamed slow task to be reported as anonymous, got %q", out.String())
+	}
+}
+
+func TestLoopSlowTaskWarningDisabled(t *testing.T) {
+	loop := NewLoop()
+	out := &syncBuffer{}
+	loop.SetWarningOutput(out)
+	loop.SetSlowTaskThreshold(0)
+	loop.Start()
+	defer loop.Stop()
+
+	runAndWait(t, loop, Task{Name: "slow", Callback: func() {
+		time.Sleep(20 * time.Millisecond)
+	}})
+
+	if got := out.String(); got != "" {
+		t.Errorf("warnings should be disabled, got %q", got)
+	}
+}
+
+func TestLoopStopAndWait(t *testing.T) {
+	loop := NewLoop()
+	loop.Start()
+	loop.Stop()
+
+	waited := make(chan struct{})
+	go func() {
+		loop.Wait()
+		close(waited)
+	}()
+
+	select {
+	case <-waited:
+	case <-time.After(time.Second):
+		t.Fatal("Wait did not return after Stop")
+	}
+
+	// scheduling after Stop must not block
+	loop.Schedule(Task{Callback: func() {}})
+}
+
+func TestLoopMicrotasksBeforeImmediates(t *testing.T) {
+	loop := NewLoop()
+	loop.Start()
+	defer loop.Stop()
+
+	var order []string
+	record := func(s string) func() {
+		return func() { order = append(order, s) }
+	}
+
+	ran := loop.Run("main", func() {
+		loop.Schedule(Task{Callback: record("task")})
+		loop.ScheduleImmediate(Task{Callback: func() {
+			order = append(order, "immediate")
+			loop.QueueMicrotask(record("microtask in immediate"))
+		}})
+		loop.QueueMicrotask(func() {
+			order = append(order, "microtask")
+			loop.QueueMicrotask(record("nested microtask"))
+		})
+		order = append(order, "main")
+	})
+	if !ran {
+		t.Fatal("Run reported the loop as stopped")
+	}
+	runAndWait(t, loop, Task{Callback: func() {}})
+
+	want := "main,microtask,nested microtask,immediate,microtask in immediate,task"
+	if got := strings.Join(order, ","); got != want {
+		t.Errorf("order = %q, want %q", got, want)
+	}
+}
+
+func TestLoopRunAfterStop(t *testing.T) {
+	loop := NewLoop()
+	loop.Start()
+	loop.Stop()
+	loop.Wait()
+
+	if loop.Run("late", func() { t.Error("fn ran after Stop") }) {
+		t.Error("Run = true after Stop, want false")
+	}
+}
+
+func TestLoopDrain(t *testing.T) {
+	loop := NewLoop()
+	loop.Start()
+	defer loop.Stop()
+
+	var mu sync.Mutex
+	var order []string
+	record := func(s string) func() {
+		return func() {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, s)
+		}
+	}
+
+	loop.Schedule(Task{Callback: func() {
+		record("task")()
+		loop.QueueMicrotask(record("microtask"))
+		loop.ScheduleImmediate(Task{Callback: func() {
+			record("immediate")()
+			loop.Schedule(Task{Callback: record("queued by immediate")})
+		}})
+	}})
+
+	if !loop.Drain(2 * time.Second) {
+		t.Fatal("Drain = false, want true")
+	}
+	mu.Lock()
+	got := strings.Join(order, ",")
+	mu.Unlock()
+	if want := "task,microtask,immediate,queued by immediate"; got != want {
+		t.Errorf("order = %q, want %q", got, want)
+	}
+
+	// a task that keeps rescheduling itself is cut off by the timeout
+	var again func()
+	again = func() { loop.Schedule(Task{Callback: again}) }
+	loop.Schedule(Task{Callback: again})
+	if loop.Drain(50 * time.Millisecond) {
+		t.Error("Drain = true with work still being queued, want false")
+	}
+}
+
+func TestDeterministicLoopTimers(t *testing.T) {
+	loop := NewDeterministicLoop()
+	loop.Start()
+	defer loop.Stop()
+
+	start := loop.Now()
+	var order []string
+	at := func(name string) Task {
+		return Task{Name: name, Callback: func() {
+			order = append(order, fmt.Sprintf("%s@%s", name, loop.Now().Sub(start)))
+		}}
+	}
+
+	loop.AfterFunc(time.Hour, at("hour"))
+	loop.AfterFunc(time.Minute, Task{Callback: func() {
+		order = append(order, "minute")
+		// a timer set by a timer runs relative to the virtual now
+		loop.AfterFunc(time.Minute, at("two minutes"))
+		loop.QueueMicrotask(func() { order = append(order, "microtask") })
+	}})
+	stop := loop.AfterFunc(30*time.Second, at("cancelled"))
+	if !stop() {
+		t.Error("stop() = false for a pending timer")
+	}
+
+	realStart := time.Now()
+	loop.Advance(90 * time.Second)
+	if got := strings.Join(order, ","); got != "minute,microtask" {
+		t.Errorf("after Advance(90s) order = %q", got)
+	}
+	if got := loop.Now().Sub(start); got != 90*time.Second {
+		t.Errorf("Now() after Advance(90s) = +%s", got)
+	}
+
+	loop.RunUntilIdle()
+	if got, want := strings.Join(order, ","), "minute,microtask,two minutes@2m0s,hour@1h0m0s"; got != want {
+		t.Errorf("order = %q, want %q", got, want)
+	}
+	if elapsed := time.Since(realStart); elapsed > time.Second {
+		t.Errorf("virtual timers took %s of real time", elapsed)
+	}
+}
@@ -0,0 +1,296 @@
+package modules
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"github.com/douglasjordan2/dougless/internal/event"
+)
+
+// abortSignalKey is the hidden property linking a JS signal object to its Go state.
+const abortSignalKey = "_signal"
+
+// AbortSignal is the Go side of a JavaScript AbortSignal.
+// Async operations watch Done() (or derive a Context) to cancel in-flight work
+// when the signal fires.
+type AbortSignal struct {
+	done      chan struct{}
+	once      sync.Once
+	mu        sync.Mutex
+	reason    goja.Value      // JS reason value (created lazily for Go-side aborts)
+	reasonMsg string          // string form of the reason, safe to read off the VM goroutine
+	errName   string          // error name used when the reason is created lazily
+	listeners []goja.Callable // 'abort' event listeners
+}
+
+func newAbortSignal() *AbortSignal {
+	return &AbortSignal{
+		done: make(chan struct{}),
+	}
+}
+
+// Done returns a channel that is closed once the signal has been aborted.
+func (s *AbortSignal) Done() <-chan struct{} {
+	return s.done
+}
+
+// Aborted reports whether the signal has fired.
+func (s *AbortSignal) Aborted() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Err returns the abort reason as a Go error, or nil if the signal has not fired.
+func (s *AbortSignal) Err() error {
+	if !s.Aborted() {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return errors.New(s.reasonMsg)
+}
+
+// Context derives a context from parent that is cancelled when the signal fires.
+// A nil signal yields a plain cancellable context.
+func (s *AbortSignal) Context(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	if s == nil {
+		return ctx, cancel
+	}
+
+	go func() {
+		select {
+		case <-s.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, cancel
+}
+
+// abort marks the signal as aborted. Returns false if it was already aborted.
+func (s *AbortSignal) abort(reason goja.Value, reasonMsg, errName string) bool {
+	fired := false
+	s.once.Do(func() {
+		s.mu.Lock()
+		s.reason = reason
+		s.reasonMsg = reasonMsg
+		s.errName = errName
+		s.mu.Unlock()
+
+		close(s.done)
+		fired = true
+	})
+	return fired
+}
+
+// Abort provides the AbortController and AbortSignal globals.
+//
+// JavaScript usage:
+//
+//	const controller = new AbortController();
+//	http.get(url, { signal: controller.signal });
+//	controller.abort();
+//
+//	http.get(url, { signal: AbortSignal.timeout(5000) });
+type Abort struct {
+	vm   *goja.Runtime
+	loop *event.Loop // runs AbortSignal.timeout's abort
+}
+
+// NewAbort creates a new Abort module instance.
+func NewAbort() *Abort {
+	return &Abort{}
+}
+
+// SetLoop makes AbortSignal.timeout fire as a task on loop.
+func (a *Abort) SetLoop(loop *event.Loop) {
+	a.loop = loop
+}
+
+// Export returns an object holding the AbortController and AbortSignal constructors.
+func (a *Abort) Export(vm *goja.Runtime) goja.Value {
+	a.vm = vm
+	obj := vm.NewObject()
+
+	controllerConstructor := func(call goja.ConstructorCall) *goja.Object {
+		sig := newAbortSignal()
+		signalObj := a.createSignalObject(sig)
+
+		controller := vm.NewObject()
+		controller.Set("signal", signalObj)
+		controller.Set("abort", func(call goja.FunctionCall) goja.Value {
+			a.abortWithValue(sig, signalObj, call.Argument(0))
+			return goja.Undefined()
+		})
+
+		return controller
+	}
+
+	signalConstructor := vm.ToValue(func(call goja.ConstructorCall) *goja.Object {
+		panic(vm.NewTypeError("Illegal constructor"))
+	}).ToObject(vm)
+
+	signalConstructor.Set("timeout", a.timeout)
+	signalConstructor.Set("abort", func(call goja.FunctionCall) goja.Value {
+		sig := newAbortSignal()
+		signalObj := a.createSignalObject(sig)
+		a.abortWithValue(sig, signalObj, call.Argument(0))
+		return signalObj
+	})
+
+	obj.Set("AbortController", controllerConstructor)
+	obj.Set("AbortSignal", signalConstructor)
+
+	return obj
+}
+
+// timeout implements AbortSignal.timeout(ms) - a signal that aborts itself
+// with a TimeoutError once the delay elapses. Like Node, the pending timeout
+// does not keep the runtime alive on its own.
+func (a *Abort) timeout(call goja.FunctionCall) goja.Value {
+	if len(call.Arguments) < 1 {
+		panic(a.vm.NewTypeError("AbortSignal.timeout requires a delay in milliseconds"))
+	}
+
+	ms := call.Argument(0).ToInteger()
+	if ms < 0 {
+		panic(a.vm.NewTypeError("AbortSignal.timeout delay must be non-negative"))
+	}
+
+	sig := newAbortSignal()
+	signalObj := a.createSignalObject(sig)
+
+	fire := func() {
+		if sig.abort(nil, "The operation was aborted due to timeout", "TimeoutError") {
+			a.dispatchAbort(sig, signalObj)
+		}
+	}
+	delay := time.Duration(ms) * time.Millisecond
+	if a.loop == nil {
+		time.AfterFunc(delay, fire)
+	} else {
+		a.loop.AfterFunc(delay, event.Task{Name: "AbortSignal.timeout", Callback: fire})
+	}
+
+	return signalObj
+}
+
+// abortWithValue aborts from JS with an optional reason value.
+// Without a reason, an AbortError is used like in browsers and Node.
+func (a *Abort) abortWithValue(sig *AbortSignal, signalObj *goja.Object, reason goja.Value) {
+	var fired bool
+	if reason == nil || goja.IsUndefined(reason) {
+		fired = sig.abort(nil, "This operation was aborted", "AbortError")
+	} else {
+		fired = sig.abort(reason, reason.String(), "")
+	}
+
+	if fired {
+		a.dispatchAbort(sig, signalObj)
+	}
+}
+
+// reasonValue returns the JS reason, creating the error object on first access.
+func (a *Abort) reasonValue(sig *AbortSignal) goja.Value {
+	if !sig.Aborted() {
+		return goja.Undefined()
+	}
+
+	sig.mu.Lock()
+	defer sig.mu.Unlock()
+
+	if sig.reason == nil {
+		errObj := a.vm.NewGoError(errors.New(sig.reasonMsg))
+		errObj.Set("name", sig.errName)
+		sig.reason = errObj
+	}
+
+	return sig.reason
+}
+
+// dispatchAbort fires onabort and every registered 'abort' listener.
+func (a *Abort) dispatchAbort(sig *AbortSignal, signalObj *goja.Object) {
+	event := a.vm.NewObject()
+	event.Set("type", "abort")
+	event.Set("target", signalObj)
+
+	if onAbort, ok := goja.AssertFunction(signalObj.Get("onabort")); ok {
+		onAbort(signalObj, event)
+	}
+
+	sig.mu.Lock()
+	listeners := append([]goja.Callable(nil), sig.listeners...)
+	sig.listeners = nil
+	sig.mu.Unlock()
+
+	for _, listener := range listeners {
+		listener(signalObj, event)
+	}
+}
+
+// createSignalObject builds the JS AbortSignal object backed by sig.
+func (a *Abort) createSignalObject(sig *AbortSignal) *goja.Object {
+	obj := a.vm.NewObject()
+
+	obj.DefineDataProperty(abortSignalKey, a.vm.ToValue(sig), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_FALSE)
+
+	obj.DefineAccessorProperty("aborted",
+		a.vm.ToValue(func() bool { return sig.Aborted() }), nil, goja.FLAG_FALSE, goja.FLAG_TRUE)
+
+	obj.DefineAccessorProperty("reason",
+		a.vm.ToValue(func() goja.Value { return a.reasonValue(sig) }), nil, goja.FLAG_FALSE, goja.FLAG_TRUE)
+
+	obj.Set("onabort", goja.Null())
+
+	obj.Set("addEventListener", func(call goja.FunctionCall) goja.Value {
+		if call.Argument(0).String() != "abort" {
+			return goja.Undefined()
+		}
+		listener, ok := goja.AssertFunction(call.Argument(1))
+		if !ok {
+			panic(a.vm.NewTypeError("listener must be a function"))
+		}
+
+		sig.mu.Lock()
+		sig.listeners = append(sig.listeners, listener)
+		sig.mu.Unlock()
+
+		return goja.Undefined()
+	})
+
+	obj.Set("throwIfAborted", func(call goja.FunctionCall) goja.Value {
+		if sig.Aborted() {
+			panic(a.reasonValue(sig))
+		}
+		return goja.Undefined()
+	})
+
+	return obj
+}
+
+// signalFromValue extracts the Go AbortSignal behind a JS signal object.
+// Returns nil if the value is not an AbortSignal.
+func signalFromValue(vm *goja.Runtime, v goja.Value) *AbortSignal {
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		return nil
+	}
+
+	hidden := v.ToObject(vm).Get(abortSignalKey)
+	if hidden == nil {
+		return nil
+	}
+
+	sig, _ := hidden.Export().(*AbortSignal)
+	return sig
+}
@@ -0,0 +1,200 @@
+package modules
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// Args parses command-line flags for scripts, via require('args').
+//
+// Available in JavaScript as:
+//
+//	const args = require('args');
+//	// dougless server.js --port=8080 -v public
+//	const { flags, positionals } = args.parse({
+//	  port: { type: 'number', alias: 'p', default: 3000 },
+//	  verbose: { type: 'boolean', alias: 'v' },
+//	  name: { type: 'string' },
+//	});
+//	// flags: { port: 8080, verbose: true }, positionals: ['public']
+//
+// parse(spec) reads the script's own arguments (process.argv after the
+// script path); parse(argv, spec) parses the given array instead.
+//
+// Flags are written --name=value, --name value, or through an alias as
+// -p value or -p=value. Boolean flags take no value (--verbose) and are
+// turned off with --no-verbose. Everything after "--" is positional.
+// Unknown flags, missing values and numbers that don't parse throw a
+// TypeError. Flags not given are left out of flags unless they have a
+// default.
+type Args struct {
+	vm   *goja.Runtime
+	argv []string // process.argv
+}
+
+// NewArgs creates the args module for a script run with process.argv argv.
+func NewArgs(argv []string) *Args {
+	return &Args{argv: argv}
+}
+
+func (a *Args) Export(vm *goja.Runtime) goja.Value {
+	a.vm = vm
+	obj := vm.NewObject()
+	obj.Set("parse", a.parse)
+	return obj
+}
+
+// argSpec describes one flag of a parse spec.
+type argSpec struct {
+	name  string
+	kind  string // "boolean", "string" or "number"
+	value goja.Value
+}
+
+// parse implements args.parse([argv], spec).
+func (a *Args) parse(call goja.FunctionCall) goja.Value {
+	var argv []string
+	specArg := call.Argument(0)
+	if len(call.Arguments) > 1 {
+		if err := a.vm.ExportTo(call.Argument(0), &argv); err != nil {
+			panic(a.vm.NewTypeError("args.parse: argv must be an array of strings"))
+		}
+		specArg = call.Argument(1)
+	} else if len(a.argv) > 2 {
+		argv = a.argv[2:] // after the executable and the script
+	}
+
+	ordered, specs, aliases := a.readSpec(specArg)
+
+	flags := a.vm.NewObject()
+	positionals := []any{}
+
+	for _, spec := range ordered {
+		if spec.value != nil {
+			flags.Set(spec.name, spec.value)
+		}
+	}
+
+	for i := 0; i < len(argv); i++ {
+		arg := argv[i]
+		if arg == "--" {
+			for _, rest := range argv[i+1:] {
+				positionals = append(positionals, rest)
+			}
+			break
+		}
+
+		var name string
+		switch {
+		case strings.HasPrefix(arg, "--"):
+			name = arg[2:]
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			name = arg[1:]
+		default:
+			positionals = append(positionals, arg)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(name, "=")
+		long := strings.HasPrefix(arg, "--")
+
+		var spec *argSpec
+		if long {
+			spec = specs[name]
+			if spec == nil && !hasValue {
+				if negated, ok := strings.CutPrefix(name, "no-"); ok && specs[negated] != nil && specs[negated].kind == "boolean" {
+					flags.Set(negated, false)
+					continue
+				}
+			}
+		} else {
+			spec = aliases[name]
+		}
+		if spec == nil {
+			panic(a.vm.NewTypeError(fmt.Sprintf("args.parse: unknown flag %s", strings.SplitN(arg, "=", 2)[0])))
+		}
+
+		if spec.kind == "boolean" {
+			if !hasValue {
+				flags.Set(spec.name, true)
+				continue
+			}
+			switch value {
+			case "true":
+				flags.Set(spec.name, true)
+			case "false":
+				flags.Set(spec.name, false)
+			default:
+				panic(a.vm.NewTypeError(fmt.Sprintf("args.parse: flag %s is a boolean, got %q", spec.name, value)))
+			}
+			continue
+		}
+
+		if !hasValue {
+			if i+1 >= len(argv) {
+				panic(a.vm.NewTypeError(fmt.Sprintf("args.parse: flag %s requires a value", spec.name)))
+			}
+			i++
+			value = argv[i]
+		}
+
+		if spec.kind == "number" {
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil || math.IsNaN(n) {
+				panic(a.vm.NewTypeError(fmt.Sprintf("args.parse: flag %s expects a number, got %q", spec.name, value)))
+			}
+			flags.Set(spec.name, n)
+		} else {
+			flags.Set(spec.name, value)
+		}
+	}
+
+	result := a.vm.NewObject()
+	result.Set("flags", flags)
+	result.Set("positionals", a.vm.NewArray(positionals...))
+	return result
+}
+
+// readSpec reads a { name: { type, alias, default } } spec, returning the
+// flags in spec order, by name and by alias.
+func (a *Args) readSpec(v goja.Value) ([]*argSpec, map[string]*argSpec, map[string]*argSpec) {
+	if goja.IsUndefined(v) || goja.IsNull(v) {
+		panic(a.vm.NewTypeError("args.parse requires a spec"))
+	}
+	specObj := v.ToObject(a.vm)
+
+	var ordered []*argSpec
+	specs := map[string]*argSpec{}
+	aliases := map[string]*argSpec{}
+	for _, name := range specObj.Keys() {
+		entry := specObj.Get(name)
+		if goja.IsUndefined(entry) || goja.IsNull(entry) {
+			continue
+		}
+		entryObj := entry.ToObject(a.vm)
+
+		spec := &argSpec{name: name, kind: "boolean"}
+		if t := entryObj.Get("type"); t != nil && !goja.IsUndefined(t) {
+			spec.kind = t.String()
+		}
+		switch spec.kind {
+		case "boolean", "string", "number":
+		default:
+			panic(a.vm.NewTypeError(fmt.Sprintf("args.parse: flag %s has unknown type %q (use boolean, string or number)", name, spec.kind)))
+		}
+		if d := entryObj.Get("default"); d != nil && !goja.IsUndefined(d) {
+			spec.value = d
+		}
+		ordered = append(ordered, spec)
+		specs[name] = spec
+
+		if alias := entryObj.Get("alias"); alias != nil && !goja.IsUndefined(alias) {
+			aliases[alias.String()] = spec
+		}
+	}
+	return ordered, specs, aliases
+}
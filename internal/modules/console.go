@@ -1,7 +1,11 @@
 package modules
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -15,6 +19,7 @@ import (
 // Available globally in JavaScript as the 'console' object.
 type Console struct {
 	vm       *goja.Runtime        // JavaScript runtime instance
+	out      io.Writer            // Destination for all console output (stdout when nil)
 	timers   map[string]time.Time // Performance timers for console.time/timeEnd
 	timersMu sync.Mutex           // Protects timers map
 }
@@ -26,6 +31,20 @@ func NewConsole() *Console {
 	}
 }
 
+// SetOutput redirects console output, e.g. to a buffer when embedding.
+func (c *Console) SetOutput(w io.Writer) {
+	c.out = w
+}
+
+// output returns the current destination, resolving os.Stdout at write time
+// so a swapped os.Stdout is still honored.
+func (c *Console) output() io.Writer {
+	if c.out == nil {
+		return os.Stdout
+	}
+	return c.out
+}
+
 // Export creates and returns the console JavaScript object with all methods.
 // This is called when the console global is initialized.
 func (c *Console) Export(vm *goja.Runtime) goja.Value {
@@ -33,30 +52,97 @@ func (c *Console) Export(vm *goja.Runtime) goja.Value {
 	obj := vm.NewObject()
 
 	obj.Set("log", c.consoleLog)
+	obj.Set("info", c.consoleLog)
+	obj.Set("debug", c.consoleDebug)
 	obj.Set("error", c.consoleError)
 	obj.Set("warn", c.consoleWarn)
+	obj.Set("trace", c.consoleTrace)
 	obj.Set("time", c.consoleTime)
 	obj.Set("timeEnd", c.consoleTimeEnd)
 	obj.Set("table", c.consoleTable)
+	obj.Set("clear", c.consoleClear)
 
 	return obj
 }
 
-// consoleLog implements console.log() - outputs messages to stdout.
-// Accepts multiple arguments of any type.
+// consoleLog implements console.log() and console.info() - outputs messages to stdout.
+// Accepts multiple arguments of any type. Error objects are printed as
+// "Name: message" followed by their stack trace.
 //
 // JavaScript usage:
 //
 //	console.log('Hello', 'World', 123, {foo: 'bar'});
 func (c *Console) consoleLog(call goja.FunctionCall) goja.Value {
-	args := make([]any, len(call.Arguments))
-	for i, arg := range call.Arguments {
-		args[i] = arg.Export()
+	args := c.formatArgs(call.Arguments)
+	fmt.Fprintln(c.output(), args...)
+	return goja.Undefined()
+}
+
+// clearScreen is the ANSI sequence that clears the screen and homes the cursor.
+const clearScreen = "\x1b[2J\x1b[H"
+
+// consoleClear implements console.clear() - clears the terminal.
+// It only writes the escape sequence when the output is a terminal; when
+// output is piped, redirected to a file or captured (SetOutput with a
+// buffer), it does nothing so logs stay free of control codes.
+//
+// JavaScript usage:
+//
+//	console.clear();
+func (c *Console) consoleClear(call goja.FunctionCall) goja.Value {
+	if f, ok := c.output().(*os.File); ok && isTerminal(f) {
+		fmt.Fprint(f, clearScreen)
 	}
-	fmt.Println(args...)
 	return goja.Undefined()
 }
 
+// isTerminal reports whether f is a character device such as a TTY.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// formatArgs converts JavaScript arguments into values for printing.
+func (c *Console) formatArgs(values []goja.Value) []any {
+	args := make([]any, len(values))
+	for i, arg := range values {
+		if obj, ok := arg.(*goja.Object); ok && obj.ClassName() == "Error" {
+			args[i] = formatError(obj)
+			continue
+		}
+		args[i] = arg.Export()
+	}
+	return args
+}
+
+// formatError renders an Error like Node does: the stack when available
+// (which starts with "Name: message"), otherwise just "Name: message".
+func formatError(obj *goja.Object) string {
+	if stack := obj.Get("stack"); stack != nil && !goja.IsUndefined(stack) && !goja.IsNull(stack) {
+		if s := strings.TrimRight(stack.String(), "\n"); s != "" {
+			return s
+		}
+	}
+
+	name := "Error"
+	if v := obj.Get("name"); v != nil && !goja.IsUndefined(v) {
+		name = v.String()
+	}
+
+	message := ""
+	if v := obj.Get("message"); v != nil && !goja.IsUndefined(v) {
+		message = v.String()
+	}
+	if message == "" {
+		return name
+	}
+
+	return name + ": " + message
+}
+
 // consoleError implements console.error() - outputs error messages with ERROR prefix.
 // Accepts multiple arguments of any type.
 //
@@ -64,12 +150,9 @@ func (c *Console) consoleLog(call goja.FunctionCall) goja.Value {
 //
 //	console.error('Something went wrong:', error);
 func (c *Console) consoleError(call goja.FunctionCall) goja.Value {
-	args := make([]any, len(call.Arguments))
-	for i, arg := range call.Arguments {
-		args[i] = arg.Export()
-	}
-	fmt.Print("ERROR: ")
-	fmt.Println(args...)
+	args := c.formatArgs(call.Arguments)
+	fmt.Fprint(c.output(), "ERROR: ")
+	fmt.Fprintln(c.output(), args...)
 	return goja.Undefined()
 }
 
@@ -80,12 +163,48 @@ func (c *Console) consoleError(call goja.FunctionCall) goja.Value {
 //
 //	console.warn('Deprecated function used');
 func (c *Console) consoleWarn(call goja.FunctionCall) goja.Value {
-	args := make([]any, len(call.Arguments))
-	for i, arg := range call.Arguments {
-		args[i] = arg.Export()
+	args := c.formatArgs(call.Arguments)
+	fmt.Fprint(c.output(), "WARN: ")
+	fmt.Fprintln(c.output(), args...)
+	return goja.Undefined()
+}
+
+// consoleDebug implements console.debug() - outputs messages with DEBUG prefix,
+// so debug output can be filtered out of the rest of the log.
+//
+// JavaScript usage:
+//
+//	console.debug('cache miss for', key);
+func (c *Console) consoleDebug(call goja.FunctionCall) goja.Value {
+	args := c.formatArgs(call.Arguments)
+	fmt.Fprint(c.output(), "DEBUG: ")
+	fmt.Fprintln(c.output(), args...)
+	return goja.Undefined()
+}
+
+// consoleTrace implements console.trace() - outputs the message with TRACE
+// prefix, followed by the JavaScript call stack at the call.
+//
+// JavaScript usage:
+//
+//	console.trace('how did we get here?');
+//	// TRACE: how did we get here?
+//	//	at handleRequest (server.js:12:5(10))
+//	//	...
+func (c *Console) consoleTrace(call goja.FunctionCall) goja.Value {
+	var b bytes.Buffer
+	b.WriteString("TRACE: ")
+	b.WriteString(strings.TrimSuffix(fmt.Sprintln(c.formatArgs(call.Arguments)...), "\n"))
+	b.WriteByte('\n')
+	for _, frame := range c.vm.CaptureCallStack(0, nil) {
+		if frame.SrcName() == "<native>" {
+			continue // console.trace itself
+		}
+		b.WriteString("\tat ")
+		frame.Write(&b)
+		b.WriteByte('\n')
 	}
-	fmt.Print("WARN: ")
-	fmt.Println(args...)
+	c.output().Write(b.Bytes())
 	return goja.Undefined()
 }
 
@@ -130,12 +249,12 @@ func (c *Console) consoleTimeEnd(call goja.FunctionCall) goja.Value {
 	c.timersMu.Unlock()
 
 	if !exists {
-		fmt.Printf("Warning: No such label '%s' for console.timeEnd()\n", label)
+		fmt.Fprintf(c.output(), "Warning: No such label '%s' for console.timeEnd()\n", label)
 		return goja.Undefined()
 	}
 
 	duration := time.Since(startTime)
-	fmt.Printf("%s: %.3fms\n", label, float64(duration.Microseconds())/1000.0)
+	fmt.Fprintf(c.output(), "%s: %.3fms\n", label, float64(duration.Microseconds())/1000.0)
 
 	return goja.Undefined()
 }
@@ -162,7 +281,7 @@ func (c *Console) consoleTable(call goja.FunctionCall) goja.Value {
 		c.printObjectTable(v)
 	default:
 		// Fallback to regular log for unsupported types
-		fmt.Println(data)
+		fmt.Fprintln(c.output(), data)
 	}
 
 	return goja.Undefined()
@@ -188,9 +307,9 @@ func (c *Console) printArrayTable(data []any) {
 	}
 
 	// Print table header
-	fmt.Println("┌─────────┬" + repeatChar('─', maxWidth+2) + "┐")
-	fmt.Printf("│ (index) │ %-*s │\n", maxWidth, "Values")
-	fmt.Println("├─────────┼" + repeatChar('─', maxWidth+2) + "┤")
+	fmt.Fprintln(c.output(), "┌─────────┬"+repeatChar('─', maxWidth+2)+"┐")
+	fmt.Fprintf(c.output(), "│ (index) │ %-*s │\n", maxWidth, "Values")
+	fmt.Fprintln(c.output(), "├─────────┼"+repeatChar('─', maxWidth+2)+"┤")
 
 	// Print table rows
 	for i, item := range data {
@@ -198,11 +317,11 @@ func (c *Console) printArrayTable(data []any) {
 		if len(valueStr) > maxWidth {
 			valueStr = valueStr[:maxWidth-3] + "..."
 		}
-		fmt.Printf("│ %-7d │ %-*s │\n", i, maxWidth, valueStr)
+		fmt.Fprintf(c.output(), "│ %-7d │ %-*s │\n", i, maxWidth, valueStr)
 	}
 
 	// Print table footer
-	fmt.Println("└─────────┴" + repeatChar('─', maxWidth+2) + "┘")
+	fmt.Fprintln(c.output(), "└─────────┴"+repeatChar('─', maxWidth+2)+"┘")
 }
 
 // printObjectTable formats and prints an object as a table.
@@ -232,9 +351,9 @@ func (c *Console) printObjectTable(data map[string]any) {
 	}
 
 	// Print table header
-	fmt.Println("┌" + repeatChar('─', maxKeyWidth+2) + "┬" + repeatChar('─', maxValWidth+2) + "┐")
-	fmt.Printf("│ %-*s │ %-*s │\n", maxKeyWidth, "(index)", maxValWidth, "Values")
-	fmt.Println("├" + repeatChar('─', maxKeyWidth+2) + "┼" + repeatChar('─', maxValWidth+2) + "┤")
+	fmt.Fprintln(c.output(), "┌"+repeatChar('─', maxKeyWidth+2)+"┬"+repeatChar('─', maxValWidth+2)+"┐")
+	fmt.Fprintf(c.output(), "│ %-*s │ %-*s │\n", maxKeyWidth, "(index)", maxValWidth, "Values")
+	fmt.Fprintln(c.output(), "├"+repeatChar('─', maxKeyWidth+2)+"┼"+repeatChar('─', maxValWidth+2)+"┤")
 
 	// Print table rows
 	for key, value := range data {
@@ -246,11 +365,11 @@ func (c *Console) printObjectTable(data map[string]any) {
 		if len(valueStr) > maxValWidth {
 			valueStr = valueStr[:maxValWidth-3] + "..."
 		}
-		fmt.Printf("│ %-*s │ %-*s │\n", maxKeyWidth, keyStr, maxValWidth, valueStr)
+		fmt.Fprintf(c.output(), "│ %-*s │ %-*s │\n", maxKeyWidth, keyStr, maxValWidth, valueStr)
 	}
 
 	// Print table footer
-	fmt.Println("└" + repeatChar('─', maxKeyWidth+2) + "┴" + repeatChar('─', maxValWidth+2) + "┘")
+	fmt.Fprintln(c.output(), "└"+repeatChar('─', maxKeyWidth+2)+"┴"+repeatChar('─', maxValWidth+2)+"┘")
 }
 
 // Helper function to repeat a character n times
@@ -1,6 +1,9 @@
 package modules
 
 import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/hmac"
 	"crypto/md5"
 	"crypto/rand"
@@ -8,178 +11,739 @@ import (
 	"crypto/sha256"
 	"crypto/sha512"
 	"crypto/subtle"
+	"encoding"
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sort"
+	"time"
 
 	"github.com/dop251/goja"
 	"github.com/google/uuid"
+
+	"github.com/douglasjordan2/dougless/internal/event"
+	"github.com/douglasjordan2/dougless/internal/permissions"
+)
+
+// hashAlgorithms maps supported hash names to their constructors.
+// It backs createHash, createHmac, and getHashes.
+var hashAlgorithms = map[string]func() hash.Hash{
+  "md5":    md5.New,
+  "sha1":   sha1.New,
+  "sha256": sha256.New,
+  "sha512": sha512.New,
+}
+
+// cipherAlgorithms maps supported cipher names to their key sizes in bytes.
+// It backs createCipheriv, createDecipheriv, and getCiphers.
+var cipherAlgorithms = map[string]int{
+  "aes-128-gcm": 16,
+  "aes-192-gcm": 24,
+  "aes-256-gcm": 32,
+}
+
+const (
+  gcmNonceSize = 12 // recommended GCM iv length in bytes
+  gcmTagSize   = 16 // GCM authentication tag length in bytes
 )
 
 type Crypto struct {
-  vm *goja.Runtime
+  vm      *goja.Runtime
+  runtime RuntimeKeepAlive
+  loop    *event.Loop // delivers results of file digests to the VM
 }
 
 func NewCrypto() *Crypto {
   return &Crypto{}
 }
 
+// SetRuntime sets the runtime kept alive while file digests are pending.
+func (c *Crypto) SetRuntime(rt RuntimeKeepAlive) {
+  c.runtime = rt
+}
+
+// SetLoop sets the event loop used to deliver asynchronous results.
+func (c *Crypto) SetLoop(loop *event.Loop) {
+  c.loop = loop
+}
+
 func (c *Crypto) Export(vm *goja.Runtime) goja.Value {
   c.vm = vm
   return vm.ToValue(c.createCryptoAPI())
 }
 func (c *Crypto) createCryptoAPI() map[string]interface{} {
   return map[string]interface{}{
-    "createHash":      c.createHash,
-    "createHmac":      c.createHmac,
-    "timingSafeEqual": c.timingSafeEqual,
-    "random":          c.random,
-    "randomBytes":     c.random, // Alias for Node.js compatibility
-    "uuid":            c.uuid,
+    "createHash":       c.createHash,
+    "createHmac":       c.createHmac,
+    "hashChain":        c.hashChain,
+    "hmacFile":         c.hmacFile,
+    "timingSafeEqual":  c.timingSafeEqual,
+    "random":           c.random,
+    "randomString":     c.randomString,
+    "randomBytes":      c.random, // Alias for Node.js compatibility
+    "uuid":             c.uuid,
+    "createCipheriv":   c.createCipheriv,
+    "createDecipheriv": c.createDecipheriv,
+    "encrypt":          c.encrypt,
+    "decrypt":          c.decrypt,
+    "getHashes":        c.getHashes,
+    "getCiphers":       c.getCiphers,
+    "getCurves":        c.getCurves,
+    "generateECDH":     c.generateECDH,
+    "ecdh":             c.ecdh,
+    "constants":        c.constants(),
   }
 }
 
+// createHash returns an incremental hash object. update() calls accumulate,
+// digest() finalizes it, reset() starts over and copy() snapshots the state.
+//
+// JavaScript usage:
+//
+//	const h = crypto.createHash('sha256');
+//	h.update('a').update('b');          // same as update('ab')
+//	const partial = h.copy().digest('hex');
+//	const full = h.update('c').digest('hex');
+//	h.reset();                          // reuse for another input
 func (c *Crypto) createHash(call goja.FunctionCall) goja.Value {
   if len(call.Arguments) < 1 {
     panic(c.vm.NewTypeError("createHash requires an algorithm argument"))
   }
-    
+
   algorithm := call.Argument(0).String()
-    
-  obj := c.vm.NewObject()
-    
-  obj.Set("update", func(call goja.FunctionCall) goja.Value {
-    if len(call.Arguments) < 1 {
-      panic(c.vm.NewTypeError("update requires data argument"))
-    }
-    data := call.Argument(0).String()
-    call.This.ToObject(c.vm).Set("_data", data)
-    return call.This
-  })
-    
-  obj.Set("digest", func(call goja.FunctionCall) goja.Value {
-    encoding := "hex"
-    if len(call.Arguments) > 0 {
-      encoding = call.Argument(0).String()
-    }
-        
-    thisObj := call.This.ToObject(c.vm)
-    dataVal := thisObj.Get("_data")
-    if dataVal == nil {
-        return c.vm.ToValue("")
-    }
-        
-    data := dataVal.String()
-    var hashBytes []byte
-        
-    switch algorithm {
-    case "md5":
-      hash := md5.Sum([]byte(data))
-      hashBytes = hash[:]
-    case "sha1":
-      hash := sha1.Sum([]byte(data))
-      hashBytes = hash[:]
-    case "sha256":
-      hash := sha256.Sum256([]byte(data))
-      hashBytes = hash[:]
-    case "sha512":
-      hash := sha512.Sum512([]byte(data))
-      hashBytes = hash[:]
-    default:
+  newHash, ok := hashAlgorithms[algorithm]
+  if !ok {
+    panic(c.vm.NewTypeError(fmt.Sprintf("unsupported algorithm: %s", algorithm)))
+  }
+
+  return c.hashObject(newHash, newHash(), true)
+}
+
+// hashChain implements crypto.hashChain(algorithms, data, [encoding='hex']).
+// It hashes data with the first algorithm, then hashes that raw digest with
+// the next, and so on, returning the last digest. Every algorithm is checked
+// before any hashing is done.
+//
+// JavaScript usage:
+//
+//	crypto.hashChain(['sha256', 'sha256'], 'block header'); // sha256(sha256(x))
+func (c *Crypto) hashChain(call goja.FunctionCall) goja.Value {
+  if len(call.Arguments) < 2 {
+    panic(c.vm.NewTypeError("hashChain requires algorithms and data arguments"))
+  }
+
+  var algorithms []string
+  if err := c.vm.ExportTo(call.Argument(0), &algorithms); err != nil || len(algorithms) == 0 {
+    panic(c.vm.NewTypeError("hashChain requires a non-empty array of algorithms"))
+  }
+  chain := make([]func() hash.Hash, len(algorithms))
+  for i, algorithm := range algorithms {
+    newHash, ok := hashAlgorithms[algorithm]
+    if !ok {
       panic(c.vm.NewTypeError(fmt.Sprintf("unsupported algorithm: %s", algorithm)))
     }
-        
-    switch encoding {
-    case "hex":
-      return c.vm.ToValue(hex.EncodeToString(hashBytes))
-    case "base64":
-      return c.vm.ToValue(base64.StdEncoding.EncodeToString(hashBytes))
-    default:
-      panic(c.vm.NewTypeError(fmt.Sprintf("unsupported encoding: %s", encoding)))
-    }
-  })
-    
-  return obj
+    chain[i] = newHash
+  }
+
+  encoding := "hex"
+  if v := call.Argument(2); !goja.IsUndefined(v) {
+    encoding = v.String()
+  }
+
+  sum := c.bytesArg(call.Argument(1), "utf8")
+  for _, newHash := range chain {
+    h := newHash()
+    h.Write(sum)
+    sum = h.Sum(nil)
+  }
+  return c.encodeBytes(sum, encoding)
 }
 
+// createHmac returns an incremental HMAC object (update/digest/reset).
 func (c *Crypto) createHmac(call goja.FunctionCall) goja.Value {
   if len(call.Arguments) < 2 {
     panic(c.vm.NewTypeError("createHmac requires algorithm and key arguments"))
   }
-    
+
   algorithm := call.Argument(0).String()
   key := call.Argument(1).String()
-    
+
+  newHash, ok := hashAlgorithms[algorithm]
+  if !ok {
+    panic(c.vm.NewTypeError(fmt.Sprintf("unsupported algorithm: %s", algorithm)))
+  }
+  newMac := func() hash.Hash { return hmac.New(newHash, []byte(key)) }
+
+  return c.hashObject(newMac, newMac(), false)
+}
+
+// hmacFile streams a file through an HMAC without reading it into memory.
+// Reading the file requires read permission.
+//
+// JavaScript usage:
+//
+//	crypto.hmacFile('sha256', key, 'release.tar.gz', 'hex', (err, digest) => {
+//	  if (err) return console.error(err);
+//	  console.log(digest);
+//	});
+//
+//	const digest = await crypto.hmacFile('sha256', key, 'release.tar.gz'); // hex
+func (c *Crypto) hmacFile(call goja.FunctionCall) goja.Value {
+  if len(call.Arguments) < 3 {
+    panic(c.vm.NewTypeError("hmacFile requires algorithm, key and path arguments"))
+  }
+
+  algorithm := call.Argument(0).String()
+  newHash, ok := hashAlgorithms[algorithm]
+  if !ok {
+    panic(c.vm.NewTypeError(fmt.Sprintf("unsupported algorithm: %s", algorithm)))
+  }
+  key := c.bytesArg(call.Argument(1), "utf8")
+  path := call.Argument(2).String()
+
+  // hmacFile(algorithm, key, path, [encoding='hex'], [callback])
+  encoding := "hex"
+  callback, hasCallback := goja.AssertFunction(call.Argument(3))
+  if !hasCallback {
+    if v := call.Argument(3); !goja.IsUndefined(v) {
+      encoding = v.String()
+    }
+    callback, hasCallback = goja.AssertFunction(call.Argument(4))
+  }
+  switch encoding {
+  case "hex", "base64":
+  default:
+    panic(c.vm.NewTypeError(fmt.Sprintf("unsupported encoding: %s", encoding)))
+  }
+
+  var promise *Promise
+  if !hasCallback {
+    promise = &Promise{
+      vm:          c.vm,
+      runtime:     c.runtime,
+      state:       PromisePending,
+      onFulfilled: []goja.Callable{},
+      onRejected:  []goja.Callable{},
+    }
+  }
+
+  done := c.runtime.KeepAlive()
+  go func() {
+    sum, errMsg := digestFile(path, hmac.New(newHash, key))
+
+    c.loop.Schedule(event.Task{Name: "crypto.hmacFile", Callback: func() {
+      defer done()
+
+      var errArg, digest goja.Value = goja.Null(), goja.Undefined()
+      if errMsg != "" {
+        errArg = c.vm.ToValue(errMsg)
+      } else {
+        digest = c.encodeBytes(sum, encoding)
+      }
+
+      switch {
+      case hasCallback:
+        callback(goja.Undefined(), errArg, digest)
+      case errMsg != "":
+        promise.reject(errArg)
+      default:
+        promise.resolve(digest)
+      }
+    }})
+  }()
+
+  if promise != nil {
+    return CreatePromiseObject(c.vm, promise)
+  }
+  return goja.Undefined()
+}
+
+// digestFile checks read permission for path and copies the file through h,
+// returning the sum or an error message.
+func digestFile(path string, h hash.Hash) ([]byte, string) {
+  ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+  defer cancel()
+
+  mgr := permissions.GetManager()
+  canRead := permissions.PermissionRead
+  if !mgr.CheckWithPrompt(ctx, canRead, path) {
+    return nil, mgr.ErrorMessage(canRead, path)
+  }
+
+  f, err := os.Open(path)
+  if err != nil {
+    return nil, err.Error()
+  }
+  defer f.Close()
+
+  if _, err := io.Copy(h, f); err != nil {
+    return nil, err.Error()
+  }
+  return h.Sum(nil), ""
+}
+
+// hashObject wraps h in a JS object with update(data, [encoding]),
+// digest([encoding]) and reset(); copy() is added when the hash state can be
+// cloned (plain hashes, not HMACs).
+func (c *Crypto) hashObject(newHash func() hash.Hash, h hash.Hash, copyable bool) *goja.Object {
   obj := c.vm.NewObject()
-    
+  finalized := false
+
   obj.Set("update", func(call goja.FunctionCall) goja.Value {
     if len(call.Arguments) < 1 {
       panic(c.vm.NewTypeError("update requires data argument"))
     }
-    data := call.Argument(0).String()
-    call.This.ToObject(c.vm).Set("_data", data)
-    return call.This
+    if finalized {
+      panic(c.vm.NewGoError(fmt.Errorf("digest already called; use reset() to reuse the hash")))
+    }
+
+    encoding := "utf8"
+    if len(call.Arguments) > 1 && !goja.IsUndefined(call.Arguments[1]) {
+      encoding = call.Argument(1).String()
+    }
+    h.Write(c.bytesArg(call.Argument(0), encoding))
+    return obj
   })
-    
+
   obj.Set("digest", func(call goja.FunctionCall) goja.Value {
+    if finalized {
+      panic(c.vm.NewGoError(fmt.Errorf("digest already called; use reset() to reuse the hash")))
+    }
+    finalized = true
+
     encoding := "hex"
     if len(call.Arguments) > 0 {
       encoding = call.Argument(0).String()
     }
-        
-    thisObj := call.This.ToObject(c.vm)
-    dataVal := thisObj.Get("_data")
-    if dataVal == nil {
-      return c.vm.ToValue("")
-    }
-        
-    data := dataVal.String()
-    var h func() []byte
-        
-    switch algorithm {
-    case "md5":
-      h = func() []byte {
-        mac := hmac.New(md5.New, []byte(key))
-        mac.Write([]byte(data))
-        return mac.Sum(nil)
+    return c.encodeBytes(h.Sum(nil), encoding)
+  })
+
+  obj.Set("reset", func(call goja.FunctionCall) goja.Value {
+    h.Reset()
+    finalized = false
+    return obj
+  })
+
+  if copyable {
+    obj.Set("copy", func(call goja.FunctionCall) goja.Value {
+      if finalized {
+        panic(c.vm.NewGoError(fmt.Errorf("digest already called; cannot copy a finalized hash")))
       }
-    case "sha1":
-      h = func() []byte {
-        mac := hmac.New(sha1.New, []byte(key))
-        mac.Write([]byte(data))
-        return mac.Sum(nil)
+
+      clone := newHash()
+      state, err := h.(encoding.BinaryMarshaler).MarshalBinary()
+      if err == nil {
+        err = clone.(encoding.BinaryUnmarshaler).UnmarshalBinary(state)
       }
-    case "sha256":
-      h = func() []byte {
-        mac := hmac.New(sha256.New, []byte(key))
-        mac.Write([]byte(data))
-        return mac.Sum(nil)
+      if err != nil {
+        panic(c.vm.NewGoError(fmt.Errorf("copy: %w", err)))
       }
-    case "sha512":
-      h = func() []byte {
-        mac := hmac.New(sha512.New, []byte(key))
-        mac.Write([]byte(data))
-        return mac.Sum(nil)
+      return c.hashObject(newHash, clone, true)
+    })
+  }
+
+  return obj
+}
+
+// encodeBytes converts raw bytes to a JS string in the requested encoding.
+func (c *Crypto) encodeBytes(data []byte, encoding string) goja.Value {
+  switch encoding {
+  case "hex":
+    return c.vm.ToValue(hex.EncodeToString(data))
+  case "base64":
+    return c.vm.ToValue(base64.StdEncoding.EncodeToString(data))
+  case "utf8", "utf-8":
+    return c.vm.ToValue(string(data))
+  default:
+    panic(c.vm.NewTypeError(fmt.Sprintf("unsupported encoding: %s", encoding)))
+  }
+}
+
+// decodeString converts a JS string in the given encoding to raw bytes.
+func (c *Crypto) decodeString(data, encoding string) []byte {
+  switch encoding {
+  case "hex":
+    decoded, err := hex.DecodeString(data)
+    if err != nil {
+      panic(c.vm.NewTypeError(fmt.Sprintf("invalid hex data: %v", err)))
+    }
+    return decoded
+  case "base64":
+    decoded, err := base64.StdEncoding.DecodeString(data)
+    if err != nil {
+      panic(c.vm.NewTypeError(fmt.Sprintf("invalid base64 data: %v", err)))
+    }
+    return decoded
+  case "utf8", "utf-8":
+    return []byte(data)
+  default:
+    panic(c.vm.NewTypeError(fmt.Sprintf("unsupported encoding: %s", encoding)))
+  }
+}
+
+// bytesArg converts a JS argument to bytes. Strings are decoded with the
+// given encoding; ArrayBuffer, typed arrays, DataView, and arrays of byte
+// values (like crypto.random(n, 'raw')) are used as-is.
+func (c *Crypto) bytesArg(value goja.Value, encoding string) []byte {
+  switch v := value.Export().(type) {
+  case string:
+    return c.decodeString(v, encoding)
+  case []byte:
+    return append([]byte(nil), v...)
+  case goja.ArrayBuffer:
+    return append([]byte(nil), v.Bytes()...)
+  case []any:
+    out := make([]byte, len(v))
+    for i, b := range v {
+      n, ok := b.(int64)
+      if !ok || n < 0 || n > 255 {
+        panic(c.vm.NewTypeError("byte arrays must contain integers between 0 and 255"))
       }
-    default:
-      panic(c.vm.NewTypeError(fmt.Sprintf("unsupported algorithm: %s", algorithm)))
+      out[i] = byte(n)
     }
-        
-    hashBytes := h()
-        
-    switch encoding {
-    case "hex":
-      return c.vm.ToValue(hex.EncodeToString(hashBytes))
-    case "base64":
-      return c.vm.ToValue(base64.StdEncoding.EncodeToString(hashBytes))
-    default:
-      panic(c.vm.NewTypeError(fmt.Sprintf("unsupported encoding: %s", encoding)))
+    return out
+  }
+  if isBinary(value) {
+    return append([]byte(nil), bytesOf(c.vm, value)...)
+  }
+  return c.decodeString(value.String(), encoding)
+}
+
+// createCipheriv implements crypto.createCipheriv(algorithm, key, iv).
+// Only AEAD (GCM) ciphers are supported, so update() buffers its input and
+// the ciphertext is produced by final(); concatenating both results works
+// the same way it does in Node.
+//
+// JavaScript usage:
+//
+//	const cipher = crypto.createCipheriv('aes-256-gcm', key, iv);
+//	const encrypted = cipher.update('secret', 'utf8', 'hex') + cipher.final('hex');
+//	const tag = cipher.getAuthTag('hex');
+//
+// cipher.setAAD(data) authenticates additional data that is not encrypted;
+// the decipher must set the same AAD before final().
+func (c *Crypto) createCipheriv(call goja.FunctionCall) goja.Value {
+  aead, iv := c.newAEAD(call, "createCipheriv")
+
+  var plaintext []byte
+  var authTag []byte
+  var aad []byte
+  finalized := false
+
+  obj := c.vm.NewObject()
+
+  obj.Set("setAAD", func(call goja.FunctionCall) goja.Value {
+    if finalized {
+      panic(c.vm.NewTypeError("setAAD must be called before final"))
     }
+    aad = c.aadArg(call)
+    return call.This
   })
-    
+
+  obj.Set("update", func(call goja.FunctionCall) goja.Value {
+    if finalized {
+      panic(c.vm.NewTypeError("cipher already finalized"))
+    }
+    inputEncoding := "utf8"
+    if len(call.Arguments) > 1 && !goja.IsUndefined(call.Argument(1)) {
+      inputEncoding = call.Argument(1).String()
+    }
+    plaintext = append(plaintext, c.bytesArg(call.Argument(0), inputEncoding)...)
+
+    outputEncoding := "hex"
+    if len(call.Arguments) > 2 {
+      outputEncoding = call.Argument(2).String()
+    }
+    return c.encodeBytes(nil, outputEncoding)
+  })
+
+  obj.Set("final", func(call goja.FunctionCall) goja.Value {
+    if finalized {
+      panic(c.vm.NewTypeError("cipher already finalized"))
+    }
+    finalized = true
+
+    outputEncoding := "hex"
+    if len(call.Arguments) > 0 {
+      outputEncoding = call.Argument(0).String()
+    }
+
+    sealed := aead.Seal(nil, iv, plaintext, aad)
+    tagStart := len(sealed) - aead.Overhead()
+    authTag = sealed[tagStart:]
+
+    return c.encodeBytes(sealed[:tagStart], outputEncoding)
+  })
+
+  obj.Set("getAuthTag", func(call goja.FunctionCall) goja.Value {
+    if !finalized {
+      panic(c.vm.NewTypeError("getAuthTag must be called after final"))
+    }
+    encoding := "hex"
+    if len(call.Arguments) > 0 {
+      encoding = call.Argument(0).String()
+    }
+    return c.encodeBytes(authTag, encoding)
+  })
+
+  return obj
+}
+
+// aadArg reads the data argument of setAAD(data[, encoding]), utf8 by default.
+func (c *Crypto) aadArg(call goja.FunctionCall) []byte {
+  if len(call.Arguments) < 1 {
+    panic(c.vm.NewTypeError("setAAD requires data"))
+  }
+  encoding := "utf8"
+  if len(call.Arguments) > 1 && !goja.IsUndefined(call.Argument(1)) {
+    encoding = call.Argument(1).String()
+  }
+  return c.bytesArg(call.Argument(0), encoding)
+}
+
+// createDecipheriv implements crypto.createDecipheriv(algorithm, key, iv).
+// The auth tag must be supplied with setAuthTag() before final(), which
+// throws if the data fails authentication.
+//
+// JavaScript usage:
+//
+//	const decipher = crypto.createDecipheriv('aes-256-gcm', key, iv);
+//	decipher.setAuthTag(tag, 'hex');
+//	decipher.setAAD(header); // only if the cipher used setAAD
+//	const plain = decipher.update(encrypted, 'hex', 'utf8') + decipher.final('utf8');
+func (c *Crypto) createDecipheriv(call goja.FunctionCall) goja.Value {
+  aead, iv := c.newAEAD(call, "createDecipheriv")
+
+  var ciphertext []byte
+  var authTag []byte
+  var aad []byte
+  finalized := false
+
+  obj := c.vm.NewObject()
+
+  obj.Set("setAAD", func(call goja.FunctionCall) goja.Value {
+    if finalized {
+      panic(c.vm.NewTypeError("setAAD must be called before final"))
+    }
+    aad = c.aadArg(call)
+    return call.This
+  })
+
+  obj.Set("setAuthTag", func(call goja.FunctionCall) goja.Value {
+    if len(call.Arguments) < 1 {
+      panic(c.vm.NewTypeError("setAuthTag requires a tag"))
+    }
+    encoding := "hex"
+    if len(call.Arguments) > 1 {
+      encoding = call.Argument(1).String()
+    }
+    authTag = c.bytesArg(call.Argument(0), encoding)
+    if len(authTag) != aead.Overhead() {
+      panic(c.vm.NewTypeError(fmt.Sprintf("invalid auth tag length: %d", len(authTag))))
+    }
+    return call.This
+  })
+
+  obj.Set("update", func(call goja.FunctionCall) goja.Value {
+    if finalized {
+      panic(c.vm.NewTypeError("decipher already finalized"))
+    }
+    inputEncoding := "hex"
+    if len(call.Arguments) > 1 && !goja.IsUndefined(call.Argument(1)) {
+      inputEncoding = call.Argument(1).String()
+    }
+    ciphertext = append(ciphertext, c.bytesArg(call.Argument(0), inputEncoding)...)
+
+    outputEncoding := "utf8"
+    if len(call.Arguments) > 2 {
+      outputEncoding = call.Argument(2).String()
+    }
+    return c.encodeBytes(nil, outputEncoding)
+  })
+
+  obj.Set("final", func(call goja.FunctionCall) goja.Value {
+    if finalized {
+      panic(c.vm.NewTypeError("decipher already finalized"))
+    }
+    finalized = true
+
+    if authTag == nil {
+      panic(c.vm.NewGoError(fmt.Errorf("setAuthTag must be called before final")))
+    }
+
+    outputEncoding := "utf8"
+    if len(call.Arguments) > 0 {
+      outputEncoding = call.Argument(0).String()
+    }
+
+    plaintext, err := aead.Open(nil, iv, append(ciphertext, authTag...), aad)
+    if err != nil {
+      panic(c.vm.NewGoError(fmt.Errorf("unsupported state or unable to authenticate data")))
+    }
+
+    return c.encodeBytes(plaintext, outputEncoding)
+  })
+
   return obj
 }
 
+// newAEAD validates (algorithm, key, iv) arguments and builds the AEAD cipher.
+func (c *Crypto) newAEAD(call goja.FunctionCall, fnName string) (cipher.AEAD, []byte) {
+  if len(call.Arguments) < 3 {
+    panic(c.vm.NewTypeError(fmt.Sprintf("%s requires algorithm, key, and iv arguments", fnName)))
+  }
+
+  block := c.blockCipher(call.Argument(0), call.Argument(1))
+
+  iv := c.bytesArg(call.Argument(2), "utf8")
+  if len(iv) == 0 {
+    panic(c.vm.NewTypeError("iv must not be empty"))
+  }
+
+  aead, err := cipher.NewGCMWithNonceSize(block, len(iv))
+  if err != nil {
+    panic(c.vm.NewGoError(err))
+  }
+
+  return aead, iv
+}
+
+// blockCipher validates (algorithm, key) arguments and builds the AES block
+// cipher for them.
+func (c *Crypto) blockCipher(algorithmArg, keyArg goja.Value) cipher.Block {
+  algorithm := algorithmArg.String()
+  keySize, ok := cipherAlgorithms[algorithm]
+  if !ok {
+    panic(c.vm.NewTypeError(fmt.Sprintf("unsupported cipher: %s", algorithm)))
+  }
+
+  key := c.bytesArg(keyArg, "utf8")
+  if len(key) != keySize {
+    panic(c.vm.NewTypeError(fmt.Sprintf("invalid key length for %s: expected %d bytes, got %d", algorithm, keySize, len(key))))
+  }
+
+  block, err := aes.NewCipher(key)
+  if err != nil {
+    panic(c.vm.NewGoError(err))
+  }
+  return block
+}
+
+// encrypt implements crypto.encrypt(algorithm, key, plaintext, [encoding='hex']),
+// a one-shot alternative to createCipheriv for storing secrets. A random
+// nonce is generated for every call and returned in front of the ciphertext
+// and auth tag, so the result is all decrypt needs besides the key.
+//
+// JavaScript usage:
+//
+//	const key = crypto.random(32, 'raw');
+//	const sealed = crypto.encrypt('aes-256-gcm', key, 'secret', 'base64');
+//	crypto.decrypt('aes-256-gcm', key, sealed, 'base64'); // 'secret'
+func (c *Crypto) encrypt(call goja.FunctionCall) goja.Value {
+  if len(call.Arguments) < 3 {
+    panic(c.vm.NewTypeError("encrypt requires algorithm, key, and plaintext arguments"))
+  }
+  aead := c.sealer(call.Argument(0), call.Argument(1))
+
+  encoding := "hex"
+  if v := call.Argument(3); !goja.IsUndefined(v) {
+    encoding = v.String()
+  }
+  if encoding != "hex" && encoding != "base64" {
+    panic(c.vm.NewTypeError(fmt.Sprintf("unsupported encoding: %s", encoding)))
+  }
+
+  nonce := make([]byte, aead.NonceSize())
+  if _, err := rand.Read(nonce); err != nil {
+    panic(c.vm.NewGoError(err))
+  }
+  plaintext := c.bytesArg(call.Argument(2), "utf8")
+
+  return c.encodeBytes(aead.Seal(nonce, nonce, plaintext, nil), encoding)
+}
+
+// decrypt implements crypto.decrypt(algorithm, key, ciphertext, [encoding='hex']),
+// the inverse of encrypt. It returns the plaintext as a utf8 string and
+// throws if the data was tampered with or the key is wrong.
+func (c *Crypto) decrypt(call goja.FunctionCall) goja.Value {
+  if len(call.Arguments) < 3 {
+    panic(c.vm.NewTypeError("decrypt requires algorithm, key, and ciphertext arguments"))
+  }
+  aead := c.sealer(call.Argument(0), call.Argument(1))
+
+  encoding := "hex"
+  if v := call.Argument(3); !goja.IsUndefined(v) {
+    encoding = v.String()
+  }
+  if encoding != "hex" && encoding != "base64" {
+    panic(c.vm.NewTypeError(fmt.Sprintf("unsupported encoding: %s", encoding)))
+  }
+
+  sealed := c.bytesArg(call.Argument(2), encoding)
+  if len(sealed) < aead.NonceSize()+aead.Overhead() {
+    panic(c.vm.NewGoError(fmt.Errorf("ciphertext is too short")))
+  }
+  nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+
+  plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+  if err != nil {
+    panic(c.vm.NewGoError(fmt.Errorf("unsupported state or unable to authenticate data")))
+  }
+  return c.vm.ToValue(string(plaintext))
+}
+
+// sealer builds the GCM cipher used by encrypt and decrypt, with the
+// standard nonce size.
+func (c *Crypto) sealer(algorithmArg, keyArg goja.Value) cipher.AEAD {
+  aead, err := cipher.NewGCM(c.blockCipher(algorithmArg, keyArg))
+  if err != nil {
+    panic(c.vm.NewGoError(err))
+  }
+  return aead
+}
+
+// getHashes implements crypto.getHashes() - lists supported hash algorithms.
+func (c *Crypto) getHashes(call goja.FunctionCall) goja.Value {
+  return c.vm.ToValue(sortedKeys(hashAlgorithms))
+}
+
+// getCiphers implements crypto.getCiphers() - lists supported cipher algorithms.
+func (c *Crypto) getCiphers(call goja.FunctionCall) goja.Value {
+  return c.vm.ToValue(sortedKeys(cipherAlgorithms))
+}
+
+// constants describes the key, digest, iv, and tag sizes of supported algorithms.
+func (c *Crypto) constants() map[string]any {
+  keyLengths := make(map[string]any, len(cipherAlgorithms))
+  for name, size := range cipherAlgorithms {
+    keyLengths[name] = size
+  }
+
+  digestLengths := make(map[string]any, len(hashAlgorithms))
+  for name, newHash := range hashAlgorithms {
+    digestLengths[name] = newHash().Size()
+  }
+
+  return map[string]any{
+    "keyLengths":    keyLengths,
+    "digestLengths": digestLengths,
+    "ivLength":      gcmNonceSize,
+    "authTagLength": gcmTagSize,
+  }
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+  keys := make([]string, 0, len(m))
+  for key := range m {
+    keys = append(keys, key)
+  }
+  sort.Strings(keys)
+  return keys
+}
+
 func (c *Crypto) timingSafeEqual(call goja.FunctionCall) goja.Value {
   if len(call.Arguments) < 2 {
     panic(c.vm.NewTypeError("timingSafeEqual requires two arguments"))
@@ -245,3 +809,64 @@ func (c *Crypto) random(call goja.FunctionCall) goja.Value {
     panic(c.vm.NewTypeError(fmt.Sprintf("unsupported encoding: %s (use 'hex', 'base64', or 'raw')", encoding)))
   }
 }
+
+// defaultRandomAlphabet is used by randomString when no alphabet is given.
+const defaultRandomAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+// randomString implements crypto.randomString(length, { alphabet }).
+// Characters are drawn with rejection sampling so every alphabet entry is
+// equally likely (a plain modulo would favour the first characters).
+//
+// JavaScript usage:
+//
+//	crypto.randomString(16);                            // alphanumeric
+//	crypto.randomString(6, { alphabet: '0123456789' }); // numeric PIN
+func (c *Crypto) randomString(call goja.FunctionCall) goja.Value {
+  if len(call.Arguments) < 1 {
+    panic(c.vm.NewTypeError("randomString requires a length argument"))
+  }
+
+  length := int(call.Argument(0).ToInteger())
+  if length <= 0 {
+    panic(c.vm.NewTypeError("length must be greater than 0"))
+  }
+  if length > 65536 {
+    panic(c.vm.NewTypeError("length must be at most 65536"))
+  }
+
+  alphabet := []rune(defaultRandomAlphabet)
+  if len(call.Arguments) > 1 && !goja.IsUndefined(call.Argument(1)) && !goja.IsNull(call.Argument(1)) {
+    if a := call.Argument(1).ToObject(c.vm).Get("alphabet"); a != nil && !goja.IsUndefined(a) {
+      alphabet = []rune(a.String())
+    }
+  }
+  if len(alphabet) == 0 {
+    panic(c.vm.NewTypeError("alphabet must not be empty"))
+  }
+  if len(alphabet) > 256 {
+    panic(c.vm.NewTypeError("alphabet must have at most 256 characters"))
+  }
+
+  // bytes at or above limit would make some indexes more likely than others
+  n := len(alphabet)
+  limit := 256 - (256 % n)
+
+  out := make([]rune, 0, length)
+  buf := make([]byte, length)
+  for len(out) < length {
+    if _, err := rand.Read(buf); err != nil {
+      panic(c.vm.NewGoError(fmt.Errorf("failed to generate random bytes: %w", err)))
+    }
+    for _, b := range buf {
+      if int(b) >= limit {
+        continue
+      }
+      out = append(out, alphabet[int(b)%n])
+      if len(out) == length {
+        break
+      }
+    }
+  }
+
+  return c.vm.ToValue(string(out))
+}
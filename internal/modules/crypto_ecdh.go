@@ -0,0 +1,130 @@
+package modules
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"fmt"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// ecdhCurves maps supported key agreement curve names to their curves.
+// It backs generateECDH, ecdh, and getCurves.
+var ecdhCurves = map[string]ecdh.Curve{
+	"P-256":  ecdh.P256(),
+	"X25519": ecdh.X25519(),
+}
+
+// generateECDH implements crypto.generateECDH(curve, [encoding]), returning
+// { curve, publicKey, privateKey } with both keys encoded as hex (the
+// default) or base64. P-256 public keys are in uncompressed form.
+//
+// JavaScript usage:
+//
+//	const alice = crypto.generateECDH('X25519');
+//	const bob = crypto.generateECDH('X25519');
+//	const secret = crypto.ecdh(alice.privateKey, bob.publicKey);
+//	// === crypto.ecdh(bob.privateKey, alice.publicKey)
+func (c *Crypto) generateECDH(call goja.FunctionCall) goja.Value {
+	if len(call.Arguments) < 1 {
+		panic(c.vm.NewTypeError("generateECDH requires a curve name"))
+	}
+	curve := c.ecdhCurve(call.Argument(0).String())
+	encoding := c.keyEncoding(call.Argument(1))
+
+	key, err := curve.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(c.vm.NewGoError(fmt.Errorf("failed to generate key: %w", err)))
+	}
+
+	obj := c.vm.NewObject()
+	obj.Set("curve", call.Argument(0).String())
+	obj.Set("publicKey", c.encodeBytes(key.PublicKey().Bytes(), encoding))
+	obj.Set("privateKey", c.encodeBytes(key.Bytes(), encoding))
+	return obj
+}
+
+// ecdh implements crypto.ecdh(privateKey, peerPublicKey, [options]),
+// returning the shared secret. Options: curve, inferred from the public
+// key's length when omitted, and encoding (hex or base64) for both the keys
+// and the result. Keys may also be given as Uint8Array or ArrayBuffer.
+//
+// The raw secret should go through a KDF (HKDF, or at least a hash) before
+// being used as a key.
+func (c *Crypto) ecdh(call goja.FunctionCall) goja.Value {
+	if len(call.Arguments) < 2 {
+		panic(c.vm.NewTypeError("ecdh requires a private key and a peer public key"))
+	}
+
+	encoding := "hex"
+	curveName := ""
+	if opts := call.Argument(2); !goja.IsUndefined(opts) && !goja.IsNull(opts) {
+		o := opts.ToObject(c.vm)
+		if v := o.Get("curve"); v != nil && !goja.IsUndefined(v) {
+			curveName = v.String()
+		}
+		encoding = c.keyEncoding(o.Get("encoding"))
+	}
+
+	privBytes := c.bytesArg(call.Argument(0), encoding)
+	pubBytes := c.bytesArg(call.Argument(1), encoding)
+
+	var curve ecdh.Curve
+	if curveName != "" {
+		curve = c.ecdhCurve(curveName)
+	} else {
+		switch len(pubBytes) {
+		case 32:
+			curve = ecdh.X25519()
+		case 65:
+			curve = ecdh.P256()
+		default:
+			panic(c.vm.NewTypeError(fmt.Sprintf("cannot infer the curve of a %d-byte public key; pass { curve }", len(pubBytes))))
+		}
+	}
+
+	priv, err := curve.NewPrivateKey(privBytes)
+	if err != nil {
+		panic(c.vm.NewTypeError(fmt.Sprintf("invalid private key: %v", err)))
+	}
+	pub, err := curve.NewPublicKey(pubBytes)
+	if err != nil {
+		panic(c.vm.NewTypeError(fmt.Sprintf("invalid public key: %v", err)))
+	}
+
+	secret, err := priv.ECDH(pub)
+	if err != nil {
+		panic(c.vm.NewGoError(fmt.Errorf("key agreement failed: %w", err)))
+	}
+	return c.encodeBytes(secret, encoding)
+}
+
+// getCurves implements crypto.getCurves() - lists supported ECDH curves.
+func (c *Crypto) getCurves(call goja.FunctionCall) goja.Value {
+	return c.vm.ToValue(sortedKeys(ecdhCurves))
+}
+
+// ecdhCurve looks up a curve by name, throwing a TypeError listing the
+// supported curves for an unknown one.
+func (c *Crypto) ecdhCurve(name string) ecdh.Curve {
+	curve, ok := ecdhCurves[name]
+	if !ok {
+		panic(c.vm.NewTypeError(fmt.Sprintf("unsupported curve: %s (use %s)", name, strings.Join(sortedKeys(ecdhCurves), " or "))))
+	}
+	return curve
+}
+
+// keyEncoding reads an optional key encoding, hex by default. Keys are
+// binary, so utf8 isn't accepted.
+func (c *Crypto) keyEncoding(v goja.Value) string {
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		return "hex"
+	}
+	switch enc := v.String(); enc {
+	case "hex", "base64":
+		return enc
+	default:
+		panic(c.vm.NewTypeError(fmt.Sprintf("unsupported key encoding: %s (use 'hex' or 'base64')", enc)))
+	}
+}
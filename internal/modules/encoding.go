@@ -0,0 +1,297 @@
+package modules
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dop251/goja"
+)
+
+// Encoding provides the WHATWG text encoding globals.
+// Only UTF-8 is supported.
+//
+// Available globally in JavaScript as:
+//
+//	const decoder = new TextDecoder();
+//	let text = '';
+//	for (const chunk of chunks) {
+//	  text += decoder.decode(chunk, { stream: true }); // holds back split characters
+//	}
+//	text += decoder.decode(); // flush
+//
+//	const bytes = new TextEncoder().encode('héllo'); // Uint8Array of UTF-8
+type Encoding struct {
+	vm *goja.Runtime
+}
+
+// NewEncoding creates a new Encoding module instance.
+func NewEncoding() *Encoding {
+	return &Encoding{}
+}
+
+// Export returns an object holding the TextEncoder and TextDecoder
+// constructors.
+func (e *Encoding) Export(vm *goja.Runtime) goja.Value {
+	e.vm = vm
+	obj := vm.NewObject()
+
+	obj.Set("TextEncoder", e.textEncoder)
+	obj.Set("TextDecoder", e.textDecoder)
+
+	return obj
+}
+
+// textEncoder implements new TextEncoder(), which always encodes UTF-8.
+func (e *Encoding) textEncoder(call goja.ConstructorCall) *goja.Object {
+	obj := call.This
+	obj.Set("encoding", "utf-8")
+
+	obj.Set("encode", func(call goja.FunctionCall) goja.Value {
+		input := ""
+		if arg := call.Argument(0); !goja.IsUndefined(arg) {
+			input = arg.String()
+		}
+		return newUint8Array(e.vm, []byte(input))
+	})
+
+	// encodeInto(string, Uint8Array) writes as many whole characters as fit
+	// and reports { read, written }, read counting UTF-16 code units
+	obj.Set("encodeInto", func(call goja.FunctionCall) goja.Value {
+		dst, ok := call.Argument(1).Export().([]byte)
+		if !ok {
+			panic(e.vm.NewTypeError("encodeInto requires a Uint8Array destination"))
+		}
+
+		read, written := 0, 0
+		for _, r := range call.Argument(0).String() {
+			n := utf8.RuneLen(r)
+			if n < 0 {
+				r, n = utf8.RuneError, 3
+			}
+			if written+n > len(dst) {
+				break
+			}
+			utf8.EncodeRune(dst[written:], r)
+			written += n
+			read += utf16Len(r)
+		}
+
+		result := e.vm.NewObject()
+		result.Set("read", read)
+		result.Set("written", written)
+		return result
+	})
+
+	return nil
+}
+
+// utf16Len returns how many UTF-16 code units r takes in a JS string.
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// textDecoder implements new TextDecoder([label], [{ fatal, ignoreBOM }]).
+func (e *Encoding) textDecoder(call goja.ConstructorCall) *goja.Object {
+	label := "utf-8"
+	if arg := call.Argument(0); !goja.IsUndefined(arg) {
+		label = strings.ToLower(strings.TrimSpace(arg.String()))
+	}
+	switch label {
+	case "utf-8", "utf8", "unicode-1-1-utf-8":
+	default:
+		panic(newRangeError(e.vm, "The encoding label provided ('"+label+"') is invalid or unsupported"))
+	}
+
+	fatal, ignoreBOM := false, false
+	if opts, ok := call.Argument(1).(*goja.Object); ok {
+		if v := opts.Get("fatal"); v != nil {
+			fatal = v.ToBoolean()
+		}
+		if v := opts.Get("ignoreBOM"); v != nil {
+			ignoreBOM = v.ToBoolean()
+		}
+	}
+
+	var pending []byte // incomplete trailing sequence from a streamed chunk
+	bomChecked := false
+
+	obj := call.This
+	obj.Set("encoding", "utf-8")
+	obj.Set("fatal", fatal)
+	obj.Set("ignoreBOM", ignoreBOM)
+
+	obj.Set("decode", func(call goja.FunctionCall) goja.Value {
+		stream := false
+		if opts, ok := call.Argument(1).(*goja.Object); ok {
+			if v := opts.Get("stream"); v != nil {
+				stream = v.ToBoolean()
+			}
+		}
+
+		data := pending
+		pending = nil
+		if input := call.Argument(0); !goja.IsUndefined(input) && !goja.IsNull(input) {
+			data = append(data, bytesOf(e.vm, input)...)
+		}
+
+		if stream {
+			cut := incompleteTail(data)
+			pending = append([]byte(nil), data[cut:]...)
+			data = data[:cut]
+		}
+
+		// a BOM is only meaningful at the very start of a stream
+		if !bomChecked && len(data) > 0 {
+			bomChecked = true
+			if !ignoreBOM && len(data) >= 3 && data[0] == 0xEF && data[1] == 0xBB && data[2] == 0xBF {
+				data = data[3:]
+			}
+		}
+
+		text, valid := decodeUTF8(data)
+		if fatal && !valid {
+			pending = nil
+			panic(e.vm.NewTypeError("The encoded data was not valid for encoding utf-8"))
+		}
+
+		if !stream {
+			bomChecked = false // the next call starts a new stream
+		}
+
+		return e.vm.ToValue(text)
+	})
+
+	return nil
+}
+
+// newRangeError creates a JS RangeError with the given message.
+func newRangeError(vm *goja.Runtime, msg string) *goja.Object {
+	ctor, ok := goja.AssertConstructor(vm.Get("RangeError"))
+	if !ok {
+		return vm.NewGoError(errors.New(msg))
+	}
+	obj, err := ctor(nil, vm.ToValue(msg))
+	if err != nil {
+		panic(err)
+	}
+	return obj
+}
+
+// bytesOf reads the bytes behind an ArrayBuffer, typed array, DataView or
+// plain array of byte values.
+func bytesOf(vm *goja.Runtime, value goja.Value) []byte {
+	switch v := value.Export().(type) {
+	case []byte:
+		return v
+	case goja.ArrayBuffer:
+		return v.Bytes()
+	case []any:
+		out := make([]byte, len(v))
+		for i, b := range v {
+			n, ok := b.(int64)
+			if !ok || n < 0 || n > 255 {
+				panic(vm.NewTypeError("byte arrays must contain integers between 0 and 255"))
+			}
+			out[i] = byte(n)
+		}
+		return out
+	}
+
+	// other views (e.g. DataView, Uint16Array) expose their underlying buffer
+	if obj, ok := value.(*goja.Object); ok {
+		if b := obj.Get("buffer"); b != nil {
+			if buf, ok := b.Export().(goja.ArrayBuffer); ok {
+				offset := obj.Get("byteOffset").ToInteger()
+				length := obj.Get("byteLength").ToInteger()
+				return buf.Bytes()[offset : offset+length]
+			}
+		}
+	}
+
+	panic(vm.NewTypeError("The \"input\" argument must be an ArrayBuffer or ArrayBufferView"))
+}
+
+// isBinary reports whether value is an ArrayBuffer or a view of one
+// (typed array or DataView), the values bytesOf reads without copying
+// element by element.
+func isBinary(value goja.Value) bool {
+	obj, ok := value.(*goja.Object)
+	if !ok {
+		return false
+	}
+	switch obj.Export().(type) {
+	case []byte, goja.ArrayBuffer:
+		return true
+	}
+	if b := obj.Get("buffer"); b != nil {
+		_, ok := b.Export().(goja.ArrayBuffer)
+		return ok
+	}
+	return false
+}
+
+// incompleteTail returns the index where a trailing, not yet complete UTF-8
+// sequence starts (len(data) when the data ends on a character boundary).
+func incompleteTail(data []byte) int {
+	// a sequence is at most 4 bytes, so only the last 3 can be a partial one
+	for i := len(data) - 1; i >= 0 && i >= len(data)-3; i-- {
+		b := data[i]
+		if b < 0x80 {
+			return len(data) // ASCII: complete
+		}
+		if b >= 0xC0 { // leading byte
+			if utf8.FullRune(data[i:]) {
+				return len(data)
+			}
+			return i
+		}
+	}
+	return len(data)
+}
+
+// decodeUTF8 converts data to a string, replacing each invalid sequence with
+// a single U+FFFD as the WHATWG decoder does (a truncated multi-byte character
+// becomes one replacement, not one per byte). The second result reports
+// whether the input was entirely valid.
+func decodeUTF8(data []byte) (string, bool) {
+	if utf8.Valid(data) {
+		return string(data), true
+	}
+
+	var sb strings.Builder
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		if r == utf8.RuneError && size == 1 {
+			size = truncatedLen(data)
+		}
+		sb.WriteRune(r)
+		data = data[size:]
+	}
+	return sb.String(), false
+}
+
+// truncatedLen returns how many bytes at the start of data form the prefix of
+// a multi-byte sequence that was cut short (at least 1).
+func truncatedLen(data []byte) int {
+	need := 0
+	switch b := data[0]; {
+	case b >= 0xC2 && b <= 0xDF:
+		need = 2
+	case b >= 0xE0 && b <= 0xEF:
+		need = 3
+	case b >= 0xF0 && b <= 0xF4:
+		need = 4
+	default:
+		return 1
+	}
+
+	n := 1
+	for n < need && n < len(data) && data[n]&0xC0 == 0x80 {
+		n++
+	}
+	return n
+}
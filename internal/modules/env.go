@@ -0,0 +1,63 @@
+package modules
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"github.com/douglasjordan2/dougless/internal/permissions"
+)
+
+// Env reads environment variables under the env permission, as process.env
+// does. Variables loaded with --env-file are included.
+//
+// Available globally in JavaScript as:
+//
+//	env.get('DATABASE_URL'); // the value, or undefined when unset
+//
+// Reading a variable not granted by --allow-env (or a prompt) throws.
+type Env struct {
+	vm *goja.Runtime
+}
+
+// NewEnv creates a new Env module instance.
+func NewEnv() *Env {
+	return &Env{}
+}
+
+func (e *Env) Export(vm *goja.Runtime) goja.Value {
+	e.vm = vm
+	obj := vm.NewObject()
+	obj.Set("get", e.get)
+	return obj
+}
+
+// get implements env.get(name).
+func (e *Env) get(call goja.FunctionCall) goja.Value {
+	if len(call.Arguments) < 1 {
+		panic(e.vm.NewTypeError("env.get requires a variable name"))
+	}
+	name := call.Argument(0).String()
+	requireEnv(e.vm, name)
+
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return goja.Undefined()
+	}
+	return e.vm.ToValue(value)
+}
+
+// requireEnv throws the standard permission error unless the env
+// permission for name is granted, prompting if needed.
+func requireEnv(vm *goja.Runtime, name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	mgr := permissions.GetManager()
+	if !mgr.CheckWithPrompt(ctx, permissions.PermissionEnv, name) {
+		panic(vm.NewGoError(fmt.Errorf("%s", mgr.ErrorMessage(permissions.PermissionEnv, name))))
+	}
+}
@@ -0,0 +1,303 @@
+package modules
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dop251/goja"
+)
+
+// DefaultMaxListeners is the per-event listener count above which an emitter
+// prints a possible-leak warning, matching Node's default of 10.
+const DefaultMaxListeners = 10
+
+// eventEmitterKey is the hidden property linking a JS emitter to its Go state.
+const eventEmitterKey = "_emitter"
+
+// listener is a single registered event handler.
+type listener struct {
+	fn       goja.Value    // Function as passed by the caller (used for removal)
+	callable goja.Callable // Callable form of fn
+	once     bool          // Removed before its first invocation
+}
+
+// emitterState holds the listeners registered on one EventEmitter instance.
+type emitterState struct {
+	events       map[string][]listener
+	order        []string        // Event names in registration order
+	maxListeners int             // Per-emitter limit; -1 means use the module default
+	warned       map[string]bool // Events that already triggered a leak warning
+}
+
+// Events provides the EventEmitter class via require('events').
+//
+// Available in JavaScript as:
+//
+//	const EventEmitter = require('events');
+//	const emitter = new EventEmitter();
+//	emitter.on('data', (chunk) => console.log(chunk));
+//	emitter.emit('data', 'hello');
+//
+//	emitter.setMaxListeners(20);           // per emitter (0 = unlimited)
+//	EventEmitter.defaultMaxListeners = 20; // every emitter without its own limit
+type Events struct {
+	vm                  *goja.Runtime
+	constructor         *goja.Object
+	defaultMaxListeners int
+	warnOutput          io.Writer // Destination for leak warnings (stderr when nil)
+}
+
+// NewEvents creates a new Events module instance.
+func NewEvents() *Events {
+	return &Events{
+		defaultMaxListeners: DefaultMaxListeners,
+	}
+}
+
+// SetWarningOutput redirects listener leak warnings (stderr by default).
+func (e *Events) SetWarningOutput(w io.Writer) {
+	e.warnOutput = w
+}
+
+// Export returns the EventEmitter constructor. The constructor is created once
+// so every require('events') shares the same class and default limit.
+func (e *Events) Export(vm *goja.Runtime) goja.Value {
+	if e.constructor != nil && e.vm == vm {
+		return e.constructor
+	}
+	e.vm = vm
+
+	constructor := vm.ToValue(func(call goja.ConstructorCall) *goja.Object {
+		e.stateOf(call.This)
+		return nil
+	}).ToObject(vm)
+
+	proto := constructor.Get("prototype").ToObject(vm)
+	proto.Set("on", e.addListener)
+	proto.Set("addListener", e.addListener)
+	proto.Set("once", e.once)
+	proto.Set("off", e.removeListener)
+	proto.Set("removeListener", e.removeListener)
+	proto.Set("removeAllListeners", e.removeAllListeners)
+	proto.Set("emit", e.emit)
+	proto.Set("listenerCount", e.listenerCount)
+	proto.Set("listeners", e.listeners)
+	proto.Set("eventNames", e.eventNames)
+	proto.Set("setMaxListeners", e.setMaxListeners)
+	proto.Set("getMaxListeners", e.getMaxListeners)
+
+	constructor.DefineAccessorProperty("defaultMaxListeners",
+		vm.ToValue(func() int { return e.defaultMaxListeners }),
+		vm.ToValue(func(n int) {
+			if n < 0 {
+				panic(vm.NewTypeError("defaultMaxListeners must be a non-negative number"))
+			}
+			e.defaultMaxListeners = n
+		}),
+		goja.FLAG_FALSE, goja.FLAG_TRUE)
+
+	// allow both require('events') and require('events').EventEmitter
+	constructor.Set("EventEmitter", constructor)
+
+	e.constructor = constructor
+	return constructor
+}
+
+// stateOf returns the Go state behind an emitter, creating it on first use so
+// objects that skipped the constructor (e.g. Object.create) still work.
+func (e *Events) stateOf(obj *goja.Object) *emitterState {
+	if hidden := obj.Get(eventEmitterKey); hidden != nil {
+		if state, ok := hidden.Export().(*emitterState); ok {
+			return state
+		}
+	}
+
+	state := &emitterState{
+		events:       make(map[string][]listener),
+		maxListeners: -1,
+		warned:       make(map[string]bool),
+	}
+	obj.DefineDataProperty(eventEmitterKey, e.vm.ToValue(state), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_FALSE)
+	return state
+}
+
+func (e *Events) limit(state *emitterState) int {
+	if state.maxListeners >= 0 {
+		return state.maxListeners
+	}
+	return e.defaultMaxListeners
+}
+
+// add registers a listener and warns once per event when the limit is exceeded.
+func (e *Events) add(call goja.FunctionCall, once bool) goja.Value {
+	this := call.This.ToObject(e.vm)
+	name := call.Argument(0).String()
+
+	fn := call.Argument(1)
+	callable, ok := goja.AssertFunction(fn)
+	if !ok {
+		panic(e.vm.NewTypeError("The \"listener\" argument must be a function"))
+	}
+
+	state := e.stateOf(this)
+	if _, exists := state.events[name]; !exists {
+		state.order = append(state.order, name)
+	}
+	state.events[name] = append(state.events[name], listener{fn: fn, callable: callable, once: once})
+
+	count := len(state.events[name])
+	if max := e.limit(state); max > 0 && count > max && !state.warned[name] {
+		state.warned[name] = true
+
+		out := e.warnOutput
+		if out == nil {
+			out = os.Stderr
+		}
+		fmt.Fprintf(out, "MaxListenersExceededWarning: Possible EventEmitter memory leak detected. "+
+			"%d %s listeners added. Use emitter.setMaxListeners() to increase limit\n", count, name)
+	}
+
+	return this
+}
+
+func (e *Events) addListener(call goja.FunctionCall) goja.Value {
+	return e.add(call, false)
+}
+
+func (e *Events) once(call goja.FunctionCall) goja.Value {
+	return e.add(call, true)
+}
+
+// removeListener removes the most recently added registration of a listener.
+func (e *Events) removeListener(call goja.FunctionCall) goja.Value {
+	this := call.This.ToObject(e.vm)
+	name := call.Argument(0).String()
+	fn := call.Argument(1)
+
+	state := e.stateOf(this)
+	list := state.events[name]
+	for i := len(list) - 1; i >= 0; i-- {
+		if list[i].fn.StrictEquals(fn) {
+			state.events[name] = append(list[:i:i], list[i+1:]...)
+			break
+		}
+	}
+
+	if len(state.events[name]) == 0 {
+		e.forget(state, name)
+	}
+
+	return this
+}
+
+func (e *Events) removeAllListeners(call goja.FunctionCall) goja.Value {
+	this := call.This.ToObject(e.vm)
+	state := e.stateOf(this)
+
+	if len(call.Arguments) == 0 || goja.IsUndefined(call.Argument(0)) {
+		state.events = make(map[string][]listener)
+		state.order = nil
+		state.warned = make(map[string]bool)
+		return this
+	}
+
+	e.forget(state, call.Argument(0).String())
+	return this
+}
+
+// forget drops every listener for an event and resets its leak warning.
+func (e *Events) forget(state *emitterState, name string) {
+	delete(state.events, name)
+	delete(state.warned, name)
+
+	for i, n := range state.order {
+		if n == name {
+			state.order = append(state.order[:i:i], state.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// emit calls every listener for an event in registration order.
+// Emitting 'error' with no listeners throws the error, like Node.
+func (e *Events) emit(call goja.FunctionCall) goja.Value {
+	this := call.This.ToObject(e.vm)
+	name := call.Argument(0).String()
+	state := e.stateOf(this)
+
+	list := state.events[name]
+	if len(list) == 0 {
+		if name == "error" {
+			err := call.Argument(1)
+			if goja.IsUndefined(err) {
+				panic(e.vm.NewGoError(fmt.Errorf("Unhandled error event")))
+			}
+			panic(err)
+		}
+		return e.vm.ToValue(false)
+	}
+
+	// snapshot so listeners added or removed during emit don't affect this round
+	snapshot := append([]listener(nil), list...)
+
+	var args []goja.Value
+	if len(call.Arguments) > 1 {
+		args = call.Arguments[1:]
+	}
+
+	for _, l := range snapshot {
+		if l.once {
+			e.removeListener(goja.FunctionCall{This: this, Arguments: []goja.Value{e.vm.ToValue(name), l.fn}})
+		}
+		if _, err := l.callable(this, args...); err != nil {
+			panic(err)
+		}
+	}
+
+	return e.vm.ToValue(true)
+}
+
+func (e *Events) listenerCount(call goja.FunctionCall) goja.Value {
+	state := e.stateOf(call.This.ToObject(e.vm))
+	return e.vm.ToValue(len(state.events[call.Argument(0).String()]))
+}
+
+func (e *Events) listeners(call goja.FunctionCall) goja.Value {
+	state := e.stateOf(call.This.ToObject(e.vm))
+
+	list := state.events[call.Argument(0).String()]
+	fns := make([]any, len(list))
+	for i, l := range list {
+		fns[i] = l.fn
+	}
+	return e.vm.NewArray(fns...)
+}
+
+func (e *Events) eventNames(call goja.FunctionCall) goja.Value {
+	state := e.stateOf(call.This.ToObject(e.vm))
+
+	names := make([]any, len(state.order))
+	for i, n := range state.order {
+		names[i] = n
+	}
+	return e.vm.NewArray(names...)
+}
+
+// setMaxListeners sets this emitter's per-event limit (0 disables the warning).
+func (e *Events) setMaxListeners(call goja.FunctionCall) goja.Value {
+	this := call.This.ToObject(e.vm)
+
+	n := call.Argument(0).ToInteger()
+	if n < 0 {
+		panic(e.vm.NewTypeError("setMaxListeners requires a non-negative number"))
+	}
+
+	e.stateOf(this).maxListeners = int(n)
+	return this
+}
+
+func (e *Events) getMaxListeners(call goja.FunctionCall) goja.Value {
+	state := e.stateOf(call.This.ToObject(e.vm))
+	return e.vm.ToValue(e.limit(state))
+}
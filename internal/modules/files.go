@@ -2,18 +2,30 @@ package modules
 
 import (
 	"context"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/dop251/goja"
 
+	"github.com/douglasjordan2/dougless/internal/event"
 	"github.com/douglasjordan2/dougless/internal/permissions"
 )
 
 type Files struct {
 	vm      *goja.Runtime
   runtime RuntimeKeepAlive
+  loop    *event.Loop // delivers results on the VM goroutine
+  errOut  io.Writer   // Destination for stream and tail errors (stderr when nil)
 }
 
 func NewFiles() *Files {
@@ -24,34 +36,184 @@ func (fs *Files) SetRuntime(rt RuntimeKeepAlive) {
   fs.runtime = rt
 }
 
+func (fs *Files) SetLoop(loop *event.Loop) {
+  fs.loop = loop
+}
+
+// SetErrorOutput redirects stream and tail callback error reports (stderr by default).
+func (fs *Files) SetErrorOutput(w io.Writer) {
+  fs.errOut = w
+}
+
+// errorOutput is where stream and tail callback errors are reported.
+func (fs *Files) errorOutput() io.Writer {
+  if fs.errOut == nil {
+    return os.Stderr
+  }
+  return fs.errOut
+}
+
+// schedule runs fn on the event loop and keeps the runtime alive until it has run.
+func (fs *Files) schedule(name string, fn func()) {
+	done := fs.runtime.KeepAlive()
+	fs.loop.Schedule(event.Task{Name: name, Callback: func() {
+		defer done()
+		fn()
+	}})
+}
+
 func (fs *Files) Export(vm *goja.Runtime) goja.Value {
 	fs.vm = vm
 	obj := vm.NewObject()
 
 	obj.Set("read", fs.read)
+	obj.Set("readBytes", fs.readBytes)
+	obj.Set("readJSON", fs.readJSON)
+	obj.Set("writeJSON", fs.writeJSON)
+	obj.Set("verifyHash", fs.verifyHash)
 	obj.Set("write", fs.write)
+	obj.Set("append", fs.append)
+	obj.Set("copy", fs.copy)
+	obj.Set("rename", fs.rename)
 	obj.Set("rm", fs.rm)
+	obj.Set("exists", fs.exists)
+	obj.Set("stat", fs.stat)
+	obj.Set("readdir", fs.readdir)
+	obj.Set("mkdir", fs.mkdir)
+	obj.Set("ensureDir", fs.ensureDir)
+	obj.Set("watchDir", fs.watchDir)
+	obj.Set("tail", fs.tail)
+	obj.Set("readStream", fs.createReadStream)
+	obj.Set("writeStream", fs.createWriteStream)
+	obj.Set("createReadStream", fs.createReadStream)
+	obj.Set("createWriteStream", fs.createWriteStream)
 
 	return obj
 }
 
+// jsValue builds an operation's data on the VM goroutine, for data that
+// needs the VM to make (Dates, typed arrays, parsed JSON). An error fails
+// the call with its message.
+type jsValue func() (goja.Value, error)
+
+// dispatch runs op off the VM goroutine with a 30s permission-prompt context
+// and delivers the result on the event loop the way every files method does:
+//
+//   - with a callback: callback(err, data), or callback(err) when op has no data
+//   - without one: a Promise that rejects with err or resolves with data (null when none)
+//
+// op must not touch the VM: it returns an error message ("" on success) and
+// its data as a plain Go value, nil when it produces none. The data is
+// converted with ToValue on the loop, or built there when it is a jsValue.
+//
+// When signal is non-nil, aborting it cancels op's context and settles the
+// call right away with an "aborted" error, even if op is stuck in a blocking
+// syscall (e.g. opening a FIFO with no writer); op's eventual result is dropped.
+func (fs *Files) dispatch(name string, callback goja.Callable, hasCallback bool, signal *AbortSignal, op func(ctx context.Context) (any, string)) goja.Value {
+	run := func() (any, string) {
+		timeoutCtx, cancelTimeout := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancelTimeout()
+		if signal == nil {
+			return op(timeoutCtx)
+		}
+
+		ctx, cancel := signal.Context(timeoutCtx)
+		defer cancel()
+
+		aborted := func() (any, string) {
+			return nil, fmt.Sprintf("aborted: %v", signal.Err())
+		}
+		if signal.Aborted() {
+			return aborted()
+		}
+
+		type result struct {
+			data   any
+			errMsg string
+		}
+		results := make(chan result, 1)
+		go func() {
+			data, errMsg := op(ctx)
+			results <- result{data, errMsg}
+		}()
+
+		select {
+		case r := <-results:
+			return r.data, r.errMsg
+		case <-signal.Done():
+			return aborted()
+		}
+	}
+
+	var promise *Promise
+	result := goja.Undefined()
+	if !hasCallback {
+		promise = &Promise{
+			vm:          fs.vm,
+			runtime:     fs.runtime,
+			state:       PromisePending,
+			onFulfilled: []goja.Callable{},
+			onRejected:  []goja.Callable{},
+		}
+		result = CreatePromiseObject(fs.vm, promise)
+	}
+
+	done := fs.runtime.KeepAlive()
+	go func() {
+		defer done()
+
+		data, errMsg := run()
+
+		fs.schedule(name, func() {
+			var value goja.Value // nil when op has no data
+			if errMsg == "" && data != nil {
+				if build, ok := data.(jsValue); ok {
+					var err error
+					if value, err = build(); err != nil {
+						errMsg = err.Error()
+					}
+				} else {
+					value = fs.vm.ToValue(data)
+				}
+			}
+
+			switch {
+			case errMsg != "" && promise != nil:
+				promise.reject(fs.vm.ToValue(errMsg))
+			case errMsg != "":
+				callback(goja.Undefined(), fs.vm.ToValue(errMsg))
+			case promise != nil && value == nil:
+				promise.resolve(goja.Null())
+			case promise != nil:
+				promise.resolve(value)
+			case value == nil:
+				callback(goja.Undefined(), goja.Null())
+			default:
+				callback(goja.Undefined(), goja.Null(), value)
+			}
+		})
+	}()
+
+	return result
+}
+
 func dirCheck(dest string) bool {
 	return len(dest) > 0 && dest[len(dest)-1] == '/'
 }
 
-func (fs *Files) doRead(ctx context.Context, dest string) (goja.Value, goja.Value) {
+func (fs *Files) doRead(ctx context.Context, dest string) (any, string) {
 	mgr := permissions.GetManager()
 	canRead := permissions.PermissionRead
 	if !mgr.CheckWithPrompt(ctx, canRead, dest) {
 		errMsg := mgr.ErrorMessage(canRead, dest)
-		return fs.vm.ToValue(errMsg), goja.Undefined()
+		return nil, errMsg
 	}
 
 	isDir := dirCheck(dest)
 
 	_, statErr := os.Stat(dest)
 	if os.IsNotExist(statErr) {
-		return goja.Null(), goja.Null()
+		return goja.Null(), ""
 	}
 
 	var fileData []byte
@@ -64,26 +226,29 @@ func (fs *Files) doRead(ctx context.Context, dest string) (goja.Value, goja.Valu
 		fileData, err = os.ReadFile(dest)
 	}
 
-	var errArg, dataArg goja.Value
 	if err != nil {
-		errArg = fs.vm.ToValue(err.Error())
-		dataArg = goja.Undefined()
-	} else {
-		errArg = goja.Null()
-		if isDir {
-			names := make([]string, len(dirData))
-			for i, entry := range dirData {
-				names[i] = entry.Name()
-			}
-			dataArg = fs.vm.ToValue(names)
-		} else {
-			dataArg = fs.vm.ToValue(string(fileData))
+		return nil, err.Error()
+	}
+	if isDir {
+		names := make([]string, len(dirData))
+		for i, entry := range dirData {
+			names[i] = entry.Name()
 		}
+		return names, ""
 	}
-
-	return errArg, dataArg
+	return string(fileData), ""
 }
 
+// read(path, [options], [callback]) reads a file's contents, or a directory's
+// entry names when path ends in '/'.
+//
+// Options:
+//
+//	signal: an AbortSignal; aborting it fails the pending read with an "aborted" error
+//
+//	const controller = new AbortController();
+//	files.read('/var/run/slow.fifo', { signal: controller.signal }, (err, data) => { ... });
+//	controller.abort();
 func (fs *Files) read(call goja.FunctionCall) goja.Value {
 	if len(call.Arguments) < 1 {
 		panic(fs.vm.NewTypeError("read requires a file or directory path"))
@@ -91,58 +256,183 @@ func (fs *Files) read(call goja.FunctionCall) goja.Value {
 
 	dest := call.Arguments[0].String()
 
-	var callback goja.Callable
-	var ok bool
-	if len(call.Arguments) > 1 {
-		callback, ok = goja.AssertFunction(call.Arguments[1])
-	}
+	var signal *AbortSignal
+	callback, ok := goja.AssertFunction(call.Argument(1))
 	if !ok {
-		promise := &Promise{
-			vm:          fs.vm,
-			runtime:     fs.runtime,
-			state:       PromisePending,
-			onFulfilled: []goja.Callable{},
-			onRejected:  []goja.Callable{},
+		if opts := call.Argument(1); !goja.IsUndefined(opts) && !goja.IsNull(opts) {
+			signal = signalFromValue(fs.vm, opts.ToObject(fs.vm).Get("signal"))
 		}
+		callback, ok = goja.AssertFunction(call.Argument(2))
+	}
 
-    done := fs.runtime.KeepAlive()
-    go func() {
-      defer done()
+	return fs.dispatch("files.read", callback, ok, signal, func(ctx context.Context) (any, string) {
+		return fs.doRead(ctx, dest)
+	})
+}
 
-      ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-      defer cancel()
+// readBytes(path, [callback]) reads a file's raw contents as a Uint8Array,
+// for binary files that read would mangle by decoding them as text.
+//
+//	const png = await files.readBytes('logo.png');
+//	const sum = crypto.createHash('sha256').update(png).digest('hex');
+//	await files.write('copy.png', png);
+func (fs *Files) readBytes(call goja.FunctionCall) goja.Value {
+	if len(call.Arguments) < 1 {
+		panic(fs.vm.NewTypeError("readBytes requires a file path"))
+	}
 
-      errArg, dataArg := fs.doRead(ctx, dest)
+	dest := call.Arguments[0].String()
+	callback, hasCallback := goja.AssertFunction(call.Argument(1))
 
-      if errArg != goja.Null() && !goja.IsNull(errArg) {
-        promise.reject(errArg)
-      } else {
-        promise.resolve(dataArg)
-      }
-		}()
+	return fs.dispatch("files.readBytes", callback, hasCallback, nil, func(ctx context.Context) (any, string) {
+		data, errMsg := fs.doReadFile(ctx, dest)
+		if errMsg != "" {
+			return nil, errMsg
+		}
+		return jsValue(func() (goja.Value, error) {
+			return newUint8Array(fs.vm, data), nil
+		}), ""
+	})
+}
 
-		return CreatePromiseObject(fs.vm, promise)
+// doReadFile reads a whole file for readBytes and readJSON, which (unlike
+// doRead) treat a missing file as an error.
+func (fs *Files) doReadFile(ctx context.Context, dest string) ([]byte, string) {
+	if errMsg := checkAll(ctx, permissionCheck{permissions.PermissionRead, dest}); errMsg != "" {
+		return nil, errMsg
 	}
+	data, err := os.ReadFile(dest)
+	if err != nil {
+		return nil, err.Error()
+	}
+	return data, ""
+}
 
-  done := fs.runtime.KeepAlive()
-  go func() {
-    defer done()
-    ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-    defer cancel()
+// readJSON(path, [callback]) reads a file and parses it as JSON. A file that
+// doesn't parse fails the call with an error naming the path; unlike read, a
+// missing file is an error too rather than null.
+//
+//	const cfg = await files.readJSON('config.json');
+func (fs *Files) readJSON(call goja.FunctionCall) goja.Value {
+	if len(call.Arguments) < 1 {
+		panic(fs.vm.NewTypeError("readJSON requires a file path"))
+	}
 
-    errArg, dataArg := fs.doRead(ctx, dest)
-    callback(goja.Undefined(), errArg, dataArg)
-	}()
+	dest := call.Arguments[0].String()
+	callback, hasCallback := goja.AssertFunction(call.Argument(1))
+
+	return fs.dispatch("files.readJSON", callback, hasCallback, nil, func(ctx context.Context) (any, string) {
+		data, errMsg := fs.doReadFile(ctx, dest)
+		if errMsg != "" {
+			return nil, errMsg
+		}
+		return jsValue(func() (goja.Value, error) {
+			value, err := fs.parseJSON(string(data))
+			if err != nil {
+				return nil, fmt.Errorf("invalid JSON in %s: %v", dest, err)
+			}
+			return value, nil
+		}), ""
+	})
+}
+
+// parseJSON parses text with the script's JSON.parse.
+func (fs *Files) parseJSON(text string) (goja.Value, error) {
+	parse, _ := goja.AssertFunction(fs.vm.Get("JSON").ToObject(fs.vm).Get("parse"))
+	value, err := parse(goja.Undefined(), fs.vm.ToValue(text))
+	if exc, ok := err.(*goja.Exception); ok {
+		return nil, errors.New(exc.Value().String())
+	}
+	return value, err
+}
+
+// writeJSON(path, value, [callback]) writes value as JSON indented by two
+// spaces, with a trailing newline. value is stringified when writeJSON is
+// called, so later changes to it aren't written, and values JSON can't hold
+// (cycles, BigInts) throw right away.
+//
+//	await files.writeJSON('config.json', { port: 8080 });
+func (fs *Files) writeJSON(call goja.FunctionCall) goja.Value {
+	if len(call.Arguments) < 2 {
+		panic(fs.vm.NewTypeError("writeJSON requires a path and a value"))
+	}
+
+	dest := call.Arguments[0].String()
+	stringify, _ := goja.AssertFunction(fs.vm.Get("JSON").ToObject(fs.vm).Get("stringify"))
+	text, err := stringify(goja.Undefined(), call.Arguments[1], goja.Null(), fs.vm.ToValue(2))
+	if err != nil {
+		panic(err)
+	}
+	if goja.IsUndefined(text) {
+		panic(fs.vm.NewTypeError("writeJSON value has no JSON representation"))
+	}
+	data := text.String() + "\n"
+
+	callback, ok := goja.AssertFunction(call.Argument(2))
+	return fs.dispatch("files.writeJSON", callback, ok, nil, func(ctx context.Context) (any, string) {
+		return nil, fs.doWrite(ctx, dest, data, false)
+	})
+}
+
+// verifyHash(path, algorithm, expectedHex, [callback]) hashes a file and
+// reports whether the digest matches expectedHex, for checking downloads.
+// The file is streamed through the hash rather than read whole, and the
+// digests are compared in constant time.
+//
+//	const ok = await files.verifyHash('release.tar.gz', 'sha256', published);
+//	if (!ok) throw new Error('checksum mismatch');
+func (fs *Files) verifyHash(call goja.FunctionCall) goja.Value {
+	if len(call.Arguments) < 3 {
+		panic(fs.vm.NewTypeError("verifyHash requires a file path, algorithm and expected hex digest"))
+	}
+
+	path := call.Arguments[0].String()
+	algorithm := strings.ToLower(call.Arguments[1].String())
+	newHash, ok := hashAlgorithms[algorithm]
+	if !ok {
+		panic(fs.vm.NewTypeError(fmt.Sprintf("unsupported hash algorithm: %s", algorithm)))
+	}
+	expected, err := hex.DecodeString(call.Arguments[2].String())
+	if err != nil {
+		panic(fs.vm.NewTypeError("verifyHash expected digest must be hex"))
+	}
+
+	callback, hasCallback := goja.AssertFunction(call.Argument(3))
+	return fs.dispatch("files.verifyHash", callback, hasCallback, nil, func(ctx context.Context) (any, string) {
+		if errMsg := checkAll(ctx, permissionCheck{permissions.PermissionRead, path}); errMsg != "" {
+			return nil, errMsg
+		}
 
-	return goja.Undefined()
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err.Error()
+		}
+		defer f.Close()
+
+		h := newHash()
+		if _, err := io.Copy(h, f); err != nil {
+			return nil, err.Error()
+		}
+		match := subtle.ConstantTimeCompare(h.Sum(nil), expected) == 1
+		return match, ""
+	})
+}
+
+// fileData converts write or append data to what is written: the bytes of
+// an ArrayBuffer or typed array as they are, anything else as a string.
+func (fs *Files) fileData(value goja.Value) string {
+	if isBinary(value) {
+		return string(bytesOf(fs.vm, value))
+	}
+	return value.String()
 }
 
-func (fs *Files) doWrite(ctx context.Context, dest string, data string) goja.Value {
+func (fs *Files) doWrite(ctx context.Context, dest string, data string, atomic bool) string {
 	mgr := permissions.GetManager()
 	canWrite := permissions.PermissionWrite
 	if !mgr.CheckWithPrompt(ctx, canWrite, dest) {
 		errMsg := mgr.ErrorMessage(canWrite, dest)
-		return fs.vm.ToValue(errMsg)
+		return errMsg
 	}
 
 	isDir := dirCheck(dest)
@@ -153,21 +443,65 @@ func (fs *Files) doWrite(ctx context.Context, dest string, data string) goja.Val
 	} else {
 		// Create parent directories if needed
 		if mkdirErr := os.MkdirAll(filepath.Dir(dest), 0755); mkdirErr != nil {
-			return fs.vm.ToValue(mkdirErr.Error())
+			return mkdirErr.Error()
+		}
+		if atomic {
+			err = writeFileAtomic(dest, []byte(data))
+		} else {
+			err = os.WriteFile(dest, []byte(data), 0644)
 		}
-		err = os.WriteFile(dest, []byte(data), 0644)
 	}
 
-	var errArg goja.Value
 	if err != nil {
-		errArg = fs.vm.ToValue(err.Error())
-	} else {
-		errArg = goja.Null()
+		return err.Error()
+	}
+	return ""
+}
+
+// writeFileAtomic writes data to a temp file next to dest and renames it over
+// dest, so readers see either the old content or the new, never a partial file.
+// An existing file's permissions are kept.
+func writeFileAtomic(dest string, data []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(dest); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), "."+filepath.Base(dest)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpPath, mode)
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, dest)
 	}
 
-	return errArg
+	if err != nil {
+		os.Remove(tmpPath)
+	}
+	return err
 }
 
+// write(path, [data], [options], [callback]) writes a file, or creates a
+// directory when path ends in '/'. data may be a string, or an ArrayBuffer or
+// typed array written byte for byte.
+//
+// Options:
+//
+//	atomic: write to a temp file in the same directory, then rename it into place
+//
+//	files.write('config.json', JSON.stringify(cfg), { atomic: true }, (err) => { ... });
 func (fs *Files) write(call goja.FunctionCall) goja.Value {
 	if len(call.Arguments) < 1 {
 		panic(fs.vm.NewTypeError("write requires at least a path"))
@@ -176,6 +510,7 @@ func (fs *Files) write(call goja.FunctionCall) goja.Value {
 	dest := call.Arguments[0].String()
 	isDir := dirCheck(dest)
 	data := "" // Default to empty string for files
+	atomic := false
 
 	var callback goja.Callable
 	var ok bool
@@ -189,73 +524,235 @@ func (fs *Files) write(call goja.FunctionCall) goja.Value {
 			if !goja.IsUndefined(call.Arguments[1]) && !goja.IsNull(call.Arguments[1]) {
 				callback, ok = goja.AssertFunction(call.Arguments[1])
 				if !ok {
-					data = call.Arguments[1].String()
+					data = fs.fileData(call.Arguments[1])
 					if len(call.Arguments) > 2 {
 						callback, ok = goja.AssertFunction(call.Arguments[2])
+						if opts, isObj := call.Arguments[2].(*goja.Object); !ok && isObj {
+							if v := opts.Get("atomic"); v != nil {
+								atomic = v.ToBoolean()
+							}
+							if len(call.Arguments) > 3 {
+								callback, ok = goja.AssertFunction(call.Arguments[3])
+							}
+						}
 					}
 				}
 			}
 		}
 	}
 
-	if !ok {
-		promise := &Promise{
-			vm:          fs.vm,
-			runtime:     fs.runtime,
-			state:       PromisePending,
-			onFulfilled: []goja.Callable{},
-			onRejected:  []goja.Callable{},
+	return fs.dispatch("files.write", callback, ok, nil, func(ctx context.Context) (any, string) {
+		return nil, fs.doWrite(ctx, dest, data, atomic)
+	})
+}
+
+func (fs *Files) doAppend(ctx context.Context, dest string, data string) string {
+	mgr := permissions.GetManager()
+	canWrite := permissions.PermissionWrite
+	if !mgr.CheckWithPrompt(ctx, canWrite, dest) {
+		errMsg := mgr.ErrorMessage(canWrite, dest)
+		return errMsg
+	}
+
+	f, err := os.OpenFile(dest, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err == nil {
+		_, err = f.WriteString(data)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
 		}
+	}
 
-    done := fs.runtime.KeepAlive()
-    go func() {
-      defer done()
-      ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-      defer cancel()
+	if err != nil {
+		return err.Error()
+	}
+	return ""
+}
 
-      errArg := fs.doWrite(ctx, dest, data)
+// append(path, data, [callback]) adds data to the end of a file, creating it
+// if needed. Unlike write, existing content is kept.
+//
+//	files.append('app.log', line + '\n', (err) => { ... });
+//	await files.append('app.log', line + '\n');
+func (fs *Files) append(call goja.FunctionCall) goja.Value {
+	if len(call.Arguments) < 2 {
+		panic(fs.vm.NewTypeError("append requires a path and data"))
+	}
 
-      if errArg != goja.Null() && !goja.IsNull(errArg) {
-        promise.reject(errArg)
-      } else {
-        promise.resolve(goja.Null())
-      }
-		}()
+	dest := call.Arguments[0].String()
+	data := fs.fileData(call.Arguments[1])
 
-		return CreatePromiseObject(fs.vm, promise)
+	var callback goja.Callable
+	var ok bool
+	if len(call.Arguments) > 2 {
+		callback, ok = goja.AssertFunction(call.Arguments[2])
 	}
+	return fs.dispatch("files.append", callback, ok, nil, func(ctx context.Context) (any, string) {
+		return nil, fs.doAppend(ctx, dest, data)
+	})
+}
 
-  done := fs.runtime.KeepAlive()
-  go func() {
-    defer done()
-    ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-    defer cancel()
+// permissionCheck pairs a permission with the path it is needed for.
+type permissionCheck struct {
+	perm permissions.Permission
+	path string
+}
 
-    errArg := fs.doWrite(ctx, dest, data)
-    callback(goja.Undefined(), errArg)
-	}()
+// checkAll prompts for each permission/path pair in turn, returning the
+// error message for the first one denied, or "" when all are granted.
+func checkAll(ctx context.Context, checks ...permissionCheck) string {
+	mgr := permissions.GetManager()
+	for _, c := range checks {
+		if !mgr.CheckWithPrompt(ctx, c.perm, c.path) {
+			return mgr.ErrorMessage(c.perm, c.path)
+		}
+	}
+	return ""
+}
 
-	return goja.Undefined()
+func (fs *Files) doCopy(ctx context.Context, src, dst string) string {
+	if errMsg := checkAll(ctx,
+		permissionCheck{permissions.PermissionRead, src},
+		permissionCheck{permissions.PermissionWrite, dst},
+	); errMsg != "" {
+		return errMsg
+	}
+
+	if err := copyFile(src, dst); err != nil {
+		return err.Error()
+	}
+	return ""
 }
 
-func (fs *Files) doRm(ctx context.Context, path string) goja.Value {
+// copyFile streams src into dst, replacing dst's content if it exists. A new
+// dst gets src's permission bits.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("copy %s: is a directory", src)
+	}
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// copyTree copies a file or directory tree from src to dst.
+func copyTree(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		default:
+			return copyFile(path, target)
+		}
+	})
+}
+
+// copy(src, dst, [callback]) copies a file, streaming it so large files aren't
+// held in memory. An existing dst is overwritten. Needs read permission for
+// src and write permission for dst.
+//
+//	files.copy('data.db', 'backup/data.db', (err) => { ... });
+//	await files.copy('data.db', 'backup/data.db');
+func (fs *Files) copy(call goja.FunctionCall) goja.Value {
+	src, dst, callback, ok := fs.srcDstArgs(call, "copy")
+	return fs.dispatch("files.copy", callback, ok, nil, func(ctx context.Context) (any, string) {
+		return nil, fs.doCopy(ctx, src, dst)
+	})
+}
+
+func (fs *Files) doRename(ctx context.Context, src, dst string) string {
+	if errMsg := checkAll(ctx,
+		permissionCheck{permissions.PermissionRead, src},
+		permissionCheck{permissions.PermissionWrite, src},
+		permissionCheck{permissions.PermissionWrite, dst},
+	); errMsg != "" {
+		return errMsg
+	}
+
+	err := os.Rename(src, dst)
+	if errors.Is(err, syscall.EXDEV) {
+		// different filesystems: copy, then remove the original
+		if err = copyTree(src, dst); err == nil {
+			err = os.RemoveAll(src)
+		}
+	}
+
+	if err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// rename(src, dst, [callback]) moves a file or directory. Moves across
+// filesystems fall back to copying and deleting the original. Needs read and
+// write permission for src (it is removed) and write permission for dst.
+//
+//	files.rename('upload.tmp', 'uploads/photo.jpg', (err) => { ... });
+func (fs *Files) rename(call goja.FunctionCall) goja.Value {
+	src, dst, callback, ok := fs.srcDstArgs(call, "rename")
+	return fs.dispatch("files.rename", callback, ok, nil, func(ctx context.Context) (any, string) {
+		return nil, fs.doRename(ctx, src, dst)
+	})
+}
+
+// srcDstArgs reads the source and destination paths and an optional callback.
+func (fs *Files) srcDstArgs(call goja.FunctionCall, name string) (string, string, goja.Callable, bool) {
+	if len(call.Arguments) < 2 {
+		panic(fs.vm.NewTypeError(name + " requires a source and destination path"))
+	}
+
+	var callback goja.Callable
+	var ok bool
+	if len(call.Arguments) > 2 {
+		callback, ok = goja.AssertFunction(call.Arguments[2])
+	}
+	return call.Arguments[0].String(), call.Arguments[1].String(), callback, ok
+}
+
+func (fs *Files) doRm(ctx context.Context, path string) string {
 	mgr := permissions.GetManager()
 	canWrite := permissions.PermissionWrite
 	if !mgr.CheckWithPrompt(ctx, canWrite, path) {
 		errMsg := mgr.ErrorMessage(canWrite, path)
-		return fs.vm.ToValue(errMsg)
+		return errMsg
 	}
 
-	err := os.RemoveAll(path)
-
-	var errArg goja.Value
-	if err != nil {
-		errArg = fs.vm.ToValue(err.Error())
-	} else {
-		errArg = goja.Null()
+	if err := os.RemoveAll(path); err != nil {
+		return err.Error()
 	}
-
-	return errArg
+	return ""
 }
 
 func (fs *Files) rm(call goja.FunctionCall) goja.Value {
@@ -269,44 +766,454 @@ func (fs *Files) rm(call goja.FunctionCall) goja.Value {
 	if len(call.Arguments) > 1 {
 		callback, ok = goja.AssertFunction(call.Arguments[1])
 	}
-	if !ok {
-		// Promise path
-		promise := &Promise{
-			vm:          fs.vm,
-			runtime:     fs.runtime,
-			state:       PromisePending,
-			onFulfilled: []goja.Callable{},
-			onRejected:  []goja.Callable{},
+	return fs.dispatch("files.rm", callback, ok, nil, func(ctx context.Context) (any, string) {
+		return nil, fs.doRm(ctx, path)
+	})
+}
+
+// doExists reports whether path exists. A missing path is not an error,
+// but a permission denial or any other stat failure is, so callers can
+// tell "not there" apart from "not allowed to look".
+func (fs *Files) doExists(ctx context.Context, path string) (any, string) {
+	mgr := permissions.GetManager()
+	canRead := permissions.PermissionRead
+	if !mgr.CheckWithPrompt(ctx, canRead, path) {
+		errMsg := mgr.ErrorMessage(canRead, path)
+		return nil, errMsg
+	}
+
+	_, err := os.Stat(path)
+	if err == nil {
+		return true, ""
+	}
+	if os.IsNotExist(err) {
+		return false, ""
+	}
+
+	return nil, err.Error()
+}
+
+func (fs *Files) exists(call goja.FunctionCall) goja.Value {
+	if len(call.Arguments) < 1 {
+		panic(fs.vm.NewTypeError("exists requires a path"))
+	}
+
+	path := call.Arguments[0].String()
+
+	var callback goja.Callable
+	var ok bool
+	if len(call.Arguments) > 1 {
+		callback, ok = goja.AssertFunction(call.Arguments[1])
+	}
+	return fs.dispatch("files.exists", callback, ok, nil, func(ctx context.Context) (any, string) {
+		return fs.doExists(ctx, path)
+	})
+}
+
+// doEnsureDir creates path and any missing parents. An existing directory is
+// not an error, but an existing non-directory at path is.
+func (fs *Files) doEnsureDir(ctx context.Context, path string) string {
+	mgr := permissions.GetManager()
+	canWrite := permissions.PermissionWrite
+	if !mgr.CheckWithPrompt(ctx, canWrite, path) {
+		errMsg := mgr.ErrorMessage(canWrite, path)
+		return errMsg
+	}
+
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return err.Error()
+	}
+
+	return ""
+}
+
+// ensureDir idempotently creates a directory tree, like mkdir -p.
+//
+// JavaScript usage:
+//
+//	files.ensureDir('./data/cache/images', (err) => { ... });
+//	await files.ensureDir('./data/cache/images');
+func (fs *Files) ensureDir(call goja.FunctionCall) goja.Value {
+	if len(call.Arguments) < 1 {
+		panic(fs.vm.NewTypeError("ensureDir requires a path"))
+	}
+
+	path := call.Arguments[0].String()
+
+	var callback goja.Callable
+	var ok bool
+	if len(call.Arguments) > 1 {
+		callback, ok = goja.AssertFunction(call.Arguments[1])
+	}
+	return fs.dispatch("files.ensureDir", callback, ok, nil, func(ctx context.Context) (any, string) {
+		return nil, fs.doEnsureDir(ctx, path)
+	})
+}
+
+// pathArgs reads the leading path argument and an optional trailing callback.
+func (fs *Files) pathArgs(call goja.FunctionCall, name string) (string, goja.Callable, bool) {
+	if len(call.Arguments) < 1 {
+		panic(fs.vm.NewTypeError(name + " requires a path"))
+	}
+
+	callback, ok := goja.AssertFunction(call.Arguments[len(call.Arguments)-1])
+	return call.Arguments[0].String(), callback, ok
+}
+
+// doStat returns metadata for path. Unlike exists, a missing path is an error.
+func (fs *Files) doStat(ctx context.Context, path string) (any, string) {
+	mgr := permissions.GetManager()
+	canRead := permissions.PermissionRead
+	if !mgr.CheckWithPrompt(ctx, canRead, path) {
+		errMsg := mgr.ErrorMessage(canRead, path)
+		return nil, errMsg
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err.Error()
+	}
+
+	// the Date and result object are built on the VM goroutine
+	return jsValue(func() (goja.Value, error) {
+		mtime, err := fs.vm.New(fs.vm.Get("Date"), fs.vm.ToValue(info.ModTime().UnixMilli()))
+		if err != nil {
+			return nil, err
 		}
 
-    done := fs.runtime.KeepAlive()
-    go func() {
-      defer done()
-      ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-      defer cancel()
+		stat := fs.vm.NewObject()
+		stat.Set("name", info.Name())
+		stat.Set("size", info.Size())
+		stat.Set("mode", int64(info.Mode().Perm()))
+		stat.Set("isFile", info.Mode().IsRegular())
+		stat.Set("isDirectory", info.IsDir())
+		stat.Set("isSymlink", info.Mode()&os.ModeSymlink != 0)
+		stat.Set("mtime", mtime)
+		return stat, nil
+	}), ""
+}
 
-      errArg := fs.doRm(ctx, path)
+// stat reports file metadata: { name, size, mode, isFile, isDirectory, isSymlink, mtime }.
+//
+// JavaScript usage:
+//
+//	files.stat('./data.json', (err, info) => { ... });
+//	const info = await files.stat('./data.json');
+func (fs *Files) stat(call goja.FunctionCall) goja.Value {
+	path, callback, ok := fs.pathArgs(call, "stat")
 
-      if errArg != goja.Null() && !goja.IsNull(errArg) {
-        promise.reject(errArg)
-      } else {
-        promise.resolve(goja.Null())
-      }
-		}()
+	return fs.dispatch("files.stat", callback, ok, nil, func(ctx context.Context) (any, string) {
+		return fs.doStat(ctx, path)
+	})
+}
 
-		return CreatePromiseObject(fs.vm, promise)
+// doReaddir lists the entry names of a directory in sorted order.
+func (fs *Files) doReaddir(ctx context.Context, path string) (any, string) {
+	mgr := permissions.GetManager()
+	canRead := permissions.PermissionRead
+	if !mgr.CheckWithPrompt(ctx, canRead, path) {
+		errMsg := mgr.ErrorMessage(canRead, path)
+		return nil, errMsg
 	}
 
-  done := fs.runtime.KeepAlive()
-  go func() {
-    defer done()
-    ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-    defer cancel()
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err.Error()
+	}
 
-    errArg := fs.doRm(ctx, path)
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name()
+	}
 
-    callback(goja.Undefined(), errArg)
+	return names, ""
+}
+
+// readdir lists a directory without needing the trailing slash files.read uses.
+//
+// JavaScript usage:
+//
+//	const names = await files.readdir('./src');
+func (fs *Files) readdir(call goja.FunctionCall) goja.Value {
+	path, callback, ok := fs.pathArgs(call, "readdir")
+
+	return fs.dispatch("files.readdir", callback, ok, nil, func(ctx context.Context) (any, string) {
+		return fs.doReaddir(ctx, path)
+	})
+}
+
+// doMkdir creates a single directory, or the whole tree when recursive.
+func (fs *Files) doMkdir(ctx context.Context, path string, recursive bool) string {
+	if recursive {
+		return fs.doEnsureDir(ctx, path)
+	}
+
+	mgr := permissions.GetManager()
+	canWrite := permissions.PermissionWrite
+	if !mgr.CheckWithPrompt(ctx, canWrite, path) {
+		errMsg := mgr.ErrorMessage(canWrite, path)
+		return errMsg
+	}
+
+	if err := os.Mkdir(path, 0755); err != nil {
+		return err.Error()
+	}
+
+	return ""
+}
+
+// mkdir creates a directory. It fails if the directory exists or its parent is
+// missing unless { recursive: true } is passed (see also ensureDir).
+//
+// JavaScript usage:
+//
+//	await files.mkdir('./out');
+//	files.mkdir('./out/a/b', { recursive: true }, (err) => { ... });
+func (fs *Files) mkdir(call goja.FunctionCall) goja.Value {
+	path, callback, ok := fs.pathArgs(call, "mkdir")
+
+	recursive := false
+	if len(call.Arguments) > 1 {
+		if _, isFn := goja.AssertFunction(call.Arguments[1]); !isFn {
+			if opts, isObj := call.Arguments[1].(*goja.Object); isObj {
+				if v := opts.Get("recursive"); v != nil {
+					recursive = v.ToBoolean()
+				}
+			}
+		}
+	}
+
+	return fs.dispatch("files.mkdir", callback, ok, nil, func(ctx context.Context) (any, string) {
+		return nil, fs.doMkdir(ctx, path, recursive)
+	})
+}
+
+// Default polling settings for watchDir
+const (
+	defaultWatchInterval = 100 * time.Millisecond
+	defaultWatchDebounce = 50 * time.Millisecond
+)
+
+// fileState is the part of a file's metadata watchDir compares between polls
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// watchEvent is a change found by watchDir, made into a JS event on the loop.
+type watchEvent struct {
+	kind string // "create", "modify" or "delete"
+	path string
+}
+
+// snapshotDir records every file under root (only direct children unless recursive)
+func snapshotDir(root string, recursive bool) map[string]fileState {
+	snapshot := make(map[string]fileState)
+
+	filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil // files can vanish mid-walk
+		}
+		if d.IsDir() {
+			if path != root && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info, err := d.Info(); err == nil {
+			snapshot[path] = fileState{modTime: info.ModTime(), size: info.Size()}
+		}
+		return nil
+	})
+
+	return snapshot
+}
+
+// diffSnapshots folds the changes between two snapshots into pending,
+// coalescing repeated events for the same path (e.g. create+modify = create).
+func diffSnapshots(prev, next map[string]fileState, pending map[string]string) bool {
+	changed := false
+
+	record := func(path, event string) {
+		changed = true
+
+		switch prior := pending[path]; {
+		case prior == "create" && event == "modify":
+			// still a new file as far as the callback is concerned
+		case prior == "create" && event == "delete":
+			delete(pending, path)
+		case prior == "delete" && event == "create":
+			pending[path] = "modify"
+		default:
+			pending[path] = event
+		}
+	}
+
+	for path, state := range next {
+		old, ok := prev[path]
+		if !ok {
+			record(path, "create")
+		} else if !old.modTime.Equal(state.modTime) || old.size != state.size {
+			record(path, "modify")
+		}
+	}
+	for path := range prev {
+		if _, ok := next[path]; !ok {
+			record(path, "delete")
+		}
+	}
+
+	return changed
+}
+
+// watchDir polls a directory tree and reports create/modify/delete events.
+// Rapid changes are coalesced until the tree has been quiet for the debounce
+// window. The watcher keeps the runtime alive until close() is called.
+//
+// JavaScript usage:
+//
+//	const watcher = files.watchDir('./src', { recursive: true }, (err, event) => {
+//	  console.log(event.type, event.path); // 'create' | 'modify' | 'delete'
+//	});
+//	watcher.close();
+func (fs *Files) watchDir(call goja.FunctionCall) goja.Value {
+	if len(call.Arguments) < 2 {
+		panic(fs.vm.NewTypeError("watchDir requires a directory path and callback"))
+	}
+
+	root := filepath.Clean(call.Arguments[0].String())
+	recursive := false
+	interval := defaultWatchInterval
+	debounce := defaultWatchDebounce
+
+	cbArg := call.Arguments[1]
+	if len(call.Arguments) > 2 {
+		cbArg = call.Arguments[2]
+		if opts := call.Arguments[1]; !goja.IsUndefined(opts) && !goja.IsNull(opts) {
+			o := opts.ToObject(fs.vm)
+			if v := o.Get("recursive"); v != nil {
+				recursive = v.ToBoolean()
+			}
+			if v := o.Get("interval"); v != nil && !goja.IsUndefined(v) {
+				interval = time.Duration(v.ToInteger()) * time.Millisecond
+			}
+			if v := o.Get("debounce"); v != nil && !goja.IsUndefined(v) {
+				debounce = time.Duration(v.ToInteger()) * time.Millisecond
+			}
+		}
+	}
+
+	callback, ok := goja.AssertFunction(cbArg)
+	if !ok {
+		panic(fs.vm.NewTypeError("watchDir callback must be a function"))
+	}
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+
+	stopped := func() bool {
+		select {
+		case <-stop:
+			return true
+		default:
+			return false
+		}
+	}
+
+	watcher := fs.vm.NewObject()
+	watcher.Set("close", func(call goja.FunctionCall) goja.Value {
+		stopOnce.Do(func() { close(stop) })
+		return goja.Undefined()
+	})
+
+	// deliver hands a debounced batch to the callback on the event loop,
+	// unless the watcher is closed by then
+	deliver := func(batch []watchEvent) {
+		fs.schedule("files.watchDir", func() {
+			for _, e := range batch {
+				if stopped() {
+					return
+				}
+				event := fs.vm.NewObject()
+				event.Set("type", e.kind)
+				event.Set("path", e.path)
+				callback(goja.Undefined(), goja.Null(), event)
+			}
+		})
+	}
+	fail := func(msg string) {
+		fs.schedule("files.watchDir", func() {
+			if !stopped() {
+				callback(goja.Undefined(), fs.vm.ToValue(msg), goja.Undefined())
+			}
+		})
+	}
+
+	done := fs.runtime.KeepAlive()
+	go func() {
+		defer done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		mgr := permissions.GetManager()
+		allowed := mgr.CheckWithPrompt(ctx, permissions.PermissionRead, root)
+		cancel()
+
+		if !allowed {
+			fail(mgr.ErrorMessage(permissions.PermissionRead, root))
+			return
+		}
+
+		if info, err := os.Stat(root); err != nil || !info.IsDir() {
+			msg := root + " is not a directory"
+			if err != nil {
+				msg = err.Error()
+			}
+			fail(msg)
+			return
+		}
+
+		prev := snapshotDir(root, recursive)
+		pending := make(map[string]string)
+		var lastChange time.Time
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+
+			next := snapshotDir(root, recursive)
+			if diffSnapshots(prev, next, pending) {
+				lastChange = time.Now()
+			}
+			prev = next
+
+			if len(pending) == 0 || time.Since(lastChange) < debounce {
+				continue
+			}
+
+			paths := make([]string, 0, len(pending))
+			for path := range pending {
+				paths = append(paths, path)
+			}
+			sort.Strings(paths)
+
+			batch := make([]watchEvent, len(paths))
+			for i, path := range paths {
+				batch[i] = watchEvent{kind: pending[path], path: path}
+			}
+			deliver(batch)
+			pending = make(map[string]string)
+		}
 	}()
 
-	return goja.Undefined()
+	return watcher
 }
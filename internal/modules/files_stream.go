@@ -0,0 +1,489 @@
+package modules
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"github.com/douglasjordan2/dougless/internal/permissions"
+)
+
+// defaultHighWaterMark is the chunk size of read streams, matching Node's fs streams.
+const defaultHighWaterMark = 64 * 1024
+
+// streamListener is a handler registered with on() or once().
+type streamListener struct {
+	fn       goja.Value
+	callable goja.Callable
+	once     bool
+}
+
+// streamEmitter is the small on/once/off event surface shared by file streams.
+// It is only touched from the VM goroutine.
+type streamEmitter struct {
+	vm        *goja.Runtime
+	obj       *goja.Object
+	listeners map[string][]streamListener
+	onListen  func(name string) // notified when a listener is added
+	errOut    io.Writer         // where unhandled and listener errors go
+}
+
+func newStreamEmitter(vm *goja.Runtime, errOut io.Writer, onListen func(name string)) *streamEmitter {
+	e := &streamEmitter{
+		vm:        vm,
+		obj:       vm.NewObject(),
+		listeners: make(map[string][]streamListener),
+		onListen:  onListen,
+		errOut:    errOut,
+	}
+
+	add := func(once bool) func(goja.FunctionCall) goja.Value {
+		return func(call goja.FunctionCall) goja.Value {
+			name := call.Argument(0).String()
+			callable, ok := goja.AssertFunction(call.Argument(1))
+			if !ok {
+				panic(vm.NewTypeError("The \"listener\" argument must be a function"))
+			}
+			e.listeners[name] = append(e.listeners[name], streamListener{fn: call.Argument(1), callable: callable, once: once})
+			if e.onListen != nil {
+				e.onListen(name)
+			}
+			return e.obj
+		}
+	}
+	off := func(call goja.FunctionCall) goja.Value {
+		name := call.Argument(0).String()
+		list := e.listeners[name]
+		for i := len(list) - 1; i >= 0; i-- {
+			if list[i].fn.StrictEquals(call.Argument(1)) {
+				e.listeners[name] = append(list[:i:i], list[i+1:]...)
+				break
+			}
+		}
+		return e.obj
+	}
+
+	e.obj.Set("on", add(false))
+	e.obj.Set("once", add(true))
+	e.obj.Set("off", off)
+	e.obj.Set("removeListener", off)
+
+	return e
+}
+
+// emit calls the listeners for an event. An 'error' nobody listens for is
+// printed to the error output rather than lost.
+func (e *streamEmitter) emit(name string, args ...goja.Value) {
+	list := e.listeners[name]
+	if len(list) == 0 && name == "error" && len(args) > 0 {
+		fmt.Fprintf(e.errOut, "Unhandled stream error: %s\n", args[0].String())
+		return
+	}
+
+	kept := list[:0:0]
+	for _, l := range list {
+		if !l.once {
+			kept = append(kept, l)
+		}
+	}
+	e.listeners[name] = kept
+
+	for _, l := range list {
+		if _, err := l.callable(e.obj, args...); err != nil {
+			fmt.Fprintf(e.errOut, "Error in stream '%s' listener: %v\n", name, err)
+		}
+	}
+}
+
+// createReadStream opens a file for chunked reading. The file is opened right
+// away (so 'error' fires for missing files), but data only starts flowing once
+// a 'data' listener is attached or the stream is piped; an idle stream does
+// not keep the runtime alive.
+// Each chunk is delivered on the event loop before the next one is read, so a
+// slow consumer throttles the reader.
+//
+// JavaScript usage:
+//
+//	const src = files.createReadStream('in.log', { encoding: 'utf8', highWaterMark: 16384 });
+//	src.on('data', (chunk) => console.log(chunk.length));
+//	src.on('end', () => console.log('done'));
+//	src.on('error', (err) => console.error(err));
+//
+//	files.createReadStream('in.log').pipe(files.createWriteStream('out.log'));
+func (fs *Files) createReadStream(call goja.FunctionCall) goja.Value {
+	if len(call.Arguments) < 1 {
+		panic(fs.vm.NewTypeError("createReadStream requires a file path"))
+	}
+	path := call.Arguments[0].String()
+
+	highWaterMark := defaultHighWaterMark
+	asString := false
+	if opts, ok := call.Argument(1).(*goja.Object); ok {
+		if v := opts.Get("highWaterMark"); v != nil && !goja.IsUndefined(v) {
+			highWaterMark = int(v.ToInteger())
+			if highWaterMark <= 0 {
+				panic(fs.vm.NewTypeError("highWaterMark must be a positive number"))
+			}
+		}
+		if v := opts.Get("encoding"); v != nil && !goja.IsUndefined(v) && !goja.IsNull(v) {
+			switch enc := strings.ToLower(v.String()); enc {
+			case "utf8", "utf-8":
+				asString = true
+			default:
+				panic(fs.vm.NewTypeError("Unsupported stream encoding: " + enc))
+			}
+		}
+	}
+
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	flowing := make(chan struct{})
+	var release func() // keeps the runtime alive while data flows
+	started := false
+
+	start := func() {
+		if started {
+			return
+		}
+		started = true
+		release = fs.runtime.KeepAlive()
+		close(flowing)
+	}
+
+	emitter := newStreamEmitter(fs.vm, fs.errorOutput(), func(name string) {
+		if name == "data" {
+			start()
+		}
+	})
+	stream := emitter.obj
+	stream.Set("path", path)
+
+	opening := fs.runtime.KeepAlive()
+	go func() {
+		f := fs.openReadStream(emitter, path)
+		opening()
+		if f == nil {
+			return
+		}
+		defer f.Close()
+
+		select {
+		case <-flowing:
+			defer release()
+		case <-stop:
+			select {
+			case <-flowing:
+				release()
+			default:
+			}
+			fs.schedule("file stream close", func() { emitter.emit("close") })
+			return
+		}
+
+		fs.pumpReadStream(emitter, f, highWaterMark, asString, stop)
+	}()
+
+	// pipe(dest) forwards every chunk to dest.write() and calls dest.end() at EOF
+	stream.Set("pipe", func(call goja.FunctionCall) goja.Value {
+		dest, ok := call.Argument(0).(*goja.Object)
+		if !ok {
+			panic(fs.vm.NewTypeError("pipe requires a writable stream"))
+		}
+		write, okWrite := goja.AssertFunction(dest.Get("write"))
+		end, okEnd := goja.AssertFunction(dest.Get("end"))
+		if !okWrite || !okEnd {
+			panic(fs.vm.NewTypeError("pipe destination must have write() and end() methods"))
+		}
+
+		emitter.listeners["data"] = append(emitter.listeners["data"], streamListener{
+			fn: goja.Undefined(),
+			callable: func(this goja.Value, args ...goja.Value) (goja.Value, error) {
+				return write(dest, args...)
+			},
+		})
+		emitter.listeners["end"] = append(emitter.listeners["end"], streamListener{
+			fn:   goja.Undefined(),
+			once: true,
+			callable: func(this goja.Value, args ...goja.Value) (goja.Value, error) {
+				return end(dest)
+			},
+		})
+		start()
+
+		return dest
+	})
+
+	// destroy() stops reading; 'close' is emitted once the file is released
+	stream.Set("destroy", func(call goja.FunctionCall) goja.Value {
+		stopOnce.Do(func() { close(stop) })
+		return stream
+	})
+
+	return stream
+}
+
+// failStream emits 'error' followed by 'close' on the event loop.
+func (fs *Files) failStream(emitter *streamEmitter, msg string) {
+	fs.schedule("file stream error", func() {
+		emitter.emit("error", fs.vm.ToValue(msg))
+		emitter.emit("close")
+	})
+}
+
+// openReadStream checks read permission and opens path, reporting failures
+// on the stream. It returns nil when the file could not be opened.
+func (fs *Files) openReadStream(emitter *streamEmitter, path string) *os.File {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	mgr := permissions.GetManager()
+	allowed := mgr.CheckWithPrompt(ctx, permissions.PermissionRead, path)
+	cancel()
+	if !allowed {
+		fs.failStream(emitter, mgr.ErrorMessage(permissions.PermissionRead, path))
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		fs.failStream(emitter, err.Error())
+		return nil
+	}
+	return f
+}
+
+// pumpReadStream reads f in chunks on a background goroutine and emits each
+// one on the event loop, waiting for delivery before reading on.
+func (fs *Files) pumpReadStream(emitter *streamEmitter, f *os.File, size int, asString bool, stop chan struct{}) {
+	// deliver runs fn on the loop and waits for it (or for destroy)
+	deliver := func(name string, fn func()) {
+		delivered := make(chan struct{})
+		fs.schedule(name, func() {
+			defer close(delivered)
+			fn()
+		})
+		select {
+		case <-delivered:
+		case <-stop:
+		}
+	}
+
+	buf := make([]byte, size)
+	for {
+		select {
+		case <-stop:
+			fs.schedule("file stream close", func() { emitter.emit("close") })
+			return
+		default:
+		}
+
+		n, err := f.Read(buf)
+		if n > 0 {
+			chunk := append([]byte(nil), buf[:n]...)
+			deliver("file stream data", func() {
+				if asString {
+					emitter.emit("data", fs.vm.ToValue(string(chunk)))
+				} else {
+					emitter.emit("data", newUint8Array(fs.vm, chunk))
+				}
+			})
+		}
+
+		if err == io.EOF {
+			deliver("file stream end", func() {
+				emitter.emit("end")
+				emitter.emit("close")
+			})
+			return
+		}
+		if err != nil {
+			fs.failStream(emitter, err.Error())
+			return
+		}
+	}
+}
+
+// writeOp is one queued write() or end() call on a write stream.
+type writeOp struct {
+	data     []byte
+	end      bool
+	callback goja.Callable
+}
+
+// createWriteStream opens a file for sequential writes. Writes are queued and
+// performed in order on a background goroutine; callbacks and events run on
+// the event loop.
+//
+// JavaScript usage:
+//
+//	const out = files.createWriteStream('out.log', { flags: 'a' }); // 'w' (default) truncates
+//	out.write('line 1\n');
+//	out.end('last line\n', () => console.log('flushed'));
+//	out.on('finish', () => console.log('all data written'));
+func (fs *Files) createWriteStream(call goja.FunctionCall) goja.Value {
+	if len(call.Arguments) < 1 {
+		panic(fs.vm.NewTypeError("createWriteStream requires a file path"))
+	}
+	path := call.Arguments[0].String()
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if opts, ok := call.Argument(1).(*goja.Object); ok {
+		if v := opts.Get("flags"); v != nil && !goja.IsUndefined(v) {
+			switch f := v.String(); f {
+			case "w":
+			case "a":
+				flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+			default:
+				panic(fs.vm.NewTypeError("Unsupported stream flags: " + f))
+			}
+		}
+	}
+
+	emitter := newStreamEmitter(fs.vm, fs.errorOutput(), nil)
+	stream := emitter.obj
+	stream.Set("path", path)
+
+	var (
+		mu      sync.Mutex
+		queue   []writeOp
+		running bool
+		ended   bool
+	)
+
+	// run drains the queue in order; it exits when the queue is empty and is
+	// restarted by the next write, so an unended stream doesn't pin the runtime
+	var file *os.File
+	var openErr string
+	opened := false
+	run := func() {
+		for {
+			mu.Lock()
+			if len(queue) == 0 {
+				running = false
+				mu.Unlock()
+				return
+			}
+			op := queue[0]
+			queue = queue[1:]
+			mu.Unlock()
+
+			if !opened {
+				opened = true
+				ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+				mgr := permissions.GetManager()
+				if mgr.CheckWithPrompt(ctx, permissions.PermissionWrite, path) {
+					var err error
+					if file, err = os.OpenFile(path, flags, 0644); err != nil {
+						openErr = err.Error()
+					}
+				} else {
+					openErr = mgr.ErrorMessage(permissions.PermissionWrite, path)
+				}
+				cancel()
+
+				if openErr != "" {
+					msg := openErr
+					fs.schedule("file stream error", func() { emitter.emit("error", fs.vm.ToValue(msg)) })
+				}
+			}
+
+			errMsg := openErr
+			if errMsg == "" && len(op.data) > 0 {
+				if _, err := file.Write(op.data); err != nil {
+					errMsg = err.Error()
+					fs.schedule("file stream error", func() { emitter.emit("error", fs.vm.ToValue(errMsg)) })
+				}
+			}
+
+			if op.end {
+				if file != nil {
+					file.Close()
+				}
+				fs.schedule("file stream finish", func() {
+					if errMsg == "" {
+						emitter.emit("finish")
+					}
+					if op.callback != nil {
+						op.callback(goja.Undefined())
+					}
+					emitter.emit("close")
+				})
+				continue
+			}
+
+			if op.callback != nil {
+				fs.schedule("file stream write", func() {
+					arg := goja.Null()
+					if errMsg != "" {
+						arg = fs.vm.ToValue(errMsg)
+					}
+					op.callback(goja.Undefined(), arg)
+				})
+			}
+		}
+	}
+
+	enqueue := func(op writeOp) {
+		mu.Lock()
+		queue = append(queue, op)
+		startWorker := !running
+		running = true
+		mu.Unlock()
+
+		if startWorker {
+			done := fs.runtime.KeepAlive()
+			go func() {
+				defer done()
+				run()
+			}()
+		}
+	}
+
+	// chunkArgs splits (chunk, [encoding], [callback]) arguments
+	chunkArgs := func(args []goja.Value) ([]byte, goja.Callable) {
+		var data []byte
+		var callback goja.Callable
+		for i, arg := range args {
+			if fn, ok := goja.AssertFunction(arg); ok {
+				callback = fn
+				break
+			}
+			if i == 0 && !goja.IsUndefined(arg) && !goja.IsNull(arg) {
+				if s, ok := arg.Export().(string); ok {
+					data = []byte(s)
+				} else {
+					data = append([]byte(nil), bytesOf(fs.vm, arg)...)
+				}
+			}
+		}
+		return data, callback
+	}
+
+	stream.Set("write", func(call goja.FunctionCall) goja.Value {
+		data, callback := chunkArgs(call.Arguments)
+		if ended {
+			fs.schedule("file stream error", func() {
+				emitter.emit("error", fs.vm.ToValue("write after end"))
+			})
+			return fs.vm.ToValue(false)
+		}
+		enqueue(writeOp{data: data, callback: callback})
+		return fs.vm.ToValue(true)
+	})
+
+	stream.Set("end", func(call goja.FunctionCall) goja.Value {
+		if ended {
+			return stream
+		}
+		ended = true
+		data, callback := chunkArgs(call.Arguments)
+		enqueue(writeOp{data: data, end: true, callback: callback})
+		return stream
+	})
+
+	return stream
+}
@@ -0,0 +1,253 @@
+package modules
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"github.com/douglasjordan2/dougless/internal/permissions"
+)
+
+// defaultTailLines is how many existing lines tail delivers by default, as
+// with tail(1).
+const defaultTailLines = 10
+
+// tail reads the last lines of a file and, with follow, keeps delivering
+// lines as they are appended, like tail -f. The file is polled for size and
+// modification time changes; a file that shrinks is assumed truncated and is
+// read again from the start. Lines are delivered on the event loop, without
+// their line ending, and a following tail keeps the runtime alive until
+// close() is called.
+//
+// JavaScript usage:
+//
+//	const t = files.tail('app.log', { lines: 20, follow: true }, (err, line) => {
+//	  if (err) return console.error(err);
+//	  console.log(line);
+//	});
+//	t.close();
+//
+// Options: lines (default 10), follow (default false) and interval, the
+// polling interval in milliseconds (default 100).
+func (fs *Files) tail(call goja.FunctionCall) goja.Value {
+	if len(call.Arguments) < 2 {
+		panic(fs.vm.NewTypeError("tail requires a file path and callback"))
+	}
+
+	path := call.Arguments[0].String()
+	lines := defaultTailLines
+	follow := false
+	interval := defaultWatchInterval
+
+	cbArg := call.Arguments[1]
+	if len(call.Arguments) > 2 {
+		cbArg = call.Arguments[2]
+		if opts := call.Arguments[1]; !goja.IsUndefined(opts) && !goja.IsNull(opts) {
+			o := opts.ToObject(fs.vm)
+			if v := o.Get("lines"); v != nil && !goja.IsUndefined(v) {
+				lines = int(v.ToInteger())
+			}
+			if v := o.Get("follow"); v != nil {
+				follow = v.ToBoolean()
+			}
+			if v := o.Get("interval"); v != nil && !goja.IsUndefined(v) {
+				interval = time.Duration(v.ToInteger()) * time.Millisecond
+			}
+		}
+	}
+
+	callback, ok := goja.AssertFunction(cbArg)
+	if !ok {
+		panic(fs.vm.NewTypeError("tail callback must be a function"))
+	}
+	if lines < 0 {
+		panic(fs.vm.NewTypeError("tail lines must not be negative"))
+	}
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	stopped := func() bool {
+		select {
+		case <-stop:
+			return true
+		default:
+			return false
+		}
+	}
+
+	handle := fs.vm.NewObject()
+	handle.Set("close", func(call goja.FunctionCall) goja.Value {
+		stopOnce.Do(func() { close(stop) })
+		return goja.Undefined()
+	})
+
+	// deliver hands lines to the callback on the event loop, unless the tail
+	// is closed by then
+	deliver := func(batch []string) {
+		if len(batch) == 0 {
+			return
+		}
+		fs.schedule("files.tail", func() {
+			for _, line := range batch {
+				if stopped() {
+					return
+				}
+				if _, err := callback(goja.Undefined(), goja.Null(), fs.vm.ToValue(line)); err != nil {
+					fmt.Fprintf(fs.errorOutput(), "Error in files.tail callback: %v\n", err)
+				}
+			}
+		})
+	}
+	fail := func(msg string) {
+		fs.schedule("files.tail", func() {
+			if !stopped() {
+				callback(goja.Undefined(), fs.vm.ToValue(msg), goja.Undefined())
+			}
+		})
+	}
+
+	done := fs.runtime.KeepAlive()
+	go func() {
+		defer done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		mgr := permissions.GetManager()
+		allowed := mgr.CheckWithPrompt(ctx, permissions.PermissionRead, path)
+		cancel()
+
+		if !allowed {
+			fail(mgr.ErrorMessage(permissions.PermissionRead, path))
+			return
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			fail(err.Error())
+			return
+		}
+		defer f.Close()
+
+		last, offset, err := lastLines(f, lines)
+		if err != nil {
+			fail(err.Error())
+			return
+		}
+		deliver(last)
+		if !follow {
+			return
+		}
+
+		var partial []byte // an appended line still waiting for its newline
+		var modTime time.Time
+		if info, err := f.Stat(); err == nil {
+			modTime = info.ModTime()
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+
+			info, err := os.Stat(path)
+			if err != nil {
+				continue // being rotated or recreated; try again next poll
+			}
+			if info.Size() == offset && info.ModTime().Equal(modTime) {
+				continue
+			}
+			modTime = info.ModTime()
+			if info.Size() < offset {
+				offset, partial = 0, nil // truncated
+			}
+
+			data := make([]byte, info.Size()-offset)
+			n, err := f.ReadAt(data, offset)
+			if err != nil && err != io.EOF {
+				fail(err.Error())
+				return
+			}
+			offset += int64(n)
+
+			var batch []string
+			batch, partial = splitLines(append(partial, data[:n]...))
+			deliver(batch)
+		}
+	}()
+
+	return handle
+}
+
+// lastLines returns the last n complete or trailing lines of f and the
+// offset of its end, reading backwards so large files aren't read whole.
+func lastLines(f *os.File, n int) ([]string, int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	end := info.Size()
+	if n == 0 || end == 0 {
+		return nil, end, nil
+	}
+
+	const blockSize = 4096
+	var buf []byte
+	pos := end
+	for pos > 0 {
+		size := int64(blockSize)
+		if pos < size {
+			size = pos
+		}
+		pos -= size
+
+		block := make([]byte, size)
+		if _, err := f.ReadAt(block, pos); err != nil && err != io.EOF {
+			return nil, 0, err
+		}
+		buf = append(block, buf...)
+
+		// one more newline than lines wanted, not counting a trailing one
+		if bytes.Count(bytes.TrimSuffix(buf, []byte("\n")), []byte("\n")) >= n {
+			break
+		}
+	}
+
+	all := strings.Split(strings.TrimSuffix(string(buf), "\n"), "\n")
+	if pos > 0 {
+		all = all[1:] // the first line may have been cut by the block boundary
+	}
+	if len(all) > n {
+		all = all[len(all)-n:]
+	}
+	for i, line := range all {
+		all[i] = strings.TrimSuffix(line, "\r")
+	}
+	return all, end, nil
+}
+
+// splitLines splits data into complete lines, returning whatever follows the
+// last newline as the remainder.
+func splitLines(data []byte) (lines []string, rest []byte) {
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			return lines, data
+		}
+		lines = append(lines, strings.TrimSuffix(string(data[:i]), "\r"))
+		data = data[i+1:]
+	}
+}
@@ -0,0 +1,14 @@
+package modules
+
+// Handle describes an open resource that keeps the runtime alive, as
+// reported by process.getActiveHandles().
+type Handle struct {
+	Type    string         // "timeout", "interval", "cron", "server" or "websocket"
+	Details map[string]any // Type-specific fields such as delay or address
+}
+
+// HandleSource is implemented by modules that own long-lived resources
+// (timers, servers, sockets) so they can be listed when debugging hangs.
+type HandleSource interface {
+	ActiveHandles() []Handle
+}
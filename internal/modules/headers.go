@@ -0,0 +1,241 @@
+package modules
+
+import (
+	netHttp "net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dop251/goja"
+	"golang.org/x/net/http/httpguts"
+)
+
+// headersKey is the hidden property linking a JS Headers object to its Go state.
+const headersKey = "_headers"
+
+// Headers provides the fetch-style Headers global. Names are matched
+// case-insensitively and iterate lowercased, in sorted order.
+//
+// Available globally in JavaScript as:
+//
+//	const headers = new Headers({ 'Content-Type': 'text/plain' });
+//	headers.append('Accept', 'text/html');
+//	headers.append('Accept', 'application/json');
+//	headers.get('accept'); // 'text/html, application/json'
+//	for (const [name, value] of headers) { ... }
+//
+//	http.get(url, { headers }); // accepted wherever a headers object is
+//
+// Response headers (res.headers) are Headers too.
+type Headers struct {
+	vm *goja.Runtime
+}
+
+// NewHeaders creates a new Headers module instance.
+func NewHeaders() *Headers {
+	return &Headers{}
+}
+
+// Export returns an object holding the Headers constructor.
+func (h *Headers) Export(vm *goja.Runtime) goja.Value {
+	h.vm = vm
+	obj := vm.NewObject()
+
+	obj.Set("Headers", func(call goja.ConstructorCall) *goja.Object {
+		header := netHttp.Header{}
+		if init := call.Argument(0); !goja.IsUndefined(init) && !goja.IsNull(init) {
+			fillHeaders(vm, header, init)
+		}
+		initHeadersObject(vm, call.This, header)
+		return nil
+	})
+
+	return obj
+}
+
+// newHeadersObject returns a Headers object backed by header, which it
+// takes ownership of. It must be called on the VM goroutine.
+func newHeadersObject(vm *goja.Runtime, header netHttp.Header) *goja.Object {
+	obj := vm.NewObject()
+	if ctor, ok := vm.Get("Headers").(*goja.Object); ok {
+		if proto, ok := ctor.Get("prototype").(*goja.Object); ok {
+			obj.SetPrototype(proto) // so instanceof Headers holds
+		}
+	}
+	initHeadersObject(vm, obj, header)
+	return obj
+}
+
+// headersFromValue returns the Go state of a Headers object, or nil if v
+// isn't one.
+func headersFromValue(v goja.Value) netHttp.Header {
+	obj, ok := v.(*goja.Object)
+	if !ok {
+		return nil
+	}
+	hidden := obj.Get(headersKey)
+	if hidden == nil {
+		return nil
+	}
+	header, _ := hidden.Export().(netHttp.Header)
+	return header
+}
+
+// fillHeaders appends the entries of init - a Headers object, an array of
+// [name, value] pairs or a plain object - to header.
+func fillHeaders(vm *goja.Runtime, header netHttp.Header, init goja.Value) {
+	if other := headersFromValue(init); other != nil {
+		for name, values := range other {
+			for _, value := range values {
+				header.Add(name, value)
+			}
+		}
+		return
+	}
+
+	initObj := init.ToObject(vm)
+	if _, isArray := init.Export().([]any); isArray {
+		length := int(initObj.Get("length").ToInteger())
+		for i := 0; i < length; i++ {
+			pair, ok := initObj.Get(strconv.Itoa(i)).(*goja.Object)
+			if !ok || pair.Get("length").ToInteger() != 2 {
+				panic(vm.NewTypeError("Headers init pairs must be [name, value] arrays"))
+			}
+			addHeader(vm, header, pair.Get("0").String(), pair.Get("1").String())
+		}
+		return
+	}
+
+	for _, name := range initObj.Keys() {
+		addHeader(vm, header, name, initObj.Get(name).String())
+	}
+}
+
+// addHeader appends a validated header, throwing a TypeError for an
+// invalid name or value.
+func addHeader(vm *goja.Runtime, header netHttp.Header, name, value string) {
+	name, value = checkHeader(vm, name, value)
+	header.Add(name, value)
+}
+
+// checkHeader validates a header name and normalizes its value.
+func checkHeader(vm *goja.Runtime, name, value string) (string, string) {
+	if !httpguts.ValidHeaderFieldName(name) {
+		panic(vm.NewTypeError("Invalid header name: '" + name + "'"))
+	}
+	value = strings.Trim(value, " \t\r\n")
+	if !httpguts.ValidHeaderFieldValue(value) {
+		panic(vm.NewTypeError("Invalid value for header '" + name + "'"))
+	}
+	return name, value
+}
+
+// headerEntries lists header as [name, value] pairs for iteration: names
+// lowercased and sorted, values combined with ", " (Set-Cookie values stay
+// separate, as they can't be combined).
+func headerEntries(header netHttp.Header) [][2]string {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return strings.ToLower(names[i]) < strings.ToLower(names[j])
+	})
+
+	var entries [][2]string
+	for _, name := range names {
+		lower := strings.ToLower(name)
+		values := header[name]
+		if len(values) == 0 {
+			continue
+		}
+		if lower == "set-cookie" {
+			for _, value := range values {
+				entries = append(entries, [2]string{lower, value})
+			}
+			continue
+		}
+		entries = append(entries, [2]string{lower, strings.Join(values, ", ")})
+	}
+	return entries
+}
+
+// initHeadersObject adds the Headers methods to obj, backed by header.
+func initHeadersObject(vm *goja.Runtime, obj *goja.Object, header netHttp.Header) {
+	obj.DefineDataProperty(headersKey, vm.ToValue(header), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_FALSE)
+
+	method := func(name string, fn func(goja.FunctionCall) goja.Value) {
+		obj.DefineDataProperty(name, vm.ToValue(fn), goja.FLAG_TRUE, goja.FLAG_TRUE, goja.FLAG_FALSE)
+	}
+
+	// iterator returns an iterator over the entries mapped by pick
+	iterator := func(pick func(entry [2]string) any) goja.Value {
+		entries := headerEntries(header)
+		items := make([]any, len(entries))
+		for i, entry := range entries {
+			items[i] = pick(entry)
+		}
+		arr := vm.NewArray(items...)
+		values, _ := goja.AssertFunction(arr.Get("values"))
+		it, err := values(arr)
+		if err != nil {
+			panic(err)
+		}
+		return it
+	}
+	entries := func(goja.FunctionCall) goja.Value {
+		return iterator(func(e [2]string) any { return vm.NewArray(e[0], e[1]) })
+	}
+
+	method("get", func(call goja.FunctionCall) goja.Value {
+		values := header.Values(call.Argument(0).String())
+		if len(values) == 0 {
+			return goja.Null()
+		}
+		return vm.ToValue(strings.Join(values, ", "))
+	})
+	method("getSetCookie", func(goja.FunctionCall) goja.Value {
+		values := header.Values("Set-Cookie")
+		items := make([]any, len(values))
+		for i, v := range values {
+			items[i] = v
+		}
+		return vm.NewArray(items...)
+	})
+	method("has", func(call goja.FunctionCall) goja.Value {
+		return vm.ToValue(len(header.Values(call.Argument(0).String())) > 0)
+	})
+	method("set", func(call goja.FunctionCall) goja.Value {
+		name, value := checkHeader(vm, call.Argument(0).String(), call.Argument(1).String())
+		header.Set(name, value)
+		return goja.Undefined()
+	})
+	method("append", func(call goja.FunctionCall) goja.Value {
+		addHeader(vm, header, call.Argument(0).String(), call.Argument(1).String())
+		return goja.Undefined()
+	})
+	method("delete", func(call goja.FunctionCall) goja.Value {
+		header.Del(call.Argument(0).String())
+		return goja.Undefined()
+	})
+	method("forEach", func(call goja.FunctionCall) goja.Value {
+		fn, ok := goja.AssertFunction(call.Argument(0))
+		if !ok {
+			panic(vm.NewTypeError("Headers.forEach requires a callback function"))
+		}
+		for _, entry := range headerEntries(header) {
+			if _, err := fn(call.Argument(1), vm.ToValue(entry[1]), vm.ToValue(entry[0]), obj); err != nil {
+				panic(err)
+			}
+		}
+		return goja.Undefined()
+	})
+	method("entries", entries)
+	method("keys", func(goja.FunctionCall) goja.Value {
+		return iterator(func(e [2]string) any { return e[0] })
+	})
+	method("values", func(goja.FunctionCall) goja.Value {
+		return iterator(func(e [2]string) any { return e[1] })
+	})
+	obj.SetSymbol(goja.SymIterator, entries)
+}
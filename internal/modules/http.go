@@ -3,48 +3,274 @@ package modules
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
+	"mime/multipart"
 	"net"
 	netHttp "net/http"
+	netUrl "net/url"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/dop251/goja"
 	"github.com/gorilla/websocket"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"golang.org/x/net/netutil"
 
-	"github.com/douglasjordan2/dougless/internal/permissions"
+	"github.com/douglasjordan2/dougless/internal/event"
 	"github.com/douglasjordan2/dougless/internal/future"
+	"github.com/douglasjordan2/dougless/internal/permissions"
 )
 
+// DefaultUserAgent is sent with outbound requests unless overridden.
+const DefaultUserAgent = "Dougless/" + Version
+
 type HTTP struct {
 	vm        *goja.Runtime 
-  taskQueue chan func()
+  loop      *event.Loop // serializes VM work from network goroutines
   runtime   RuntimeKeepAlive
+  userAgent string      // default User-Agent for outbound requests
+  errOut    io.Writer   // Destination for server errors (stderr when nil)
+
+  handlesMu sync.Mutex
+  servers   map[*netHttp.Server]string  // listening servers -> address
+  sockets   map[*websocket.Conn]string  // open server-side websockets -> path
+  wsClients map[*websocket.Conn]string  // open http.connect websockets -> url
+
+  transportsMu sync.Mutex
+  transports   map[string]*netHttp.Transport // per localAddr, so connections are reused
 }
 
 func (http *HTTP) SetRuntime(rt RuntimeKeepAlive) {
   http.runtime = rt
 }
 
-func NewHTTP(vm *goja.Runtime) *HTTP {
-  h := &HTTP{
+// SetErrorOutput redirects server error reports (stderr by default).
+func (http *HTTP) SetErrorOutput(w io.Writer) {
+  http.errOut = w
+}
+
+// errorOutput is where server and close callback errors are reported.
+func (http *HTTP) errorOutput() io.Writer {
+  if http.errOut == nil {
+    return os.Stderr
+  }
+  return http.errOut
+}
+
+func NewHTTP(vm *goja.Runtime, loop *event.Loop) *HTTP {
+  return &HTTP{
     vm:        vm,
-    taskQueue: make(chan func(), 100),
+    loop:      loop,
+    userAgent: DefaultUserAgent,
+    servers:   make(map[*netHttp.Server]string),
+    sockets: make(map[*websocket.Conn]string),
+    wsClients: make(map[*websocket.Conn]string),
+    transports: make(map[string]*netHttp.Transport),
+  }
+}
+
+// ActiveHandles lists listening servers and open websocket connections
+// (server-side and http.connect).
+func (http *HTTP) ActiveHandles() []Handle {
+  http.handlesMu.Lock()
+  defer http.handlesMu.Unlock()
+
+  handles := make([]Handle, 0, len(http.servers)+len(http.sockets)+len(http.wsClients))
+  for _, addr := range http.servers {
+    handles = append(handles, Handle{Type: "server", Details: map[string]any{"address": addr}})
+  }
+  for conn, path := range http.sockets {
+    handles = append(handles, Handle{
+      Type:    "websocket",
+      Details: map[string]any{"path": path, "remoteAddress": conn.RemoteAddr().String()},
+    })
+  }
+  for conn, url := range http.wsClients {
+    handles = append(handles, Handle{
+      Type:    "websocket",
+      Details: map[string]any{"url": url, "remoteAddress": conn.RemoteAddr().String()},
+    })
+  }
+  return handles
+}
+
+// SetUserAgent changes the runtime-wide default User-Agent (--user-agent).
+func (http *HTTP) SetUserAgent(ua string) {
+  http.userAgent = ua
+}
+
+// DefaultMaxRedirects is how many redirects a request follows before failing.
+const DefaultMaxRedirects = 10
+
+// DefaultRequestTimeout bounds an outbound request, including reading its
+// response body, unless a timeoutMs option says otherwise.
+const DefaultRequestTimeout = 30 * time.Second
+
+// defaultShutdownGrace is how long server.close() lets in-flight requests
+// finish before dropping their connections.
+const defaultShutdownGrace = 30 * time.Second
+
+// defaultWSWriteTimeout is how long a websocket send may wait on a client
+// that isn't reading before the connection is dropped.
+const defaultWSWriteTimeout = 10 * time.Second
+
+// clientOptions holds per-client settings for outbound requests.
+type clientOptions struct {
+  userAgent    string       // overrides the runtime default when set
+  maxRedirects *int         // nil means DefaultMaxRedirects; 0 returns the redirect response itself
+  localAddr    *net.TCPAddr // source address for outbound connections (nil lets the OS choose)
+  timeout      *time.Duration // nil means DefaultRequestTimeout; 0 disables the timeout
+  breaker      *circuitBreaker // fails requests fast to hosts that keep failing (createClient only)
+}
+
+// requestTimeout returns the timeout requests made with opts are bound by.
+func (opts clientOptions) requestTimeout() time.Duration {
+  if opts.timeout != nil {
+    return *opts.timeout
+  }
+  return DefaultRequestTimeout
+}
+
+// client returns an http.Client enforcing the redirect limit. Exceeding the
+// limit fails the request instead of following a redirect loop forever.
+func (http *HTTP) client(opts clientOptions) *netHttp.Client {
+  max := DefaultMaxRedirects
+  if opts.maxRedirects != nil {
+    max = *opts.maxRedirects
+  }
+
+  transport := http.transport(opts.localAddr)
+  if opts.breaker != nil {
+    transport = &breakerTransport{next: transport, breaker: opts.breaker}
+  }
+
+  return &netHttp.Client{
+    Transport: transport,
+    Timeout:   opts.requestTimeout(),
+    CheckRedirect: func(req *netHttp.Request, via []*netHttp.Request) error {
+      if max == 0 {
+        return netHttp.ErrUseLastResponse
+      }
+      if len(via) > max {
+        return fmt.Errorf("too many redirects (max %d)", max)
+      }
+      return nil
+    },
+  }
+}
+
+// transport returns the shared transport for connections from localAddr, or
+// the default transport when localAddr is nil.
+func (http *HTTP) transport(localAddr *net.TCPAddr) netHttp.RoundTripper {
+  if localAddr == nil {
+    return netHttp.DefaultTransport
+  }
+
+  key := localAddr.String()
+  http.transportsMu.Lock()
+  defer http.transportsMu.Unlock()
+
+  if t, ok := http.transports[key]; ok {
+    return t
+  }
+  dialer := &net.Dialer{
+    LocalAddr: localAddr,
+    Timeout:   30 * time.Second,
+    KeepAlive: 30 * time.Second,
   }
+  t := netHttp.DefaultTransport.(*netHttp.Transport).Clone()
+  t.DialContext = dialer.DialContext
+  http.transports[key] = t
+  return t
+}
 
-  go h.runTaskQueue() // dedicated goroutine for VM tasks
+// localAddrOption reads a localAddr option: an IP address, optionally with a
+// port ("10.0.0.2" or "10.0.0.2:0"), that outbound connections bind to.
+func (http *HTTP) localAddrOption(optsObj *goja.Object, dst *clientOptions) {
+  v := optsObj.Get("localAddr")
+  if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+    return
+  }
 
-  return h
+  addr := v.String()
+  host, portStr := addr, "0"
+  if h, p, err := net.SplitHostPort(addr); err == nil {
+    host, portStr = h, p
+  }
+  ip := net.ParseIP(host)
+  port, err := strconv.Atoi(portStr)
+  if ip == nil || err != nil || port < 0 || port > 65535 {
+    panic(http.vm.NewTypeError(fmt.Sprintf("invalid localAddr %q: expected an IP address", addr)))
+  }
+  dst.localAddr = &net.TCPAddr{IP: ip, Port: port}
 }
 
-func (http *HTTP) runTaskQueue() {
-  for task := range http.taskQueue {
-    task()
+// redirectOption reads a maxRedirects option, leaving dst untouched when absent.
+func (http *HTTP) redirectOption(optsObj *goja.Object, dst *clientOptions) {
+  v := optsObj.Get("maxRedirects")
+  if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+    return
+  }
+  n := int(v.ToInteger())
+  if n < 0 {
+    panic(http.vm.NewTypeError("maxRedirects must be a non-negative number"))
+  }
+  dst.maxRedirects = &n
+}
+
+// timeoutOption reads a timeoutMs option, leaving dst untouched when absent.
+func (http *HTTP) timeoutOption(optsObj *goja.Object, dst *clientOptions) {
+  v := optsObj.Get("timeoutMs")
+  if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+    return
+  }
+  ms := v.ToInteger()
+  if ms < 0 {
+    panic(http.vm.NewTypeError("timeoutMs must be a non-negative number"))
   }
+  timeout := time.Duration(ms) * time.Millisecond
+  dst.timeout = &timeout
+}
+
+// requestError explains why a request failed when it was aborted or timed
+// out, and returns err unchanged otherwise.
+func requestError(url string, err error, signal *AbortSignal, opts clientOptions) error {
+  if signal != nil && signal.Aborted() {
+    return fmt.Errorf("request to %s aborted: %v", url, signal.Err())
+  }
+  var netErr net.Error
+  if errors.As(err, &netErr) && netErr.Timeout() {
+    return fmt.Errorf("request to %s timed out after %v", url, opts.requestTimeout())
+  }
+  return err
+}
+
+// applyDefaults fills in headers the caller did not set explicitly.
+func (http *HTTP) applyDefaults(req *netHttp.Request, opts clientOptions) {
+  if req.Header.Get("User-Agent") != "" {
+    return
+  }
+  ua := opts.userAgent
+  if ua == "" {
+    ua = http.userAgent
+  }
+  req.Header.Set("User-Agent", ua)
+}
+
+// schedule runs fn on the event loop goroutine, the only place the VM may be touched
+func (http *HTTP) schedule(name string, fn func()) {
+  http.loop.Schedule(event.Task{Name: name, Callback: fn})
 }
 
 func (http *HTTP) Export(vm *goja.Runtime) goja.Value {
@@ -53,14 +279,18 @@ func (http *HTTP) Export(vm *goja.Runtime) goja.Value {
 
 	obj.Set("get", http.get)
 	obj.Set("post", http.post)
+	obj.Set("request", http.request)
 	obj.Set("createServer", http.createServer)
+	obj.Set("createClient", http.createClient)
+	obj.Set("connect", http.connect)
 
 	return obj
 }
 
 func (http *HTTP) extractHost(urlStr string) string {
-	urlStr = strings.TrimPrefix(urlStr, "http://")
-	urlStr = strings.TrimPrefix(urlStr, "https://")
+	for _, scheme := range []string{"http://", "https://", "ws://", "wss://"} {
+		urlStr = strings.TrimPrefix(urlStr, scheme)
+	}
 
 	parts := strings.SplitN(urlStr, "/", 2)
 	return parts[0]
@@ -85,32 +315,48 @@ func (http *HTTP) hasNetPermissions(url string, ctx context.Context) (string, bo
   return host, canAccess
 }
 
-func (http *HTTP) getHeaders(resp *netHttp.Response) map[string]any {
-  headers := make(map[string]any)
-  for key, values := range resp.Header {
+// getHeaders copies a response's headers; responses expose them as a
+// Headers object (see responseHeaders).
+func (http *HTTP) getHeaders(resp *netHttp.Response) netHttp.Header {
+  return resp.Header.Clone()
+}
+
+// responseHeaders returns res.headers: a Headers object that, for existing
+// scripts, also keeps each header as a property under its canonical name
+// (a string, or an array when repeated). It must run on the VM goroutine.
+func responseHeaders(vm *goja.Runtime, header netHttp.Header) goja.Value {
+  obj := newHeadersObject(vm, header)
+  for key, values := range header {
     if len(values) == 1 {
-      headers[key] = values[0]
+      obj.Set(key, values[0])
     } else if len(values) > 1 {
-      headers[key] = values
+      obj.Set(key, values)
     }
   }
-  return headers
+  return obj
 }
 
 
-func createProxy(vm *goja.Runtime, future *future.Future) goja.Value {
+func createProxy(vm *goja.Runtime, rt RuntimeKeepAlive, loop *event.Loop, future *future.Future) goja.Value {
   obj := vm.NewObject()
 
   getter := func(key string) any {
-    result, ok := future.MustGet().(map[string]any)
+    value, err := future.Get()
+    if err != nil {
+      panic(vm.NewGoError(err))
+    }
+    result, ok := value.(map[string]any)
     if !ok {
       return nil
     }
+    if lazy, ok := result[key].(*loopValue); ok {
+      return lazy.get()
+    }
     return result[key]
   }
 
   obj.DefineAccessorProperty("status",
-    vm.ToValue(func() any { return getter("status") }), // getter
+    vm.ToValue(func() any { return getter("statusCode") }), // getter
     nil, // setter
     goja.FLAG_FALSE, // is writeable?
     goja.FLAG_TRUE) // is enumerable?
@@ -118,18 +364,179 @@ func createProxy(vm *goja.Runtime, future *future.Future) goja.Value {
   obj.DefineAccessorProperty("body",
     vm.ToValue(func() any { return getter("body") }), nil, goja.FLAG_FALSE, goja.FLAG_TRUE)
 
+  // headers become a Headers object on first use, on the VM goroutine
+  var headersObj goja.Value
+  headers := func() goja.Value {
+    if headersObj == nil {
+      header, _ := getter("headers").(netHttp.Header)
+      if header == nil {
+        header = netHttp.Header{}
+      }
+      headersObj = responseHeaders(vm, header)
+    }
+    return headersObj
+  }
+
   obj.DefineAccessorProperty("headers",
-    vm.ToValue(func() any { return getter("headers") }), nil, goja.FLAG_FALSE, goja.FLAG_TRUE)
+    vm.ToValue(headers), nil, goja.FLAG_FALSE, goja.FLAG_TRUE)
+
+  // thenable, so callers can await the response or catch failures
+  promise := &Promise{
+    vm:          vm,
+    runtime:     rt,
+    state:       PromisePending,
+    onFulfilled: []goja.Callable{},
+    onRejected:  []goja.Callable{},
+  }
+
+  done := rt.KeepAlive()
+  go func() {
+    value, err := future.Get()
+
+    // the error and the value have to be built on the VM goroutine
+    loop.Schedule(event.Task{Name: "http response", Callback: func() {
+      defer done()
+      if err != nil {
+        promise.reject(vm.NewGoError(err))
+        return
+      }
+
+      result, ok := value.(map[string]any)
+      if !ok {
+        promise.resolve(vm.ToValue(value))
+        return
+      }
+      res := vm.NewObject()
+      for key, v := range result {
+        if key == "headers" {
+          res.Set(key, headers())
+          continue
+        }
+        if lazy, ok := v.(*loopValue); ok {
+          v = lazy.get()
+        }
+        res.Set(key, v)
+      }
+      if code, ok := result["statusCode"]; ok {
+        res.Set("status", code) // as on the proxy
+      }
+      promise.resolve(res)
+    }})
+  }()
+
+  promiseObj := CreatePromiseObject(vm, promise).ToObject(vm)
+  obj.Set("then", promiseObj.Get("then"))
+  obj.Set("catch", promiseObj.Get("catch"))
 
   return obj
 }
 
 
+// createClient returns an object with get/post that share settings.
+//
+// JavaScript usage:
+//
+//	const client = http.createClient({ userAgent: 'my-bot/1.0', maxRedirects: 5, localAddr: '10.0.0.2', timeoutMs: 5000 });
+//	const res = await client.get('https://example.com');
+//
+// With circuitBreaker: { failureThreshold, resetMs }, a host that fails
+// failureThreshold times in a row (network errors or 5xx responses) gets its
+// requests rejected at once with a "circuit open" error until resetMs has
+// passed; then one request probes it, closing the circuit again on success.
+func (http *HTTP) createClient(call goja.FunctionCall) goja.Value {
+  opts := clientOptions{}
+  if len(call.Arguments) > 0 && !goja.IsUndefined(call.Arguments[0]) && !goja.IsNull(call.Arguments[0]) {
+    optsObj := call.Arguments[0].ToObject(http.vm)
+    if ua := optsObj.Get("userAgent"); ua != nil && !goja.IsUndefined(ua) && !goja.IsNull(ua) {
+      opts.userAgent = ua.String()
+    }
+    http.redirectOption(optsObj, &opts)
+    http.localAddrOption(optsObj, &opts)
+    http.timeoutOption(optsObj, &opts)
+    http.circuitBreakerOption(optsObj, &opts)
+  }
+
+  client := http.vm.NewObject()
+  client.Set("get", func(call goja.FunctionCall) goja.Value { return http.doGet(call, opts) })
+  client.Set("post", func(call goja.FunctionCall) goja.Value { return http.doPost(call, opts) })
+  client.Set("request", func(call goja.FunctionCall) goja.Value { return http.doRequest(call, opts) })
+  return client
+}
+
+// requestHeaders reads the optional headers of a request options argument:
+// a Headers object or a plain object of names to values.
+func (http *HTTP) requestHeaders(optsObj *goja.Object) netHttp.Header {
+  headers := netHttp.Header{}
+  headersVal := optsObj.Get("headers")
+  if headersVal == nil || goja.IsUndefined(headersVal) || goja.IsNull(headersVal) {
+    return headers
+  }
+
+  if h := headersFromValue(headersVal); h != nil {
+    return h.Clone()
+  }
+
+  headersObj := headersVal.ToObject(http.vm)
+  for _, key := range headersObj.Keys() {
+    headers.Set(key, headersObj.Get(key).String())
+  }
+  return headers
+}
+
+// conditionalHeaders applies the ifNoneMatch and ifModifiedSince request
+// options. ifModifiedSince accepts a Date or an HTTP date string.
+func (http *HTTP) conditionalHeaders(optsObj *goja.Object, headers netHttp.Header) {
+  if v := optsObj.Get("ifNoneMatch"); v != nil && !goja.IsUndefined(v) && !goja.IsNull(v) {
+    headers.Set("If-None-Match", v.String())
+  }
+
+  v := optsObj.Get("ifModifiedSince")
+  if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+    return
+  }
+  if t, ok := v.Export().(time.Time); ok {
+    headers.Set("If-Modified-Since", t.UTC().Format(netHttp.TimeFormat))
+  } else {
+    headers.Set("If-Modified-Since", v.String())
+  }
+}
+
+// get(url, [options]) fetches url and resolves with
+// { statusCode, statusText, body, headers, notModified }.
+//
+// Options: headers, signal, stream, maxRedirects, localAddr, timeoutMs, and the
+// conditional ifNoneMatch / ifModifiedSince. timeoutMs (default 30000, 0 for
+// none) bounds the whole request; for a streamed body it only bounds the wait
+// for the response to start. A 304 reply resolves with an empty body and
+// notModified: true so the caller can keep using its cached copy:
+//
+//	const res = await http.get(url, { ifNoneMatch: cached.etag });
+//	const data = res.notModified ? cached.data : res.body;
 func (http *HTTP) get(call goja.FunctionCall) goja.Value {
+  return http.doGet(call, clientOptions{})
+}
+
+func (http *HTTP) doGet(call goja.FunctionCall, clientOpts clientOptions) goja.Value {
   http.argCheck(call, 1, "GET requires a URL")
 
 	url := call.Arguments[0].String()
 
+	var signal *AbortSignal
+	headers := netHttp.Header{}
+	stream := false
+	if len(call.Arguments) > 1 && !goja.IsUndefined(call.Arguments[1]) && !goja.IsNull(call.Arguments[1]) {
+		optsObj := call.Arguments[1].ToObject(http.vm)
+		signal = signalFromValue(http.vm, optsObj.Get("signal"))
+		headers = http.requestHeaders(optsObj)
+		http.conditionalHeaders(optsObj, headers)
+		http.redirectOption(optsObj, &clientOpts)
+		http.localAddrOption(optsObj, &clientOpts)
+		http.timeoutOption(optsObj, &clientOpts)
+		if v := optsObj.Get("stream"); v != nil {
+			stream = v.ToBoolean()
+		}
+	}
+
 	f := future.NewFuture(func() (any, error) {
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
@@ -139,31 +546,90 @@ func (http *HTTP) get(call goja.FunctionCall) goja.Value {
 			return nil, fmt.Errorf("permission denied for %s", host)
 		}
 
-		resp, err := netHttp.Get(url)
+		// a streamed body outlives this function, so it releases the context itself
+		reqCtx, reqCancel := signal.Context(context.Background())
+		streaming := false
+		defer func() {
+			if !streaming {
+				reqCancel()
+			}
+		}()
+
+		req, err := netHttp.NewRequestWithContext(reqCtx, netHttp.MethodGet, url, nil)
 		if err != nil {
 			return nil, err
 		}
+		req.Header = headers
+		http.applyDefaults(req, clientOpts)
+
+		// the client's timeout would cut a streamed body off, so a stream is
+		// only timed until its response arrives
+		doOpts := clientOpts
+		var timer *time.Timer
+		if stream {
+			if timeout := clientOpts.requestTimeout(); timeout > 0 {
+				timer = time.AfterFunc(timeout, reqCancel)
+			}
+			none := time.Duration(0)
+			doOpts.timeout = &none
+		}
+
+		resp, err := http.client(doOpts).Do(req)
+		if timer != nil && !timer.Stop() {
+			if err == nil {
+				resp.Body.Close()
+			}
+			return nil, fmt.Errorf("request to %s timed out after %v", url, clientOpts.requestTimeout())
+		}
+		if err != nil {
+			return nil, requestError(url, err, signal, clientOpts)
+		}
+
+		if stream {
+			streaming = true
+			return map[string]any{
+				"statusCode":  resp.StatusCode,
+				"statusText":  resp.Status,
+				"body":        &loopValue{build: func() goja.Value { return http.newBodyStream(resp.Body, reqCancel) }},
+				"headers":     http.getHeaders(resp),
+				"notModified": resp.StatusCode == netHttp.StatusNotModified,
+			}, nil
+		}
 		defer resp.Body.Close()
 
 		body, readErr := io.ReadAll(resp.Body)
 		if readErr != nil {
-			return nil, readErr
+			return nil, requestError(url, readErr, signal, clientOpts)
 		}
 
 		headers := http.getHeaders(resp)
 
 		return map[string]any{
-			"statusCode":     resp.StatusCode,
-			"statusText": resp.Status,
-			"body":       string(body),
-			"headers":    headers,
+			"statusCode":  resp.StatusCode,
+			"statusText":  resp.Status,
+			"body":        string(body),
+			"headers":     headers,
+			"notModified": resp.StatusCode == netHttp.StatusNotModified,
 		}, nil
 	})
 
-	return createProxy(http.vm, f)
+	return createProxy(http.vm, http.runtime, http.loop, f)
 }
 
+// post(url, payload, [options]) sends payload as JSON and resolves like get.
+// Options: headers, whose Content-Type overrides the application/json default
+// (as does a contentType field in the payload), and timeoutMs.
+//
+// A { bodyFile: path } payload uploads that file instead, streamed from disk
+// rather than read into memory, as application/octet-stream by default. It
+// needs read permission for the file as well as net permission for the host:
+//
+//	const res = await http.post(url, { bodyFile: 'backup.tar.gz' });
 func (http *HTTP) post(call goja.FunctionCall) goja.Value {
+  return http.doPost(call, clientOptions{})
+}
+
+func (http *HTTP) doPost(call goja.FunctionCall, clientOpts clientOptions) goja.Value {
   http.argCheck(call, 2, "POST requires a URL and a payload")
 
 	url := call.Arguments[0].String()
@@ -171,8 +637,13 @@ func (http *HTTP) post(call goja.FunctionCall) goja.Value {
 
 	contentType := "application/json"
 	dataMap, isMap := payload.(map[string]any)
+	bodyFile := ""
 
 	if isMap {
+		if path, exists := dataMap["bodyFile"]; exists {
+			bodyFile = fmt.Sprint(path)
+			contentType = "application/octet-stream"
+		}
 		if ct, exists := dataMap["contentType"]; exists {
 			contentType = ct.(string)
 			delete(dataMap, "contentType")
@@ -180,6 +651,17 @@ func (http *HTTP) post(call goja.FunctionCall) goja.Value {
 		}
 	}
 
+	// post(url, payload, { headers }); a Content-Type here wins
+	headers := netHttp.Header{}
+	if len(call.Arguments) > 2 && !goja.IsUndefined(call.Arguments[2]) && !goja.IsNull(call.Arguments[2]) {
+		optsObj := call.Arguments[2].ToObject(http.vm)
+		headers = http.requestHeaders(optsObj)
+		http.timeoutOption(optsObj, &clientOpts)
+	}
+	if headers.Get("Content-Type") == "" {
+		headers.Set("Content-Type", contentType)
+	}
+
   f := future.NewFuture(func() (any, error) {
     ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
     defer cancel()
@@ -189,21 +671,34 @@ func (http *HTTP) post(call goja.FunctionCall) goja.Value {
       return nil, fmt.Errorf("permission denied for %s", host)
     }
 
-    jsonBytes, marshalErr := json.Marshal(payload)
-    if marshalErr != nil {
-      return nil, marshalErr
+    var req *netHttp.Request
+    var err error
+    if bodyFile != "" {
+      req, err = newFileRequest(ctx, context.Background(), netHttp.MethodPost, url, bodyFile)
+    } else {
+      jsonBytes, marshalErr := json.Marshal(payload)
+      if marshalErr != nil {
+        return nil, marshalErr
+      }
+      req, err = netHttp.NewRequest(netHttp.MethodPost, url, bytes.NewBuffer(jsonBytes))
     }
-
-    body := bytes.NewBuffer(jsonBytes)
-    resp, err := netHttp.Post(url, contentType, body)
     if err != nil {
       return nil, err
     }
+    for name, values := range headers {
+      req.Header[name] = values
+    }
+    http.applyDefaults(req, clientOpts)
+
+    resp, err := http.client(clientOpts).Do(req)
+    if err != nil {
+      return nil, requestError(url, err, nil, clientOpts)
+    }
     defer resp.Body.Close()
 
     respBody, readErr := io.ReadAll(resp.Body)
     if readErr != nil {
-      return nil, readErr
+      return nil, requestError(url, readErr, nil, clientOpts)
     }
 
 		headers := http.getHeaders(resp)
@@ -216,22 +711,352 @@ func (http *HTTP) post(call goja.FunctionCall) goja.Value {
     }, nil
   })
 
-	return createProxy(http.vm, f)
+	return createProxy(http.vm, http.runtime, http.loop, f)
+}
+
+// request(url, [options]) sends a request with any method and resolves like
+// get with { statusCode, statusText, body, headers }.
+//
+// Options: method (default GET), headers, body, bodyFile, signal,
+// maxRedirects, localAddr and timeoutMs. A string body is sent as is
+// (text/plain by default), an ArrayBuffer or typed array as raw bytes
+// (application/octet-stream) and anything else as JSON (application/json).
+// bodyFile streams a file as the body (application/octet-stream), as with
+// post. A Content-Type in headers always wins over these defaults.
+//
+//	const res = await http.request(url, {
+//	  method: 'PUT',
+//	  headers: { 'Content-Type': 'application/merge-patch+json' },
+//	  body: { name: 'new name' },
+//	});
+func (http *HTTP) request(call goja.FunctionCall) goja.Value {
+  return http.doRequest(call, clientOptions{})
 }
 
-func (http *HTTP) createRequestObject(r *netHttp.Request) goja.Value {
+func (http *HTTP) doRequest(call goja.FunctionCall, clientOpts clientOptions) goja.Value {
+  http.argCheck(call, 1, "request requires a URL")
+
+  url := call.Arguments[0].String()
+  method := netHttp.MethodGet
+  headers := netHttp.Header{}
+  var signal *AbortSignal
+  var body []byte
+  hasBody := false
+  bodyFile := ""
+  contentType := ""
+
+  if len(call.Arguments) > 1 && !goja.IsUndefined(call.Arguments[1]) && !goja.IsNull(call.Arguments[1]) {
+    optsObj := call.Arguments[1].ToObject(http.vm)
+    if v := optsObj.Get("method"); v != nil && !goja.IsUndefined(v) && !goja.IsNull(v) {
+      method = strings.ToUpper(v.String())
+    }
+    signal = signalFromValue(http.vm, optsObj.Get("signal"))
+    headers = http.requestHeaders(optsObj)
+    http.redirectOption(optsObj, &clientOpts)
+    http.localAddrOption(optsObj, &clientOpts)
+    http.timeoutOption(optsObj, &clientOpts)
+
+    if v := optsObj.Get("body"); v != nil && !goja.IsUndefined(v) && !goja.IsNull(v) {
+      hasBody = true
+      body, contentType = http.requestBody(v)
+    }
+    if v := optsObj.Get("bodyFile"); v != nil && !goja.IsUndefined(v) && !goja.IsNull(v) {
+      if hasBody {
+        panic(http.vm.NewTypeError("request takes a body or a bodyFile, not both"))
+      }
+      hasBody = true
+      bodyFile = v.String()
+      contentType = "application/octet-stream"
+    }
+  }
+  if hasBody && headers.Get("Content-Type") == "" {
+    headers.Set("Content-Type", contentType)
+  }
+
+  f := future.NewFuture(func() (any, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+    defer cancel()
+
+    host, canAccess := http.hasNetPermissions(url, ctx)
+    if !canAccess {
+      return nil, fmt.Errorf("permission denied for %s", host)
+    }
+
+    reqCtx, reqCancel := signal.Context(context.Background())
+    defer reqCancel()
+
+    var req *netHttp.Request
+    var err error
+    if bodyFile != "" {
+      req, err = newFileRequest(ctx, reqCtx, method, url, bodyFile)
+    } else {
+      var reqBody io.Reader
+      if hasBody {
+        reqBody = bytes.NewReader(body)
+      }
+      req, err = netHttp.NewRequestWithContext(reqCtx, method, url, reqBody)
+    }
+    if err != nil {
+      return nil, err
+    }
+    for name, values := range headers {
+      req.Header[name] = values
+    }
+    http.applyDefaults(req, clientOpts)
+
+    resp, err := http.client(clientOpts).Do(req)
+    if err != nil {
+      return nil, requestError(url, err, signal, clientOpts)
+    }
+    defer resp.Body.Close()
+
+    respBody, readErr := io.ReadAll(resp.Body)
+    if readErr != nil {
+      return nil, requestError(url, readErr, signal, clientOpts)
+    }
+
+    return map[string]any{
+      "statusCode": resp.StatusCode,
+      "statusText": resp.Status,
+      "body":       string(respBody),
+      "headers":    http.getHeaders(resp),
+    }, nil
+  })
+
+  return createProxy(http.vm, http.runtime, http.loop, f)
+}
+
+// requestBody encodes a request body option, returning the bytes and the
+// Content-Type to send when the caller didn't set one.
+func (http *HTTP) requestBody(v goja.Value) ([]byte, string) {
+  if s, ok := v.Export().(string); ok {
+    return []byte(s), "text/plain; charset=utf-8"
+  }
+
+  if isBinary(v) {
+    return bytesOf(http.vm, v), "application/octet-stream"
+  }
+
+  encoded, err := jsonStringify(http.vm, v, goja.Undefined(), goja.Undefined())
+  if err != nil {
+    panic(http.vm.NewGoError(fmt.Errorf("request body: %w", err)))
+  }
+  return []byte(encoded), "application/json"
+}
+
+// newFileRequest builds a request whose body is streamed from path, after
+// checking read permission for it under permCtx. The file's size is sent as
+// the Content-Length; the transport closes the file once it is sent.
+func newFileRequest(permCtx, reqCtx context.Context, method, url, path string) (*netHttp.Request, error) {
+  if errMsg := checkAll(permCtx, permissionCheck{permissions.PermissionRead, path}); errMsg != "" {
+    return nil, errors.New(errMsg)
+  }
+
+  f, err := os.Open(path)
+  if err != nil {
+    return nil, err
+  }
+  info, err := f.Stat()
+  if err != nil {
+    f.Close()
+    return nil, err
+  }
+  if info.IsDir() {
+    f.Close()
+    return nil, fmt.Errorf("bodyFile %s is a directory", path)
+  }
+
+  req, err := netHttp.NewRequestWithContext(reqCtx, method, url, f)
+  if err != nil {
+    f.Close()
+    return nil, err
+  }
+  req.ContentLength = info.Size()
+  if info.Size() == 0 {
+    f.Close()
+    req.Body = netHttp.NoBody
+  }
+  return req, nil
+}
+
+// openForSend checks read permission for path and opens it for sendFile.
+// It may prompt, so it runs off the event loop. On failure it returns the
+// status to answer with and a message.
+func openForSend(path string) (*os.File, int, string) {
+  ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+  defer cancel()
+  if errMsg := checkAll(ctx, permissionCheck{permissions.PermissionRead, path}); errMsg != "" {
+    return nil, netHttp.StatusForbidden, errMsg
+  }
+
+  f, err := os.Open(path)
+  if err != nil {
+    if errors.Is(err, os.ErrNotExist) {
+      return nil, netHttp.StatusNotFound, err.Error()
+    }
+    return nil, netHttp.StatusInternalServerError, err.Error()
+  }
+  if info, err := f.Stat(); err != nil || info.IsDir() {
+    f.Close()
+    if err != nil {
+      return nil, netHttp.StatusInternalServerError, err.Error()
+    }
+    return nil, netHttp.StatusNotFound, fmt.Sprintf("sendFile: %s is a directory", path)
+  }
+  return f, 0, ""
+}
+
+// serveFile writes f, which it closes, as the response to r. A plain 200
+// goes through http.ServeContent for Range, If-Modified-Since and friends;
+// any other status set by the handler sends the whole file with it.
+func serveFile(w netHttp.ResponseWriter, r *netHttp.Request, f *os.File, statusCode int) {
+  defer f.Close()
+
+  info, err := f.Stat()
+  if err != nil {
+    netHttp.Error(w, err.Error(), netHttp.StatusInternalServerError)
+    return
+  }
+  if statusCode == netHttp.StatusOK {
+    netHttp.ServeContent(w, r, info.Name(), info.ModTime(), f)
+    return
+  }
+
+  if w.Header().Get("Content-Type") == "" {
+    if ctype := mime.TypeByExtension(filepath.Ext(info.Name())); ctype != "" {
+      w.Header().Set("Content-Type", ctype)
+    } else {
+      w.Header().Set("Content-Type", "application/octet-stream")
+    }
+  }
+  w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+  w.WriteHeader(statusCode)
+  if r.Method != netHttp.MethodHead {
+    io.Copy(w, f)
+  }
+}
+
+// newUint8Array wraps data in a JS Uint8Array without any string conversion.
+func newUint8Array(vm *goja.Runtime, data []byte) goja.Value {
+	buf := vm.NewArrayBuffer(append([]byte(nil), data...))
+
+	ctor, ok := goja.AssertConstructor(vm.Get("Uint8Array"))
+	if !ok {
+		return vm.ToValue(buf)
+	}
+
+	arr, err := ctor(nil, vm.ToValue(buf))
+	if err != nil {
+		panic(err)
+	}
+	return arr
+}
+
+// parseForm turns a form body into an object keyed by field name. Repeated
+// fields become arrays. Multipart file parts are returned as
+// { filename, contentType, size, data } with data as a Uint8Array.
+func (http *HTTP) parseForm(contentType string, body []byte) (*goja.Object, error) {
+  form := http.vm.NewObject()
+  values := map[string][]any{}
+  var order []string
+  add := func(name string, value any) {
+    if _, seen := values[name]; !seen {
+      order = append(order, name)
+    }
+    values[name] = append(values[name], value)
+  }
+
+  mediaType, params, _ := mime.ParseMediaType(contentType)
+  switch mediaType {
+  case "multipart/form-data":
+    reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+    for {
+      part, err := reader.NextPart()
+      if err == io.EOF {
+        break
+      }
+      if err != nil {
+        return nil, err
+      }
+
+      data, err := io.ReadAll(part)
+      if err != nil {
+        return nil, err
+      }
+
+      if part.FileName() == "" {
+        add(part.FormName(), string(data))
+        continue
+      }
+
+      file := http.vm.NewObject()
+      file.Set("filename", part.FileName())
+      file.Set("contentType", part.Header.Get("Content-Type"))
+      file.Set("size", len(data))
+      file.Set("data", newUint8Array(http.vm, data))
+      add(part.FormName(), file)
+    }
+
+  case "", "application/x-www-form-urlencoded":
+    query, err := netUrl.ParseQuery(string(body))
+    if err != nil {
+      return nil, err
+    }
+    keys := make([]string, 0, len(query))
+    for key := range query {
+      keys = append(keys, key)
+    }
+    sort.Strings(keys)
+    for _, key := range keys {
+      for _, v := range query[key] {
+        add(key, v)
+      }
+    }
+
+  default:
+    return nil, fmt.Errorf("unsupported content type %q", mediaType)
+  }
+
+  for _, name := range order {
+    if list := values[name]; len(list) == 1 {
+      form.Set(name, list[0])
+    } else {
+      form.Set(name, http.vm.NewArray(list...))
+    }
+  }
+  return form, nil
+}
+
+// createRequestObject builds the req passed to server handlers. The body is
+// read in full up front unless streamBody is set, in which case req.body is
+// empty and the caller delivers it through req.on('data') (see streamRequestBody).
+func (http *HTTP) createRequestObject(r *netHttp.Request, streamBody bool) goja.Value {
 	reqObj := http.vm.NewObject()
 
 	reqObj.Set("method", r.Method)
 	reqObj.Set("url", r.URL.String())
+	reqObj.Set("httpVersion", fmt.Sprintf("%d.%d", r.ProtoMajor, r.ProtoMinor))
+
+	// remoteAddress is the client IP (the raw address for Unix sockets)
+	remoteAddress := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		remoteAddress = host
+	}
+	reqObj.Set("remoteAddress", remoteAddress)
 
-	body, readErr := io.ReadAll(r.Body)
-	r.Body.Close()
+	var body []byte
+	var readErr error
+	if !streamBody {
+		body, readErr = io.ReadAll(r.Body)
+		r.Body.Close()
+	}
 
-	if readErr != nil {
+	if streamBody || readErr != nil {
 		reqObj.Set("body", "")
+		reqObj.Set("rawBody", newUint8Array(http.vm, nil))
 	} else {
 		reqObj.Set("body", string(body))
+		reqObj.Set("rawBody", newUint8Array(http.vm, body)) // binary-safe view of the body
     r.Body = io.NopCloser(bytes.NewBuffer(body))
 	}
 
@@ -245,26 +1070,175 @@ func (http *HTTP) createRequestObject(r *netHttp.Request) goja.Value {
 	}
 	reqObj.Set("headers", headersObj)
 
+	// json([reviver]) parses the body with JSON.parse
+	reqObj.Set("json", func(call goja.FunctionCall) goja.Value {
+		parsed, err := jsonParse(http.vm, reqObj.Get("body").String(), call.Argument(0))
+		if err != nil {
+			panic(err)
+		}
+		return parsed
+	})
+
+	// form() parses urlencoded or multipart/form-data bodies
+	contentType := r.Header.Get("Content-Type")
+	reqObj.Set("form", func(call goja.FunctionCall) goja.Value {
+		form, err := http.parseForm(contentType, body)
+		if err != nil {
+			panic(http.vm.NewGoError(fmt.Errorf("req.form: %w", err)))
+		}
+		return form
+	})
+
 	return reqObj
 }
 
+// offersDeflate reports whether a websocket handshake offers the
+// permessage-deflate extension.
+func offersDeflate(r *netHttp.Request) bool {
+	for _, header := range r.Header.Values("Sec-Websocket-Extensions") {
+		for _, ext := range strings.Split(header, ",") {
+			name, _, _ := strings.Cut(ext, ";")
+			if strings.EqualFold(strings.TrimSpace(name), "permessage-deflate") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// wsCloseInfo is passed to a websocket's close callback as { code, reason, wasClean }.
+type wsCloseInfo struct {
+	code     int
+	reason   string
+	wasClean bool // a close frame was exchanged rather than the connection dropping
+}
+
+// createServer([handler], [options]) creates a server. Requests go through
+// the middleware added with use(), then to the first route added with
+// get/post/put/patch/delete/all that matches, then to handler. Without a
+// handler, a request no route matches gets a 404.
+//
+//	const server = http.createServer();
+//	server.get('/users/:id', (req, res) => res.json(users[req.params.id]));
+//	server.post('/users', (req, res) => { ... });
+//	server.listen(8080);
 func (http *HTTP) createServer(call goja.FunctionCall) goja.Value {
-	if len(call.Arguments) < 1 {
-		panic(http.vm.ToValue("createServer requires a request handler function"))
+	var requestHandler goja.Callable
+	optsArg := call.Argument(1)
+	if first := call.Argument(0); !goja.IsUndefined(first) && !goja.IsNull(first) {
+		fn, ok := goja.AssertFunction(first)
+		if _, isObj := first.(*goja.Object); !ok && !isObj {
+			panic(http.vm.ToValue("argument must be a function"))
+		}
+		if ok {
+			requestHandler = fn
+		} else {
+			optsArg = first // createServer(options)
+		}
+	}
+
+	// responses with a body but no explicit Content-Type get this default,
+	// so browsers don't have to sniff. Pass defaultContentType: '' to disable.
+	defaultContentType := "text/plain; charset=utf-8"
+	// maxConnections caps simultaneous connections (0 = unlimited); excess
+	// connections wait in the listen backlog until one closes
+	maxConnections := 0
+	// streamBody delivers request bodies through req.on('data') as they
+	// arrive (including chunked uploads); the response is sent when the
+	// handler calls res.end() rather than when it returns
+	streamBody := false
+	// h2c serves cleartext HTTP/2 (prior knowledge or Upgrade: h2c) on
+	// listen and listenUnix, for internal services behind a proxy; HTTP/2
+	// over TLS (listenTLS) is always on
+	enableH2C := false
+	if !goja.IsUndefined(optsArg) && !goja.IsNull(optsArg) {
+		optsObj := optsArg.ToObject(http.vm)
+		if ctVal := optsObj.Get("defaultContentType"); ctVal != nil && !goja.IsUndefined(ctVal) {
+			if goja.IsNull(ctVal) {
+				defaultContentType = ""
+			} else {
+				defaultContentType = ctVal.String()
+			}
+		}
+		if maxVal := optsObj.Get("maxConnections"); maxVal != nil && !goja.IsUndefined(maxVal) {
+			maxConnections = int(maxVal.ToInteger())
+			if maxConnections < 0 {
+				panic(http.vm.NewTypeError("maxConnections must not be negative"))
+			}
+		}
+		if streamVal := optsObj.Get("streamBody"); streamVal != nil && !goja.IsUndefined(streamVal) {
+			streamBody = streamVal.ToBoolean()
+		}
+		if h2cVal := optsObj.Get("h2c"); h2cVal != nil && !goja.IsUndefined(h2cVal) {
+			enableH2C = h2cVal.ToBoolean()
+		}
 	}
 
-	requestHandler, ok := goja.AssertFunction(call.Arguments[0])
-	if !ok {
-		panic(http.vm.ToValue("argument must be a function"))
+	serverObj := http.vm.NewObject()
+	signals := &Abort{vm: http.vm}
+
+	routes := &router{}
+
+	// dispatch hands a request that made it through the middleware to its
+	// route, the catch-all handler, or a 404
+	dispatch := func(req, res goja.Value) {
+		reqObj := req.ToObject(http.vm)
+		params := http.vm.NewObject()
+		reqObj.Set("params", params)
+
+		// routed by req.method and req.url, so middleware can rewrite them
+		if u, err := netUrl.Parse(reqObj.Get("url").String()); err == nil {
+			if handler, matched, ok := routes.match(reqObj.Get("method").String(), u.EscapedPath()); ok {
+				for name, value := range matched {
+					params.Set(name, value)
+				}
+				handler(goja.Undefined(), req, res)
+				return
+			}
+		}
+
+		if requestHandler != nil {
+			requestHandler(goja.Undefined(), req, res)
+			return
+		}
+
+		resObj := res.ToObject(http.vm)
+		resObj.Set("statusCode", netHttp.StatusNotFound)
+		if end, ok := goja.AssertFunction(resObj.Get("end")); ok {
+			end(resObj, http.vm.ToValue("Not Found"))
+		}
 	}
 
-	serverObj := http.vm.NewObject()
+	// middleware added with server.use(fn) runs in order before the handler;
+	// each calls next() to continue or responds itself to stop the chain
+	var middleware []goja.Callable
+	var runChain func(i int, req, res goja.Value)
+	runChain = func(i int, req, res goja.Value) {
+		if i == len(middleware) {
+			dispatch(req, res)
+			return
+		}
+		called := false
+		next := func(goja.FunctionCall) goja.Value {
+			if !called {
+				called = true
+				runChain(i+1, req, res)
+			}
+			return goja.Undefined()
+		}
+		// like the handler's, a middleware exception leaves the response as is
+		middleware[i](goja.Undefined(), req, res, http.vm.ToValue(next))
+	}
 
   type responseState struct {
     statusCode int
     headers    map[string]string
     body       string
+    binary     bool // the body was written as bytes, so it isn't text/plain
+    file       *os.File // set by sendFile; served instead of body
     mu         sync.Mutex
+    ended      chan struct{} // closed by the first end/json/redirect
+    endOnce    sync.Once
   }
 
 	goServer := &netHttp.Server{
@@ -273,12 +1247,62 @@ func (http *HTTP) createServer(call goja.FunctionCall) goja.Value {
       state := &responseState{
         statusCode: 200,
         headers:    make(map[string]string),
+        ended:      make(chan struct{}),
+      }
+      markEnded := func() {
+        state.endOnce.Do(func() { close(state.ended) })
       }
 
-      http.taskQueue <- func() {
-        defer close(done)
+      // the response is ready when the handler returns, or once it ends the
+      // response when bodies are streamed
+      finished := done
+      if streamBody {
+        finished = state.ended
+      }
+
+      // the handler's task and each sendFile still opening its file hold
+      // the response back; done closes once they are all through. Only
+      // touched on the event loop.
+      busy := 1
+      release := func() {
+        busy--
+        if busy == 0 {
+          close(done)
+        }
+      }
+
+      http.schedule("http request", func() {
+        defer release()
+
+        // the client went away (or the server closed) before we got here
+        if r.Context().Err() != nil {
+          return
+        }
+
+        reqObj := http.createRequestObject(r, streamBody).ToObject(http.vm)
+
+        // req.signal aborts when the client disconnects so long handlers can bail out
+        sig := newAbortSignal()
+        signalObj := signals.createSignalObject(sig)
+        reqObj.Set("signal", signalObj)
+
+        go func() {
+          select {
+          case <-r.Context().Done():
+            select {
+            case <-finished: // finished normally; ctx is cancelled after every response
+              return
+            default:
+            }
+            if sig.abort(nil, "The client disconnected", "AbortError") {
+              http.schedule("http request abort", func() {
+                signals.dispatchAbort(sig, signalObj)
+              })
+            }
+          case <-finished:
+          }
+        }()
 
-        reqObj := http.createRequestObject(r)
         resObj := http.vm.NewObject()
 
         resObj.Set("statusCode", 200)
@@ -320,34 +1344,221 @@ func (http *HTTP) createServer(call goja.FunctionCall) goja.Value {
           return goja.Undefined()
         })
 
+        // appendBody adds a chunk to the body: the bytes of an ArrayBuffer
+        // or typed array as they are, anything else as a string. Chunks
+        // after the response has ended are dropped.
+        appendBody := func(chunk goja.Value) {
+          select {
+          case <-state.ended:
+            return
+          default:
+          }
+          if isBinary(chunk) {
+            data := bytesOf(http.vm, chunk)
+            state.mu.Lock()
+            state.body += string(data)
+            state.binary = true
+            state.mu.Unlock()
+            return
+          }
+          text := chunk.String()
+          state.mu.Lock()
+          state.body += text
+          state.mu.Unlock()
+        }
+
+        // write(chunk) adds to the body; it is sent in one piece when the
+        // response ends
+        resObj.Set("write", func(call goja.FunctionCall) goja.Value {
+          if chunk := call.Argument(0); !goja.IsUndefined(chunk) && !goja.IsNull(chunk) {
+            appendBody(chunk)
+          }
+          return goja.Undefined()
+        })
+
         resObj.Set("end", func(call goja.FunctionCall) goja.Value {
+          if len(call.Arguments) > 0 && !goja.IsUndefined(call.Arguments[0]) {
+            appendBody(call.Arguments[0])
+          }
+
           state.mu.Lock()
           if statusVal := resObj.Get("statusCode"); statusVal != nil && !goja.IsUndefined(statusVal) {
             state.statusCode = int(statusVal.ToInteger())
           }
+          state.mu.Unlock()
+          markEnded()
 
-          if len(call.Arguments) > 0 && !goja.IsUndefined(call.Arguments[0]) {
-            state.body = call.Arguments[0].String()
+          return goja.Undefined()
+        })
+
+        // json(value, [replacer], [space]) sends value serialized with JSON.stringify
+        resObj.Set("json", func(call goja.FunctionCall) goja.Value {
+          body, err := jsonStringify(http.vm, call.Argument(0), call.Argument(1), call.Argument(2))
+          if err != nil {
+            panic(http.vm.NewGoError(fmt.Errorf("res.json: %w", err)))
+          }
+
+          state.mu.Lock()
+          if statusVal := resObj.Get("statusCode"); statusVal != nil && !goja.IsUndefined(statusVal) {
+            state.statusCode = int(statusVal.ToInteger())
           }
+          hasContentType := false
+          for name := range state.headers {
+            if strings.EqualFold(name, "Content-Type") {
+              hasContentType = true
+            }
+          }
+          if !hasContentType {
+            state.headers["Content-Type"] = "application/json; charset=utf-8"
+          }
+          state.body = body
+          state.binary = false
           state.mu.Unlock()
+          markEnded()
 
           return goja.Undefined()
         })
 
-        requestHandler(goja.Undefined(), reqObj, resObj)
-      }
+        // redirect(location, [status=302]) sends an empty 3xx response
+        resObj.Set("redirect", func(call goja.FunctionCall) goja.Value {
+          if len(call.Arguments) < 1 || goja.IsUndefined(call.Arguments[0]) {
+            panic(http.vm.ToValue("redirect requires a location"))
+          }
+          location := call.Arguments[0].String()
+
+          statusCode := netHttp.StatusFound
+          if len(call.Arguments) > 1 && !goja.IsUndefined(call.Arguments[1]) {
+            statusCode = int(call.Arguments[1].ToInteger())
+          }
+          if statusCode < 300 || statusCode > 399 {
+            panic(http.vm.ToValue(fmt.Sprintf("redirect status must be 3xx, got %d", statusCode)))
+          }
+
+          resObj.Set("statusCode", statusCode)
+
+          state.mu.Lock()
+          state.statusCode = statusCode
+          state.headers["Location"] = location
+          state.body = ""
+          state.mu.Unlock()
+          markEnded()
+
+          return goja.Undefined()
+        })
+
+        // sendFile(path, [callback]) ends the response with a file's
+        // content. Range and conditional requests are honoured, so clients
+        // can resume downloads or seek in media with 206 Partial Content.
+        // The permission check and open happen off the event loop; if they
+        // fail, callback(err) is called to respond, or without one the
+        // response is a 403, 404 or 500 carrying the error message.
+        resObj.Set("sendFile", func(call goja.FunctionCall) goja.Value {
+          if len(call.Arguments) < 1 || goja.IsUndefined(call.Arguments[0]) {
+            panic(http.vm.NewTypeError("sendFile requires a path"))
+          }
+          path := call.Arguments[0].String()
+          callback, hasCallback := goja.AssertFunction(call.Argument(1))
+
+          statusCode := 0
+          if statusVal := resObj.Get("statusCode"); statusVal != nil && !goja.IsUndefined(statusVal) {
+            statusCode = int(statusVal.ToInteger())
+          }
+
+          busy++
+          keepAlive := http.runtime.KeepAlive()
+          go func() {
+            f, errStatus, errMsg := openForSend(path)
+            http.schedule("http sendFile", func() {
+              defer keepAlive()
+              defer release()
+
+              select {
+              case <-state.ended: // already answered, or nobody is waiting
+                if f != nil {
+                  f.Close()
+                }
+                return
+              default:
+              }
+              if r.Context().Err() != nil {
+                if f != nil {
+                  f.Close()
+                }
+                return
+              }
+
+              if errMsg != "" {
+                if hasCallback {
+                  // like the handler's, a callback exception leaves the response as is
+                  callback(goja.Undefined(), http.vm.NewGoError(errors.New(errMsg)))
+                  return
+                }
+                state.mu.Lock()
+                state.statusCode = errStatus
+                state.body = errMsg
+                state.binary = false
+                state.mu.Unlock()
+                markEnded()
+                return
+              }
+
+              state.mu.Lock()
+              if statusCode != 0 {
+                state.statusCode = statusCode
+              }
+              state.file = f
+              state.body = ""
+              state.mu.Unlock()
+              markEnded()
+            })
+          }()
+
+          return goja.Undefined()
+        })
+
+        var startBody func()
+        if streamBody {
+          startBody = http.streamRequestBody(r, reqObj, state.ended)
+        }
+
+        runChain(0, reqObj, resObj)
+
+        if startBody != nil {
+          startBody() // listeners are attached; start reading
+        }
+      })
       
       select {
-      case <-done:
+      case <-finished:
         state.mu.Lock()
         for name, value := range state.headers {
           w.Header().Set(name, value)
         }
+        if state.file != nil {
+          serveFile(w, r, state.file, state.statusCode)
+          state.mu.Unlock()
+          return
+        }
+        if state.body != "" && defaultContentType != "" && w.Header().Get("Content-Type") == "" {
+          if state.binary {
+            w.Header().Set("Content-Type", "application/octet-stream")
+          } else {
+            w.Header().Set("Content-Type", defaultContentType)
+          }
+        }
         w.WriteHeader(state.statusCode)
         if state.body != "" {
           w.Write([]byte(state.body))
         }
         state.mu.Unlock()
+      case <-r.Context().Done():
+        // nobody left to write the response to
+        state.mu.Lock()
+        if state.file != nil {
+          state.file.Close()
+        }
+        state.mu.Unlock()
+        return
       case <-time.After(30 * time.Second):
         w.WriteHeader(netHttp.StatusGatewayTimeout)
         w.Write([]byte("Request handler timeout"))
@@ -355,6 +1566,71 @@ func (http *HTTP) createServer(call goja.FunctionCall) goja.Value {
 		}),
 	}
 
+	if enableH2C {
+		goServer.Handler = h2c.NewHandler(goServer.Handler, &http2.Server{})
+	}
+
+	// shutdown state shared by every close() call: the first one shuts the
+	// server down, and the callbacks of all of them run, in order, once that
+	// has finished. Only closed and closeErr are used off the event loop.
+	var closeOnce sync.Once
+	closed := make(chan struct{})
+	var closeErr error
+	var closeCallbacks []goja.Callable
+	closeFinished := false
+
+	notifyClosed := func(callbacks []goja.Callable) {
+		errArg := goja.Null()
+		if closeErr != nil {
+			errArg = http.vm.NewGoError(closeErr)
+		}
+		for _, callback := range callbacks {
+			if _, err := callback(goja.Undefined(), errArg); err != nil {
+				fmt.Fprintf(http.errorOutput(), "Error in server close callback: %v\n", err)
+			}
+		}
+	}
+
+	// serve starts accepting connections on ln, over TLS when tlsConfig is
+	// set; the server keeps the runtime alive until it is closed and drained
+	serve := func(ln net.Listener, tlsConfig *tls.Config, callback goja.Callable) {
+		if maxConnections > 0 {
+			ln = netutil.LimitListener(ln, maxConnections)
+		}
+		goServer.Addr = ln.Addr().String()
+		goServer.TLSConfig = tlsConfig
+		serverObj.Set("address", goServer.Addr)
+
+		http.handlesMu.Lock()
+		http.servers[goServer] = goServer.Addr
+		http.handlesMu.Unlock()
+
+		done := http.runtime.KeepAlive()
+		go func() {
+			defer done()
+			defer func() {
+				http.handlesMu.Lock()
+				delete(http.servers, goServer)
+				http.handlesMu.Unlock()
+			}()
+			var err error
+			if tlsConfig != nil {
+				err = goServer.ServeTLS(ln, "", "") // negotiates h2 via ALPN
+			} else {
+				err = goServer.Serve(ln)
+			}
+			if err == netHttp.ErrServerClosed {
+				<-closed // Serve returns as soon as shutdown starts; wait for the drain
+			} else if err != nil {
+				fmt.Fprintf(http.errorOutput(), "Server error: %v\n", err)
+			}
+		}()
+
+		if callback != nil {
+			callback(goja.Undefined())
+		}
+	}
+
 	serverObj.Set("listen", func(call goja.FunctionCall) goja.Value {
 		if len(call.Arguments) < 1 {
 			panic(http.vm.ToValue("listen requires a port number"))
@@ -392,27 +1668,195 @@ func (http *HTTP) createServer(call goja.FunctionCall) goja.Value {
 			panic(http.vm.ToValue(err.Error()))
 		}
 
-		goServer.Addr = ln.Addr().String()
-		serverObj.Set("address", goServer.Addr)
+		serve(ln, nil, callback)
+		return goja.Undefined()
+	})
 
-    done := http.runtime.KeepAlive()
-		go func() {
-      defer done()
-			err := goServer.Serve(ln)
-			if err != nil && err != netHttp.ErrServerClosed {
-				fmt.Fprintf(os.Stderr, "Server error: %v\n", err)
-			}
-		}()
+	// listenTLS(port, [host], { cert, key }, [callback]) serves HTTPS using a
+	// PEM certificate chain and private key. Clients that support HTTP/2
+	// negotiate it; the rest get HTTP/1.1.
+	serverObj.Set("listenTLS", func(call goja.FunctionCall) goja.Value {
+		if len(call.Arguments) < 2 {
+			panic(http.vm.ToValue("listenTLS requires a port number and { cert, key }"))
+		}
 
-		if callback != nil {
-			callback(goja.Undefined())
+		port := call.Arguments[0].String()
+		bindAddr := "0.0.0.0"
+		argOffset := 1
+
+		if _, isObj := call.Arguments[1].(*goja.Object); !isObj {
+			bindAddr = call.Arguments[1].String()
+			argOffset = 2
+		}
+
+		optsVal := call.Argument(argOffset)
+		if goja.IsUndefined(optsVal) || goja.IsNull(optsVal) {
+			panic(http.vm.NewTypeError("listenTLS requires { cert, key }"))
+		}
+		optsObj := optsVal.ToObject(http.vm)
+		certVal, keyVal := optsObj.Get("cert"), optsObj.Get("key")
+		if certVal == nil || goja.IsUndefined(certVal) || keyVal == nil || goja.IsUndefined(keyVal) {
+			panic(http.vm.NewTypeError("listenTLS requires { cert, key }"))
+		}
+		cert, err := tls.X509KeyPair([]byte(certVal.String()), []byte(keyVal.String()))
+		if err != nil {
+			panic(http.vm.NewGoError(fmt.Errorf("listenTLS: %w", err)))
+		}
+
+		var callback goja.Callable
+		if len(call.Arguments) > argOffset+1 {
+			callback, _ = goja.AssertFunction(call.Arguments[argOffset+1])
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		permHost := bindAddr + ":" + port
+		mgr := permissions.GetManager()
+		canNet := permissions.PermissionNet
+		if !mgr.CheckWithPrompt(ctx, canNet, permHost) {
+			errMsg := mgr.ErrorMessage(canNet, permHost)
+			panic(http.vm.ToValue(errMsg))
+		}
+
+		ln, err := net.Listen("tcp", bindAddr+":"+port)
+		if err != nil {
+			panic(http.vm.ToValue(err.Error()))
+		}
+
+		serve(ln, &tls.Config{Certificates: []tls.Certificate{cert}}, callback)
+		return goja.Undefined()
+	})
+
+	// listenUnix(socketPath, [callback]) serves on a Unix domain socket.
+	// The socket is created as a file, so it needs write permission on the
+	// path; the file is removed again when the server closes.
+	serverObj.Set("listenUnix", func(call goja.FunctionCall) goja.Value {
+		if len(call.Arguments) < 1 {
+			panic(http.vm.ToValue("listenUnix requires a socket path"))
+		}
+
+		socketPath := call.Arguments[0].String()
+
+		var callback goja.Callable
+		if len(call.Arguments) > 1 {
+			callback, _ = goja.AssertFunction(call.Arguments[1])
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		mgr := permissions.GetManager()
+		canWrite := permissions.PermissionWrite
+		if !mgr.CheckWithPrompt(ctx, canWrite, socketPath) {
+			errMsg := mgr.ErrorMessage(canWrite, socketPath)
+			panic(http.vm.ToValue(errMsg))
+		}
+
+		ln, err := net.Listen("unix", socketPath)
+		if err != nil {
+			panic(http.vm.ToValue(err.Error()))
 		}
 
+		serve(ln, nil, callback)
 		return goja.Undefined()
 	})
 
+	// use(fn) adds middleware called as fn(req, res, next). Responses are
+	// sent when the handler returns, so next() must be called synchronously.
+	serverObj.Set("use", func(call goja.FunctionCall) goja.Value {
+		fn, ok := goja.AssertFunction(call.Argument(0))
+		if !ok {
+			panic(http.vm.NewTypeError("use requires a middleware function"))
+		}
+		middleware = append(middleware, fn)
+		return serverObj
+	})
+
+	// get/post/put/patch/delete(pattern, handler) add routes for one
+	// method, all(pattern, handler) for every method; see router
+	addRoute := func(name, method string) {
+		serverObj.Set(name, func(call goja.FunctionCall) goja.Value {
+			handler, ok := goja.AssertFunction(call.Argument(1))
+			if !ok {
+				panic(http.vm.NewTypeError(name + " requires a path pattern and a handler function"))
+			}
+			if err := routes.add(method, call.Argument(0).String(), handler); err != nil {
+				panic(http.vm.NewTypeError(err.Error()))
+			}
+			return serverObj
+		})
+	}
+	addRoute("get", netHttp.MethodGet)
+	addRoute("post", netHttp.MethodPost)
+	addRoute("put", netHttp.MethodPut)
+	addRoute("patch", netHttp.MethodPatch)
+	addRoute("delete", netHttp.MethodDelete)
+	addRoute("all", "")
+
+	// rateLimit({ windowMs = 60000, max = 60, keyBy }) adds rate-limiting
+	// middleware; see rateLimitMiddleware
+	serverObj.Set("rateLimit", func(call goja.FunctionCall) goja.Value {
+		fn, _ := goja.AssertFunction(http.rateLimitMiddleware(call.Argument(0)))
+		middleware = append(middleware, fn)
+		return serverObj
+	})
+
+	// close([{ gracePeriodMs }], [callback]) shuts the server down
+	// gracefully. New connections are refused immediately, while requests in
+	// progress get up to gracePeriodMs (default 30000) to finish; connections
+	// still busy after that are dropped. callback(err) runs once the server
+	// has fully stopped, with err set only when the grace period ran out.
+	// Calling close again is harmless: its callback runs at the same point.
 	serverObj.Set("close", func(call goja.FunctionCall) goja.Value {
-		_ = goServer.Close()
+		grace := defaultShutdownGrace
+		cbArg := call.Argument(0)
+		if _, isFn := goja.AssertFunction(cbArg); !isFn && !goja.IsUndefined(cbArg) && !goja.IsNull(cbArg) {
+			optsObj := cbArg.ToObject(http.vm)
+			if v := optsObj.Get("gracePeriodMs"); v != nil && !goja.IsUndefined(v) {
+				if v.ToInteger() < 0 {
+					panic(http.vm.NewTypeError("gracePeriodMs must not be negative"))
+				}
+				grace = time.Duration(v.ToInteger()) * time.Millisecond
+			}
+			cbArg = call.Argument(1)
+		}
+		callback, hasCallback := goja.AssertFunction(cbArg)
+
+		if hasCallback {
+			if closeFinished {
+				done := http.runtime.KeepAlive()
+				http.schedule("server close", func() {
+					defer done()
+					notifyClosed([]goja.Callable{callback})
+				})
+			} else {
+				closeCallbacks = append(closeCallbacks, callback)
+			}
+		}
+
+		// Shutdown waits for handlers, which run on the event loop, so it
+		// must not block the loop
+		closeOnce.Do(func() {
+			done := http.runtime.KeepAlive()
+			go func() {
+				ctx, cancel := context.WithTimeout(context.Background(), grace)
+				defer cancel()
+				if err := goServer.Shutdown(ctx); err != nil {
+					goServer.Close()
+					closeErr = fmt.Errorf("grace period of %v expired; remaining connections were dropped", grace)
+				}
+				close(closed)
+
+				http.schedule("server close", func() {
+					defer done()
+					closeFinished = true
+					callbacks := closeCallbacks
+					closeCallbacks = nil
+					notifyClosed(callbacks)
+				})
+			}()
+		})
 		return goja.Undefined()
 	})
 
@@ -450,10 +1894,41 @@ func (http *HTTP) createServer(call goja.FunctionCall) goja.Value {
 			onError, _ = goja.AssertFunction(errorCb)
 		}
 
+		// optional third argument:
+		//   { json: true, reviver } parses incoming text frames
+		//   { compression: true } offers permessage-deflate (ws.compressed reports the outcome)
+		//   { writeTimeout: ms } bounds each send (default 10s); a client too slow
+		//     to take a message gets an error event and its connection closed
+		jsonMode := false
+		compression := false
+		writeTimeout := defaultWSWriteTimeout
+		reviver := goja.Undefined()
+		if len(call.Arguments) > 2 && !goja.IsUndefined(call.Arguments[2]) && !goja.IsNull(call.Arguments[2]) {
+			optsObj := call.Arguments[2].ToObject(http.vm)
+			if jsonVal := optsObj.Get("json"); jsonVal != nil && !goja.IsUndefined(jsonVal) {
+				jsonMode = jsonVal.ToBoolean()
+			}
+			if reviverVal := optsObj.Get("reviver"); reviverVal != nil {
+				if _, ok := goja.AssertFunction(reviverVal); ok {
+					reviver = reviverVal
+				}
+			}
+			if compressionVal := optsObj.Get("compression"); compressionVal != nil && !goja.IsUndefined(compressionVal) {
+				compression = compressionVal.ToBoolean()
+			}
+			if timeoutVal := optsObj.Get("writeTimeout"); timeoutVal != nil && !goja.IsUndefined(timeoutVal) {
+				writeTimeout = time.Duration(timeoutVal.ToInteger()) * time.Millisecond
+				if writeTimeout <= 0 {
+					panic(http.vm.NewTypeError("writeTimeout must be positive"))
+				}
+			}
+		}
+
 		upgrader := websocket.Upgrader{
 			CheckOrigin: func(r *netHttp.Request) bool {
 				return true
 			},
+			EnableCompression: compression,
 		}
 
 		mux, ok := goServer.Handler.(*netHttp.ServeMux)
@@ -472,40 +1947,38 @@ func (http *HTTP) createServer(call goja.FunctionCall) goja.Value {
 			if err != nil {
 				if onError != nil {
 					errMsg := err.Error() 
-					http.taskQueue <- func() {
+					http.schedule("websocket error", func() {
             onError(goja.Undefined(), http.vm.ToValue(errMsg))
-					}
+					})
 				}
 				return
 			}
 
-			const (
-				wsConnecting = 0
-				wsOpen       = 1
-				wsClosing    = 2
-				wsClosed     = 3
-			)
+			http.handlesMu.Lock()
+			http.sockets[conn] = wsPath
+			http.handlesMu.Unlock()
+
+			// the upgrader accepts permessage-deflate whenever it's enabled and offered
+			compressed := compression && offersDeflate(r)
 
 			var writeMu sync.Mutex
 			var state int = wsOpen
+			closeInfo := wsCloseInfo{code: websocket.CloseAbnormalClosure} // until a close frame says otherwise
 			ctx, cancel := context.WithCancel(context.Background())
 
 			// Create and setup WebSocket object in VM-safe goroutine
 			wsObjChan := make(chan *goja.Object)
-			http.taskQueue <- func() {
+			http.schedule("websocket setup", func() {
 				wsObj := http.vm.NewObject()
 				
 				wsObj.Set("readyState", wsOpen)
+				wsObj.Set("compressed", compressed)
 				wsObj.Set("CONNECTING", wsConnecting)
 				wsObj.Set("OPEN", wsOpen)
 				wsObj.Set("CLOSING", wsClosing)
 				wsObj.Set("CLOSED", wsClosed)
 
-				wsObj.Set("send", func(call goja.FunctionCall) goja.Value {
-					if len(call.Arguments) < 1 {
-						panic(http.vm.ToValue("send requires a message"))
-					}
-
+				writeText := func(message []byte) {
 					writeMu.Lock()
 					currentState := state
 					writeMu.Unlock()
@@ -514,50 +1987,96 @@ func (http *HTTP) createServer(call goja.FunctionCall) goja.Value {
 						panic(http.vm.ToValue("websocket connection is not open"))
 					}
 
-					message := call.Arguments[0].String()
-
 					writeMu.Lock()
-					err := conn.WriteMessage(websocket.TextMessage, []byte(message))
+					conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+					err := conn.WriteMessage(websocket.TextMessage, message)
+					var netErr net.Error
+					timedOut := errors.As(err, &netErr) && netErr.Timeout()
+					if timedOut {
+						// the client stopped reading; drop it rather than block the loop again
+						state = wsClosing
+						cancel()
+						conn.UnderlyingConn().Close()
+					}
 					writeMu.Unlock()
 
+					if timedOut {
+						wsObj.Set("readyState", wsClosing)
+					}
+
 					if err != nil && onError != nil {
 						errMsg := err.Error()
-						http.taskQueue <- func() {
+						http.schedule("websocket error", func() {
 							onError(goja.Undefined(), http.vm.ToValue(errMsg))
-						}
+						})
+					}
+				}
+
+				wsObj.Set("send", func(call goja.FunctionCall) goja.Value {
+					if len(call.Arguments) < 1 {
+						panic(http.vm.ToValue("send requires a message"))
+					}
+
+					writeText([]byte(call.Arguments[0].String()))
+
+					return goja.Undefined()
+				})
+
+				wsObj.Set("sendJSON", func(call goja.FunctionCall) goja.Value {
+					if len(call.Arguments) < 1 {
+						panic(http.vm.ToValue("sendJSON requires a value"))
+					}
+
+					// sendJSON(value, [replacer])
+					message, err := jsonStringify(http.vm, call.Arguments[0], call.Argument(1), nil)
+					if err != nil {
+						panic(http.vm.NewGoError(fmt.Errorf("sendJSON: %w", err)))
 					}
 
+					writeText([]byte(message))
+
 					return goja.Undefined()
 				})
 
+				// close([code=1000], [reason])
 				wsObj.Set("close", func(call goja.FunctionCall) goja.Value {
+					code := websocket.CloseNormalClosure
+					if v := call.Argument(0); !goja.IsUndefined(v) {
+						code = int(v.ToInteger())
+					}
+					reason := ""
+					if v := call.Argument(1); !goja.IsUndefined(v) {
+						reason = v.String()
+					}
+
 					writeMu.Lock()
 					if state == wsOpen || state == wsConnecting {
 						state = wsClosing
-						closeMsg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+						closeMsg := websocket.FormatCloseMessage(code, reason)
 						conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(time.Second))
+						closeInfo = wsCloseInfo{code: code, reason: reason, wasClean: true}
 						cancel()
 					}
 					writeMu.Unlock()
 
-					http.taskQueue <- func() {
+					http.schedule("websocket state", func() {
 						writeMu.Lock()
 						currentState := state
 						writeMu.Unlock()
 						wsObj.Set("readyState", currentState)
-					}
+					})
 
 					return goja.Undefined()
 				})
 				
 				wsObjChan <- wsObj
-			}
+			})
 			wsObj := <-wsObjChan
 
 			if onOpen != nil {
-				http.taskQueue <- func() {
+				http.schedule("websocket open", func() {
           onOpen(goja.Undefined(), wsObj)
-				}
+				})
 			}
 
       done := http.runtime.KeepAlive()
@@ -570,11 +2089,15 @@ func (http *HTTP) createServer(call goja.FunctionCall) goja.Value {
 					state = wsClosed
 					writeMu.Unlock()
 
-          http.taskQueue <- func() {
+          http.schedule("websocket state", func() {
             wsObj.Set("readyState", wsClosed)
-          }
+          })
 
 					conn.Close()
+
+					http.handlesMu.Lock()
+					delete(http.sockets, conn)
+					http.handlesMu.Unlock()
 				}()
 
 				readDone := make(chan struct{})
@@ -596,14 +2119,22 @@ func (http *HTTP) createServer(call goja.FunctionCall) goja.Value {
 					messageType, message, err := conn.ReadMessage()
 
 					if err != nil {
+						var closeErr *websocket.CloseError
 						select {
 						case <-ctx.Done():
 						default:
+							if errors.As(err, &closeErr) {
+								// the peer closed the connection; not an error
+								writeMu.Lock()
+								closeInfo = wsCloseInfo{code: closeErr.Code, reason: closeErr.Text, wasClean: true}
+								writeMu.Unlock()
+								break
+							}
 							if onError != nil {
 								errMsg := err.Error()
-								http.taskQueue <- func() {
+								http.schedule("websocket error", func() {
                   onError(goja.Undefined(), http.vm.ToValue(errMsg))
-								}
+								})
 							}
 						}
 						break
@@ -613,6 +2144,19 @@ func (http *HTTP) createServer(call goja.FunctionCall) goja.Value {
 						var msgData any
 						if messageType == websocket.TextMessage {
 							msgData = string(message)
+
+							if jsonMode {
+								// validate here, but parse on the loop so the reviver can run
+								if err := json.Unmarshal(message, new(json.RawMessage)); err != nil {
+									if onError != nil {
+										errMsg := fmt.Sprintf("invalid JSON message: %v", err)
+										http.schedule("websocket error", func() {
+											onError(goja.Undefined(), http.vm.ToValue(errMsg))
+										})
+									}
+									continue
+								}
+							}
 						} else {
 							msgData = message
 						}
@@ -620,12 +2164,23 @@ func (http *HTTP) createServer(call goja.FunctionCall) goja.Value {
 						capturedData := msgData
 						capturedType := messageType
 
-						http.taskQueue <- func() {
+						http.schedule("websocket message", func() {
               msgObj := http.vm.NewObject()
-              msgObj.Set("data", capturedData)
+              if jsonMode && capturedType == websocket.TextMessage {
+                parsed, err := jsonParse(http.vm, capturedData.(string), reviver)
+                if err != nil {
+                  if onError != nil {
+                    onError(goja.Undefined(), http.vm.ToValue(fmt.Sprintf("invalid JSON message: %v", err)))
+                  }
+                  return
+                }
+                msgObj.Set("data", parsed)
+              } else {
+                msgObj.Set("data", capturedData)
+              }
               msgObj.Set("type", capturedType)
               onMessage(goja.Undefined(), msgObj)
-						}
+						})
 					}
 				}
 
@@ -638,15 +2193,23 @@ func (http *HTTP) createServer(call goja.FunctionCall) goja.Value {
 				writeMu.Unlock()
 
 				if shouldUpdateState {
-          http.taskQueue <- func() {
+          http.schedule("websocket state", func() {
             wsObj.Set("readyState", wsClosing)
-          }
+          })
 				}
 
 				if onClose != nil {
-					http.taskQueue <- func() {
-            onClose(goja.Undefined())
-					}
+					writeMu.Lock()
+					info := closeInfo
+					writeMu.Unlock()
+
+					http.schedule("websocket close", func() {
+            event := http.vm.NewObject()
+            event.Set("code", info.code)
+            event.Set("reason", info.reason)
+            event.Set("wasClean", info.wasClean)
+            onClose(goja.Undefined(), event)
+					})
 				}
 			}()
 		})
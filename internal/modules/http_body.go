@@ -0,0 +1,246 @@
+package modules
+
+import (
+	"io"
+	netHttp "net/http"
+	"sync"
+
+	"github.com/dop251/goja"
+)
+
+// bodyChunkSize is the most a single read() of a streamed body returns.
+const bodyChunkSize = 32 * 1024
+
+// asyncIteratorSymbol returns Symbol.asyncIterator, defining it when the VM
+// lacks it. The fallback matches the Symbol.for key esbuild's for-await
+// helper looks up, so transpiled `for await` loops find our iterators.
+func asyncIteratorSymbol(vm *goja.Runtime) *goja.Symbol {
+	symbolCtor := vm.Get("Symbol").ToObject(vm)
+	if sym, ok := symbolCtor.Get("asyncIterator").(*goja.Symbol); ok {
+		return sym
+	}
+
+	symbolFor, _ := goja.AssertFunction(symbolCtor.Get("for"))
+	v, err := symbolFor(symbolCtor, vm.ToValue("Symbol.asyncIterator"))
+	if err != nil {
+		panic(err)
+	}
+	sym := v.(*goja.Symbol)
+	symbolCtor.DefineDataProperty("asyncIterator", sym, goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_FALSE)
+	return sym
+}
+
+// loopValue is a response value that can only be made on the VM goroutine,
+// such as a streamed body. createProxy builds it on first use, so the proxy
+// and the resolved response share it.
+type loopValue struct {
+	build func() goja.Value
+	value goja.Value
+}
+
+// get builds the value if needed. It must run on the VM goroutine.
+func (l *loopValue) get() goja.Value {
+	if l.value == nil {
+		l.value = l.build()
+	}
+	return l.value
+}
+
+// newBodyStream exposes a response body as a minimal ReadableStream:
+// body.getReader().read() and `for await (const chunk of body)` both yield
+// Uint8Array chunks as they arrive instead of buffering the whole payload.
+// release is called once the body is exhausted, fails or is cancelled.
+// It must run on the VM goroutine.
+//
+// JavaScript usage:
+//
+//	const res = await http.get(url, { stream: true });
+//	for await (const chunk of res.body) { ... }
+//
+//	const reader = res.body.getReader();
+//	const { value, done } = await reader.read();
+func (http *HTTP) newBodyStream(body io.ReadCloser, release func()) *goja.Object {
+	vm := http.vm
+
+	var mu sync.Mutex // one read at a time, in call order
+	var closeOnce sync.Once
+	closed := make(chan struct{})
+
+	// finish may run while a read is blocked; closing the body unblocks it
+	finish := func() {
+		closeOnce.Do(func() {
+			close(closed)
+			body.Close()
+			release()
+		})
+	}
+	isClosed := func() bool {
+		select {
+		case <-closed:
+			return true
+		default:
+			return false
+		}
+	}
+
+	// readChunk reads the next chunk, returning nil once the body is done
+	readChunk := func() ([]byte, error) {
+		buf := make([]byte, bodyChunkSize)
+		for !isClosed() {
+			n, err := body.Read(buf)
+			if n > 0 {
+				return buf[:n], nil // any error is reported again by the next read
+			}
+			if err != nil {
+				cancelled := isClosed()
+				finish()
+				if err != io.EOF && !cancelled {
+					return nil, err
+				}
+			}
+		}
+		return nil, nil
+	}
+
+	result := func(value goja.Value, done bool) goja.Value {
+		obj := vm.NewObject()
+		obj.Set("value", value)
+		obj.Set("done", done)
+		return obj
+	}
+
+	read := func(call goja.FunctionCall) goja.Value {
+		promise := &Promise{
+			vm:          vm,
+			runtime:     http.runtime,
+			state:       PromisePending,
+			onFulfilled: []goja.Callable{},
+			onRejected:  []goja.Callable{},
+		}
+
+		done := http.runtime.KeepAlive()
+		go func() {
+			mu.Lock()
+			chunk, err := readChunk()
+			mu.Unlock()
+
+			// the chunk and result objects are built on the VM goroutine
+			http.schedule("http body read", func() {
+				defer done()
+				switch {
+				case err != nil:
+					promise.reject(vm.NewGoError(err))
+				case chunk == nil:
+					promise.resolve(result(goja.Undefined(), true))
+				default:
+					promise.resolve(result(newUint8Array(vm, chunk), false))
+				}
+			})
+		}()
+
+		return CreatePromiseObject(vm, promise)
+	}
+
+	cancel := func(call goja.FunctionCall) goja.Value {
+		finish()
+
+		promise := &Promise{
+			vm:          vm,
+			runtime:     http.runtime,
+			state:       PromisePending,
+			onFulfilled: []goja.Callable{},
+			onRejected:  []goja.Callable{},
+		}
+		promise.resolve(result(goja.Undefined(), true))
+		return CreatePromiseObject(vm, promise)
+	}
+
+	stream := vm.NewObject()
+	stream.Set("getReader", func(call goja.FunctionCall) goja.Value {
+		reader := vm.NewObject()
+		reader.Set("read", read)
+		reader.Set("cancel", cancel)
+		reader.Set("releaseLock", func(call goja.FunctionCall) goja.Value { return goja.Undefined() })
+		return reader
+	})
+	stream.Set("cancel", cancel)
+	stream.SetSymbol(asyncIteratorSymbol(vm), func(call goja.FunctionCall) goja.Value {
+		iterator := vm.NewObject()
+		iterator.Set("next", read)
+		iterator.Set("return", cancel) // break out of for-await closes the body
+		return iterator
+	})
+
+	return stream
+}
+
+// streamRequestBody adds req.on(event, listener) for a server started with
+// streamBody: true and returns a function that starts reading the body. Each
+// chunk is emitted as 'data' (a Uint8Array) as soon as it arrives, which for
+// chunked uploads means before the client has finished sending, followed by
+// 'end', or 'error' if the read fails. Reading stops once the response has
+// been sent (ended is closed), since the server discards the rest.
+//
+// JavaScript usage:
+//
+//	http.createServer((req, res) => {
+//	  let size = 0;
+//	  req.on('data', (chunk) => { size += chunk.length; });
+//	  req.on('end', () => res.end(String(size)));
+//	}, { streamBody: true });
+func (http *HTTP) streamRequestBody(r *netHttp.Request, reqObj *goja.Object, ended <-chan struct{}) func() {
+	vm := http.vm
+	listeners := map[string][]goja.Callable{} // only touched on the loop
+
+	reqObj.Set("on", func(call goja.FunctionCall) goja.Value {
+		event := call.Argument(0).String()
+		fn, ok := goja.AssertFunction(call.Argument(1))
+		if !ok {
+			panic(vm.NewTypeError("req.on requires a listener function"))
+		}
+		listeners[event] = append(listeners[event], fn)
+		return reqObj
+	})
+
+	// emit runs event's listeners on the loop; arg builds their argument there
+	emit := func(event string, arg func() goja.Value) {
+		http.schedule("http request "+event, func() {
+			var args []goja.Value
+			if arg != nil {
+				args = append(args, arg())
+			}
+			for _, fn := range listeners[event] {
+				fn(reqObj, args...)
+			}
+		})
+	}
+
+	return func() {
+		go func() {
+			defer r.Body.Close()
+
+			buf := make([]byte, bodyChunkSize)
+			for {
+				n, err := r.Body.Read(buf)
+				select {
+				case <-ended:
+					return
+				default:
+				}
+
+				if n > 0 {
+					chunk := append([]byte(nil), buf[:n]...)
+					emit("data", func() goja.Value { return newUint8Array(vm, chunk) })
+				}
+				if err == io.EOF {
+					emit("end", nil)
+					return
+				}
+				if err != nil {
+					emit("error", func() goja.Value { return vm.NewGoError(err) })
+					return
+				}
+			}
+		}()
+	}
+}
@@ -0,0 +1,148 @@
+package modules
+
+import (
+	"fmt"
+	netHttp "net/http"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// circuitState is where a host's circuit is in the breaker's cycle.
+type circuitState int
+
+const (
+	circuitClosed   circuitState = iota // requests flow; failures are counted
+	circuitOpen                         // requests fail fast until the reset window has passed
+	circuitHalfOpen                     // one probe request decides whether to close or reopen
+)
+
+// circuit is the breaker state of one host.
+type circuit struct {
+	state    circuitState
+	failures int       // consecutive failures while closed
+	openedAt time.Time // when the circuit last opened
+	probing  bool      // a half-open probe is in flight
+}
+
+// circuitBreaker stops a client from hammering a host that keeps failing.
+// After failureThreshold consecutive failures (network errors or 5xx
+// responses) the host's circuit opens and requests to it fail at once. Once
+// reset has passed, a single probe request is let through: success closes
+// the circuit, failure opens it for another window. Hosts are tracked
+// separately, and requests may run on any goroutine.
+type circuitBreaker struct {
+	threshold int
+	reset     time.Duration
+	mu        sync.Mutex
+	hosts     map[string]*circuit
+}
+
+func newCircuitBreaker(threshold int, reset time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		reset:     reset,
+		hosts:     make(map[string]*circuit),
+	}
+}
+
+// allow reports whether a request to host may be sent at now, returning the
+// fast-fail error when it may not.
+func (b *circuitBreaker) allow(host string, now time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.hosts[host]
+	if !ok {
+		return nil
+	}
+	switch c.state {
+	case circuitOpen:
+		if wait := c.openedAt.Add(b.reset).Sub(now); wait > 0 {
+			return fmt.Errorf("circuit open for %s: retry in %v", host, wait.Round(time.Millisecond))
+		}
+		c.state = circuitHalfOpen
+		c.probing = true
+	case circuitHalfOpen:
+		if c.probing {
+			return fmt.Errorf("circuit open for %s: waiting on a probe request", host)
+		}
+		c.probing = true
+	}
+	return nil
+}
+
+// record notes the outcome of a request to host.
+func (b *circuitBreaker) record(host string, failed bool, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.hosts[host]
+	if !failed {
+		if ok {
+			delete(b.hosts, host) // back to closed with no failures
+		}
+		return
+	}
+	if !ok {
+		c = &circuit{}
+		b.hosts[host] = c
+	}
+
+	c.probing = false
+	c.failures++
+	if c.state == circuitHalfOpen || c.failures >= b.threshold {
+		c.state = circuitOpen
+		c.openedAt = now
+	}
+}
+
+// breakerTransport checks every round trip, redirects included, against a
+// circuit breaker.
+type breakerTransport struct {
+	next    netHttp.RoundTripper
+	breaker *circuitBreaker
+}
+
+func (t *breakerTransport) RoundTrip(req *netHttp.Request) (*netHttp.Response, error) {
+	host := req.URL.Host
+	if err := t.breaker.allow(host, time.Now()); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	t.breaker.record(host, err != nil || resp.StatusCode >= 500, time.Now())
+	return resp, err
+}
+
+// circuitBreakerOption reads a circuitBreaker option:
+// { failureThreshold = 5, resetMs = 30000 }.
+func (http *HTTP) circuitBreakerOption(optsObj *goja.Object, dst *clientOptions) {
+	v := optsObj.Get("circuitBreaker")
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		return
+	}
+	cbObj := v.ToObject(http.vm)
+
+	threshold := 5
+	if n := cbObj.Get("failureThreshold"); n != nil && !goja.IsUndefined(n) {
+		if n.ToInteger() < 1 {
+			panic(http.vm.NewTypeError("circuitBreaker.failureThreshold must be at least 1"))
+		}
+		threshold = int(n.ToInteger())
+	}
+
+	reset := 30 * time.Second
+	if n := cbObj.Get("resetMs"); n != nil && !goja.IsUndefined(n) {
+		if n.ToInteger() < 0 {
+			panic(http.vm.NewTypeError("circuitBreaker.resetMs must be a non-negative number"))
+		}
+		reset = time.Duration(n.ToInteger()) * time.Millisecond
+	}
+
+	dst.breaker = newCircuitBreaker(threshold, reset)
+}
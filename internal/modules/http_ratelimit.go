@@ -0,0 +1,128 @@
+package modules
+
+import (
+	"fmt"
+	"math"
+	netHttp "net/http"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// rateLimiter counts requests per key over a sliding window. It is only used
+// from the VM goroutine, so it needs no locking.
+type rateLimiter struct {
+	window    time.Duration
+	max       int
+	hits      map[string][]time.Time // request times within the window, oldest first
+	lastSweep time.Time
+}
+
+func newRateLimiter(window time.Duration, max int) *rateLimiter {
+	return &rateLimiter{
+		window:    window,
+		max:       max,
+		hits:      make(map[string][]time.Time),
+		lastSweep: time.Now(),
+	}
+}
+
+// allow records a request for key at now and reports whether it is within the
+// limit. When it isn't, retryAfter is how long until the oldest counted
+// request leaves the window.
+func (l *rateLimiter) allow(key string, now time.Time) (ok bool, retryAfter time.Duration) {
+	cutoff := now.Add(-l.window)
+	l.sweep(now, cutoff)
+
+	hits := l.hits[key]
+	i := 0
+	for i < len(hits) && !hits[i].After(cutoff) {
+		i++
+	}
+	hits = hits[i:]
+
+	if len(hits) >= l.max {
+		l.hits[key] = hits
+		return false, hits[0].Sub(cutoff)
+	}
+
+	l.hits[key] = append(hits, now)
+	return true, 0
+}
+
+// sweep drops keys with no recent requests, at most once per window, so idle
+// clients don't accumulate.
+func (l *rateLimiter) sweep(now, cutoff time.Time) {
+	if now.Sub(l.lastSweep) < l.window {
+		return
+	}
+	l.lastSweep = now
+
+	for key, hits := range l.hits {
+		if len(hits) == 0 || !hits[len(hits)-1].After(cutoff) {
+			delete(l.hits, key)
+		}
+	}
+}
+
+// rateLimitMiddleware implements server.rateLimit({ windowMs, max, keyBy }).
+// Requests beyond max per key within the last windowMs get a 429 with a
+// Retry-After header instead of reaching later middleware and the handler.
+// The key is req.remoteAddress unless keyBy(req) returns one.
+func (http *HTTP) rateLimitMiddleware(opts goja.Value) goja.Value {
+	window := time.Minute
+	max := 60
+	var keyBy goja.Callable
+
+	if opts != nil && !goja.IsUndefined(opts) && !goja.IsNull(opts) {
+		optsObj := opts.ToObject(http.vm)
+		if v := optsObj.Get("windowMs"); v != nil && !goja.IsUndefined(v) {
+			window = time.Duration(v.ToInteger()) * time.Millisecond
+		}
+		if v := optsObj.Get("max"); v != nil && !goja.IsUndefined(v) {
+			max = int(v.ToInteger())
+		}
+		if v := optsObj.Get("keyBy"); v != nil && !goja.IsUndefined(v) {
+			fn, ok := goja.AssertFunction(v)
+			if !ok {
+				panic(http.vm.NewTypeError("rateLimit keyBy must be a function"))
+			}
+			keyBy = fn
+		}
+	}
+	if window <= 0 || max <= 0 {
+		panic(http.vm.NewTypeError("rateLimit windowMs and max must be positive"))
+	}
+
+	limiter := newRateLimiter(window, max)
+
+	return http.vm.ToValue(func(call goja.FunctionCall) goja.Value {
+		req := call.Argument(0).ToObject(http.vm)
+		res := call.Argument(1).ToObject(http.vm)
+		next, _ := goja.AssertFunction(call.Argument(2))
+
+		key := req.Get("remoteAddress")
+		if keyBy != nil {
+			k, err := keyBy(goja.Undefined(), req)
+			if err != nil {
+				panic(err)
+			}
+			key = k
+		}
+
+		ok, retryAfter := limiter.allow(key.String(), time.Now())
+		if ok {
+			if next != nil {
+				next(goja.Undefined())
+			}
+			return goja.Undefined()
+		}
+
+		setHeader, _ := goja.AssertFunction(res.Get("setHeader"))
+		end, _ := goja.AssertFunction(res.Get("end"))
+		res.Set("statusCode", netHttp.StatusTooManyRequests)
+		setHeader(res, http.vm.ToValue("Retry-After"), http.vm.ToValue(fmt.Sprint(int(math.Ceil(retryAfter.Seconds())))))
+		end(res, http.vm.ToValue("Too Many Requests"))
+		return goja.Undefined()
+	})
+}
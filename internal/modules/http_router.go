@@ -0,0 +1,107 @@
+package modules
+
+import (
+	"fmt"
+	netHttp "net/http"
+	netUrl "net/url"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// route is a handler registered with server.get, server.post and friends.
+type route struct {
+	method   string   // "" matches every method (server.all)
+	segments []string // the pattern's path segments; ":name" captures, a final "*" captures the rest
+	handler  goja.Callable
+}
+
+// router dispatches requests to routes by method and path. Routes are
+// tried in the order they were added and the first match wins.
+//
+// Patterns are literal segments, ":name" segments that capture one segment
+// into req.params.name, and an optional final "*" that captures the rest of
+// the path into req.params['*']:
+//
+//	server.get('/users/:id', (req, res) => res.json({ id: req.params.id }));
+//	server.get('/static/*', (req, res) => serve(req.params['*']));
+//
+// A GET route also answers HEAD requests. Trailing slashes are ignored.
+type router struct {
+	routes []*route
+}
+
+// add registers handler for method and pattern.
+func (rt *router) add(method, pattern string, handler goja.Callable) error {
+	if !strings.HasPrefix(pattern, "/") {
+		return fmt.Errorf("route pattern must start with /: %q", pattern)
+	}
+
+	segments := splitPath(pattern)
+	for i, seg := range segments {
+		switch {
+		case seg == "*" && i != len(segments)-1:
+			return fmt.Errorf("route pattern %q: * must be the last segment", pattern)
+		case seg == ":":
+			return fmt.Errorf("route pattern %q: parameter needs a name", pattern)
+		}
+	}
+
+	rt.routes = append(rt.routes, &route{method: method, segments: segments, handler: handler})
+	return nil
+}
+
+// match finds the route for method and the escaped request path, returning
+// its handler and the captured parameters.
+func (rt *router) match(method, escapedPath string) (goja.Callable, map[string]string, bool) {
+	segments := splitPath(escapedPath)
+	for i, seg := range segments {
+		if unescaped, err := netUrl.PathUnescape(seg); err == nil {
+			segments[i] = unescaped
+		}
+	}
+
+	for _, r := range rt.routes {
+		if r.method != "" && r.method != method && !(r.method == netHttp.MethodGet && method == netHttp.MethodHead) {
+			continue
+		}
+		if params, ok := r.matchPath(segments); ok {
+			return r.handler, params, true
+		}
+	}
+	return nil, nil, false
+}
+
+// matchPath matches the request's unescaped path segments against the
+// route's pattern.
+func (r *route) matchPath(segments []string) (map[string]string, bool) {
+	params := map[string]string{}
+	for i, seg := range r.segments {
+		if seg == "*" {
+			params["*"] = strings.Join(segments[i:], "/")
+			return params, true
+		}
+		if i >= len(segments) {
+			return nil, false
+		}
+		if name, isParam := strings.CutPrefix(seg, ":"); isParam {
+			params[name] = segments[i]
+		} else if seg != segments[i] {
+			return nil, false
+		}
+	}
+	if len(segments) != len(r.segments) {
+		return nil, false
+	}
+	return params, true
+}
+
+// splitPath splits a path into its segments, ignoring leading and trailing
+// slashes ("/" has none).
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
@@ -0,0 +1,380 @@
+package modules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/gorilla/websocket"
+
+	"github.com/douglasjordan2/dougless/internal/permissions"
+)
+
+// readyState values, shared with server-side websockets.
+const (
+	wsConnecting = 0
+	wsOpen       = 1
+	wsClosing    = 2
+	wsClosed     = 3
+)
+
+// connect implements http.connect(url, { open, message, close, error,
+// reconnecting }, [options]), a client websocket. It returns the socket right
+// away in the CONNECTING state; open fires once the handshake completes. Like
+// server-side sockets, message receives { data, type } and close receives
+// { code, reason, wasClean }.
+//
+// JavaScript usage:
+//
+//	const ws = http.connect('wss://example.com/feed', {
+//	  open: (ws) => ws.send('subscribe'),
+//	  message: (msg) => console.log(msg.data),
+//	  close: (event) => console.log('closed', event.code),
+//	  error: (err) => console.error(err),
+//	});
+//	ws.close();
+//
+// With { reconnect: { maxRetries = 5, backoffMs = 1000 } } (or reconnect:
+// true for those defaults), a connection that ends without ws.close() is
+// dialed again: reconnecting receives { attempt, delayMs, code, reason } and
+// open fires again once it is back. The delay doubles with each consecutive
+// failed attempt, up to 30 seconds; close fires only after maxRetries
+// attempts in a row have failed, or once ws.close() is called.
+//
+//	http.connect(url, {
+//	  message: (msg) => render(msg.data),
+//	  reconnecting: (e) => console.log('retry', e.attempt, 'in', e.delayMs),
+//	}, { reconnect: { maxRetries: 10, backoffMs: 500 } });
+//
+// The host needs net permission; a denial is reported through error and close.
+func (http *HTTP) connect(call goja.FunctionCall) goja.Value {
+	http.argCheck(call, 1, "connect requires a URL")
+
+	url := call.Arguments[0].String()
+	if !strings.HasPrefix(url, "ws://") && !strings.HasPrefix(url, "wss://") {
+		panic(http.vm.NewTypeError(fmt.Sprintf("connect: %q is not a ws:// or wss:// URL", url)))
+	}
+
+	var onOpen, onMessage, onClose, onError, onReconnecting goja.Callable
+	if cb := call.Argument(1); !goja.IsUndefined(cb) && !goja.IsNull(cb) {
+		callbackObj := cb.ToObject(http.vm)
+		onOpen, _ = goja.AssertFunction(callbackObj.Get("open"))
+		onMessage, _ = goja.AssertFunction(callbackObj.Get("message"))
+		onClose, _ = goja.AssertFunction(callbackObj.Get("close"))
+		onError, _ = goja.AssertFunction(callbackObj.Get("error"))
+		onReconnecting, _ = goja.AssertFunction(callbackObj.Get("reconnecting"))
+	}
+
+	var reconnect wsReconnect
+	if opts := call.Argument(2); !goja.IsUndefined(opts) && !goja.IsNull(opts) {
+		reconnect = http.reconnectOption(opts.ToObject(http.vm).Get("reconnect"))
+	}
+
+	var mu sync.Mutex // guards conn, state and closeInfo; held while writing
+	var conn *websocket.Conn
+	state := wsConnecting
+	closeInfo := wsCloseInfo{code: websocket.CloseAbnormalClosure}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	wsObj := http.vm.NewObject()
+	wsObj.Set("url", url)
+	wsObj.Set("readyState", wsConnecting)
+	wsObj.Set("CONNECTING", wsConnecting)
+	wsObj.Set("OPEN", wsOpen)
+	wsObj.Set("CLOSING", wsClosing)
+	wsObj.Set("CLOSED", wsClosed)
+
+	reportError := func(msg string) {
+		if onError != nil {
+			http.schedule("websocket error", func() {
+				onError(goja.Undefined(), http.vm.ToValue(msg))
+			})
+		}
+	}
+
+	writeText := func(message []byte) {
+		mu.Lock()
+		if state != wsOpen {
+			mu.Unlock()
+			panic(http.vm.ToValue("websocket connection is not open"))
+		}
+		conn.SetWriteDeadline(time.Now().Add(defaultWSWriteTimeout))
+		err := conn.WriteMessage(websocket.TextMessage, message)
+		mu.Unlock()
+
+		if err != nil {
+			reportError(err.Error())
+		}
+	}
+
+	wsObj.Set("send", func(call goja.FunctionCall) goja.Value {
+		if len(call.Arguments) < 1 {
+			panic(http.vm.ToValue("send requires a message"))
+		}
+		writeText([]byte(call.Arguments[0].String()))
+		return goja.Undefined()
+	})
+
+	wsObj.Set("sendJSON", func(call goja.FunctionCall) goja.Value {
+		if len(call.Arguments) < 1 {
+			panic(http.vm.ToValue("sendJSON requires a value"))
+		}
+		message, err := jsonStringify(http.vm, call.Arguments[0], call.Argument(1), nil)
+		if err != nil {
+			panic(http.vm.NewGoError(fmt.Errorf("sendJSON: %w", err)))
+		}
+		writeText([]byte(message))
+		return goja.Undefined()
+	})
+
+	// close([code=1000], [reason]); closing while connecting abandons the handshake
+	wsObj.Set("close", func(call goja.FunctionCall) goja.Value {
+		code := websocket.CloseNormalClosure
+		if v := call.Argument(0); !goja.IsUndefined(v) {
+			code = int(v.ToInteger())
+		}
+		reason := ""
+		if v := call.Argument(1); !goja.IsUndefined(v) {
+			reason = v.String()
+		}
+
+		mu.Lock()
+		switch state {
+		case wsConnecting:
+			state = wsClosing
+			cancel()
+		case wsOpen:
+			state = wsClosing
+			closeMsg := websocket.FormatCloseMessage(code, reason)
+			conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(time.Second))
+			closeInfo = wsCloseInfo{code: code, reason: reason, wasClean: true}
+			cancel()
+		}
+		current := state
+		mu.Unlock()
+
+		wsObj.Set("readyState", current)
+		return goja.Undefined()
+	})
+
+	done := http.runtime.KeepAlive()
+	go func() {
+		defer done()
+		defer cancel()
+
+		// finish reports the socket closed, once, after any open/message events
+		finish := func() {
+			mu.Lock()
+			state = wsClosed
+			info := closeInfo
+			mu.Unlock()
+
+			http.schedule("websocket close", func() {
+				wsObj.Set("readyState", wsClosed)
+				if onClose != nil {
+					event := http.vm.NewObject()
+					event.Set("code", info.code)
+					event.Set("reason", info.reason)
+					event.Set("wasClean", info.wasClean)
+					onClose(goja.Undefined(), event)
+				}
+			})
+		}
+
+		permCtx, permCancel := context.WithTimeout(ctx, 30*time.Second)
+		host, canAccess := http.hasNetPermissions(url, permCtx)
+		permCancel()
+		if !canAccess {
+			reportError(permissions.GetManager().ErrorMessage(permissions.PermissionNet, host))
+			finish()
+			return
+		}
+
+		// serve runs one connection: it marks the socket open and delivers
+		// messages until the connection ends, reporting whether it ended
+		// unexpectedly (so the socket may reconnect)
+		serve := func(c *websocket.Conn) bool {
+			defer c.Close()
+
+			mu.Lock()
+			if state != wsConnecting { // closed while the handshake was finishing
+				mu.Unlock()
+				return false
+			}
+			conn = c
+			state = wsOpen
+			mu.Unlock()
+
+			http.handlesMu.Lock()
+			http.wsClients[c] = url
+			http.handlesMu.Unlock()
+			defer func() {
+				http.handlesMu.Lock()
+				delete(http.wsClients, c)
+				http.handlesMu.Unlock()
+			}()
+
+			http.schedule("websocket open", func() {
+				wsObj.Set("readyState", wsOpen)
+				if onOpen != nil {
+					onOpen(goja.Undefined(), wsObj)
+				}
+			})
+
+			// close() cancels ctx; unblock the pending read
+			connDone := make(chan struct{})
+			defer close(connDone)
+			go func() {
+				select {
+				case <-ctx.Done():
+					c.SetReadDeadline(time.Now())
+				case <-connDone:
+				}
+			}()
+
+			for {
+				messageType, message, err := c.ReadMessage()
+				if err != nil {
+					var closeErr *websocket.CloseError
+					var netErr net.Error
+					switch {
+					case ctx.Err() != nil && errors.As(err, &netErr) && netErr.Timeout():
+						return false // closed locally
+					case errors.As(err, &closeErr):
+						// the server closed the connection; not an error
+						mu.Lock()
+						closeInfo = wsCloseInfo{code: closeErr.Code, reason: closeErr.Text, wasClean: true}
+						mu.Unlock()
+					default:
+						reportError(err.Error())
+					}
+					return ctx.Err() == nil
+				}
+
+				if onMessage == nil {
+					continue
+				}
+				var data any = message
+				if messageType == websocket.TextMessage {
+					data = string(message)
+				}
+				http.schedule("websocket message", func() {
+					msgObj := http.vm.NewObject()
+					msgObj.Set("data", data)
+					msgObj.Set("type", messageType)
+					onMessage(goja.Undefined(), msgObj)
+				})
+			}
+		}
+
+		// retries counts consecutive failed dials and drops; it is reset
+		// whenever a connection opens
+		for retries := 0; ; retries++ {
+			c, _, err := websocket.DefaultDialer.DialContext(ctx, url, nil)
+			if err == nil {
+				retries = 0
+				if !serve(c) {
+					break
+				}
+			} else if ctx.Err() != nil {
+				break // abandoned by close()
+			} else if retries < reconnect.maxRetries {
+				mu.Lock()
+				closeInfo = wsCloseInfo{code: websocket.CloseAbnormalClosure, reason: err.Error()}
+				mu.Unlock()
+			} else {
+				reportError(err.Error())
+				break
+			}
+
+			if retries >= reconnect.maxRetries {
+				break
+			}
+
+			mu.Lock()
+			state = wsConnecting
+			info := closeInfo
+			closeInfo = wsCloseInfo{code: websocket.CloseAbnormalClosure}
+			mu.Unlock()
+
+			attempt, delay := retries+1, reconnect.delay(retries)
+			http.schedule("websocket reconnecting", func() {
+				wsObj.Set("readyState", wsConnecting)
+				if onReconnecting != nil {
+					event := http.vm.NewObject()
+					event.Set("attempt", attempt)
+					event.Set("delayMs", delay.Milliseconds())
+					event.Set("code", info.code)
+					event.Set("reason", info.reason)
+					onReconnecting(goja.Undefined(), event)
+				}
+			})
+
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+			case <-timer.C:
+			}
+			if ctx.Err() != nil {
+				break // closed while waiting to re-dial
+			}
+		}
+
+		finish()
+	}()
+
+	return wsObj
+}
+
+// wsReconnect is the reconnect option of http.connect. maxRetries is 0 when
+// reconnecting is off.
+type wsReconnect struct {
+	maxRetries int
+	backoff    time.Duration
+}
+
+// maxReconnectDelay caps the doubling delay between reconnect attempts.
+const maxReconnectDelay = 30 * time.Second
+
+// delay is how long to wait before the attempt after retries failed ones.
+func (r wsReconnect) delay(retries int) time.Duration {
+	d := r.backoff
+	for i := 0; i < retries && d < maxReconnectDelay; i++ {
+		d *= 2
+	}
+	return min(d, maxReconnectDelay)
+}
+
+// reconnectOption reads the reconnect option: true, or
+// { maxRetries, backoffMs }.
+func (http *HTTP) reconnectOption(v goja.Value) wsReconnect {
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) || !v.ToBoolean() {
+		return wsReconnect{}
+	}
+
+	r := wsReconnect{maxRetries: 5, backoff: time.Second}
+	if _, isBool := v.Export().(bool); isBool {
+		return r
+	}
+
+	obj := v.ToObject(http.vm)
+	if n := obj.Get("maxRetries"); n != nil && !goja.IsUndefined(n) {
+		if n.ToInteger() < 0 {
+			panic(http.vm.NewTypeError("reconnect.maxRetries must not be negative"))
+		}
+		r.maxRetries = int(n.ToInteger())
+	}
+	if n := obj.Get("backoffMs"); n != nil && !goja.IsUndefined(n) {
+		if n.ToInteger() < 0 {
+			panic(http.vm.NewTypeError("reconnect.backoffMs must not be negative"))
+		}
+		r.backoff = time.Duration(n.ToInteger()) * time.Millisecond
+	}
+	return r
+}
@@ -0,0 +1,196 @@
+package modules
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// jsonStringify serializes value with the VM's JSON.stringify so helpers such
+// as res.json and ws.sendJSON honor the same replacer and toJSON semantics as
+// scripts do. replacer and space may be undefined.
+func jsonStringify(vm *goja.Runtime, value, replacer, space goja.Value) (string, error) {
+	stringify, ok := goja.AssertFunction(vm.Get("JSON").ToObject(vm).Get("stringify"))
+	if !ok {
+		return "", errors.New("JSON.stringify is not available")
+	}
+
+	if replacer == nil {
+		replacer = goja.Undefined()
+	}
+	if space == nil {
+		space = goja.Undefined()
+	}
+
+	out, err := stringify(goja.Undefined(), value, replacer, space)
+	if err != nil {
+		return "", err
+	}
+	if goja.IsUndefined(out) {
+		return "", errors.New("value is not JSON-serializable")
+	}
+	return out.String(), nil
+}
+
+// jsonParse parses text with the VM's JSON.parse, applying reviver when it is
+// a function.
+func jsonParse(vm *goja.Runtime, text string, reviver goja.Value) (goja.Value, error) {
+	parse, ok := goja.AssertFunction(vm.Get("JSON").ToObject(vm).Get("parse"))
+	if !ok {
+		return nil, errors.New("JSON.parse is not available")
+	}
+
+	if reviver == nil {
+		reviver = goja.Undefined()
+	}
+	return parse(goja.Undefined(), vm.ToValue(text), reviver)
+}
+
+// maxSafeInteger is Number.MAX_SAFE_INTEGER (2^53 - 1); integers beyond it
+// can't be represented exactly as float64.
+const maxSafeInteger = 1<<53 - 1
+
+// SetupJSON adds Dougless extensions to the JSON global:
+//
+//	JSON.parseBig('{"id": 9223372036854775807}').id // "9223372036854775807"
+//
+// JSON.parseBig(text, [reviver]) behaves like JSON.parse, except integers
+// outside the safe range (±2^53-1) are returned as BigInt when the engine
+// supports it, and as decimal strings otherwise, instead of being rounded.
+func SetupJSON(vm *goja.Runtime) {
+	jsonObj := vm.Get("JSON").ToObject(vm)
+	jsonObj.Set("parseBig", func(call goja.FunctionCall) goja.Value {
+		value, err := jsonParseBig(vm, call.Argument(0).String())
+		if err != nil {
+			syntaxError, _ := vm.New(vm.Get("SyntaxError"), vm.ToValue(err.Error()))
+			panic(syntaxError)
+		}
+
+		reviver, ok := goja.AssertFunction(call.Argument(1))
+		if !ok {
+			return value
+		}
+		holder := vm.NewObject()
+		holder.Set("", value)
+		return jsonRevive(vm, reviver, holder, "")
+	})
+}
+
+// jsonParseBig decodes text, keeping object key order and large integers.
+func jsonParseBig(vm *goja.Runtime, text string) (goja.Value, error) {
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.UseNumber()
+
+	var bigInt goja.Callable
+	if fn, ok := goja.AssertFunction(vm.Get("BigInt")); ok {
+		bigInt = fn
+	}
+
+	var decode func(tok json.Token) (goja.Value, error)
+	decode = func(tok json.Token) (goja.Value, error) {
+		switch v := tok.(type) {
+		case json.Delim:
+			if v == '[' {
+				items := []any{}
+				for dec.More() {
+					next, err := dec.Token()
+					if err != nil {
+						return nil, err
+					}
+					item, err := decode(next)
+					if err != nil {
+						return nil, err
+					}
+					items = append(items, item)
+				}
+				if _, err := dec.Token(); err != nil {
+					return nil, err
+				}
+				return vm.NewArray(items...), nil
+			}
+
+			obj := vm.NewObject()
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				next, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				value, err := decode(next)
+				if err != nil {
+					return nil, err
+				}
+				obj.Set(key.(string), value)
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			return obj, nil
+		case json.Number:
+			if n, err := v.Int64(); err == nil && n >= -maxSafeInteger && n <= maxSafeInteger {
+				return vm.ToValue(n), nil
+			}
+			if isJSONInteger(string(v)) {
+				if bigInt != nil {
+					return bigInt(goja.Undefined(), vm.ToValue(string(v)))
+				}
+				return vm.ToValue(string(v)), nil
+			}
+			f, err := v.Float64()
+			if err != nil {
+				return nil, err
+			}
+			return vm.ToValue(f), nil
+		case nil:
+			return goja.Null(), nil
+		default:
+			return vm.ToValue(v), nil
+		}
+	}
+
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	value, err := decode(tok)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after JSON value")
+	}
+	return value, nil
+}
+
+// isJSONInteger reports whether a JSON number literal has no fraction or
+// exponent.
+func isJSONInteger(s string) bool {
+	return !strings.ContainsAny(s, ".eE")
+}
+
+// jsonRevive applies reviver bottom-up, as JSON.parse does.
+func jsonRevive(vm *goja.Runtime, reviver goja.Callable, holder *goja.Object, key string) goja.Value {
+	value := holder.Get(key)
+	if obj, ok := value.(*goja.Object); ok {
+		for _, k := range obj.Keys() {
+			revived := jsonRevive(vm, reviver, obj, k)
+			if goja.IsUndefined(revived) {
+				obj.Delete(k)
+			} else {
+				obj.Set(k, revived)
+			}
+		}
+	}
+
+	result, err := reviver(holder, vm.ToValue(key), value)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
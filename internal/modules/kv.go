@@ -0,0 +1,163 @@
+package modules
+
+import (
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"github.com/douglasjordan2/dougless/internal/event"
+)
+
+// kvEntry is a stored value and, when it has a TTL, the timer that
+// removes it.
+type kvEntry struct {
+	value   goja.Value
+	expires time.Time   // zero when the entry never expires
+	stop    func() bool // cancels the removal task queued on the event loop
+}
+
+// expired reports whether the entry's TTL has run out at now.
+func (e *kvEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+// KV is an in-memory key-value store shared by everything in the runtime,
+// for caching between requests in a long-running server. Keys are strings
+// and values are kept as they are, not copied.
+//
+// Available in JavaScript as:
+//
+//	const kv = require('kv');
+//	kv.set('user:42', user, { ttlMs: 60000 });
+//	kv.get('user:42');    // user, or undefined once expired
+//	kv.has('user:42');
+//	kv.delete('user:42'); // true if it was there
+//	kv.clear();
+//
+// Expired entries are removed by a task on the event loop, and never
+// returned even if that task hasn't run yet. Pending expiries don't keep
+// the runtime alive.
+type KV struct {
+	loop    *event.Loop
+	mu      sync.Mutex
+	entries map[string]*kvEntry
+}
+
+// NewKV creates the kv module; expiries run on loop.
+func NewKV(loop *event.Loop) *KV {
+	return &KV{
+		loop:    loop,
+		entries: make(map[string]*kvEntry),
+	}
+}
+
+func (kv *KV) Export(vm *goja.Runtime) goja.Value {
+	obj := vm.NewObject()
+
+	// set(key, value, [{ ttlMs }]) stores value, replacing any previous
+	// entry and its TTL
+	obj.Set("set", func(call goja.FunctionCall) goja.Value {
+		if len(call.Arguments) < 2 {
+			panic(vm.NewTypeError("kv.set requires a key and a value"))
+		}
+		key := call.Argument(0).String()
+
+		var ttl time.Duration
+		if opts := call.Argument(2); !goja.IsUndefined(opts) && !goja.IsNull(opts) {
+			if v := opts.ToObject(vm).Get("ttlMs"); v != nil && !goja.IsUndefined(v) {
+				if v.ToInteger() <= 0 {
+					panic(vm.NewTypeError("ttlMs must be a positive number"))
+				}
+				ttl = time.Duration(v.ToInteger()) * time.Millisecond
+			}
+		}
+
+		kv.set(key, call.Argument(1), ttl)
+		return goja.Undefined()
+	})
+
+	obj.Set("get", func(call goja.FunctionCall) goja.Value {
+		if entry := kv.lookup(call.Argument(0).String()); entry != nil {
+			return entry.value
+		}
+		return goja.Undefined()
+	})
+
+	obj.Set("has", func(call goja.FunctionCall) goja.Value {
+		return vm.ToValue(kv.lookup(call.Argument(0).String()) != nil)
+	})
+
+	obj.Set("delete", func(call goja.FunctionCall) goja.Value {
+		key := call.Argument(0).String()
+
+		kv.mu.Lock()
+		defer kv.mu.Unlock()
+		entry, ok := kv.entries[key]
+		if !ok {
+			return vm.ToValue(false)
+		}
+		kv.remove(key, entry)
+		return vm.ToValue(!entry.expired(kv.loop.Now()))
+	})
+
+	obj.Set("clear", func(call goja.FunctionCall) goja.Value {
+		kv.mu.Lock()
+		defer kv.mu.Unlock()
+		for key, entry := range kv.entries {
+			kv.remove(key, entry)
+		}
+		return goja.Undefined()
+	})
+
+	return obj
+}
+
+// set stores value under key, expiring after ttl when it is positive.
+func (kv *KV) set(key string, value goja.Value, ttl time.Duration) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	if old, ok := kv.entries[key]; ok {
+		kv.remove(key, old)
+	}
+
+	entry := &kvEntry{value: value}
+	if ttl > 0 {
+		entry.expires = kv.loop.Now().Add(ttl)
+		entry.stop = kv.loop.AfterFunc(ttl, event.Task{Name: "kv expiry", Callback: func() {
+			kv.mu.Lock()
+			defer kv.mu.Unlock()
+			// the key may have been set again since
+			if kv.entries[key] == entry {
+				delete(kv.entries, key)
+			}
+		}})
+	}
+	kv.entries[key] = entry
+}
+
+// lookup returns the live entry for key, or nil when there is none or it
+// has expired.
+func (kv *KV) lookup(key string) *kvEntry {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	entry, ok := kv.entries[key]
+	if !ok {
+		return nil
+	}
+	if entry.expired(kv.loop.Now()) {
+		kv.remove(key, entry)
+		return nil
+	}
+	return entry
+}
+
+// remove deletes key's entry and stops its expiry timer. kv.mu must be held.
+func (kv *KV) remove(key string, entry *kvEntry) {
+	if entry.stop != nil {
+		entry.stop()
+	}
+	delete(kv.entries, key)
+}
@@ -0,0 +1,128 @@
+package modules
+
+import (
+	"context"
+	"fmt"
+	"os"
+	goruntime "runtime"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"github.com/douglasjordan2/dougless/internal/permissions"
+)
+
+// OS reports information about the system the script runs on, without
+// shelling out, via require('os').
+//
+// Available in JavaScript as:
+//
+//	const os = require('os');
+//	os.platform(); // 'linux', 'darwin', 'windows', ... (Go's GOOS)
+//	os.arch();     // 'amd64', 'arm64', ... (Go's GOARCH)
+//	os.tmpdir();   // '/tmp'
+//	os.cpus();     // one entry per logical CPU; only the length is filled in
+//	os.hostname(); // needs --allow-sys (or --allow-sys=hostname)
+//	os.homedir();  // needs --allow-sys=homedir
+//	os.totalmem(); // bytes; needs --allow-sys=totalmem
+//	os.freemem();  // bytes; needs --allow-sys=freemem
+//
+// What identifies the machine or its user, or describes its load (the host
+// name, the home directory and memory figures), is gated by the sys
+// permission, keyed by the function's name so --allow-sys=hostname grants
+// only that. The platform, architecture, temp directory and CPU count are
+// what any program can assume about where it runs, so they are not.
+type OS struct {
+	vm *goja.Runtime
+}
+
+// NewOS creates the os module.
+func NewOS() *OS {
+	return &OS{}
+}
+
+func (o *OS) Export(vm *goja.Runtime) goja.Value {
+	o.vm = vm
+	obj := vm.NewObject()
+	obj.Set("platform", func(call goja.FunctionCall) goja.Value {
+		return vm.ToValue(goruntime.GOOS)
+	})
+	obj.Set("arch", func(call goja.FunctionCall) goja.Value {
+		return vm.ToValue(goruntime.GOARCH)
+	})
+	obj.Set("tmpdir", func(call goja.FunctionCall) goja.Value {
+		return vm.ToValue(os.TempDir())
+	})
+	obj.Set("cpus", o.cpus)
+	obj.Set("hostname", o.hostname)
+	obj.Set("homedir", o.homedir)
+	obj.Set("totalmem", o.totalmem)
+	obj.Set("freemem", o.freemem)
+	return obj
+}
+
+// cpus implements os.cpus(). Go doesn't report CPU models or times, so the
+// entries are empty objects; scripts use the length.
+func (o *OS) cpus(call goja.FunctionCall) goja.Value {
+	list := make([]any, goruntime.NumCPU())
+	for i := range list {
+		list[i] = o.vm.NewObject()
+	}
+	return o.vm.NewArray(list...)
+}
+
+// hostname implements os.hostname().
+func (o *OS) hostname(call goja.FunctionCall) goja.Value {
+	requireSys(o.vm, "hostname")
+
+	name, err := os.Hostname()
+	if err != nil {
+		panic(o.vm.NewGoError(err))
+	}
+	return o.vm.ToValue(name)
+}
+
+// homedir implements os.homedir().
+func (o *OS) homedir(call goja.FunctionCall) goja.Value {
+	requireSys(o.vm, "homedir")
+
+	dir, err := os.UserHomeDir()
+	if err != nil {
+		panic(o.vm.NewGoError(err))
+	}
+	return o.vm.ToValue(dir)
+}
+
+// totalmem implements os.totalmem().
+func (o *OS) totalmem(call goja.FunctionCall) goja.Value {
+	requireSys(o.vm, "totalmem")
+
+	total, _, err := memoryInfo()
+	if err != nil {
+		panic(o.vm.NewGoError(err))
+	}
+	return o.vm.ToValue(total)
+}
+
+// freemem implements os.freemem().
+func (o *OS) freemem(call goja.FunctionCall) goja.Value {
+	requireSys(o.vm, "freemem")
+
+	_, free, err := memoryInfo()
+	if err != nil {
+		panic(o.vm.NewGoError(err))
+	}
+	return o.vm.ToValue(free)
+}
+
+// requireSys throws the standard permission error unless the sys
+// permission for name is granted, prompting if needed.
+func requireSys(vm *goja.Runtime, name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	mgr := permissions.GetManager()
+	if !mgr.CheckWithPrompt(ctx, permissions.PermissionSys, name) {
+		panic(vm.NewGoError(fmt.Errorf("%s", mgr.ErrorMessage(permissions.PermissionSys, name))))
+	}
+}
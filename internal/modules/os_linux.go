@@ -0,0 +1,13 @@
+package modules
+
+import "syscall"
+
+// memoryInfo returns the system's total and free memory in bytes.
+func memoryInfo() (total, free uint64, err error) {
+	var info syscall.Sysinfo_t
+	if err := syscall.Sysinfo(&info); err != nil {
+		return 0, 0, err
+	}
+	unit := uint64(info.Unit)
+	return uint64(info.Totalram) * unit, uint64(info.Freeram) * unit, nil
+}
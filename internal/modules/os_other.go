@@ -0,0 +1,14 @@
+//go:build !linux
+
+package modules
+
+import (
+	"fmt"
+	goruntime "runtime"
+)
+
+// memoryInfo returns the system's total and free memory in bytes. It is
+// only implemented on Linux.
+func memoryInfo() (total, free uint64, err error) {
+	return 0, 0, fmt.Errorf("memory information is not available on %s", goruntime.GOOS)
+}
@@ -0,0 +1,84 @@
+package modules
+
+import (
+	"context"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"github.com/douglasjordan2/dougless/internal/permissions"
+)
+
+// Permissions lets scripts check what they may access before trying, like
+// Deno.permissions. Both calls are synchronous.
+//
+// Available globally in JavaScript as:
+//
+//	permissions.query({ name: 'read', resource: './config.json' });
+//	// 'granted' | 'prompt' | 'denied' - never prompts
+//
+//	if (permissions.request({ name: 'net', resource: 'api.example.com' })) {
+//	  // granted, by flags or by answering the prompt
+//	}
+//
+// Names are read, write, net, env, run and sys.
+type Permissions struct {
+	vm *goja.Runtime
+}
+
+// NewPermissions creates a new Permissions module instance.
+func NewPermissions() *Permissions {
+	return &Permissions{}
+}
+
+func (p *Permissions) Export(vm *goja.Runtime) goja.Value {
+	p.vm = vm
+	obj := vm.NewObject()
+	obj.Set("query", p.query)
+	obj.Set("request", p.request)
+	return obj
+}
+
+// query implements permissions.query({ name, resource }), reporting the
+// current state without prompting.
+func (p *Permissions) query(call goja.FunctionCall) goja.Value {
+	desc := p.descriptor(call.Argument(0), "permissions.query")
+	return p.vm.ToValue(string(permissions.GetManager().Query(desc)))
+}
+
+// request implements permissions.request({ name, resource }), prompting
+// when the permission isn't already decided and prompts are enabled.
+func (p *Permissions) request(call goja.FunctionCall) goja.Value {
+	desc := p.descriptor(call.Argument(0), "permissions.request")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return p.vm.ToValue(permissions.GetManager().CheckWithPrompt(ctx, desc.Name, desc.Resource))
+}
+
+// descriptor reads a { name, resource } argument, throwing a TypeError for
+// a missing or unknown name.
+func (p *Permissions) descriptor(v goja.Value, fn string) permissions.PermissionDescriptor {
+	if goja.IsUndefined(v) || goja.IsNull(v) {
+		panic(p.vm.NewTypeError(fn + " requires a { name, resource } descriptor"))
+	}
+	obj := v.ToObject(p.vm)
+
+	var name permissions.Permission
+	if n := obj.Get("name"); n != nil && !goja.IsUndefined(n) {
+		name = permissions.Permission(n.String())
+	}
+	switch name {
+	case permissions.PermissionRead, permissions.PermissionWrite, permissions.PermissionNet,
+		permissions.PermissionEnv, permissions.PermissionRun, permissions.PermissionSys:
+	default:
+		panic(p.vm.NewTypeError(fn + ": unknown permission name '" + string(name) + "'"))
+	}
+
+	resource := ""
+	if r := obj.Get("resource"); r != nil && !goja.IsUndefined(r) && !goja.IsNull(r) {
+		resource = r.String()
+	}
+	return permissions.PermissionDescriptor{Name: name, Resource: resource}
+}
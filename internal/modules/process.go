@@ -5,16 +5,27 @@ import (
 	"os"
 	"os/signal"
 	"runtime"
+	"sort"
+	"strings"
 	"syscall"
 
 	"github.com/dop251/goja"
+
+	"github.com/douglasjordan2/dougless/internal/permissions"
 )
 
+// Version is the Dougless runtime version (process.version, User-Agent).
+const Version = "0.8.0"
+
 type Process struct {
 	vm      *goja.Runtime
   runtime RuntimeKeepAlive
 	argv    []string
 	onExit  []func(int)
+	atExit  []func(int) // runtime hooks, run after the script's 'exit' listeners
+	sources []HandleSource // modules reported by getActiveHandles
+
+	onRejection []goja.Callable // process.on('unhandledRejection') listeners
 }
 
 func NewProcess(argv []string) *Process {
@@ -24,10 +35,27 @@ func NewProcess(argv []string) *Process {
 	}
 }
 
+// Argv returns process.argv: the executable, the script, then its arguments.
+func (p *Process) Argv() []string {
+	return p.argv
+}
+
+// OnExit registers fn to run when the script calls process.exit, after the
+// script's own 'exit' listeners.
+func (p *Process) OnExit(fn func(code int)) {
+	p.atExit = append(p.atExit, fn)
+}
+
 func (p *Process) SetRuntime(rt RuntimeKeepAlive) {
   p.runtime = rt
 }
 
+// AddHandleSource registers a module whose open resources should be
+// listed by process.getActiveHandles().
+func (p *Process) AddHandleSource(src HandleSource) {
+	p.sources = append(p.sources, src)
+}
+
 func (p *Process) Export(vm *goja.Runtime) goja.Value {
 	p.vm = vm
 	return vm.ToValue(p.createProcessAPI())
@@ -35,7 +63,7 @@ func (p *Process) Export(vm *goja.Runtime) goja.Value {
 
 func (p *Process) createProcessAPI() map[string]any {
 	return map[string]any{
-		"env":      p.getEnv(),
+		"env":      p.vm.NewDynamicObject(&processEnv{vm: p.vm}),
 		"argv":     p.argv,
 		"exit":     p.exit,
 		"cwd":      p.cwd,
@@ -43,24 +71,123 @@ func (p *Process) createProcessAPI() map[string]any {
 		"pid":      os.Getpid(),
 		"platform": p.getPlatform(),
 		"arch":     p.getArch(),
-		"version":  "v0.8.0", // Dougless runtime version
+		"version":  "v" + Version, // Dougless runtime version
 		"on":       p.on,
+
+		"getActiveHandles": p.getActiveHandles,
+		"memoryUsage":      p.memoryUsage,
 	}
 }
 
-func (p *Process) getEnv() map[string]string {
-	envMap := make(map[string]string)
-	for _, e := range os.Environ() {
-		for i := 0; i < len(e); i++ {
-			if e[i] == '=' {
-				key := e[:i]
-				value := e[i+1:]
-				envMap[key] = value
-				break
+// memoryUsage implements process.memoryUsage(), for watching memory growth
+// in long-running scripts. Figures are bytes from Go's runtime.MemStats:
+// heapUsed is the live heap, heapTotal the heap obtained from the OS, and
+// rss everything the Go runtime has obtained from the OS. external is
+// always 0, as nothing is allocated outside the Go heap.
+//
+// JavaScript usage:
+//
+//	const { heapUsed, heapTotal } = process.memoryUsage();
+func (p *Process) memoryUsage(call goja.FunctionCall) goja.Value {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	obj := p.vm.NewObject()
+	obj.Set("rss", stats.Sys)
+	obj.Set("heapTotal", stats.HeapSys)
+	obj.Set("heapUsed", stats.HeapAlloc)
+	obj.Set("external", 0)
+	return obj
+}
+
+// getActiveHandles implements process.getActiveHandles() - a snapshot of
+// the timers, servers and sockets currently keeping the runtime alive.
+//
+// JavaScript usage:
+//
+//	process.getActiveHandles();
+//	// [{ type: 'server', address: '127.0.0.1:8080' }, { type: 'timeout', id: '...', delay: 1000 }]
+func (p *Process) getActiveHandles(call goja.FunctionCall) goja.Value {
+	list := []any{}
+	for _, src := range p.sources {
+		for _, h := range src.ActiveHandles() {
+			obj := p.vm.NewObject()
+			obj.Set("type", h.Type)
+			for k, v := range h.Details {
+				obj.Set(k, v)
 			}
+			list = append(list, obj)
 		}
 	}
-	return envMap
+	return p.vm.NewArray(list...)
+}
+
+// processEnv backs process.env. Reading, setting or deleting a variable
+// needs the env permission for its name, and throws the standard permission
+// error otherwise; enumerating lists only the variables already granted.
+//
+//	process.env.API_KEY;           // needs --allow-env=API_KEY
+//	process.env.DEBUG = '1';       // so does setting it
+//	Object.keys(process.env);      // just the granted variables
+type processEnv struct {
+	vm *goja.Runtime
+}
+
+func (e *processEnv) Get(key string) goja.Value {
+	if inheritedProperty(e.vm, key) {
+		return nil // toString, toJSON and friends come from the prototype
+	}
+	requireEnv(e.vm, key)
+	if value, ok := os.LookupEnv(key); ok {
+		return e.vm.ToValue(value)
+	}
+	return goja.Undefined()
+}
+
+func (e *processEnv) Set(key string, val goja.Value) bool {
+	requireEnv(e.vm, key)
+	return os.Setenv(key, val.String()) == nil
+}
+
+func (e *processEnv) Has(key string) bool {
+	if inheritedProperty(e.vm, key) {
+		return false
+	}
+	requireEnv(e.vm, key)
+	_, ok := os.LookupEnv(key)
+	return ok
+}
+
+func (e *processEnv) Delete(key string) bool {
+	requireEnv(e.vm, key)
+	return os.Unsetenv(key) == nil
+}
+
+// Keys never prompts, so listing process.env doesn't ask about every
+// variable in the environment. They are sorted, as os.Environ's order isn't
+// stable.
+func (e *processEnv) Keys() []string {
+	mgr := permissions.GetManager()
+	var keys []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if name != "" && mgr.Check(permissions.PermissionEnv, name) {
+			keys = append(keys, name)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// inheritedProperty reports whether key is one of the properties
+// process.env gets from its prototype or that JSON.stringify looks up, so
+// using the object as an object doesn't trigger permission checks.
+func inheritedProperty(vm *goja.Runtime, key string) bool {
+	if key == "toJSON" {
+		return true
+	}
+	proto := vm.Get("Object").ToObject(vm).Get("prototype").ToObject(vm)
+	return proto.Get(key) != nil
 }
 
 func (p *Process) exit(call goja.FunctionCall) goja.Value {
@@ -72,6 +199,9 @@ func (p *Process) exit(call goja.FunctionCall) goja.Value {
 	for _, handler := range p.onExit {
 		handler(code)
 	}
+	for _, hook := range p.atExit {
+		hook(code)
+	}
 
 	os.Exit(code)
 	return goja.Undefined()
@@ -124,6 +254,9 @@ func (p *Process) on(call goja.FunctionCall) goja.Value {
 			callback(goja.Undefined(), p.vm.ToValue(code))
 		})
 
+	case "unhandledRejection":
+		p.onRejection = append(p.onRejection, callback)
+
 	case "SIGINT":
 		p.setupSignalHandler(syscall.SIGINT, callback)
 
@@ -140,6 +273,31 @@ func (p *Process) on(call goja.FunctionCall) goja.Value {
 	return goja.Undefined()
 }
 
+// EmitUnhandledRejection calls the process.on('unhandledRejection')
+// listeners with the reason and the promise, reporting false when there
+// are none so the runtime prints its default warning instead. It must run
+// on the VM goroutine.
+//
+//	process.on('unhandledRejection', (reason, promise) => {
+//	  log.error('unhandled rejection', reason);
+//	});
+func (p *Process) EmitUnhandledRejection(reason goja.Value, promise *Promise) (bool, error) {
+	if len(p.onRejection) == 0 {
+		return false, nil
+	}
+	if reason == nil {
+		reason = goja.Undefined()
+	}
+
+	promiseObj := CreatePromiseObject(p.vm, promise)
+	for _, listener := range p.onRejection {
+		if _, err := listener(goja.Undefined(), reason, promiseObj); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
 func (p *Process) setupSignalHandler(sig os.Signal, callback goja.Callable) {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, sig)
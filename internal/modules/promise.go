@@ -1,6 +1,7 @@
 package modules
 
 import (
+	"bytes"
 	"strconv"
 	"sync"
 
@@ -23,12 +24,21 @@ type Promise struct {
 	reason      goja.Value
 	onFulfilled []goja.Callable
 	onRejected  []goja.Callable
+	handled     bool   // a then/catch has been attached
+	stack       string // where the promise was rejected, when known
 	mu          sync.Mutex
 }
 
 func NewPromise(vm *goja.Runtime, executor goja.Callable) *Promise {
+	return newPromise(vm, nil, executor)
+}
+
+// newPromise is NewPromise with the runtime set before the executor runs,
+// so a rejection inside the executor can be tracked.
+func newPromise(vm *goja.Runtime, rt RuntimeKeepAlive, executor goja.Callable) *Promise {
 	p := &Promise{
 		vm:          vm,
+		runtime:     rt,
 		state:       PromisePending,
 		onFulfilled: []goja.Callable{},
 		onRejected:  []goja.Callable{},
@@ -40,13 +50,13 @@ func NewPromise(vm *goja.Runtime, executor goja.Callable) *Promise {
 	}
 
 	reject := func(call goja.FunctionCall) goja.Value {
-		p.reject(call.Argument(0))
+		p.rejectAt(call.Argument(0), rejectionStack(vm, call.Argument(0)))
 		return goja.Undefined()
 	}
 
 	_, err := executor(goja.Undefined(), vm.ToValue(resolve), vm.ToValue(reject))
 	if err != nil {
-		p.reject(vm.ToValue(err.Error()))
+		p.rejectAt(vm.ToValue(err.Error()), exceptionStack(err))
 	}
 
 	return p
@@ -56,6 +66,27 @@ func (p *Promise) SetRuntime(rt RuntimeKeepAlive) {
   p.runtime = rt
 }
 
+// MicrotaskQueue is implemented by runtimes that run promise reactions as
+// microtasks on their event loop, in the order they were queued.
+type MicrotaskQueue interface {
+	QueueMicrotask(fn func())
+}
+
+// react runs a promise reaction: as a microtask when the runtime has a
+// queue, otherwise on its own goroutine.
+func (p *Promise) react(fn func()) {
+	if queue, ok := p.runtime.(MicrotaskQueue); ok {
+		queue.QueueMicrotask(fn)
+		return
+	}
+
+	done := p.runtime.KeepAlive()
+	go func() {
+		defer done()
+		fn()
+	}()
+}
+
 func (p *Promise) resolve(value goja.Value) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -70,18 +101,52 @@ func (p *Promise) resolve(value goja.Value) {
 		h := handler // capture for closure
 		v := value   // capture value
 
-    done := p.runtime.KeepAlive()
-    go func() {
-      defer done()
-      h(goja.Undefined(), v)
-		}()
+		p.react(func() {
+			h(goja.Undefined(), v)
+		})
 	}
 
 	p.onFulfilled = nil
 	p.onRejected = nil
 }
 
+// RejectionTracker is implemented by runtimes that report rejections no
+// handler was attached to. TrackRejection is called when a promise without
+// handlers is rejected; the runtime checks Handled later, once the current
+// task and its microtasks have had a chance to attach one.
+type RejectionTracker interface {
+	TrackRejection(p *Promise)
+}
+
+// Reason is the value the promise was rejected with.
+func (p *Promise) Reason() goja.Value {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.reason
+}
+
+// Handled reports whether a then or catch has been attached.
+func (p *Promise) Handled() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.handled
+}
+
+// Stack is where the promise was rejected: the reason's own stack when it is
+// an Error, otherwise the JavaScript call stack at the rejection. It is empty
+// for rejections coming from Go.
+func (p *Promise) Stack() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stack
+}
+
 func (p *Promise) reject(reason goja.Value) {
+	p.rejectAt(reason, "")
+}
+
+// rejectAt rejects the promise, recording stack as where it happened.
+func (p *Promise) rejectAt(reason goja.Value, stack string) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -90,15 +155,21 @@ func (p *Promise) reject(reason goja.Value) {
 	}
 	p.state = PromiseRejected
 	p.reason = reason
+	p.stack = stack
+
+	if !p.handled {
+		if tracker, ok := p.runtime.(RejectionTracker); ok {
+			tracker.TrackRejection(p)
+		}
+	}
 
 	for _, handler := range p.onRejected {
 		h := handler // capture for closure
 		r := reason  // capture reason
-    done := p.runtime.KeepAlive()
-    go func() {
-      defer done()
-      h(goja.Undefined(), r)
-		}()
+
+		p.react(func() {
+			h(goja.Undefined(), r)
+		})
 	}
 
 	p.onFulfilled = nil
@@ -122,7 +193,7 @@ func (p *Promise) Then(onFulfilled, onRejected goja.Callable) *Promise {
 
 		result, err := onFulfilled(goja.Undefined(), call.Argument(0))
 		if err != nil {
-			newPromise.reject(p.vm.ToValue(err.Error()))
+			newPromise.rejectAt(p.vm.ToValue(err.Error()), exceptionStack(err))
 			return goja.Undefined()
 		}
 
@@ -154,13 +225,13 @@ func (p *Promise) Then(onFulfilled, onRejected goja.Callable) *Promise {
 
 	rejectedWrapper := func(call goja.FunctionCall) goja.Value {
 		if onRejected == nil {
-			newPromise.reject(call.Argument(0))
+			newPromise.rejectAt(call.Argument(0), p.Stack()) // keep the original site
 			return goja.Undefined()
 		}
 
 		result, err := onRejected(goja.Undefined(), call.Argument(0))
 		if err != nil {
-			newPromise.reject(p.vm.ToValue(err.Error()))
+			newPromise.rejectAt(p.vm.ToValue(err.Error()), exceptionStack(err))
 		} else {
 			newPromise.resolve(result)
 		}
@@ -171,6 +242,8 @@ func (p *Promise) Then(onFulfilled, onRejected goja.Callable) *Promise {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	p.handled = true
+
   switch p.state {
   case PromisePending:
     wrappedFulfilled, _ := goja.AssertFunction(p.vm.ToValue(fulfilledWrapper))
@@ -180,19 +253,105 @@ func (p *Promise) Then(onFulfilled, onRejected goja.Callable) *Promise {
     p.onRejected = append(p.onRejected, wrappedRejected)
   case PromiseFulfilled:
     val := p.value
-    fulfilledWrapper(goja.FunctionCall{Arguments: []goja.Value{val}})
+    p.settled(func() {
+      fulfilledWrapper(goja.FunctionCall{Arguments: []goja.Value{val}})
+    })
   case PromiseRejected:
     rsn := p.reason
-    rejectedWrapper(goja.FunctionCall{Arguments: []goja.Value{rsn}})
+    p.settled(func() {
+      rejectedWrapper(goja.FunctionCall{Arguments: []goja.Value{rsn}})
+    })
   }
 
 	return newPromise
 }
 
+// settled runs a handler attached to an already settled promise: queued as
+// a microtask when the runtime has a queue, otherwise right away.
+func (p *Promise) settled(fn func()) {
+	if queue, ok := p.runtime.(MicrotaskQueue); ok {
+		queue.QueueMicrotask(fn)
+		return
+	}
+	fn()
+}
+
 func (p *Promise) Catch(onRejected goja.Callable) *Promise {
 	return p.Then(nil, onRejected)
 }
 
+// Finally runs onFinally once the promise settles either way. The returned
+// promise settles like this one, after any promise onFinally returns, unless
+// onFinally throws or returns a promise that rejects.
+func (p *Promise) Finally(onFinally goja.Callable) *Promise {
+	newPromise := &Promise{
+		vm:          p.vm,
+		runtime:     p.runtime,
+		state:       PromisePending,
+		onFulfilled: []goja.Callable{},
+		onRejected:  []goja.Callable{},
+	}
+
+	// passThrough settles newPromise with this promise's outcome
+	passThrough := func() {
+		p.mu.Lock()
+		state, value, reason, stack := p.state, p.value, p.reason, p.stack
+		p.mu.Unlock()
+
+		if state == PromiseFulfilled {
+			newPromise.resolve(value)
+		} else {
+			newPromise.rejectAt(reason, stack)
+		}
+	}
+
+	finallyWrapper := func(goja.FunctionCall) goja.Value {
+		if onFinally == nil {
+			passThrough()
+			return goja.Undefined()
+		}
+
+		result, err := onFinally(goja.Undefined())
+		if err != nil {
+			newPromise.rejectAt(p.vm.ToValue(err.Error()), exceptionStack(err))
+			return goja.Undefined()
+		}
+
+		if thenFunc, ok := thenableOf(p.vm, result); ok {
+			resolveFn := func(goja.FunctionCall) goja.Value {
+				passThrough()
+				return goja.Undefined()
+			}
+			rejectFn := func(call goja.FunctionCall) goja.Value {
+				newPromise.reject(call.Argument(0))
+				return goja.Undefined()
+			}
+			thenFunc(result, p.vm.ToValue(resolveFn), p.vm.ToValue(rejectFn))
+			return goja.Undefined()
+		}
+
+		passThrough()
+		return goja.Undefined()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.handled = true
+
+	if p.state == PromisePending {
+		wrapped, _ := goja.AssertFunction(p.vm.ToValue(finallyWrapper))
+		p.onFulfilled = append(p.onFulfilled, wrapped)
+		p.onRejected = append(p.onRejected, wrapped)
+	} else {
+		p.settled(func() {
+			finallyWrapper(goja.FunctionCall{})
+		})
+	}
+
+	return newPromise
+}
+
 func CreatePromiseObject(vm *goja.Runtime, promise *Promise) goja.Value {
 	obj := vm.NewObject()
 
@@ -209,9 +368,61 @@ func CreatePromiseObject(vm *goja.Runtime, promise *Promise) goja.Value {
 		return CreatePromiseObject(vm, newPromise)
 	})
 
+	obj.Set("finally", func(call goja.FunctionCall) goja.Value {
+		onFinally, _ := goja.AssertFunction(call.Argument(0))
+		return CreatePromiseObject(vm, promise.Finally(onFinally))
+	})
+
 	return obj
 }
 
+// rejectionStack returns where a JS reject call happened: the reason's own
+// stack when it is an Error, otherwise the current call stack. It must run
+// on the VM goroutine.
+func rejectionStack(vm *goja.Runtime, reason goja.Value) string {
+	if obj, ok := reason.(*goja.Object); ok {
+		if stack := obj.Get("stack"); stack != nil && !goja.IsUndefined(stack) {
+			return stack.String()
+		}
+	}
+
+	var b bytes.Buffer
+	for _, frame := range vm.CaptureCallStack(0, nil) {
+		if frame.SrcName() == "<native>" {
+			continue // the reject function itself
+		}
+		b.WriteString("\tat ")
+		frame.Write(&b)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// exceptionStack returns the stack of an error thrown by a handler or
+// executor, if it carries one.
+func exceptionStack(err error) string {
+	if ex, ok := err.(*goja.Exception); ok {
+		return ex.String()
+	}
+	return ""
+}
+
+// thenableOf returns the callable then method of a thenable value.
+// Plain values, holes (nil), and objects whose then is not callable
+// are not thenables and should be treated as already-resolved values.
+func thenableOf(vm *goja.Runtime, value goja.Value) (goja.Callable, bool) {
+	if value == nil || goja.IsUndefined(value) || goja.IsNull(value) {
+		return nil, false
+	}
+
+	then := value.ToObject(vm).Get("then")
+	if then == nil {
+		return nil, false
+	}
+
+	return goja.AssertFunction(then)
+}
+
 func SetupPromise(vm *goja.Runtime, rt RuntimeKeepAlive) {
 	promiseConstructor := func(call goja.ConstructorCall) *goja.Object {
 		executor, ok := goja.AssertFunction(call.Argument(0))
@@ -219,8 +430,7 @@ func SetupPromise(vm *goja.Runtime, rt RuntimeKeepAlive) {
 			panic(vm.NewTypeError("Promise executor must be a function"))
 		}
 
-		promise := NewPromise(vm, executor)
-    promise.SetRuntime(rt)
+		promise := newPromise(vm, rt, executor)
 
 		obj := vm.NewObject()
 		obj.Set("then", func(call goja.FunctionCall) goja.Value {
@@ -238,6 +448,10 @@ func SetupPromise(vm *goja.Runtime, rt RuntimeKeepAlive) {
 				onR, _ := goja.AssertFunction(call.Argument(0))
 				return vm.ToValue(newPromise.Catch(onR))
 			})
+			newObj.Set("finally", func(call goja.FunctionCall) goja.Value {
+				onFinally, _ := goja.AssertFunction(call.Argument(0))
+				return CreatePromiseObject(vm, newPromise.Finally(onFinally))
+			})
 			return newObj
 		})
 
@@ -255,9 +469,18 @@ func SetupPromise(vm *goja.Runtime, rt RuntimeKeepAlive) {
 				onR, _ := goja.AssertFunction(call.Argument(0))
 				return vm.ToValue(newPromise.Catch(onR))
 			})
+			newObj.Set("finally", func(call goja.FunctionCall) goja.Value {
+				onFinally, _ := goja.AssertFunction(call.Argument(0))
+				return CreatePromiseObject(vm, newPromise.Finally(onFinally))
+			})
 			return newObj
 		})
 
+		obj.Set("finally", func(call goja.FunctionCall) goja.Value {
+			onFinally, _ := goja.AssertFunction(call.Argument(0))
+			return CreatePromiseObject(vm, promise.Finally(onFinally))
+		})
+
 		return obj
 	}
 
@@ -282,11 +505,11 @@ func SetupPromise(vm *goja.Runtime, rt RuntimeKeepAlive) {
 		promise := &Promise{
 			vm:          vm,
 			runtime:     rt,
-			state:       PromiseRejected,
-			reason:      reason,
+			state:       PromisePending,
 			onFulfilled: []goja.Callable{},
 			onRejected:  []goja.Callable{},
 		}
+		promise.rejectAt(reason, rejectionStack(vm, reason))
 		return CreatePromiseObject(vm, promise)
 	})
 
@@ -331,8 +554,13 @@ func SetupPromise(vm *goja.Runtime, rt RuntimeKeepAlive) {
 		for i := 0; i < length; i++ {
 			index := i // capture for closure
 			promiseVal := promisesObj.Get(strconv.Itoa(i))
+			thenFunc, isThenable := thenableOf(vm, promiseVal)
+
+			if !isThenable {
+				if promiseVal == nil { // hole in a sparse array
+					promiseVal = goja.Undefined()
+				}
 
-			if promiseVal.ExportType() == nil || promiseVal.ToObject(vm).Get("then") == nil {
 				// not a promise, treat as resolved value
 				mu.Lock()
 				results[index] = promiseVal
@@ -344,13 +572,6 @@ func SetupPromise(vm *goja.Runtime, rt RuntimeKeepAlive) {
 				continue
 			}
 
-			// this is a promise, attach handlers
-			promiseObj := promiseVal.ToObject(vm)
-			thenFunc, ok := goja.AssertFunction(promiseObj.Get("then"))
-			if !ok {
-				continue
-			}
-
 			successHandler := func(call goja.FunctionCall) goja.Value {
 				mu.Lock()
 				defer mu.Unlock()
@@ -419,8 +640,13 @@ func SetupPromise(vm *goja.Runtime, rt RuntimeKeepAlive) {
 
 		for i := 0; i < length; i++ {
 			promiseVal := promisesObj.Get(strconv.Itoa(i))
+			thenFunc, isThenable := thenableOf(vm, promiseVal)
+
+			if !isThenable {
+				if promiseVal == nil { // hole in a sparse array
+					promiseVal = goja.Undefined()
+				}
 
-			if promiseVal.ExportType() == nil || promiseVal.ToObject(vm).Get("then") == nil {
 				// not a promise, treat as resolved. race is won immediately
 				if !settled {
 					settled = true
@@ -429,13 +655,6 @@ func SetupPromise(vm *goja.Runtime, rt RuntimeKeepAlive) {
 				return CreatePromiseObject(vm, racePromise)
 			}
 
-			// this is a promise
-			promiseObj := promiseVal.ToObject(vm)
-			thenFunc, ok := goja.AssertFunction(promiseObj.Get("then"))
-			if !ok {
-				continue
-			}
-
 			successHandler := func(call goja.FunctionCall) goja.Value {
 				mu.Lock()
 				defer mu.Unlock()
@@ -506,20 +725,19 @@ func SetupPromise(vm *goja.Runtime, rt RuntimeKeepAlive) {
 		for i := 0; i < length; i++ {
 			index := i // closure stuff again
 			promiseVal := promisesObj.Get(strconv.Itoa(i))
+			thenFunc, isThenable := thenableOf(vm, promiseVal)
+
+			if !isThenable {
+				if promiseVal == nil { // hole in a sparse array
+					promiseVal = goja.Undefined()
+				}
 
-			if promiseVal.ExportType() == nil || promiseVal.ToObject(vm).Get("then") == nil {
-				// not a promise, return immediately
+				// not a promise, so it wins; the rest are still subscribed
+				// to, as they count as handled
 				mu.Lock()
+				settled = true
 				anyPromise.resolve(promiseVal)
 				mu.Unlock()
-
-				return CreatePromiseObject(vm, anyPromise)
-			}
-
-			promiseObj := promiseVal.ToObject(vm)
-			thenFunc, ok := goja.AssertFunction(promiseObj.Get("then"))
-
-			if !ok {
 				continue
 			}
 
@@ -598,8 +816,13 @@ func SetupPromise(vm *goja.Runtime, rt RuntimeKeepAlive) {
 		for i := 0; i < length; i++ {
 			index := i
 			promiseVal := promisesObj.Get(strconv.Itoa(i))
+			thenFunc, isThenable := thenableOf(vm, promiseVal)
+
+			if !isThenable {
+				if promiseVal == nil { // hole in a sparse array
+					promiseVal = goja.Undefined()
+				}
 
-			if promiseVal.ExportType() == nil || promiseVal.ToObject(vm).Get("then") == nil {
 				// not a promise
 				mu.Lock()
 
@@ -618,13 +841,6 @@ func SetupPromise(vm *goja.Runtime, rt RuntimeKeepAlive) {
 				continue
 			}
 
-			promiseObj := promiseVal.ToObject(vm)
-			thenFunc, ok := goja.AssertFunction(promiseObj.Get("then"))
-
-			if !ok {
-				continue
-			}
-
 			successHandler := func(call goja.FunctionCall) goja.Value {
 				mu.Lock()
 				defer mu.Unlock()
@@ -7,7 +7,7 @@
 //
 // Built-in modules can be accessed either:
 //  1. Globally (e.g., console.log, file.read, http.get)
-//  2. Via require() (e.g., require('path'))
+//  2. Via require() (e.g., require('path'), require('events'))
 //
 // Example:
 //
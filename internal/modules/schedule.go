@@ -0,0 +1,202 @@
+package modules
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"github.com/douglasjordan2/dougless/internal/event"
+)
+
+// cronJob is a scheduled cron callback.
+type cronJob struct {
+	expr      string
+	spec      *cronSpec
+	cancel    chan struct{}
+	once      sync.Once
+	mu        sync.Mutex
+	next      time.Time   // Next planned fire time
+	stopTimer func() bool // cancels the pending run on the loop's clock
+	finish    func()      // forgets the job and releases the runtime
+}
+
+// stop cancels the job; safe to call more than once.
+func (j *cronJob) stop() {
+	j.once.Do(func() {
+		close(j.cancel)
+		j.mu.Lock()
+		stopTimer := j.stopTimer
+		j.mu.Unlock()
+		if stopTimer != nil {
+			stopTimer()
+		}
+		j.finish()
+	})
+}
+
+// Schedule runs callbacks on cron schedules via require('schedule').
+//
+// Available in JavaScript as:
+//
+//	const schedule = require('schedule');
+//	const job = schedule.cron('*/5 * * * *', (firedAt) => {
+//	  console.log('every five minutes', firedAt);
+//	});
+//	job.next();   // Date of the next run
+//	job.cancel();
+//
+// Expressions use the standard 5 fields (minute hour day-of-month month
+// day-of-week), an optional leading seconds field, or macros like @hourly.
+// Like setInterval, a job keeps the runtime alive until it is cancelled.
+type Schedule struct {
+	vm      *goja.Runtime
+	loop    *event.Loop
+	runtime RuntimeKeepAlive
+	mu      sync.Mutex
+	jobs    map[int]*cronJob
+	nextID  int
+	errOut  io.Writer // Destination for callback errors (stderr when nil)
+}
+
+// NewSchedule creates the schedule module; callbacks run on loop.
+func NewSchedule(loop *event.Loop) *Schedule {
+	return &Schedule{
+		loop: loop,
+		jobs: make(map[int]*cronJob),
+	}
+}
+
+// SetRuntime sets the runtime kept alive while jobs are scheduled.
+func (s *Schedule) SetRuntime(rt RuntimeKeepAlive) {
+	s.runtime = rt
+}
+
+// SetErrorOutput redirects callback error reports (stderr by default).
+func (s *Schedule) SetErrorOutput(w io.Writer) {
+	s.errOut = w
+}
+
+// ActiveHandles lists scheduled cron jobs.
+func (s *Schedule) ActiveHandles() []Handle {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	handles := make([]Handle, 0, len(s.jobs))
+	for id, job := range s.jobs {
+		handles = append(handles, Handle{
+			Type:    "cron",
+			Details: map[string]any{"id": id, "expression": job.expr},
+		})
+	}
+	return handles
+}
+
+func (s *Schedule) Export(vm *goja.Runtime) goja.Value {
+	s.vm = vm
+	obj := vm.NewObject()
+	obj.Set("cron", s.cron)
+	return obj
+}
+
+// cron implements schedule.cron(expr, fn), returning a handle with
+// cancel(), next() and the expression.
+func (s *Schedule) cron(call goja.FunctionCall) goja.Value {
+	expr := call.Argument(0).String()
+	fn, ok := goja.AssertFunction(call.Argument(1))
+	if !ok {
+		panic(s.vm.NewTypeError("schedule.cron requires a callback function"))
+	}
+
+	spec, err := parseCron(expr)
+	if err != nil {
+		panic(s.vm.NewTypeError(err.Error()))
+	}
+	first := spec.next(s.loop.Now())
+	if first.IsZero() {
+		panic(s.vm.NewTypeError(fmt.Sprintf("cron expression %q never fires", expr)))
+	}
+
+	job := &cronJob{expr: expr, spec: spec, cancel: make(chan struct{}), next: first}
+
+	s.mu.Lock()
+	s.nextID++
+	id := s.nextID
+	s.jobs[id] = job
+	s.mu.Unlock()
+
+	done := s.runtime.KeepAlive()
+	job.finish = func() {
+		s.mu.Lock()
+		delete(s.jobs, id)
+		s.mu.Unlock()
+		done()
+	}
+	s.arm(job, fn)
+
+	handle := s.vm.NewObject()
+	handle.Set("expression", expr)
+	handle.Set("cancel", func(goja.FunctionCall) goja.Value {
+		job.stop()
+		return goja.Undefined()
+	})
+	handle.Set("next", func(goja.FunctionCall) goja.Value {
+		job.mu.Lock()
+		next := job.next
+		job.mu.Unlock()
+		select {
+		case <-job.cancel:
+			return goja.Null()
+		default:
+		}
+		date, _ := s.vm.New(s.vm.Get("Date"), s.vm.ToValue(next.UnixMilli()))
+		return date
+	})
+	return handle
+}
+
+// arm queues the job's next run on the loop's clock. Each run arms the
+// one after it, until the job is cancelled or the expression stops matching.
+func (s *Schedule) arm(job *cronJob, fn goja.Callable) {
+	job.mu.Lock()
+	next := job.next
+	job.mu.Unlock()
+	if next.IsZero() {
+		job.stop()
+		return
+	}
+
+	stopTimer := s.loop.AfterFunc(next.Sub(s.loop.Now()), event.Task{Name: "schedule.cron", Callback: func() {
+		select {
+		case <-job.cancel:
+			return // cancelled while queued
+		default:
+		}
+
+		job.mu.Lock()
+		// computed from the planned time so a slow wake-up can't skip a slot
+		job.next = job.spec.next(next)
+		job.mu.Unlock()
+		s.arm(job, fn)
+
+		date, _ := s.vm.New(s.vm.Get("Date"), s.vm.ToValue(next.UnixMilli()))
+		if _, err := fn(goja.Undefined(), date); err != nil {
+			s.reportError(err)
+		}
+	}})
+	job.mu.Lock()
+	job.stopTimer = stopTimer
+	job.mu.Unlock()
+}
+
+// reportError prints an error thrown by a cron callback.
+func (s *Schedule) reportError(err error) {
+	out := s.errOut
+	if out == nil {
+		out = os.Stderr
+	}
+	fmt.Fprintf(out, "schedule.cron callback error: %v\n", err)
+}
@@ -2,35 +2,120 @@ package modules
 
 import (
 	"fmt"
+	"io"
   "os"
 	"sync"
 	"time"
 
 	"github.com/dop251/goja"
 	"github.com/google/uuid"
+
+	"github.com/douglasjordan2/dougless/internal/event"
 )
 
 type RuntimeKeepAlive interface {
 	KeepAlive() func()
 }
 
+// timerEntry is a pending timeout, interval or immediate
+type timerEntry struct {
+  stop      func() bool // cancels the armed timer, false once it has fired
+  done      func()      // releases the runtime once the timer is finished
+  delay     int64 // milliseconds
+  interval  bool
+  immediate bool
+}
+
 type Timers struct {
 	vm      *goja.Runtime
-  timers  map[string]chan struct{}
+  timers  map[string]*timerEntry
   mu      sync.Mutex
   runtime RuntimeKeepAlive
+  loop    *event.Loop // runs callbacks; nil calls them from the timer goroutine
+  errOut  io.Writer   // Destination for callback errors (stderr when nil)
 }
 
 func NewTimers() *Timers {
 	return &Timers{
-		timers: make(map[string]chan struct{}),
+		timers: make(map[string]*timerEntry),
 	}
 }
 
+// SetErrorOutput redirects callback error reports (stderr by default).
+func (t *Timers) SetErrorOutput(w io.Writer) {
+  t.errOut = w
+}
+
+// reportError prints an error thrown by a timer callback.
+func (t *Timers) reportError(kind string, err error) {
+  out := t.errOut
+  if out == nil {
+    out = os.Stderr
+  }
+  fmt.Fprintf(out, "%s callback error: %v\n", kind, err)
+}
+
+// ActiveHandles lists pending timeouts and intervals.
+func (t *Timers) ActiveHandles() []Handle {
+  t.mu.Lock()
+  defer t.mu.Unlock()
+
+  handles := make([]Handle, 0, len(t.timers))
+  for id, entry := range t.timers {
+    kind := "timeout"
+    if entry.interval {
+      kind = "interval"
+    } else if entry.immediate {
+      kind = "immediate"
+    }
+    handles = append(handles, Handle{
+      Type:    kind,
+      Details: map[string]any{"id": id, "delay": entry.delay},
+    })
+  }
+  return handles
+}
+
 func (t *Timers) SetRuntime(rt RuntimeKeepAlive) {
 	t.runtime = rt
 }
 
+// SetLoop makes timer callbacks run as tasks on loop, which setImmediate and
+// queueMicrotask also require.
+func (t *Timers) SetLoop(loop *event.Loop) {
+  t.loop = loop
+}
+
+// after runs fn once d has passed: as a task on the loop, on the loop's
+// clock, or without a loop directly from a timer goroutine. The returned
+// function cancels it, reporting false if fn is already on its way.
+func (t *Timers) after(name string, d time.Duration, fn func()) func() bool {
+  if t.loop == nil {
+    return time.AfterFunc(d, fn).Stop
+  }
+  return t.loop.AfterFunc(d, event.Task{Name: name, Callback: fn})
+}
+
+// arm records the stop function of a timer's current arming, unless the
+// timer was cleared in the meantime.
+func (t *Timers) arm(timerID string, stop func() bool) {
+  t.mu.Lock()
+  defer t.mu.Unlock()
+  if entry, ok := t.timers[timerID]; ok {
+    entry.stop = stop
+  }
+}
+
+// take removes a timer that is about to fire, reporting whether it was still
+// pending (not cleared while its callback was queued).
+func (t *Timers) take(timerID string) bool {
+  t.mu.Lock()
+  defer t.mu.Unlock()
+  _, ok := t.timers[timerID]
+  delete(t.timers, timerID)
+  return ok
+}
+
 func (t *Timers) Export(vm *goja.Runtime) goja.Value {
 	t.vm = vm
 	obj := vm.NewObject()
@@ -39,11 +124,14 @@ func (t *Timers) Export(vm *goja.Runtime) goja.Value {
 	obj.Set("setInterval", t.setInterval)
 	obj.Set("clearTimeout", t.clearTimeout)
 	obj.Set("clearInterval", t.clearInterval)
+	obj.Set("setImmediate", t.setImmediate)
+	obj.Set("clearImmediate", t.clearImmediate)
+	obj.Set("queueMicrotask", t.queueMicrotask)
 
 	return obj
 }
 
-func timerHelper(t *Timers, call goja.FunctionCall) (fn goja.Callable, ms int64, timerID string, done func(), cancel chan struct{}) {
+func timerHelper(t *Timers, call goja.FunctionCall, interval bool) (fn goja.Callable, delay time.Duration, timerID string, done func()) {
   if len(call.Arguments) < 2 {
 		panic(t.vm.NewTypeError("timer setters require at least 2 arguments"))
 	}
@@ -53,75 +141,67 @@ func timerHelper(t *Timers, call goja.FunctionCall) (fn goja.Callable, ms int64,
     panic(t.vm.NewTypeError("First argument must be a function"))
   }
 
-  ms = call.Arguments[1].ToInteger()
+  ms := call.Arguments[1].ToInteger()
+  delay = time.Duration(ms) * time.Millisecond
 
   timerID = uuid.New().String()
-  cancel = make(chan struct{})
+  done = t.runtime.KeepAlive()
 
   t.mu.Lock()
-  t.timers[timerID] = cancel
+  t.timers[timerID] = &timerEntry{done: done, delay: ms, interval: interval}
   t.mu.Unlock()
 
-  done = t.runtime.KeepAlive()
-
-  return fn, ms, timerID, done, cancel
+  return fn, delay, timerID, done
 }
 
 func (t *Timers) setTimeout(call goja.FunctionCall) goja.Value {
-  fn, ms, timerID, done, cancel := timerHelper(t, call)
+  fn, delay, timerID, done := timerHelper(t, call, false)
 
-  go func() {
+  t.arm(timerID, t.after("setTimeout", delay, func() {
     defer done()
+    // cleanup first so the firing timer no longer counts as active
+    if !t.take(timerID) {
+      return // cleared while queued
+    }
 
-    select {
-    case <-time.After(time.Duration(ms) * time.Millisecond):
-      // execute callback in vm
-      if _, err := fn(nil, call.Arguments[2:]...); err != nil {
-        fmt.Fprintf(os.Stderr, "setTimeout callback error: %v\n", err)
-      }
-      
-      // cleanup
-      t.mu.Lock()
-      delete(t.timers, timerID)
-      t.mu.Unlock()
-
-    case <-cancel:
-      t.mu.Lock()
-      delete(t.timers, timerID)
-      t.mu.Unlock()
-      return
+    // execute callback in vm
+    if _, err := fn(nil, call.Arguments[2:]...); err != nil {
+      t.reportError("setTimeout", err)
     }
-  }()
+  }))
 
   return t.vm.ToValue(timerID)
 }
 
 func (t *Timers) setInterval(call goja.FunctionCall) goja.Value {
-  fn, ms, timerID, done, cancel := timerHelper(t, call)
+  fn, delay, timerID, done := timerHelper(t, call, true)
+  if delay <= 0 {
+    delay = time.Millisecond // as in Node
+  }
 
-  go func() {
-    defer done()
-    ticker := time.NewTicker(time.Duration(ms) * time.Millisecond)
-    defer ticker.Stop()
-
-    for {
-      select {
-      case <-ticker.C:
-        if _, err := fn(nil, call.Arguments[2:]...); err != nil {
-          fmt.Fprintf(os.Stderr, "setInterval callback error: %v\n", err)
-        }
-      case <-cancel:
-        t.mu.Lock()
-        delete(t.timers, timerID)
-        t.mu.Unlock()
-        return
-      }
+  // each tick arms the next one before running the callback
+  var tick func()
+  tick = func() {
+    t.mu.Lock()
+    _, pending := t.timers[timerID]
+    t.mu.Unlock()
+    if !pending {
+      done() // cleared while queued
+      return
     }
-  }()
+
+    t.arm(timerID, t.after("setInterval", delay, tick))
+    if _, err := fn(nil, call.Arguments[2:]...); err != nil {
+      t.reportError("setInterval", err)
+    }
+  }
+  t.arm(timerID, t.after("setInterval", delay, tick))
 
   return t.vm.ToValue(timerID)
 }
 
+// clearTimeout cancels a timeout, interval or immediate. A timer whose
+// callback is already queued is released when that callback finds it gone.
 func (t *Timers) clearTimeout(call goja.FunctionCall) goja.Value {
   if len(call.Arguments) < 1 {
     return goja.Undefined()
@@ -130,16 +210,71 @@ func (t *Timers) clearTimeout(call goja.FunctionCall) goja.Value {
   timerID := call.Arguments[0].String()
 
   t.mu.Lock()
-  cancel, ok := t.timers[timerID]
-  if ok {
-    close(cancel)
-    delete(t.timers, timerID)
-  }
+  entry, ok := t.timers[timerID]
+  delete(t.timers, timerID)
   t.mu.Unlock()
 
+  if ok && entry.stop != nil && entry.stop() {
+    entry.done()
+  }
+
   return goja.Undefined()
 }
 
 func (t *Timers) clearInterval(call goja.FunctionCall) goja.Value {
   return t.clearTimeout(call)
 }
+
+// setImmediate runs fn(...args) once the current task and its microtasks
+// have finished, before timers and other tasks queued after it.
+func (t *Timers) setImmediate(call goja.FunctionCall) goja.Value {
+  fn, ok := goja.AssertFunction(call.Argument(0))
+  if !ok {
+    panic(t.vm.NewTypeError("setImmediate requires a callback function"))
+  }
+  var args []goja.Value
+  if len(call.Arguments) > 1 {
+    args = call.Arguments[1:]
+  }
+
+  timerID := uuid.New().String()
+  t.mu.Lock()
+  t.timers[timerID] = &timerEntry{immediate: true}
+  t.mu.Unlock()
+
+  done := t.runtime.KeepAlive()
+  t.loop.ScheduleImmediate(event.Task{Name: "setImmediate", Callback: func() {
+    defer done()
+    if !t.take(timerID) {
+      return // cleared
+    }
+    if _, err := fn(nil, args...); err != nil {
+      t.reportError("setImmediate", err)
+    }
+  }})
+
+  return t.vm.ToValue(timerID)
+}
+
+func (t *Timers) clearImmediate(call goja.FunctionCall) goja.Value {
+  return t.clearTimeout(call)
+}
+
+// queueMicrotask runs fn after the current task, before the next immediate,
+// in the same queue as promise reactions.
+func (t *Timers) queueMicrotask(call goja.FunctionCall) goja.Value {
+  fn, ok := goja.AssertFunction(call.Argument(0))
+  if !ok {
+    panic(t.vm.NewTypeError("queueMicrotask requires a callback function"))
+  }
+
+  done := t.runtime.KeepAlive()
+  t.loop.QueueMicrotask(func() {
+    defer done()
+    if _, err := fn(nil); err != nil {
+      t.reportError("queueMicrotask", err)
+    }
+  })
+
+  return goja.Undefined()
+}
@@ -0,0 +1,254 @@
+package modules
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+
+	"github.com/douglasjordan2/dougless/internal/permissions"
+)
+
+// Wasm loads WebAssembly modules via require('wasm'), running them on the
+// pure-Go wazero runtime.
+//
+// Available in JavaScript as:
+//
+//	const wasm = require('wasm');
+//	const { exports } = wasm.instantiate(bytes, {
+//	  env: { log: (n) => console.log(n) }, // functions the module imports
+//	});
+//	exports.add(2, 3); // 5
+//
+//	const plugin = wasm.instantiateFile('plugin.wasm'); // needs --allow-read
+//	plugin.close();
+//
+// Numbers cross the boundary as i32/i64/f32/f64 according to the function's
+// signature (i64 values beyond 2^53 lose precision). A function with several
+// results returns them as an array. Instantiation is synchronous.
+type Wasm struct {
+	vm *goja.Runtime
+}
+
+// NewWasm creates the wasm module.
+func NewWasm() *Wasm {
+	return &Wasm{}
+}
+
+func (w *Wasm) Export(vm *goja.Runtime) goja.Value {
+	w.vm = vm
+	obj := vm.NewObject()
+	obj.Set("instantiate", w.instantiate)
+	obj.Set("instantiateFile", w.instantiateFile)
+	return obj
+}
+
+// instantiate implements wasm.instantiate(bytes, [imports]).
+func (w *Wasm) instantiate(call goja.FunctionCall) goja.Value {
+	if len(call.Arguments) < 1 {
+		panic(w.vm.NewTypeError("instantiate requires the module bytes"))
+	}
+	return w.instantiateBytes(bytesOf(w.vm, call.Argument(0)), call.Argument(1))
+}
+
+// instantiateFile implements wasm.instantiateFile(path, [imports]), which
+// requires read permission for path.
+func (w *Wasm) instantiateFile(call goja.FunctionCall) goja.Value {
+	if len(call.Arguments) < 1 {
+		panic(w.vm.NewTypeError("instantiateFile requires a path"))
+	}
+	path := call.Argument(0).String()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	mgr := permissions.GetManager()
+	canRead := permissions.PermissionRead
+	if !mgr.CheckWithPrompt(ctx, canRead, path) {
+		panic(w.vm.NewGoError(fmt.Errorf("%s", mgr.ErrorMessage(canRead, path))))
+	}
+
+	binary, err := os.ReadFile(path)
+	if err != nil {
+		panic(w.vm.NewGoError(err))
+	}
+	return w.instantiateBytes(binary, call.Argument(1))
+}
+
+// instantiateBytes compiles binary in its own wazero runtime, links the
+// requested imports to JS functions and returns { exports, close }.
+func (w *Wasm) instantiateBytes(binary []byte, imports goja.Value) goja.Value {
+	ctx := context.Background()
+	r := wazero.NewRuntime(ctx)
+
+	fail := func(err error) {
+		r.Close(ctx)
+		panic(w.vm.NewGoError(fmt.Errorf("wasm: %w", err)))
+	}
+
+	compiled, err := r.CompileModule(ctx, binary)
+	if err != nil {
+		fail(err)
+	}
+
+	if err := w.linkImports(ctx, r, compiled, imports); err != nil {
+		fail(err)
+	}
+
+	mod, err := r.InstantiateModule(ctx, compiled, wazero.NewModuleConfig())
+	if err != nil {
+		fail(err)
+	}
+
+	exports := w.vm.NewObject()
+	for name, def := range compiled.ExportedFunctions() {
+		exports.Set(name, w.exportedFunction(mod.ExportedFunction(name), def))
+	}
+
+	instance := w.vm.NewObject()
+	instance.Set("exports", exports)
+	instance.Set("close", func(goja.FunctionCall) goja.Value {
+		r.Close(ctx)
+		return goja.Undefined()
+	})
+	return instance
+}
+
+// linkImports instantiates one host module per imported module name, backing
+// each imported function with imports[module][name].
+func (w *Wasm) linkImports(ctx context.Context, r wazero.Runtime, compiled wazero.CompiledModule, imports goja.Value) error {
+	defs := compiled.ImportedFunctions()
+	if len(defs) == 0 {
+		return nil
+	}
+	if imports == nil || goja.IsUndefined(imports) || goja.IsNull(imports) {
+		moduleName, name, _ := defs[0].Import()
+		return fmt.Errorf("missing import %s.%s", moduleName, name)
+	}
+	importsObj := imports.ToObject(w.vm)
+
+	builders := map[string]wazero.HostModuleBuilder{}
+	var order []string
+	for _, def := range defs {
+		moduleName, name, _ := def.Import()
+
+		var fn goja.Callable
+		if namespace := importsObj.Get(moduleName); namespace != nil && !goja.IsUndefined(namespace) && !goja.IsNull(namespace) {
+			fn, _ = goja.AssertFunction(namespace.ToObject(w.vm).Get(name))
+		}
+		if fn == nil {
+			return fmt.Errorf("missing import %s.%s (expected a function)", moduleName, name)
+		}
+
+		builder, ok := builders[moduleName]
+		if !ok {
+			builder = r.NewHostModuleBuilder(moduleName)
+			builders[moduleName] = builder
+			order = append(order, moduleName)
+		}
+		builder.NewFunctionBuilder().
+			WithGoFunction(w.hostFunction(fn, def), def.ParamTypes(), def.ResultTypes()).
+			Export(name)
+	}
+
+	for _, moduleName := range order {
+		if _, err := builders[moduleName].Instantiate(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hostFunction adapts a JS import to wazero's stack-based calling convention.
+func (w *Wasm) hostFunction(fn goja.Callable, def api.FunctionDefinition) api.GoFunc {
+	paramTypes, resultTypes := def.ParamTypes(), def.ResultTypes()
+
+	return func(ctx context.Context, stack []uint64) {
+		args := make([]goja.Value, len(paramTypes))
+		for i, t := range paramTypes {
+			args[i] = w.vm.ToValue(decodeWasmValue(t, stack[i]))
+		}
+
+		result, err := fn(goja.Undefined(), args...)
+		if err != nil {
+			panic(err)
+		}
+
+		switch len(resultTypes) {
+		case 0:
+		case 1:
+			stack[0] = encodeWasmValue(resultTypes[0], result)
+		default:
+			values := result.ToObject(w.vm)
+			for i, t := range resultTypes {
+				stack[i] = encodeWasmValue(t, values.Get(fmt.Sprint(i)))
+			}
+		}
+	}
+}
+
+// exportedFunction wraps a wasm export as a JS function.
+func (w *Wasm) exportedFunction(fn api.Function, def api.FunctionDefinition) func(goja.FunctionCall) goja.Value {
+	paramTypes, resultTypes := def.ParamTypes(), def.ResultTypes()
+
+	return func(call goja.FunctionCall) goja.Value {
+		params := make([]uint64, len(paramTypes))
+		for i, t := range paramTypes {
+			params[i] = encodeWasmValue(t, call.Argument(i))
+		}
+
+		results, err := fn.Call(context.Background(), params...)
+		if err != nil {
+			panic(w.vm.NewGoError(fmt.Errorf("wasm %s: %w", def.DebugName(), err)))
+		}
+
+		switch len(results) {
+		case 0:
+			return goja.Undefined()
+		case 1:
+			return w.vm.ToValue(decodeWasmValue(resultTypes[0], results[0]))
+		}
+		values := make([]any, len(results))
+		for i, t := range resultTypes {
+			values[i] = decodeWasmValue(t, results[i])
+		}
+		return w.vm.ToValue(values)
+	}
+}
+
+// encodeWasmValue converts a JS number to wazero's uint64 encoding of t.
+func encodeWasmValue(t api.ValueType, v goja.Value) uint64 {
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		return 0
+	}
+	switch t {
+	case api.ValueTypeI32:
+		return api.EncodeI32(int32(v.ToInteger()))
+	case api.ValueTypeI64:
+		return api.EncodeI64(v.ToInteger())
+	case api.ValueTypeF32:
+		return api.EncodeF32(float32(v.ToFloat()))
+	case api.ValueTypeF64:
+		return api.EncodeF64(v.ToFloat())
+	}
+	return 0
+}
+
+// decodeWasmValue converts wazero's uint64 encoding of t to a Go number.
+func decodeWasmValue(t api.ValueType, v uint64) any {
+	switch t {
+	case api.ValueTypeI32:
+		return api.DecodeI32(v)
+	case api.ValueTypeI64:
+		return int64(v)
+	case api.ValueTypeF32:
+		return api.DecodeF32(v)
+	case api.ValueTypeF64:
+		return api.DecodeF64(v)
+	}
+	return v
+}
@@ -17,6 +17,7 @@ type PermissionSet struct {
 	Net   []string `json:"net"`
 	Env   []string `json:"env"`
 	Run   []string `json:"run"`
+	Sys   []string `json:"sys"`
 }
 
 func FindConfig(startDir string) (string, error) {
@@ -76,6 +77,8 @@ func SavePermissionToConfig(configPath string, perm Permission, resource string)
 		targetArray = &config.Permissions.Env
 	case PermissionRun:
 		targetArray = &config.Permissions.Run
+	case PermissionSys:
+		targetArray = &config.Permissions.Sys
 	default:
 		return fmt.Errorf("unknown permission type: %s", perm)
 	}
@@ -0,0 +1,103 @@
+package permissions
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// MessageKind names a user-facing permission message that can be replaced
+// with a template.
+type MessageKind string
+
+// Customizable messages.
+const (
+	MessageDenied MessageKind = "denied" // returned by ErrorMessage
+	MessagePrompt MessageKind = "prompt" // the request line shown before the terminal prompt's choices
+)
+
+// MessageData is what message templates are executed with.
+type MessageData struct {
+	Permission Permission // e.g. "read"
+	Resource   string     // the path, host, variable or program; empty for all
+	Descriptor string     // e.g. "read access to '/etc/passwd'"
+	Flag       string     // e.g. "--allow-read"
+	Example    string     // e.g. "dougless --allow-read=/etc/passwd script.js"
+}
+
+// SetMessageTemplate replaces the text of a permission message with a
+// text/template executed with MessageData, for embedders and localized CLIs:
+//
+//	mgr.SetMessageTemplate(permissions.MessageDenied,
+//		"Zugriff verweigert: {{.Descriptor}} (Start mit {{.Flag}})")
+//
+// An empty text restores the default message. A template that fails to
+// execute also falls back to the default.
+func (m *Manager) SetMessageTemplate(kind MessageKind, text string) error {
+	if kind != MessageDenied && kind != MessagePrompt {
+		return fmt.Errorf("unknown permission message: %q", kind)
+	}
+
+	var tmpl *template.Template
+	if text != "" {
+		var err error
+		if tmpl, err = template.New(string(kind)).Parse(text); err != nil {
+			return fmt.Errorf("invalid %s message template: %w", kind, err)
+		}
+	}
+
+	m.messagesMu.Lock()
+	defer m.messagesMu.Unlock()
+	if m.messages == nil {
+		m.messages = make(map[MessageKind]*template.Template)
+	}
+	if tmpl == nil {
+		delete(m.messages, kind)
+	} else {
+		m.messages[kind] = tmpl
+	}
+	return nil
+}
+
+// PromptMessage is the line StdioPrompter shows for a permission request.
+// Custom Prompter implementations can use it to honour a MessagePrompt
+// template.
+func (m *Manager) PromptMessage(desc PermissionDescriptor) string {
+	if msg, ok := m.renderMessage(MessagePrompt, desc.Name, desc.Resource); ok {
+		return msg
+	}
+	return fmt.Sprintf("⚠️  Permission request: %s", desc)
+}
+
+// renderMessage executes the template set for kind, reporting false when
+// there is none or it fails.
+func (m *Manager) renderMessage(kind MessageKind, perm Permission, resource string) (string, bool) {
+	m.messagesMu.RLock()
+	tmpl := m.messages[kind]
+	m.messagesMu.RUnlock()
+	if tmpl == nil {
+		return "", false
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, messageData(perm, resource)); err != nil {
+		return "", false
+	}
+	return b.String(), true
+}
+
+// messageData describes perm and resource for a message.
+func messageData(perm Permission, resource string) MessageData {
+	flag := "--allow-" + string(perm)
+	example := fmt.Sprintf("dougless %s script.js", flag)
+	if resource != "" {
+		example = fmt.Sprintf("dougless %s=%s script.js", flag, resource)
+	}
+	return MessageData{
+		Permission: perm,
+		Resource:   resource,
+		Descriptor: PermissionDescriptor{Name: perm, Resource: resource}.String(),
+		Flag:       flag,
+		Example:    example,
+	}
+}
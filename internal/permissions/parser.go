@@ -5,6 +5,7 @@ package permissions
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
@@ -14,18 +15,27 @@ import (
 // Supported flags:
 //
 //	--allow-all or -A: Grant all permissions (warns about security implications)
-//	--allow-read[=paths]: Grant read permission (comma-separated paths, empty = all)
+//	--allow-read[=paths]: Grant read permission (comma-separated paths, empty = all;
+//	  path:pattern limits a path to matching file names, e.g. /data:*.json)
 //	--allow-write[=paths]: Grant write permission
 //	--allow-net[=hosts]: Grant network permission (supports wildcards and ports)
 //	--allow-env[=vars]: Grant environment variable access
 //	--allow-run[=programs]: Grant program execution permission
+//	--allow-sys[=names]: Grant system information access (e.g. hostname,totalmem)
 //	--prompt: Force enable interactive prompts
 //	--no-prompt: Disable interactive prompts
+//	--trace-permissions: Log every permission check and its result to stderr
+//
+// Flags are only recognized before the script path. The script path and every
+// argument after it are returned untouched in remainingArgs, so
+// "dougless app.js --allow-net" passes "--allow-net" to the script rather
+// than granting anything.
 //
 // Examples:
 //
 //	dougless --allow-read script.js                    (all read access)
 //	dougless --allow-read=.,/tmp script.js             (specific paths)
+//	dougless --allow-read=/data:*.json script.js       (only .json files under /data)
 //	dougless --allow-net=localhost:3000 script.js      (specific host:port)
 //	dougless --allow-all script.js                     (all permissions)
 func ParseFlags(args []string) (*Manager, []string, error) {
@@ -68,12 +78,22 @@ func ParseFlags(args []string) (*Manager, []string, error) {
 				return nil, nil, err
 			}
 			manager.GrantRun(programs)
+		} else if strings.HasPrefix(arg, "--allow-sys") {
+			names, err := parsePermissionValue(arg, "--allow-sys")
+			if err != nil {
+				return nil, nil, err
+			}
+			manager.GrantSys(names)
 		} else if arg == "--prompt" {
 			manager.SetPromptMode(true)
 		} else if arg == "--no-prompt" {
 			manager.SetPromptMode(false)
+		} else if arg == "--trace-permissions" {
+			manager.SetTraceOutput(os.Stderr)
 		} else {
-			remainingArgs = append(remainingArgs, arg)
+			// first positional is the script; the rest belong to it
+			remainingArgs = append(remainingArgs, args[i:]...)
+			break
 		}
 	}
 
@@ -110,6 +130,14 @@ func parsePermissionValue(arg, flagName string) ([]string, error) {
 
 	for i, v := range values {
 		values[i] = strings.TrimSpace(v)
+
+		if flagName == "--allow-read" || flagName == "--allow-write" {
+			if _, pattern := splitPathGlob(values[i]); pattern != "" {
+				if _, err := filepath.Match(pattern, ""); err != nil {
+					return nil, fmt.Errorf("%s: invalid file pattern %q", flagName, pattern)
+				}
+			}
+		}
 	}
 
 	return values, nil
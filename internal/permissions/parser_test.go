@@ -1,6 +1,8 @@
 package permissions
 
 import (
+	"context"
+	"os"
 	"testing"
 )
 
@@ -46,6 +48,9 @@ func TestParseFlags(t *testing.T) {
 		if !manager.Check(PermissionNet, "example.com") {
 			t.Error("net should be granted with --allow-all")
 		}
+		if !manager.Check(PermissionSys, "hostname") {
+			t.Error("sys should be granted with --allow-all")
+		}
 	})
 
 	t.Run("allow-all short flag", func(t *testing.T) {
@@ -251,6 +256,50 @@ func TestParseFlags(t *testing.T) {
 		}
 	})
 
+	t.Run("allow-sys all", func(t *testing.T) {
+		args := []string{"--allow-sys", "script.js"}
+		manager, _, err := ParseFlags(args)
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !manager.Check(PermissionSys, "hostname") {
+			t.Error("sys should be allowed")
+		}
+	})
+
+	t.Run("allow-sys specific names", func(t *testing.T) {
+		args := []string{"--allow-sys=hostname,totalmem", "script.js"}
+		manager, _, err := ParseFlags(args)
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !manager.Check(PermissionSys, "hostname") {
+			t.Error("hostname should be allowed")
+		}
+		if !manager.Check(PermissionSys, "totalmem") {
+			t.Error("totalmem should be allowed")
+		}
+		if manager.Check(PermissionSys, "freemem") {
+			t.Error("freemem should be denied")
+		}
+	})
+
+	t.Run("sys denied without flag", func(t *testing.T) {
+		manager, _, err := ParseFlags([]string{"--allow-env", "script.js"})
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if manager.Check(PermissionSys, "hostname") {
+			t.Error("sys should be denied without --allow-sys")
+		}
+	})
+
 	t.Run("prompt mode", func(t *testing.T) {
 		args := []string{"--prompt", "script.js"}
 		manager, _, err := ParseFlags(args)
@@ -270,6 +319,52 @@ func TestParseFlags(t *testing.T) {
 		}
 	})
 
+	t.Run("trace-permissions flag", func(t *testing.T) {
+		manager, remaining, err := ParseFlags([]string{"--trace-permissions", "script.js"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if manager.trace != os.Stderr {
+			t.Error("expected permission checks to be traced to stderr")
+		}
+		if len(remaining) != 1 || remaining[0] != "script.js" {
+			t.Errorf("expected [script.js], got %v", remaining)
+		}
+	})
+
+	t.Run("no-prompt overrides terminal detection", func(t *testing.T) {
+		orig := stdinIsTerminal
+		stdinIsTerminal = func() bool { return true }
+		defer func() { stdinIsTerminal = orig }()
+
+		desc := PermissionDescriptor{
+			Name:     PermissionRead,
+			Resource: "/tmp/file.txt",
+		}
+
+		// sanity check: on a TTY a missing permission would prompt
+		tty, _, err := ParseFlags([]string{"script.js"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if state := tty.Query(desc); state != StatePrompt {
+			t.Fatalf("expected StatePrompt on a terminal, got %v", state)
+		}
+
+		manager, _, err := ParseFlags([]string{"--no-prompt", "script.js"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if state := manager.Query(desc); state != StateDenied {
+			t.Errorf("expected StateDenied with --no-prompt, got %v", state)
+		}
+		if manager.CheckWithPrompt(context.Background(), PermissionRead, "/tmp/file.txt") {
+			t.Error("CheckWithPrompt should deny without prompting")
+		}
+	})
+
 	t.Run("multiple flags", func(t *testing.T) {
 		args := []string{
 			"--allow-read=/tmp",
@@ -324,6 +419,29 @@ func TestParseFlags(t *testing.T) {
 			}
 		}
 	})
+
+	t.Run("flags after script are passed to the script", func(t *testing.T) {
+		args := []string{"script.js", "--allow-write", "--prompt", "input.txt"}
+		manager, remaining, err := ParseFlags(args)
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if manager.Check(PermissionWrite, "/tmp/test.txt") {
+			t.Error("--allow-write after the script must not grant write permission")
+		}
+
+		expected := []string{"script.js", "--allow-write", "--prompt", "input.txt"}
+		if len(remaining) != len(expected) {
+			t.Fatalf("expected %v, got %v", expected, remaining)
+		}
+		for i, arg := range expected {
+			if remaining[i] != arg {
+				t.Errorf("arg[%d]: expected %q, got %q", i, arg, remaining[i])
+			}
+		}
+	})
 }
 
 func TestParsePermissionValue(t *testing.T) {
@@ -380,6 +498,30 @@ func TestParsePermissionValue(t *testing.T) {
 			t.Error("expected error for empty value after equals")
 		}
 	})
+
+	t.Run("invalid file pattern", func(t *testing.T) {
+		_, err := parsePermissionValue("--allow-read=/data:[*.json", "--allow-read")
+		if err == nil {
+			t.Error("expected error for a malformed file pattern")
+		}
+	})
+}
+
+func TestParseFlagsReadFilePattern(t *testing.T) {
+	manager, _, err := ParseFlags([]string{"--allow-read=/data:*.json", "app.js"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !manager.Check(PermissionRead, "/data/x.json") {
+		t.Error("/data/x.json should be allowed")
+	}
+	if manager.Check(PermissionRead, "/data/x.txt") {
+		t.Error("/data/x.txt should be denied")
+	}
+	if manager.Check(PermissionRead, "/data/../x.json") {
+		t.Error("/data/../x.json should be denied")
+	}
 }
 
 func TestComplexScenarios(t *testing.T) {
@@ -14,10 +14,12 @@ package permissions
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"text/template"
 )
 
 // Permission represents a category of system access.
@@ -30,6 +32,7 @@ const (
 	PermissionNet   Permission = "net"   // Network access (HTTP, WebSocket)
 	PermissionEnv   Permission = "env"   // Environment variable access
 	PermissionRun   Permission = "run"   // Program execution access
+	PermissionSys   Permission = "sys"   // System information access (hostname, memory, ...)
 )
 
 // PermissionState represents the granted/denied status of a permission.
@@ -64,22 +67,32 @@ func (pd PermissionDescriptor) String() string {
 // The manager is typically initialized from CLI flags and accessed
 // globally throughout the runtime.
 type Manager struct {
-	allowRead     *[]string                  // Allowed read paths (nil = denied, empty = all)
-	allowWrite    *[]string                  // Allowed write paths
-	allowNet      *[]string                  // Allowed network hosts
-	allowEnv      *[]string                  // Allowed environment variables
-	allowRun      *[]string                  // Allowed programs to execute
-	config        *Config                    // Loaded .douglessrc configuration (if any)
-	configPath    string                     // Path to .douglessrc file (for saving)
-	promptMode    bool                       // Whether to prompt for permissions
-	prompter      Prompter                   // Interface for prompting user
-	promptCache   map[string]PermissionState // Cache of user responses
-	promptCacheMu sync.RWMutex               // Protects promptCache
+	allowRead     *[]string                          // Allowed read paths (nil = denied, empty = all)
+	allowWrite    *[]string                          // Allowed write paths
+	allowNet      *[]string                          // Allowed network hosts
+	allowEnv      *[]string                          // Allowed environment variables
+	allowRun      *[]string                          // Allowed programs to execute
+	allowSys      *[]string                          // Allowed system information APIs
+	config        *Config                            // Loaded .douglessrc configuration (if any)
+	configPath    string                             // Path to .douglessrc file (for saving)
+	promptMode    bool                               // Whether to prompt for permissions
+	prompter      Prompter                           // Interface for prompting user
+	promptCache   map[string]PermissionState         // Cache of user responses
+	promptDirs    map[Permission][]string            // Directories granted by ScopeDirectory answers
+	promptCacheMu sync.RWMutex                       // Protects promptCache and promptDirs
+	trace         io.Writer                          // Receives a line per check when set (--trace-permissions)
+	traceMu       sync.Mutex                         // Serializes trace lines
+	messages      map[MessageKind]*template.Template // Custom message templates (SetMessageTemplate)
+	messagesMu    sync.RWMutex                       // Protects messages
 }
 
 // globalManager is the singleton permission manager instance.
 var globalManager *Manager
 
+// globalMu guards globalManager, which background goroutines read while a
+// runtime (or a test) may be swapping it.
+var globalMu sync.Mutex
+
 // IsTerminal checks if stdin is connected to a terminal.
 // This determines whether interactive prompts are available.
 func IsTerminal() bool {
@@ -90,35 +103,58 @@ func IsTerminal() bool {
 	return (fileInfo.Mode() & os.ModeCharDevice) != 0
 }
 
+// stdinIsTerminal is the TTY check used by NewManager (replaced in tests).
+var stdinIsTerminal = IsTerminal
+
 // NewManager creates a new permission manager with default settings.
 // Prompt mode is automatically enabled if stdin is a terminal.
 func NewManager() *Manager {
-	return &Manager{
+	m := &Manager{
 		allowRead:   nil,
 		allowWrite:  nil,
 		allowNet:    nil,
 		allowEnv:    nil,
 		allowRun:    nil,
-		promptMode:  IsTerminal(),
-		prompter:    NewStdioPrompter(),
+		allowSys:    nil,
+		promptMode:  stdinIsTerminal(),
 		promptCache: make(map[string]PermissionState),
+		promptDirs:  make(map[Permission][]string),
 	}
+	m.prompter = &StdioPrompter{message: m.PromptMessage}
+	return m
 }
 
 // SetGlobalManager sets the global permission manager instance.
 // This should be called once during runtime initialization.
 func SetGlobalManager(m *Manager) {
+	globalMu.Lock()
+	defer globalMu.Unlock()
 	globalManager = m
 }
 
 // GetManager returns the global permission manager, creating one if needed.
 func GetManager() *Manager {
+	globalMu.Lock()
+	defer globalMu.Unlock()
 	if globalManager == nil {
 		globalManager = NewManager()
 	}
 	return globalManager
 }
 
+// Reset replaces the global manager with a fresh NewManager(), so embedders
+// running several scripts in one process start each execution with no grants
+// and no cached prompt answers. Callers still holding the previous manager
+// see its prompt cache cleared as well.
+func Reset() {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	if globalManager != nil {
+		globalManager.ClearPromptCache()
+	}
+	globalManager = NewManager()
+}
+
 // GrantAll grants all permission types without restriction.
 // This is equivalent to --allow-all and should only be used in development.
 func (m *Manager) GrantAll() {
@@ -127,6 +163,7 @@ func (m *Manager) GrantAll() {
 	m.allowNet = &[]string{}
 	m.allowEnv = &[]string{}
 	m.allowRun = &[]string{}
+	m.allowSys = &[]string{}
 }
 
 // GrantRead grants read permission for the specified paths.
@@ -166,12 +203,47 @@ func (m *Manager) GrantRun(programs []string) {
 	m.allowRun = &cp
 }
 
+// GrantSys grants access to the specified system information APIs, named
+// as the os module names them (e.g. "hostname", "totalmem").
+// If names is empty, all system information is accessible.
+func (m *Manager) GrantSys(names []string) {
+	cp := append([]string(nil), names...)
+	m.allowSys = &cp
+}
+
 // SetPromptMode enables or disables interactive permission prompts.
 // When enabled and stdin is a terminal, users are prompted for missing permissions.
 func (m *Manager) SetPromptMode(enabled bool) {
 	m.promptMode = enabled
 }
 
+// SetTraceOutput logs every Check and CheckWithPrompt call, with the
+// permission, resource and result, to w. A nil writer disables tracing.
+func (m *Manager) SetTraceOutput(w io.Writer) {
+	m.traceMu.Lock()
+	defer m.traceMu.Unlock()
+	m.trace = w
+}
+
+// traceCheck writes one trace line if tracing is enabled.
+// via names what decided the outcome (e.g. "prompt", "cached answer").
+func (m *Manager) traceCheck(method string, perm Permission, resource string, granted bool, via string) {
+	m.traceMu.Lock()
+	defer m.traceMu.Unlock()
+	if m.trace == nil {
+		return
+	}
+
+	result := "denied"
+	if granted {
+		result = "granted"
+	}
+	if via != "" {
+		result += " (" + via + ")"
+	}
+	fmt.Fprintf(m.trace, "[permissions] %s %s %q: %s\n", method, perm, resource, result)
+}
+
 // SetConfig sets the .douglessrc configuration for this manager.
 // Config permissions are checked before CLI flags and prompts.
 func (m *Manager) SetConfig(config *Config) {
@@ -202,6 +274,8 @@ func (m *Manager) checkConfig(perm Permission, resource string) bool {
 		paths = m.config.Permissions.Env
 	case PermissionRun:
 		paths = m.config.Permissions.Run
+	case PermissionSys:
+		paths = m.config.Permissions.Sys
 	default:
 		return false
 	}
@@ -216,7 +290,7 @@ func (m *Manager) checkConfig(perm Permission, resource string) bool {
 			matches = matchPath(allowed, resource)
 		case PermissionNet:
 			matches = matchHost(allowed, resource)
-		case PermissionEnv, PermissionRun:
+		case PermissionEnv, PermissionRun, PermissionSys:
 			matches = matchExact(allowed, resource)
 		}
 
@@ -231,8 +305,16 @@ func (m *Manager) checkConfig(perm Permission, resource string) bool {
 // Check verifies if a permission is granted for the specified resource.
 // Returns true if the permission is explicitly granted, false otherwise.
 // This method does NOT trigger interactive prompts.
-// Checks config first, then CLI flags
+// Checks config first, then CLI flags. Answers given at a prompt, including
+// ScopeDirectory grants kept in promptDirs, are not consulted; only
+// CheckWithPrompt and Query see them.
 func (m *Manager) Check(perm Permission, resource string) bool {
+	granted := m.check(perm, resource)
+	m.traceCheck("Check", perm, resource, granted, "")
+	return granted
+}
+
+func (m *Manager) check(perm Permission, resource string) bool {
 	if m.checkConfig(perm, resource) {
 		return true
 	}
@@ -248,6 +330,8 @@ func (m *Manager) Check(perm Permission, resource string) bool {
 		return m.checkPermission(m.allowEnv, resource, matchExact)
 	case PermissionRun:
 		return m.checkPermission(m.allowRun, resource, matchExact)
+	case PermissionSys:
+		return m.checkPermission(m.allowSys, resource, matchExact)
 	default:
 		return false
 	}
@@ -273,10 +357,34 @@ func (m *Manager) checkPermission(allowList *[]string, resource string, matcher
 	return false
 }
 
+// splitPathGlob splits an allow entry like "/data:*.json" into the path and
+// the file name pattern. Entries without a ':' suffix containing a glob
+// character (so also Windows drive paths like C:\data) have no pattern.
+func splitPathGlob(entry string) (path, pattern string) {
+	i := strings.LastIndex(entry, ":")
+	if i <= 0 {
+		return entry, ""
+	}
+	suffix := entry[i+1:]
+	if suffix == "" || strings.ContainsAny(suffix, `/\`) || !strings.ContainsAny(suffix, "*?[") {
+		return entry, ""
+	}
+	return entry[:i], suffix
+}
+
 // matchPath checks if a requested path is allowed based on an allowed path.
 // Supports directory hierarchies: if /home/user is allowed, /home/user/file.txt is also allowed.
 // Prevents directory traversal attacks by checking for ".." in relative paths.
+// An allowed path of the form /data:*.json additionally requires the
+// requested file name to match the pattern (see filepath.Match).
 func matchPath(allowedPath, requestedPath string) bool {
+	allowedPath, pattern := splitPathGlob(allowedPath)
+	if pattern != "" {
+		if ok, err := filepath.Match(pattern, filepath.Base(requestedPath)); err != nil || !ok {
+			return false
+		}
+	}
+
 	allowed, err := filepath.Abs(filepath.Clean(allowedPath))
 	if err != nil {
 		return false
@@ -397,14 +505,19 @@ func matchExact(allowed, requested string) bool {
 	return allowed == requested
 }
 
-// Query determines the current state of a permission.
-// Returns StateGranted if allowed, StatePrompt if prompting is enabled, or StateDenied otherwise.
+// Query determines the current state of a permission without prompting.
+// Returns StateGranted if allowed, the remembered answer if a prompt was
+// already answered for the session, StatePrompt if prompting is enabled, or
+// StateDenied otherwise.
 func (m *Manager) Query(desc PermissionDescriptor) PermissionState {
 	if m.Check(desc.Name, desc.Resource) {
 		return StateGranted
 	}
 
 	if m.promptMode {
+		if state, exists := m.cachedAnswer(desc.Name, desc.Resource); exists {
+			return state
+		}
 		return StatePrompt
 	}
 
@@ -412,54 +525,18 @@ func (m *Manager) Query(desc PermissionDescriptor) PermissionState {
 }
 
 // ErrorMessage generates a helpful error message for permission denials.
-// Includes examples of how to grant the required permission via CLI flags.
+// Includes examples of how to grant the required permission via CLI flags,
+// unless a MessageDenied template has been set.
 func (m *Manager) ErrorMessage(perm Permission, resource string) string {
-	var flag string
-	var example string
-
-	switch perm {
-	case PermissionRead:
-		flag = "--allow-read"
-		if resource != "" {
-			example = fmt.Sprintf("  dougless --allow-read=%s script.js", resource)
-		} else {
-			example = "  dougless --allow-read script.js"
-		}
-	case PermissionWrite:
-		flag = "--allow-write"
-		if resource != "" {
-			example = fmt.Sprintf("  dougless --allow-write=%s script.js", resource)
-		} else {
-			example = "  dougless --allow-write script.js"
-		}
-	case PermissionNet:
-		flag = "--allow-net"
-		if resource != "" {
-			example = fmt.Sprintf("  dougless --allow-net=%s script.js", resource)
-		} else {
-			example = "  dougless --allow-net script.js"
-		}
-	case PermissionEnv:
-		flag = "--allow-env"
-		if resource != "" {
-			example = fmt.Sprintf("  dougless --allow-env=%s script.js", resource)
-		} else {
-			example = "  dougless --allow-env script.js"
-		}
-	case PermissionRun:
-		flag = "--allow-run"
-		if resource != "" {
-			example = fmt.Sprintf("  dougless --allow-run=%s script.js", resource)
-		} else {
-			example = "  dougless --allow-run script.js"
-		}
+	if msg, ok := m.renderMessage(MessageDenied, perm, resource); ok {
+		return msg
 	}
 
-	desc := PermissionDescriptor{Name: perm, Resource: resource}
+	data := messageData(perm, resource)
 
-	msg := fmt.Sprintf("Permission denied: %s\n\n", desc)
-	msg += fmt.Sprintf("Run your script with:\n%s\n\n", example)
-	msg += fmt.Sprintf("Or grant all %s access:\n  dougless %s script.js\n", perm, flag)
+	msg := fmt.Sprintf("Permission denied: %s\n\n", data.Descriptor)
+	msg += fmt.Sprintf("Run your script with:\n  %s\n\n", data.Example)
+	msg += fmt.Sprintf("Or grant all %s access:\n  dougless %s script.js\n", perm, data.Flag)
 	msg += fmt.Sprintf("\nFor dev, use:\n  dougless --allow-all script.js")
 
 	return msg
@@ -470,6 +547,23 @@ func cacheKey(perm Permission, resource string) string {
 	return fmt.Sprintf("%s:%s", perm, resource)
 }
 
+// cachedAnswer returns the remembered answer for a resource: an exact
+// session answer, or a grant of a directory containing it.
+func (m *Manager) cachedAnswer(perm Permission, resource string) (PermissionState, bool) {
+	m.promptCacheMu.RLock()
+	defer m.promptCacheMu.RUnlock()
+
+	if state, exists := m.promptCache[cacheKey(perm, resource)]; exists {
+		return state, true
+	}
+	for _, dir := range m.promptDirs[perm] {
+		if matchPath(dir, resource) {
+			return StateGranted, true
+		}
+	}
+	return "", false
+}
+
 // SetPrompter replaces the default prompter with a custom implementation.
 // Useful for testing or alternative UI implementations.
 func (m *Manager) SetPrompter(p Prompter) {
@@ -482,34 +576,39 @@ func (m *Manager) ClearPromptCache() {
 	m.promptCacheMu.Lock()
 	defer m.promptCacheMu.Unlock()
 	m.promptCache = make(map[string]PermissionState)
+	m.promptDirs = make(map[Permission][]string)
 }
 
 // CheckWithPrompt checks a permission and prompts the user if needed.
 // If the permission is not granted and prompt mode is enabled, the user is prompted.
-// Responses can be cached for the session if the user selects "always".
+// "Allow always" and "deny always" answers are cached for the session;
+// "allow once" answers are not, so the next check prompts again.
 //
 // This is the primary method used by runtime operations to check permissions.
 func (m *Manager) CheckWithPrompt(ctx context.Context, perm Permission, resource string) bool {
-	if m.Check(perm, resource) {
-		return true
+	granted, via := m.checkWithPrompt(ctx, perm, resource)
+	m.traceCheck("CheckWithPrompt", perm, resource, granted, via)
+	return granted
+}
+
+// checkWithPrompt implements CheckWithPrompt and reports what decided the outcome.
+func (m *Manager) checkWithPrompt(ctx context.Context, perm Permission, resource string) (bool, string) {
+	if m.check(perm, resource) {
+		return true, "allowed"
 	}
 
 	if !m.promptMode {
-		return false
+		return false, "not allowed"
 	}
 
-	key := cacheKey(perm, resource)
-	m.promptCacheMu.RLock()
-	if state, exists := m.promptCache[key]; exists {
-		m.promptCacheMu.RUnlock()
-		return state == StateGranted
+	if state, exists := m.cachedAnswer(perm, resource); exists {
+		return state == StateGranted, "cached answer"
 	}
-	m.promptCacheMu.RUnlock()
 
 	desc := PermissionDescriptor{Name: perm, Resource: resource}
 	response, err := m.prompter.Prompt(ctx, desc)
 	if err != nil {
-		return false
+		return false, "prompt failed"
 	}
 
 	state := StateDenied
@@ -517,17 +616,36 @@ func (m *Manager) CheckWithPrompt(ctx context.Context, perm Permission, resource
 		state = StateGranted
 	}
 
+	// a directory-scoped read/write grant covers the whole directory, unless
+	// that would be the filesystem root
+	granted := resource
+	coversDir := false
+	if response.Granted && response.Scope == ScopeDirectory &&
+		(perm == PermissionRead || perm == PermissionWrite) {
+		dir := filepath.Dir(filepath.Clean(resource))
+		if dir != filepath.Dir(dir) {
+			granted, coversDir = dir, true
+		}
+	}
+
 	// Save to config if requested
 	if response.SaveToConfig {
-		if err := SavePermissionToConfig(m.configPath, perm, resource); err != nil {
+		if err := SavePermissionToConfig(m.configPath, perm, granted); err != nil {
 			// Log error but don't fail the permission grant
 			fmt.Fprintf(os.Stderr, "Warning: Failed to save to .douglessrc: %v\n", err)
 		}
 	}
 
-	m.promptCacheMu.Lock()
-	m.promptCache[key] = state
-	m.promptCacheMu.Unlock()
+	switch {
+	case coversDir:
+		m.promptCacheMu.Lock()
+		m.promptDirs[perm] = append(m.promptDirs[perm], granted)
+		m.promptCacheMu.Unlock()
+	case response.Scope == ScopeSession || response.Scope == ScopeDirectory:
+		m.promptCacheMu.Lock()
+		m.promptCache[cacheKey(perm, resource)] = state
+		m.promptCacheMu.Unlock()
+	}
 
-	return response.Granted
+	return response.Granted, "prompt"
 }
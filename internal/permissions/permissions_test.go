@@ -1,7 +1,10 @@
 package permissions
 
 import (
+	"bytes"
+	"context"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -180,6 +183,12 @@ func TestMatchPath(t *testing.T) {
 		{"different path", "/tmp", "/etc/passwd", false},
 		{"parent directory escape attempt", "/tmp", "/tmp/../etc/passwd", false},
 		{"relative path allowed", "/tmp", "tmp/test.txt", false},
+		{"extension pattern match", "/data:*.json", "/data/x.json", true},
+		{"extension pattern nested", "/data:*.json", "/data/sub/y.json", true},
+		{"extension pattern mismatch", "/data:*.json", "/data/x.txt", false},
+		{"extension pattern outside path", "/data:*.json", "/etc/x.json", false},
+		{"extension pattern escape attempt", "/data:*.json", "/data/../etc/x.json", false},
+		{"colon without pattern is a path", "/data:v2", "/data:v2/file.txt", true},
 	}
 
 	for _, tt := range tests {
@@ -306,6 +315,21 @@ func TestQuery(t *testing.T) {
 			t.Errorf("expected StatePrompt, got %v", state)
 		}
 	})
+
+	t.Run("remembers session answers", func(t *testing.T) {
+		manager := NewManager()
+		manager.SetPromptMode(true)
+		manager.SetPrompter(&MockPrompter{Response: PromptResponse{Granted: false, Scope: ScopeSession}})
+		desc := PermissionDescriptor{
+			Name:     PermissionRead,
+			Resource: "/etc/passwd",
+		}
+
+		manager.CheckWithPrompt(context.Background(), desc.Name, desc.Resource)
+		if state := manager.Query(desc); state != StateDenied {
+			t.Errorf("expected StateDenied after a denied prompt, got %v", state)
+		}
+	})
 }
 
 func TestPermissionDescriptorString(t *testing.T) {
@@ -360,6 +384,60 @@ func TestErrorMessage(t *testing.T) {
 	})
 }
 
+func TestMessageTemplates(t *testing.T) {
+	manager := NewManager()
+	defaultMsg := manager.ErrorMessage(PermissionRead, "/etc/passwd")
+	defaultPrompt := manager.PromptMessage(PermissionDescriptor{Name: PermissionNet, Resource: "example.com"})
+
+	if err := manager.SetMessageTemplate(MessageDenied, "Zugriff verweigert: {{.Descriptor}} ({{.Flag}}; {{.Example}})"); err != nil {
+		t.Fatalf("SetMessageTemplate(denied) error = %v", err)
+	}
+	if err := manager.SetMessageTemplate(MessagePrompt, "Erlauben: {{.Permission}} {{.Resource}}?"); err != nil {
+		t.Fatalf("SetMessageTemplate(prompt) error = %v", err)
+	}
+
+	want := "Zugriff verweigert: read access to '/etc/passwd' (--allow-read; dougless --allow-read=/etc/passwd script.js)"
+	if got := manager.ErrorMessage(PermissionRead, "/etc/passwd"); got != want {
+		t.Errorf("ErrorMessage() = %q, want %q", got, want)
+	}
+	if got := manager.PromptMessage(PermissionDescriptor{Name: PermissionNet, Resource: "example.com"}); got != "Erlauben: net example.com?" {
+		t.Errorf("PromptMessage() = %q", got)
+	}
+
+	// other managers keep the default text
+	if got := NewManager().ErrorMessage(PermissionRead, "/etc/passwd"); got != defaultMsg {
+		t.Errorf("ErrorMessage() on a new manager = %q, want the default %q", got, defaultMsg)
+	}
+
+	// an empty template restores the default
+	manager.SetMessageTemplate(MessageDenied, "")
+	manager.SetMessageTemplate(MessagePrompt, "")
+	if got := manager.ErrorMessage(PermissionRead, "/etc/passwd"); got != defaultMsg {
+		t.Errorf("ErrorMessage() after reset = %q, want %q", got, defaultMsg)
+	}
+	if got := manager.PromptMessage(PermissionDescriptor{Name: PermissionNet, Resource: "example.com"}); got != defaultPrompt {
+		t.Errorf("PromptMessage() after reset = %q, want %q", got, defaultPrompt)
+	}
+
+	for _, tt := range []struct {
+		kind MessageKind
+		text string
+	}{
+		{MessageDenied, "{{.Descriptor"},
+		{"banner", "hello"},
+	} {
+		if err := manager.SetMessageTemplate(tt.kind, tt.text); err == nil {
+			t.Errorf("SetMessageTemplate(%q, %q) error = nil", tt.kind, tt.text)
+		}
+	}
+
+	// a template that fails to execute falls back to the default
+	manager.SetMessageTemplate(MessageDenied, "{{.Missing}}")
+	if got := manager.ErrorMessage(PermissionRead, "/etc/passwd"); got != defaultMsg {
+		t.Errorf("ErrorMessage() with a failing template = %q, want the default", got)
+	}
+}
+
 func TestGlobalManager(t *testing.T) {
 	// Save and restore original global manager
 	originalManager := globalManager
@@ -504,3 +582,66 @@ func TestGrantDefensiveCopy(t *testing.T) {
 		t.Error("expected rm not allowed due to defensive copy (run)")
 	}
 }
+
+func TestReset(t *testing.T) {
+	previous := globalManager
+	defer SetGlobalManager(previous)
+
+	manager := NewManager()
+	manager.GrantRead([]string{"/tmp/embedded"})
+	manager.GrantNet([]string{"api.example.com"})
+	manager.promptCache[cacheKey(PermissionWrite, "/tmp/out")] = StateGranted
+	SetGlobalManager(manager)
+
+	if !GetManager().Check(PermissionRead, "/tmp/embedded/data.txt") {
+		t.Fatal("expected read to be granted before Reset")
+	}
+
+	Reset()
+
+	if GetManager() == manager {
+		t.Fatal("Reset should install a new global manager")
+	}
+	if GetManager().Check(PermissionRead, "/tmp/embedded/data.txt") {
+		t.Error("read should be denied after Reset")
+	}
+	if GetManager().Check(PermissionNet, "api.example.com") {
+		t.Error("net should be denied after Reset")
+	}
+	if len(manager.promptCache) != 0 {
+		t.Error("Reset should clear the previous manager's prompt cache")
+	}
+}
+
+func TestTracePermissions(t *testing.T) {
+	manager := NewManager()
+	manager.SetPromptMode(false)
+	manager.GrantRead([]string{"/tmp/allowed"})
+
+	var trace bytes.Buffer
+	manager.SetTraceOutput(&trace)
+
+	manager.Check(PermissionRead, "/tmp/allowed/data.txt")
+	manager.CheckWithPrompt(context.Background(), PermissionRead, "/etc/shadow")
+
+	lines := strings.Split(strings.TrimSpace(trace.String()), "\n")
+	want := []string{
+		`[permissions] Check read "/tmp/allowed/data.txt": granted`,
+		`[permissions] CheckWithPrompt read "/etc/shadow": denied (not allowed)`,
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("expected %d trace lines, got %q", len(want), trace.String())
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("trace line %d = %q, want %q", i, lines[i], want[i])
+		}
+	}
+
+	trace.Reset()
+	manager.SetTraceOutput(nil)
+	manager.Check(PermissionRead, "/tmp/allowed/data.txt")
+	if trace.Len() != 0 {
+		t.Errorf("expected no trace after disabling, got %q", trace.String())
+	}
+}
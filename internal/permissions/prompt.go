@@ -11,10 +11,27 @@ import (
 	"sync"
 )
 
+// PromptScope controls how long a prompt answer is remembered.
+type PromptScope int
+
+// Prompt scopes.
+const (
+	ScopeSession   PromptScope = iota // Remember the answer for the rest of the session
+	ScopeOnce                         // Apply to this request only; ask again next time
+	ScopeDirectory                    // Like ScopeSession, but a read/write grant covers the file's whole directory
+)
+
 // PromptResponse represents the user's response to a permission prompt.
+//
+// The prompt offers these choices:
+//   - allow once:      {Granted: true, Scope: ScopeOnce}
+//   - allow always:    {Granted: true, Scope: ScopeSession}
+//   - allow directory: {Granted: true, Scope: ScopeDirectory} (read/write only)
+//   - deny always:     {Granted: false, Scope: ScopeSession}
 type PromptResponse struct {
-	Granted      bool // Whether the permission was granted
-	SaveToConfig bool // Whether to write to .douglessrc
+	Granted      bool        // Whether the permission was granted
+	Scope        PromptScope // How long the answer is cached (session by default)
+	SaveToConfig bool        // Whether to write to .douglessrc
 }
 
 // Prompter is the interface for prompting users for permissions.
@@ -26,7 +43,8 @@ type Prompter interface {
 // StdioPrompter implements interactive terminal prompts via stdin/stderr.
 // All prompts are serialized to prevent concurrent stdin reads.
 type StdioPrompter struct {
-	mu sync.Mutex // Serialize prompts to prevent concurrent stdin reads
+	mu      sync.Mutex                        // Serialize prompts to prevent concurrent stdin reads
+	message func(PermissionDescriptor) string // Request line; the manager's PromptMessage for its own prompter
 }
 
 // NewStdioPrompter creates a new terminal prompter.
@@ -35,8 +53,10 @@ func NewStdioPrompter() *StdioPrompter {
 }
 
 // Prompt displays a permission request and waits for user input.
-// Accepts responses: y/yes (grant), or any other (deny).
-// If granted, prompts whether to save to .douglessrc.
+// Accepts responses: o/once (allow this request only), a/always or y/yes
+// (allow for the session), t/directory (for read/write, allow the file's
+// whole directory for the session), or any other (deny for the session).
+// If allowed always, prompts whether to save to .douglessrc.
 // Respects context cancellation and timeouts.
 //
 // The prompt is displayed on stderr to avoid interfering with program output.
@@ -49,8 +69,17 @@ func (p *StdioPrompter) Prompt(ctx context.Context, desc PermissionDescriptor) (
 	errorChan := make(chan error, 1)
 
 	go func() {
-		fmt.Fprintf(os.Stderr, "\n⚠️  Permission request: %s\n", desc)
-		fmt.Fprintf(os.Stderr, "Allow? (y/n): ")
+		coversDir := desc.Name == PermissionRead || desc.Name == PermissionWrite
+		if p.message != nil {
+			fmt.Fprintf(os.Stderr, "\n%s\n", p.message(desc))
+		} else {
+			fmt.Fprintf(os.Stderr, "\n⚠️  Permission request: %s\n", desc)
+		}
+		if coversDir {
+			fmt.Fprintf(os.Stderr, "Allow? [o]nce, [a]lways, [t]his directory, [d]eny always: ")
+		} else {
+			fmt.Fprintf(os.Stderr, "Allow? [o]nce, [a]lways, [d]eny always: ")
+		}
 
 		// Create a fresh reader for each prompt to avoid buffering issues
 		reader := bufio.NewReader(os.Stdin)
@@ -62,21 +91,34 @@ func (p *StdioPrompter) Prompt(ctx context.Context, desc PermissionDescriptor) (
 
 		response := strings.TrimSpace(strings.ToLower(line))
 
-		// If denied, return early
-		if response != "y" && response != "yes" {
-			fmt.Fprintln(os.Stderr, "✗ Permission denied")
+		scope := ScopeSession
+		switch response {
+		case "o", "once":
+			fmt.Fprintln(os.Stderr, "✓ Granted once")
+			responseChan <- PromptResponse{Granted: true, Scope: ScopeOnce}
+			return
+		case "a", "always", "y", "yes":
+		case "t", "directory":
+			if !coversDir {
+				fmt.Fprintln(os.Stderr, "✗ Permission denied for this session")
+				responseChan <- PromptResponse{Granted: false, SaveToConfig: false}
+				return
+			}
+			scope = ScopeDirectory
+		default:
+			fmt.Fprintln(os.Stderr, "✗ Permission denied for this session")
 			responseChan <- PromptResponse{Granted: false, SaveToConfig: false}
 			return
 		}
 
-		// User said yes - ask about config
+		// User said always - ask about config
 		fmt.Fprintf(os.Stderr, "Save to .douglessrc? (y/n): ")
 
 		line, err = reader.ReadString('\n')
 		if err != nil {
 			// Grant for session even if second read fails
 			fmt.Fprintln(os.Stderr, "✓ Granted for this session")
-			responseChan <- PromptResponse{Granted: true, SaveToConfig: false}
+			responseChan <- PromptResponse{Granted: true, Scope: scope, SaveToConfig: false}
 			return
 		}
 
@@ -91,6 +133,7 @@ func (p *StdioPrompter) Prompt(ctx context.Context, desc PermissionDescriptor) (
 
 		responseChan <- PromptResponse{
 			Granted:      true,
+			Scope:        scope,
 			SaveToConfig: saveToConfig,
 		}
 	}()
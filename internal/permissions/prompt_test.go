@@ -365,3 +365,101 @@ func TestManagerWithMockPrompter_ClearCache(t *testing.T) {
 		t.Errorf("expected 2 calls after cache clear, got %d", mock.Called)
 	}
 }
+
+func TestManagerWithMockPrompter_Scopes(t *testing.T) {
+	tests := []struct {
+		name        string
+		response    PromptResponse
+		wantGranted bool
+		wantCalls   int // prompts after two checks of the same resource
+	}{
+		{
+			name:        "allow once re-prompts",
+			response:    PromptResponse{Granted: true, Scope: ScopeOnce},
+			wantGranted: true,
+			wantCalls:   2,
+		},
+		{
+			name:        "allow always is cached",
+			response:    PromptResponse{Granted: true, Scope: ScopeSession},
+			wantGranted: true,
+			wantCalls:   1,
+		},
+		{
+			name:        "deny always is cached",
+			response:    PromptResponse{Granted: false, Scope: ScopeSession},
+			wantGranted: false,
+			wantCalls:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := NewManager()
+			manager.SetPromptMode(true)
+
+			mock := &MockPrompter{Response: tt.response}
+			manager.SetPrompter(mock)
+
+			for i := 0; i < 2; i++ {
+				granted := manager.CheckWithPrompt(context.Background(), PermissionRead, "/tmp/scoped.txt")
+				if granted != tt.wantGranted {
+					t.Errorf("check %d: granted = %v, want %v", i+1, granted, tt.wantGranted)
+				}
+			}
+
+			if mock.Called != tt.wantCalls {
+				t.Errorf("expected %d prompts, got %d", tt.wantCalls, mock.Called)
+			}
+		})
+	}
+}
+
+func TestManagerWithMockPrompter_DirectoryScope(t *testing.T) {
+	manager := NewManager()
+	manager.SetPromptMode(true)
+
+	mock := &MockPrompter{Response: PromptResponse{Granted: true, Scope: ScopeDirectory}}
+	manager.SetPrompter(mock)
+
+	if !manager.CheckWithPrompt(context.Background(), PermissionRead, "/data/a.txt") {
+		t.Fatal("expected /data/a.txt to be granted")
+	}
+
+	// the rest of the directory is covered without another prompt
+	for _, path := range []string{"/data/b.txt", "/data/sub/c.txt"} {
+		if !manager.CheckWithPrompt(context.Background(), PermissionRead, path) {
+			t.Errorf("expected %s to be granted", path)
+		}
+		if state := manager.Query(PermissionDescriptor{Name: PermissionRead, Resource: path}); state != StateGranted {
+			t.Errorf("Query(%s) = %v, want StateGranted", path, state)
+		}
+	}
+	if mock.Called != 1 {
+		t.Errorf("expected 1 prompt, got %d", mock.Called)
+	}
+	// Check only consults the allow-lists, not prompt answers
+	if manager.Check(PermissionRead, "/data/b.txt") {
+		t.Error("expected Check to ignore the directory grant")
+	}
+
+	// other directories and permissions still prompt
+	manager.CheckWithPrompt(context.Background(), PermissionRead, "/etc/passwd")
+	manager.CheckWithPrompt(context.Background(), PermissionWrite, "/data/b.txt")
+	if mock.Called != 3 {
+		t.Errorf("expected 3 prompts, got %d", mock.Called)
+	}
+
+	// a file at the root doesn't grant the whole filesystem
+	manager.CheckWithPrompt(context.Background(), PermissionWrite, "/top.txt")
+	manager.CheckWithPrompt(context.Background(), PermissionWrite, "/other.txt")
+	if mock.Called != 5 {
+		t.Errorf("expected 5 prompts, got %d", mock.Called)
+	}
+
+	manager.ClearPromptCache()
+	manager.CheckWithPrompt(context.Background(), PermissionRead, "/data/b.txt")
+	if mock.Called != 6 {
+		t.Errorf("expected a prompt after clearing the cache, got %d prompts", mock.Called)
+	}
+}
@@ -2,9 +2,10 @@
 //
 // The REPL allows users to interactively execute JavaScript code, with features including:
 //   - Multi-line input support with automatic bracket/brace detection
-//   - Command history (up/down arrows)
+//   - Command history (up/down arrows), kept in ~/.dougless_history
 //   - Special commands (.help, .exit, .clear)
 //   - State preservation between evaluations
+//   - Promises (and a leading await) are awaited and their results printed
 //   - Proper error display with Goja exception handling
 //
 // Example usage:
@@ -17,44 +18,129 @@
 package repl
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
-	"github.com/dop251/goja"
 	"github.com/peterh/liner"
 
 	"github.com/douglasjordan2/dougless/internal/runtime"
 )
 
+// lineReader is the input source behind the REPL prompt.
+type lineReader interface {
+	Prompt(prompt string) (string, error)
+	AppendHistory(item string)
+	Close() error
+}
+
+// DefaultAwaitTimeout is how long the REPL waits for a promise to settle
+// before giving the prompt back.
+const DefaultAwaitTimeout = 5 * time.Second
+
+// DefaultHistorySize is how many lines of history the REPL keeps.
+const DefaultHistorySize = 1000
+
+// HistoryFileName is the file in the home directory where an interactive
+// REPL keeps its history between sessions.
+const HistoryFileName = ".dougless_history"
+
 // REPL represents an interactive JavaScript shell.
 // It maintains state between evaluations and supports multi-line input.
 type REPL struct {
-	runtime *runtime.Runtime // JavaScript runtime for code execution
-	line    *liner.State     // Liner instance for input handling and history
-	writer  io.Writer        // Output writer for results and messages
+	runtime      *runtime.Runtime // JavaScript runtime for code execution
+	line         lineReader       // Input handling and history
+	writer       io.Writer        // Output writer for results and messages
+	awaitTimeout time.Duration    // How long to wait for a promise result to settle
+	historyFile  string           // Where history is saved ("" keeps it in memory)
+	historySize  int              // Maximum number of history lines kept
+	history      []string         // This session's history, oldest first
 }
 
 // New creates a new REPL instance with the given runtime and I/O streams.
 //
-// The reader parameter is included for API compatibility but currently unused
-// as liner handles input directly from the terminal.
+// When reader is os.Stdin, liner handles input (line editing and history on a
+// terminal) and history is saved to ~/.dougless_history. Any other reader is
+// read line by line, which is how piped or scripted sessions are driven;
+// their history isn't saved unless SetHistoryFile says where.
 //
 // Example:
 //
 //	rt := runtime.New()
 //	repl := repl.New(rt, os.Stdin, os.Stdout)
 func New(rt *runtime.Runtime, reader io.Reader, writer io.Writer) *REPL {
-	line := liner.NewLiner()
-	line.SetCtrlCAborts(true)
+	var line lineReader
+	historyFile := ""
+	if reader == nil || reader == os.Stdin {
+		state := liner.NewLiner()
+		state.SetCtrlCAborts(true)
+		line = state
+		if home, err := os.UserHomeDir(); err == nil {
+			historyFile = filepath.Join(home, HistoryFileName)
+		}
+	} else {
+		line = &plainReader{scanner: bufio.NewScanner(reader), writer: writer}
+	}
 
 	return &REPL{
-		runtime: rt,
-		line:    line,
-		writer:  writer,
+		runtime:      rt,
+		line:         line,
+		writer:       writer,
+		awaitTimeout: DefaultAwaitTimeout,
+		historyFile:  historyFile,
+		historySize:  DefaultHistorySize,
+	}
+}
+
+// SetHistoryFile changes where history is loaded from when Run starts and
+// saved to after each line; "" keeps history in memory only.
+func (r *REPL) SetHistoryFile(path string) {
+	r.historyFile = path
+}
+
+// SetHistorySize caps how many lines of history are kept and saved
+// (DefaultHistorySize unless set). The terminal line editor recalls at most
+// its own limit of 1000.
+func (r *REPL) SetHistorySize(n int) {
+	if n < 1 {
+		n = 1
+	}
+	r.historySize = n
+}
+
+// SetAwaitTimeout changes how long the REPL waits for a promise to settle
+// (DefaultAwaitTimeout unless set).
+func (r *REPL) SetAwaitTimeout(d time.Duration) {
+	r.awaitTimeout = d
+}
+
+// plainReader reads lines from a non-terminal reader without line editing.
+type plainReader struct {
+	scanner *bufio.Scanner
+	writer  io.Writer
+}
+
+func (p *plainReader) Prompt(prompt string) (string, error) {
+	fmt.Fprint(p.writer, prompt)
+
+	if !p.scanner.Scan() {
+		if err := p.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
 	}
+	return p.scanner.Text(), nil
 }
 
+func (p *plainReader) AppendHistory(item string) {}
+
+func (p *plainReader) Close() error { return nil }
+
 // isIncompleteInput detects if the user's input is incomplete (unmatched brackets).
 // This enables multi-line input support by checking for unclosed:
 //   - Braces: { }
@@ -75,6 +161,64 @@ func (r *REPL) isIncompleteInput(input string) bool {
 	return openBraces > 0 || openBrackets > 0 || openParens > 0
 }
 
+// loadHistory reads the history file, if there is one, so earlier sessions'
+// lines can be recalled. A missing or unreadable file just means no history.
+func (r *REPL) loadHistory() {
+	if r.historyFile == "" {
+		return
+	}
+	data, err := os.ReadFile(r.historyFile)
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			r.addToHistory(line)
+		}
+	}
+	for _, line := range r.history {
+		r.line.AppendHistory(line)
+	}
+}
+
+// addHistory records a line entered at the prompt and saves the history.
+// A line repeating the previous one isn't recorded again.
+func (r *REPL) addHistory(line string) {
+	if !r.addToHistory(line) {
+		return
+	}
+	r.line.AppendHistory(line)
+	r.saveHistory()
+}
+
+// addToHistory appends line unless it repeats the last entry, dropping the
+// oldest entries beyond the size limit. It reports whether line was added.
+func (r *REPL) addToHistory(line string) bool {
+	if n := len(r.history); n > 0 && r.history[n-1] == line {
+		return false
+	}
+	r.history = append(r.history, line)
+	if extra := len(r.history) - r.historySize; extra > 0 {
+		r.history = append([]string(nil), r.history[extra:]...)
+	}
+	return true
+}
+
+// saveHistory writes the history file. Failures, like an unwritable home
+// directory, are ignored: history is a convenience, not worth interrupting
+// the session for.
+func (r *REPL) saveHistory() {
+	if r.historyFile == "" {
+		return
+	}
+	var b strings.Builder
+	for _, line := range r.history {
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	os.WriteFile(r.historyFile, []byte(b.String()), 0600)
+}
+
 // printWelcome displays the welcome message when the REPL starts.
 func (r *REPL) printWelcome() {
 	fmt.Fprintln(r.writer, "Dougless Runtime REPL v0.1.0")
@@ -126,10 +270,15 @@ func (r *REPL) printHelp() {
 //  4. Prints results or errors
 //  5. Repeats until .exit command or EOF (Ctrl+D)
 //
+// On exit (including EOF on stdin) the runtime's event loop is stopped so
+// the process can shut down cleanly instead of hanging.
+//
 // Returns an error if there's a problem with I/O, or nil on normal exit.
 func (r *REPL) Run() error {
+	defer r.runtime.Close()
 	defer r.line.Close()
 
+	r.loadHistory()
 	r.printWelcome()
 
 	var multilineBuffer strings.Builder
@@ -180,21 +329,37 @@ func (r *REPL) Run() error {
 		}
 
 		if !inMultiline && line != "" {
-			r.line.AppendHistory(line)
+			r.addHistory(line)
 		}
 
-		result, err := r.runtime.Evaluate(currentInput)
-		if err != nil {
-			if jsErr, ok := err.(*goja.Exception); ok {
-				fmt.Fprintf(r.writer, "Error: %s\n", jsErr.String())
-			} else {
-				fmt.Fprintf(r.writer, "Error: %v\n", err)
-			}
-		} else if result != nil && !goja.IsUndefined(result) {
-			fmt.Fprintf(r.writer, "%v\n", result)
-		}
+		r.evaluate(currentInput)
 
 		multilineBuffer.Reset()
 		inMultiline = false
 	}
 }
+
+// evaluate runs input and prints its result. When the result is a promise
+// it is awaited, so "http.get(url)" or "await http.get(url)" prints the
+// response rather than a pending promise; a leading await is dropped, as
+// scripts can't use it at the top level. A promise that doesn't settle
+// within the await timeout is left running and the prompt comes back.
+func (r *REPL) evaluate(input string) {
+	if rest, ok := strings.CutPrefix(input, "await "); ok {
+		input = rest
+	}
+
+	result, err := r.runtime.EvaluateAwait(input, r.awaitTimeout)
+
+	var rejection *runtime.RejectionError
+	switch {
+	case errors.Is(err, runtime.ErrAwaitTimeout):
+		fmt.Fprintf(r.writer, "Promise still pending after %v; it keeps running in the background\n", r.awaitTimeout)
+	case errors.As(err, &rejection):
+		fmt.Fprintf(r.writer, "Error: %v\n", rejection)
+	case err != nil:
+		fmt.Fprintf(r.writer, "Error: %v\n", err)
+	case result != "":
+		fmt.Fprintln(r.writer, result)
+	}
+}
@@ -0,0 +1,126 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// ErrAwaitTimeout is returned by EvaluateAwait when the promise has not
+// settled in time. The promise itself keeps going.
+var ErrAwaitTimeout = errors.New("promise did not settle in time")
+
+// RejectionError is returned by EvaluateAwait for a rejected promise.
+type RejectionError struct {
+	message string // the rejection reason as text, taken on the loop goroutine
+}
+
+func (e *RejectionError) Error() string {
+	return "Uncaught (in promise) " + e.message
+}
+
+// EvaluateAwait evaluates code like Evaluate and, if the result is a
+// promise (or anything else with a then method), waits for it to settle and
+// returns what it resolved to. The event loop keeps running meanwhile, so
+// the timers, requests and other work the promise depends on can complete;
+// on a deterministic loop pending timers are fired first. The handlers are
+// attached in the same task as the evaluation, so a promise that rejects
+// is never reported as unhandled.
+//
+// The result comes back as text, formatted on the loop goroutine since the
+// VM can't be touched from the caller's; undefined comes back as "". A
+// thrown exception is returned as a plain error carrying its stack.
+// A rejection is returned as a *RejectionError. When timeout passes first,
+// EvaluateAwait returns ErrAwaitTimeout and the promise keeps going; the
+// REPL uses this to wait for promises typed at the prompt without hanging on
+// ones that never settle.
+func (rt *Runtime) EvaluateAwait(code string, timeout time.Duration) (string, error) {
+	type settlement struct {
+		text     string
+		rejected bool
+	}
+	settled := make(chan settlement, 1)
+	// only the first outcome counts; a misbehaving thenable may report more
+	settle := func(s settlement) {
+		select {
+		case settled <- s:
+		default:
+		}
+	}
+
+	var text string
+	var err error
+	thenable := false
+	rt.runOnLoop("evaluate", func() {
+		value, runErr := rt.vm.RunString(code)
+		if runErr != nil {
+			err = scriptError(runErr)
+			if exc, ok := err.(*goja.Exception); ok {
+				err = errors.New(exc.String())
+			}
+			return
+		}
+		obj, ok := value.(*goja.Object)
+		if !ok {
+			text = valueText(value)
+			return
+		}
+		then, ok := goja.AssertFunction(obj.Get("then"))
+		if !ok {
+			text = valueText(value)
+			return
+		}
+		thenable = true
+
+		onFulfilled := func(call goja.FunctionCall) goja.Value {
+			settle(settlement{text: valueText(call.Argument(0))})
+			return goja.Undefined()
+		}
+		onRejected := func(call goja.FunctionCall) goja.Value {
+			reason := call.Argument(0)
+			message := reason.String()
+			if reasonObj, ok := reason.(*goja.Object); ok {
+				if stack := reasonObj.Get("stack"); stack != nil && !goja.IsUndefined(stack) {
+					message = stack.String()
+				}
+			}
+			settle(settlement{text: message, rejected: true})
+			return goja.Undefined()
+		}
+		if _, thenErr := then(obj, rt.vm.ToValue(onFulfilled), rt.vm.ToValue(onRejected)); thenErr != nil {
+			settle(settlement{text: fmt.Sprint(scriptError(thenErr)), rejected: true})
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+	if !thenable {
+		return text, nil
+	}
+
+	if rt.loop.Deterministic() {
+		rt.loop.RunUntilIdle()
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case s := <-settled:
+		if s.rejected {
+			return "", &RejectionError{message: s.text}
+		}
+		return s.text, nil
+	case <-timer.C:
+		return "", ErrAwaitTimeout
+	}
+}
+
+// valueText formats a result for EvaluateAwait; it must run on the loop.
+func valueText(value goja.Value) string {
+	if value == nil || goja.IsUndefined(value) {
+		return ""
+	}
+	return value.String()
+}
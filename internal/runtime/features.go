@@ -0,0 +1,88 @@
+package runtime
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/dop251/goja"
+
+	"github.com/douglasjordan2/dougless/internal/modules"
+)
+
+// featureProbes maps feature names to JavaScript expressions that evaluate to
+// true when goja supports the feature natively. A probe that fails to compile
+// or throws means the feature is missing. Probes run untranspiled, so they
+// report what goja itself can do; esbuild may still lower some syntax (e.g.
+// async generators) for scripts.
+var featureProbes = map[string]string{
+	"BigInt":            "typeof BigInt === 'function' && typeof BigInt(1) === 'bigint'",
+	"Proxy":             "typeof Proxy === 'function' && new Proxy({}, { get: function() { return 1; } }).x === 1",
+	"Reflect":           "typeof Reflect === 'object' && typeof Reflect.ownKeys === 'function'",
+	"Symbol":            "typeof Symbol === 'function' && typeof Symbol() === 'symbol'",
+	"WeakRef":           "typeof WeakRef === 'function'",
+	"Intl":              "typeof Intl === 'object'",
+	"SharedArrayBuffer": "typeof SharedArrayBuffer === 'function'",
+	"asyncIterator":     "typeof Symbol === 'function' && typeof Symbol.asyncIterator === 'symbol'",
+	"generators":        "(function*() { yield 1; })().next().value === 1",
+	"asyncAwait":        "typeof (async function() { await 1; }) === 'function'",
+	"asyncGenerators":   "typeof (async function*() { yield 1; }) === 'function'",
+	"classFields":       "new (class { x = 1; })().x === 1",
+	"optionalChaining":  "({ a: { b: 1 } })?.a?.b === 1",
+	"nullishCoalescing": "(null ?? 1) === 1",
+}
+
+var (
+	featuresOnce sync.Once
+	features     map[string]bool
+)
+
+// probeFeatures runs every probe once per process on a scratch VM, so the
+// results can't be skewed by globals a runtime installs (e.g. polyfills).
+func probeFeatures() map[string]bool {
+	featuresOnce.Do(func() {
+		vm := goja.New()
+		features = make(map[string]bool, len(featureProbes))
+		for name, probe := range featureProbes {
+			value, err := vm.RunString(probe)
+			features[name] = err == nil && value.ToBoolean()
+		}
+	})
+	return features
+}
+
+// Supports reports whether goja natively supports a language feature, as
+// listed in Features. Unknown feature names report false.
+func (rt *Runtime) Supports(feature string) bool {
+	return probeFeatures()[feature]
+}
+
+// Features returns the names of all probed features, sorted.
+func Features() []string {
+	names := make([]string, 0, len(featureProbes))
+	for name := range featureProbes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// douglessObject builds the frozen Dougless global:
+//
+//	Dougless.version          // "0.8.0"
+//	Dougless.features.Proxy   // true when goja supports Proxy
+func (rt *Runtime) douglessObject() *goja.Object {
+	featureObj := rt.vm.NewObject()
+	for _, name := range Features() {
+		featureObj.Set(name, rt.Supports(name))
+	}
+
+	obj := rt.vm.NewObject()
+	obj.Set("version", modules.Version)
+	obj.Set("features", featureObj)
+
+	freeze, _ := goja.AssertFunction(rt.vm.Get("Object").ToObject(rt.vm).Get("freeze"))
+	freeze(goja.Undefined(), featureObj)
+	freeze(goja.Undefined(), obj)
+
+	return obj
+}
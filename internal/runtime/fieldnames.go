@@ -0,0 +1,181 @@
+package runtime
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/dop251/goja"
+)
+
+// CamelCaseFieldNames names struct fields the way scripts expect: by their
+// json tag when they have one, otherwise by their Go name with the leading
+// capitals lowered (MaxRetries -> maxRetries, URL -> url, HTTPPort ->
+// httpPort). Fields tagged json:"-" are left out.
+//
+//	rt.SetFieldNameMapper(runtime.CamelCaseFieldNames())
+func CamelCaseFieldNames() goja.FieldNameMapper {
+	return camelCaseMapper{}
+}
+
+type camelCaseMapper struct{}
+
+func (camelCaseMapper) FieldName(_ reflect.Type, f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return lowerCamel(f.Name)
+	}
+	return name
+}
+
+func (camelCaseMapper) MethodName(_ reflect.Type, m reflect.Method) string {
+	return lowerCamel(m.Name)
+}
+
+// lowerCamel lowers a Go name's leading capitals, keeping the last one of
+// an initialism that starts the next word.
+func lowerCamel(name string) string {
+	runes := []rune(name)
+	upper := 0
+	for upper < len(runes) && unicode.IsUpper(runes[upper]) {
+		upper++
+	}
+	if upper > 1 && upper < len(runes) {
+		upper-- // "HTTPPort": the P of Port stays
+	}
+	for i := 0; i < upper; i++ {
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
+
+// SetFieldNameMapper changes how SetGlobal names struct fields, e.g. to
+// CamelCaseFieldNames, or to goja.TagFieldNameMapper("json", true) to
+// expose only json-tagged fields. nil restores the default, which names
+// fields as encoding/json does. Call it before SetGlobal.
+func (rt *Runtime) SetFieldNameMapper(mapper goja.FieldNameMapper) {
+	rt.fieldNames = mapper
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// mapFields copies v into maps and slices for json.Marshal, naming struct
+// fields with mapper. Types with their own JSON or text form are kept as
+// they are, as are values json.Marshal should reject.
+func mapFields(v reflect.Value, mapper goja.FieldNameMapper) any {
+	if !v.IsValid() {
+		return nil
+	}
+	if v.CanInterface() && (v.Type().Implements(jsonMarshalerType) || v.Type().Implements(textMarshalerType)) {
+		return v.Interface()
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return mapFields(v.Elem(), mapper)
+	case reflect.Struct:
+		out := map[string]any{}
+		addFields(v, mapper, out)
+		return out
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		if k := v.Type().Key().Kind(); k != reflect.String && (k < reflect.Int || k > reflect.Uint64) {
+			break
+		}
+		out := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key, _ := json.Marshal(iter.Key().Interface())
+			out[strings.Trim(string(key), `"`)] = mapFields(iter.Value(), mapper)
+		}
+		return out
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			break // base64, as json does
+		}
+		fallthrough
+	case reflect.Array:
+		out := make([]any, v.Len())
+		for i := range out {
+			out[i] = mapFields(v.Index(i), mapper)
+		}
+		return out
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint()
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.String:
+		return v.String()
+	}
+
+	if v.CanInterface() {
+		return v.Interface()
+	}
+	return nil
+}
+
+// addFields adds a struct's exported fields to out, flattening untagged
+// embedded structs as encoding/json does and honouring omitempty.
+func addFields(v reflect.Value, mapper goja.FieldNameMapper, out map[string]any) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		fv := v.Field(i)
+
+		if f.Anonymous && f.Tag.Get("json") == "" {
+			for fv.Kind() == reflect.Pointer {
+				if fv.IsNil() {
+					break
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				addFields(fv, mapper, out)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		name := mapper.FieldName(t, f)
+		if name == "" {
+			continue
+		}
+		if _, opts, _ := strings.Cut(f.Tag.Get("json"), ","); strings.Contains(opts, "omitempty") && isEmptyValue(fv) {
+			continue
+		}
+		out[name] = mapFields(fv, mapper)
+	}
+}
+
+// isEmptyValue reports whether omitempty leaves v out.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Struct:
+		return false
+	}
+	return v.IsZero()
+}
@@ -0,0 +1,134 @@
+package runtime
+
+import (
+	"fmt"
+	goruntime "runtime"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// DefaultTarget is the language level scripts are transpiled to unless
+// --target says otherwise.
+const DefaultTarget = "es2017"
+
+// targets maps --target values to esbuild targets. Lower targets downlevel
+// more syntax; esnext leaves modern syntax untouched for goja to run natively.
+// Note that esbuild cannot lower every feature to es5 (const, let and class
+// are rejected), so es5 is only useful for sources that avoid them.
+var targets = map[string]api.Target{
+	"es5":    api.ES5,
+	"es2015": api.ES2015,
+	"es2017": api.ES2017,
+	"esnext": api.ESNext,
+}
+
+// Options holds runtime (non-permission) command-line settings.
+type Options struct {
+	Target    string // Transpile target: es5, es2015, es2017 or esnext
+	UserAgent string // Default User-Agent for outbound HTTP ("" keeps Dougless/<version>)
+	EnvFile   string // File of variables to load into the environment (see LoadEnvFile)
+
+	PreserveSymlinks bool // Identify required files by their symlink path instead of the real path
+	ExposeGC         bool // Define a global gc() that forces a garbage collection
+	Profile          bool // Count calls and time per function, printing a summary on exit
+}
+
+// ParseFlags extracts runtime flags from args and returns the rest untouched
+// for permissions.ParseFlags. Like the permission parser, it stops at the
+// first positional argument (the script) so script arguments are never
+// interpreted as runtime flags.
+//
+// Supported flags:
+//
+//	--target=es5|es2015|es2017|esnext: Transpile target (default es2017)
+//	--user-agent=value: Default User-Agent for outbound HTTP requests
+//	--preserve-symlinks: Don't resolve symlinks when requiring files
+//	--env-file=path: Load environment variables from a file before running
+//	--expose-gc: Define a global gc() function
+//	--profile: Print call counts and time per function when the script exits
+func ParseFlags(args []string) (Options, []string, error) {
+	opts := Options{Target: DefaultTarget}
+	remaining := []string{}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		if arg == "--target" || strings.HasPrefix(arg, "--target=") {
+			value := strings.TrimPrefix(strings.TrimPrefix(arg, "--target"), "=")
+			if value == "" {
+				return opts, nil, fmt.Errorf("--target requires a value (es5, es2015, es2017 or esnext)")
+			}
+			if _, ok := targets[value]; !ok {
+				return opts, nil, fmt.Errorf("unknown --target %q (use es5, es2015, es2017 or esnext)", value)
+			}
+			opts.Target = value
+		} else if strings.HasPrefix(arg, "--user-agent=") {
+			value := strings.TrimPrefix(arg, "--user-agent=")
+			if value == "" {
+				return opts, nil, fmt.Errorf("--user-agent requires a value")
+			}
+			opts.UserAgent = value
+		} else if strings.HasPrefix(arg, "--env-file=") {
+			value := strings.TrimPrefix(arg, "--env-file=")
+			if value == "" {
+				return opts, nil, fmt.Errorf("--env-file requires a path")
+			}
+			opts.EnvFile = value
+		} else if arg == "--preserve-symlinks" {
+			opts.PreserveSymlinks = true
+		} else if arg == "--expose-gc" {
+			opts.ExposeGC = true
+		} else if arg == "--profile" {
+			opts.Profile = true
+		} else if strings.HasPrefix(arg, "-") {
+			remaining = append(remaining, arg)
+		} else {
+			remaining = append(remaining, args[i:]...)
+			break
+		}
+	}
+
+	return opts, remaining, nil
+}
+
+// SetTarget sets the language level scripts are transpiled to.
+func (rt *Runtime) SetTarget(target string) error {
+	t, ok := targets[target]
+	if !ok {
+		return fmt.Errorf("unknown target %q (use es5, es2015, es2017 or esnext)", target)
+	}
+	rt.target = t
+	return nil
+}
+
+// SetPreserveSymlinks controls how require() identifies files reached through
+// symlinks. By default a module's identity is its real path, so one file
+// linked from several places loads once. When preserved, the symlink path is
+// the identity: each link loads its own copy, and __filename/__dirname (and
+// therefore relative requires) follow the link, as monorepo setups expect.
+func (rt *Runtime) SetPreserveSymlinks(preserve bool) {
+	rt.preserveSymlinks = preserve
+}
+
+// SetExposeGC defines (or removes) a global gc() that runs Go's garbage
+// collector, so scripts diagnosing memory growth can read
+// process.memoryUsage() after a collection.
+func (rt *Runtime) SetExposeGC(expose bool) {
+	rt.runOnLoop("setExposeGC", func() {
+		if !expose {
+			rt.vm.GlobalObject().Delete("gc")
+			return
+		}
+		rt.vm.Set("gc", func(call goja.FunctionCall) goja.Value {
+			goruntime.GC()
+			return goja.Undefined()
+		})
+	})
+}
+
+// SetUserAgent changes the default User-Agent sent with outbound HTTP requests.
+func (rt *Runtime) SetUserAgent(ua string) {
+	rt.http.SetUserAgent(ua)
+}
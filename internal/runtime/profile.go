@@ -0,0 +1,368 @@
+package runtime
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/dop251/goja/ast"
+	"github.com/dop251/goja/file"
+	"github.com/dop251/goja/parser"
+)
+
+// profileGlobal is the hidden global instrumented code reports calls to.
+const profileGlobal = "__douglessProfile"
+
+// callProfile counts calls to, and time spent in, every function of the
+// scripts it has instrumented. It is only touched on the loop goroutine.
+type callProfile struct {
+	w     io.Writer
+	funcs []*profiledFunc // by id
+}
+
+// profiledFunc is the profile of one function in the source.
+type profiledFunc struct {
+	label string // name (file:line)
+	calls int
+	total time.Duration // from the outermost call to its return
+	depth int           // calls in progress, so recursion is timed once
+	start time.Time     // when the outermost call in progress began
+}
+
+// SetProfile turns on call profiling: scripts run or required from now on
+// are instrumented to count calls to each function and the time spent in
+// them, and a table of the results, sorted by time, is written to w when the
+// script finishes or calls process.exit. A function's time runs from its
+// call to its return, including the functions it calls and, for async
+// functions, the time spent awaiting. Code typed at the REPL isn't profiled,
+// and a profiled function's toString() shows the instrumentation.
+func (rt *Runtime) SetProfile(w io.Writer) {
+	rt.runOnLoop("setProfile", func() {
+		rt.profile = &callProfile{w: w}
+
+		obj := rt.vm.NewObject()
+		obj.Set("enter", func(call goja.FunctionCall) goja.Value {
+			rt.profile.enter(int(call.Argument(0).ToInteger()))
+			return goja.Undefined()
+		})
+		obj.Set("exit", func(call goja.FunctionCall) goja.Value {
+			rt.profile.exit(int(call.Argument(0).ToInteger()))
+			return goja.Undefined()
+		})
+		rt.vm.GlobalObject().DefineDataProperty(profileGlobal, obj, goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_FALSE)
+
+		rt.process.OnExit(func(int) { rt.profile.report() })
+	})
+}
+
+// writeProfile writes the profile report, if profiling is on.
+func (rt *Runtime) writeProfile() {
+	rt.runOnLoop("writeProfile", func() {
+		if rt.profile != nil {
+			rt.profile.report()
+		}
+	})
+}
+
+func (p *callProfile) enter(id int) {
+	if id < 0 || id >= len(p.funcs) {
+		return
+	}
+	f := p.funcs[id]
+	f.calls++
+	if f.depth == 0 {
+		f.start = time.Now()
+	}
+	f.depth++
+}
+
+func (p *callProfile) exit(id int) {
+	if id < 0 || id >= len(p.funcs) {
+		return
+	}
+	f := p.funcs[id]
+	if f.depth == 0 {
+		return
+	}
+	f.depth--
+	if f.depth == 0 {
+		f.total += time.Since(f.start)
+	}
+}
+
+// report writes the functions that were called, most time first.
+func (p *callProfile) report() {
+	var called []*profiledFunc
+	for _, f := range p.funcs {
+		if f.calls > 0 {
+			called = append(called, f)
+		}
+	}
+	sort.SliceStable(called, func(i, j int) bool {
+		if called[i].total != called[j].total {
+			return called[i].total > called[j].total
+		}
+		return called[i].calls > called[j].calls
+	})
+
+	fmt.Fprintf(p.w, "\nProfile (by total time):\n")
+	fmt.Fprintf(p.w, "%12s %10s %12s  %s\n", "total (ms)", "calls", "avg (ms)", "function")
+	for _, f := range called {
+		total := float64(f.total) / float64(time.Millisecond)
+		fmt.Fprintf(p.w, "%12.3f %10d %12.4f  %s\n", total, f.calls, total/float64(f.calls), f.label)
+	}
+}
+
+// profileEdit is text inserted into a script being instrumented.
+type profileEdit struct {
+	offset int
+	open   bool // opens a function body (closes one otherwise)
+	depth  int  // how deeply the function is nested
+	text   string
+}
+
+// instrument rewrites code, a transpiled script, so every function reports
+// its calls to the profile: a block body becomes
+//
+//	{ enter(id); try { body } finally { exit(id) } }
+//
+// and an expression body is turned into a block returning the expression.
+// Lines are kept, so stack traces still point to the right place.
+func (p *callProfile) instrument(code, filename string) (string, error) {
+	program, err := parser.ParseFile(nil, filename, code, 0)
+	if err != nil {
+		return "", err
+	}
+
+	in := &instrumenter{profile: p, code: code, file: program.File}
+	for _, stmt := range program.Body {
+		in.walk(reflect.ValueOf(stmt), "", 0)
+	}
+
+	sort.SliceStable(in.edits, func(i, j int) bool {
+		a, b := in.edits[i], in.edits[j]
+		if a.offset != b.offset {
+			return a.offset < b.offset
+		}
+		if a.open != b.open {
+			return a.open // a function's opening goes before any closing at the same place
+		}
+		if a.open {
+			return a.depth < b.depth // outer functions open first
+		}
+		return a.depth > b.depth // inner functions close first
+	})
+
+	var out strings.Builder
+	last := 0
+	for _, e := range in.edits {
+		out.WriteString(code[last:e.offset])
+		out.WriteString(e.text)
+		last = e.offset
+	}
+	out.WriteString(code[last:])
+	return out.String(), nil
+}
+
+// instrumenter walks a parsed script, recording the edits that instrument
+// each function.
+type instrumenter struct {
+	profile *callProfile
+	code    string
+	file    *file.File
+	seen    map[ast.Node]bool
+	edits   []profileEdit
+}
+
+var astPackage = reflect.TypeOf(ast.Program{}).PkgPath()
+
+// walk visits the AST below v. name is what a function found there is
+// called when it has no name of its own, such as the variable or property
+// it's assigned to.
+func (in *instrumenter) walk(v reflect.Value, name string, depth int) {
+	switch v.Kind() {
+	case reflect.Interface:
+		if !v.IsNil() {
+			in.walk(v.Elem(), name, depth)
+		}
+		return
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			in.walk(v.Index(i), "", depth)
+		}
+		return
+	case reflect.Pointer:
+		if v.IsNil() || v.Elem().Kind() != reflect.Struct || v.Elem().Type().PkgPath() != astPackage || !v.CanInterface() {
+			return
+		}
+	default:
+		return
+	}
+
+	if node, ok := v.Interface().(ast.Node); ok {
+		if in.seen[node] {
+			return
+		}
+		if in.seen == nil {
+			in.seen = make(map[ast.Node]bool)
+		}
+		in.seen[node] = true
+	}
+
+	switch n := v.Interface().(type) {
+	case *ast.FunctionLiteral:
+		if n.Name != nil {
+			name = n.Name.Name.String()
+		}
+		in.function(n, name, depth)
+		depth++
+	case *ast.ArrowFunctionLiteral:
+		in.arrow(n, name, depth)
+		depth++
+	case *ast.Binding:
+		if id, ok := n.Target.(*ast.Identifier); ok {
+			in.walk(reflect.ValueOf(n.Initializer), id.Name.String(), depth)
+			return
+		}
+	case *ast.AssignExpression:
+		in.walk(reflect.ValueOf(n.Left), "", depth)
+		in.walk(reflect.ValueOf(n.Right), targetName(n.Left), depth)
+		return
+	case *ast.PropertyKeyed:
+		if !n.Computed {
+			in.walk(reflect.ValueOf(n.Value), keyName(n.Key), depth)
+			return
+		}
+	case *ast.MethodDefinition:
+		if !n.Computed {
+			in.walk(reflect.ValueOf(n.Body), keyName(n.Key), depth)
+			return
+		}
+	case *ast.FieldDefinition:
+		if !n.Computed {
+			in.walk(reflect.ValueOf(n.Initializer), keyName(n.Key), depth)
+			return
+		}
+	}
+
+	elem := v.Elem()
+	for i := 0; i < elem.NumField(); i++ {
+		// hoisted declarations repeat nodes already in the body
+		if elem.Type().Field(i).Name == "DeclarationList" {
+			continue
+		}
+		in.walk(elem.Field(i), "", depth)
+	}
+}
+
+// function instruments a function with a block body.
+func (in *instrumenter) function(fn *ast.FunctionLiteral, name string, depth int) {
+	if fn.Body == nil {
+		return
+	}
+	id := in.register(name, fn.Idx0())
+
+	// after any directives, so "use strict" keeps working
+	start := in.offset(fn.Body.LeftBrace) + 1
+	for _, stmt := range fn.Body.List {
+		expr, ok := stmt.(*ast.ExpressionStatement)
+		if !ok {
+			break
+		}
+		if _, ok := expr.Expression.(*ast.StringLiteral); !ok {
+			break
+		}
+		start = in.offset(expr.Idx1())
+	}
+
+	in.edits = append(in.edits,
+		profileEdit{offset: start, open: true, depth: depth, text: fmt.Sprintf(";%s.enter(%d);try{", profileGlobal, id)},
+		profileEdit{offset: in.offset(fn.Body.RightBrace), depth: depth, text: fmt.Sprintf("}finally{%s.exit(%d)}", profileGlobal, id)},
+	)
+}
+
+// arrow instruments an arrow function.
+func (in *instrumenter) arrow(fn *ast.ArrowFunctionLiteral, name string, depth int) {
+	if block, ok := fn.Body.(*ast.BlockStatement); ok {
+		in.function(&ast.FunctionLiteral{Function: fn.Start, Body: block}, name, depth)
+		return
+	}
+
+	// the parser leaves parentheses around the expression out of its
+	// position, so find where the body really ends: after as many of the
+	// closing parentheses that follow as leave it balanced
+	bodyStart, bodyEnd := in.offset(fn.Body.Idx0()), in.offset(fn.Body.Idx1())
+	arrow := strings.LastIndex(in.code[:bodyStart], "=>")
+	if arrow < 0 {
+		return
+	}
+	start := arrow + len("=>")
+	open := strings.Count(in.code[start:bodyStart], "(")
+
+	end := -1
+	for closing, pos := 0, bodyEnd; closing <= open; closing++ {
+		if _, err := parser.ParseFile(nil, "", "(async function(){return("+in.code[start:pos]+"\n)})", 0, parser.WithDisableSourceMaps); err == nil {
+			end = pos
+			break
+		}
+		next := pos + len(in.code[pos:]) - len(strings.TrimLeft(in.code[pos:], " \t\r\n"))
+		if next >= len(in.code) || in.code[next] != ')' {
+			break
+		}
+		pos = next + 1
+	}
+	if end < 0 {
+		return // left uncounted rather than risk breaking the script
+	}
+
+	id := in.register(name, fn.Idx0())
+	in.edits = append(in.edits,
+		profileEdit{offset: start, open: true, depth: depth, text: fmt.Sprintf("{%s.enter(%d);try{return ", profileGlobal, id)},
+		profileEdit{offset: end, depth: depth, text: fmt.Sprintf("}finally{%s.exit(%d)}}", profileGlobal, id)},
+	)
+}
+
+// register adds a function found at idx to the profile, returning its id.
+func (in *instrumenter) register(name string, idx file.Idx) int {
+	if name == "" {
+		name = "(anonymous)"
+	}
+	pos := in.file.Position(in.offset(idx))
+	in.profile.funcs = append(in.profile.funcs, &profiledFunc{
+		label: fmt.Sprintf("%s (%s:%d)", name, pos.Filename, pos.Line),
+	})
+	return len(in.profile.funcs) - 1
+}
+
+// offset converts a parser position to an offset into the code.
+func (in *instrumenter) offset(idx file.Idx) int {
+	return int(idx) - in.file.Base()
+}
+
+// keyName is the name of a property key, or "" for keys that aren't names.
+func keyName(key ast.Expression) string {
+	switch k := key.(type) {
+	case *ast.Identifier:
+		return k.Name.String()
+	case *ast.StringLiteral:
+		return k.Value.String()
+	case *ast.PrivateIdentifier:
+		return "#" + k.Name.String()
+	}
+	return ""
+}
+
+// targetName names what a function is assigned to: x = ... or obj.x = ...
+func targetName(target ast.Expression) string {
+	switch t := target.(type) {
+	case *ast.Identifier:
+		return t.Name.String()
+	case *ast.DotExpression:
+		return t.Identifier.Name.String()
+	}
+	return ""
+}
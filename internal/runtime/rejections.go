@@ -0,0 +1,75 @@
+package runtime
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/douglasjordan2/dougless/internal/event"
+	"github.com/douglasjordan2/dougless/internal/modules"
+)
+
+// TrackRejection records a promise rejected without a handler. The check
+// runs as an immediate, after the current task and its microtasks, so a
+// catch attached in the meantime still counts as handling it.
+func (rt *Runtime) TrackRejection(p *modules.Promise) {
+	rt.rejectionsMu.Lock()
+	first := len(rt.rejections) == 0
+	rt.rejections = append(rt.rejections, p)
+	rt.rejectionsMu.Unlock()
+
+	if !first {
+		return // a check is already scheduled
+	}
+
+	done := rt.KeepAlive()
+	rt.loop.ScheduleImmediate(event.Task{Name: "unhandledRejection", Callback: func() {
+		defer done()
+		rt.checkRejections()
+	}})
+}
+
+// checkRejections reports the tracked rejections that are still unhandled,
+// to the process.on('unhandledRejection') listeners when there are any and
+// as a warning on stderr otherwise.
+func (rt *Runtime) checkRejections() {
+	rt.rejectionsMu.Lock()
+	pending := rt.rejections
+	rt.rejections = nil
+	rt.rejectionsMu.Unlock()
+
+	for _, p := range pending {
+		if p.Handled() {
+			continue
+		}
+		if rt.process != nil {
+			emitted, err := rt.process.EmitUnhandledRejection(p.Reason(), p)
+			if err != nil {
+				fmt.Fprintf(rt.stderr, "Error in unhandledRejection handler: %v\n", scriptError(err))
+			}
+			if emitted {
+				continue
+			}
+		}
+		fmt.Fprint(rt.stderr, formatRejection(p))
+	}
+}
+
+// formatRejection describes an unhandled rejection along with where it
+// happened, when that is known.
+func formatRejection(p *modules.Promise) string {
+	reason := "undefined"
+	if r := p.Reason(); r != nil {
+		reason = r.String()
+	}
+	stack := strings.TrimRight(p.Stack(), "\n")
+
+	switch {
+	case stack == "":
+		return fmt.Sprintf("Unhandled promise rejection: %s\n", reason)
+	case strings.HasPrefix(stack, reason):
+		// an Error's stack already starts with its message
+		return fmt.Sprintf("Unhandled promise rejection: %s\n", stack)
+	default:
+		return fmt.Sprintf("Unhandled promise rejection: %s\n%s\n", reason, stack)
+	}
+}
@@ -0,0 +1,201 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"github.com/douglasjordan2/dougless/internal/permissions"
+)
+
+// moduleExtensions are tried, in order, when a file specifier has no match
+// as written.
+var moduleExtensions = []string{".js", ".json"}
+
+// newRequire builds a require function resolving file specifiers from the
+// directory dirOf returns. Built-in modules (require('path')) come from the
+// registry; relative and absolute specifiers load CommonJS files.
+//
+// Like Node's, the function carries require.resolve(specifier), which
+// resolves without loading, and require.cache, the loaded modules keyed by
+// resolved path. Deleting a cache entry makes the next require reload it.
+func (rt *Runtime) newRequire(dirOf func() string) goja.Value {
+	require := rt.vm.ToValue(func(call goja.FunctionCall) goja.Value {
+		moduleName := requireSpecifier(rt.vm, call, "require()")
+		if module := rt.modules.Get(moduleName); module != nil {
+			return module.Export(rt.vm)
+		}
+		return rt.loadModule(rt.resolveSpecifier(moduleName, dirOf()))
+	}).ToObject(rt.vm)
+
+	require.Set("resolve", func(call goja.FunctionCall) goja.Value {
+		moduleName := requireSpecifier(rt.vm, call, "require.resolve()")
+		if rt.modules.Get(moduleName) != nil {
+			return rt.vm.ToValue(moduleName) // built-ins resolve to their own name
+		}
+		return rt.vm.ToValue(rt.resolveSpecifier(moduleName, dirOf()))
+	})
+	require.Set("cache", rt.moduleCache)
+
+	return require
+}
+
+// mainRequireDir is the directory the main script's require resolves from.
+func (rt *Runtime) mainRequireDir() string {
+	if rt.mainDir != "" {
+		return rt.mainDir
+	}
+	dir, _ := os.Getwd()
+	return dir
+}
+
+// requireSpecifier returns the module name passed to fn, throwing a
+// TypeError when it is missing.
+func requireSpecifier(vm *goja.Runtime, call goja.FunctionCall, fn string) string {
+	if len(call.Arguments) == 0 {
+		panic(vm.NewTypeError(fn + " missing module name"))
+	}
+	return call.Arguments[0].String()
+}
+
+// resolveSpecifier resolves a non built-in specifier from dir, throwing when
+// it can't be found.
+func (rt *Runtime) resolveSpecifier(spec, dir string) string {
+	if !isFileSpecifier(spec) {
+		panic(rt.vm.NewGoError(fmt.Errorf("Cannot find module '%s'", spec)))
+	}
+
+	resolved, err := rt.resolveModule(spec, dir)
+	if err != nil {
+		panic(rt.vm.NewGoError(err))
+	}
+	return resolved
+}
+
+// isFileSpecifier reports whether a specifier names a file rather than a
+// built-in module.
+func isFileSpecifier(spec string) bool {
+	return strings.HasPrefix(spec, "./") || strings.HasPrefix(spec, "../") ||
+		spec == "." || spec == ".." || filepath.IsAbs(spec)
+}
+
+// checkModuleRead asks for read permission on a file require() is about to
+// look at, returning the permission error if it's denied.
+func checkModuleRead(path string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	mgr := permissions.GetManager()
+	canRead := permissions.PermissionRead
+	if !mgr.CheckWithPrompt(ctx, canRead, path) {
+		return errors.New(mgr.ErrorMessage(canRead, path))
+	}
+	return nil
+}
+
+// resolveModule finds the file a specifier refers to: the path as written,
+// then with each of moduleExtensions, then index.js inside a directory.
+// Each candidate needs read permission before it is looked at. Symlinks are
+// resolved so a module reached through different links loads once, unless
+// --preserve-symlinks keeps the path as written.
+func (rt *Runtime) resolveModule(spec, dir string) (string, error) {
+	base := spec
+	if !filepath.IsAbs(base) {
+		base = filepath.Join(dir, spec)
+	}
+
+	candidates := []string{base}
+	for _, ext := range moduleExtensions {
+		candidates = append(candidates, base+ext)
+	}
+	candidates = append(candidates, filepath.Join(base, "index.js"))
+
+	for _, candidate := range candidates {
+		if err := checkModuleRead(candidate); err != nil {
+			return "", err
+		}
+		info, err := os.Stat(candidate)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		if rt.preserveSymlinks {
+			return candidate, nil
+		}
+		return filepath.EvalSymlinks(candidate)
+	}
+
+	return "", fmt.Errorf("Cannot find module '%s'", spec)
+}
+
+// loadModule evaluates the file at path once and returns its module.exports.
+// A module is cached before it runs, so require cycles see the partially
+// filled exports, as in Node.
+func (rt *Runtime) loadModule(path string) goja.Value {
+	if cached := rt.moduleCache.Get(path); cached != nil && !goja.IsUndefined(cached) {
+		return cached.ToObject(rt.vm).Get("exports")
+	}
+
+	if err := checkModuleRead(path); err != nil {
+		panic(rt.vm.NewGoError(err))
+	}
+	source, err := os.ReadFile(path)
+	if err != nil {
+		panic(rt.vm.NewGoError(fmt.Errorf("Cannot load module '%s': %w", path, err)))
+	}
+
+	module := rt.vm.NewObject()
+	exports := rt.vm.NewObject()
+	module.Set("exports", exports)
+	module.Set("id", path)
+	module.Set("filename", path)
+	rt.moduleCache.Set(path, module)
+
+	if filepath.Ext(path) == ".json" {
+		parse, _ := goja.AssertFunction(rt.vm.Get("JSON").ToObject(rt.vm).Get("parse"))
+		value, err := parse(goja.Undefined(), rt.vm.ToValue(string(source)))
+		if err != nil {
+			rt.moduleCache.Delete(path)
+			panic(err)
+		}
+		module.Set("exports", value)
+		return value
+	}
+
+	code, err := rt.transpile(string(source), path)
+	if err != nil {
+		rt.moduleCache.Delete(path)
+		panic(rt.vm.NewGoError(fmt.Errorf("transpilation error: %w", err)))
+	}
+
+	if rt.profile != nil {
+		if code, err = rt.profile.instrument(code, path); err != nil {
+			rt.moduleCache.Delete(path)
+			panic(rt.vm.NewGoError(fmt.Errorf("profiling %s: %w", path, err)))
+		}
+	}
+
+	// the wrapper opens on the module's first line so stack traces keep
+	// their line numbers
+	wrapped := "(function (exports, require, module, __filename, __dirname) {" + code + "\n})"
+	fnValue, err := rt.vm.RunScript(path, wrapped)
+	if err != nil {
+		rt.moduleCache.Delete(path)
+		panic(err)
+	}
+	fn, _ := goja.AssertFunction(fnValue)
+
+	dir := filepath.Dir(path)
+	moduleRequire := rt.newRequire(func() string { return dir })
+
+	if _, err := fn(exports, exports, moduleRequire, module, rt.vm.ToValue(path), rt.vm.ToValue(dir)); err != nil {
+		rt.moduleCache.Delete(path)
+		panic(err)
+	}
+	return module.Get("exports")
+}
@@ -1,13 +1,19 @@
 package runtime
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"reflect"
 	"sync"
+	"time"
 
 	"github.com/dop251/goja"
 	"github.com/evanw/esbuild/pkg/api"
 
+	"github.com/douglasjordan2/dougless/internal/event"
 	"github.com/douglasjordan2/dougless/internal/modules"
 	"github.com/douglasjordan2/dougless/internal/permissions"
 )
@@ -16,11 +22,46 @@ type Runtime struct {
 	vm        *goja.Runtime
 	modules   *modules.Registry
 	config    *permissions.Config
+	loop      *event.Loop    // runs VM work scheduled from other goroutines
+	target    api.Target     // transpile target (see SetTarget)
+	http      *modules.HTTP
+	console   *modules.Console
+	timers    *modules.Timers
+	files     *modules.Files
+	events    *modules.Events
+	schedule  *modules.Schedule
+	process   *modules.Process
+	stderr    io.Writer      // runtime diagnostics (see SetStderr)
   wg        sync.WaitGroup // track pending i/o
+
+	rejectionsMu sync.Mutex
+	rejections   []*modules.Promise // rejected without a handler, awaiting the check
+
+	fieldNames goja.FieldNameMapper // struct field names for SetGlobal (see SetFieldNameMapper)
+
+	// file modules loaded by require()
+	mainDir          string       // directory the main script resolves from
+	moduleCache      *goja.Object // module objects by resolved path (require.cache)
+	preserveSymlinks bool         // see SetPreserveSymlinks
+
+	profile *callProfile // call counts and times, when profiling (see SetProfile)
 }
 
 func New(argv []string) *Runtime {
-	vm := goja.New()
+	return newRuntime(argv, event.NewLoop())
+}
+
+// NewDeterministic creates a runtime for tests whose timers run on a
+// virtual clock (see event.NewDeterministicLoop). Execute moves the clock
+// forward to each pending timer instead of waiting for it, so a script that
+// sets a one-hour timeout finishes straight away with the timeout having
+// fired. Intervals must be cleared for Execute to return.
+func NewDeterministic(argv []string) *Runtime {
+	return newRuntime(argv, event.NewDeterministicLoop())
+}
+
+func newRuntime(argv []string, loop *event.Loop) *Runtime {
+	vm := newVM()
 	moduleRegistry := modules.NewRegistry()
 
 	var config *permissions.Config
@@ -38,7 +79,13 @@ func New(argv []string) *Runtime {
 		vm:        vm,
 		modules:   moduleRegistry,
 		config:    config,
+		loop:      loop,
+		target:    targets[DefaultTarget],
+		stderr:    os.Stderr,
+
+		moduleCache: vm.NewObject(),
 	}
+	rt.loop.Start()
 
 	permManager := permissions.GetManager()
 	if config != nil {
@@ -69,16 +116,64 @@ func (rt *Runtime) Execute(source, filename string) error {
 		return fmt.Errorf("transpilation error: %w", err)
 	}
 
-	_, err = rt.vm.RunScript(filename, transpiledCode)
+	if abs, err := filepath.Abs(filename); err == nil {
+		rt.mainDir = filepath.Dir(abs)
+	}
+
+	rt.runOnLoop("main", func() {
+		if rt.profile != nil {
+			if transpiledCode, err = rt.profile.instrument(transpiledCode, filename); err != nil {
+				return
+			}
+		}
+		_, err = rt.vm.RunScript(filename, transpiledCode)
+	})
 	if err != nil {
-		return fmt.Errorf("execution error: %w", err)
+		rt.writeProfile()
+		return fmt.Errorf("execution error: %w", scriptError(err))
 	}
 
+  if rt.loop.Deterministic() {
+    rt.loop.RunUntilIdle() // fire pending timers on the virtual clock
+  }
   rt.wg.Wait() // wait for pending futures
+	rt.writeProfile()
 
 	return nil
 }
 
+// closeDrainTimeout bounds how long Close spends running queued tasks.
+const closeDrainTimeout = 2 * time.Second
+
+// Close runs the tasks still queued on the event loop, so output they would
+// produce isn't lost, then stops the loop and waits for it to exit. Queued
+// work that takes longer than closeDrainTimeout is dropped, and timers that
+// haven't fired are not waited for.
+func (rt *Runtime) Close() {
+	rt.loop.Drain(closeDrainTimeout)
+	rt.loop.Stop()
+	rt.loop.Wait()
+}
+
+// runOnLoop runs fn as a task on the event loop, so the microtasks and
+// immediates it queues run after it and in order. Once the loop has been
+// stopped fn runs on the calling goroutine instead.
+func (rt *Runtime) runOnLoop(name string, fn func()) {
+	if !rt.loop.Run(name, fn) {
+		fn()
+	}
+}
+
+// QueueMicrotask queues fn on the event loop's microtask queue, keeping the
+// runtime alive until it has run. Promise reactions are queued this way.
+func (rt *Runtime) QueueMicrotask(fn func()) {
+	done := rt.KeepAlive()
+	rt.loop.QueueMicrotask(func() {
+		defer done()
+		fn()
+	})
+}
+
 func (rt *Runtime) KeepAlive() func() {
   rt.wg.Add(1)
   return func() {
@@ -86,7 +181,71 @@ func (rt *Runtime) KeepAlive() func() {
   }
 }
 
+// SetStdout redirects console output, e.g. to a buffer when embedding.
+// Note that console.error and console.warn also write here (with ERROR:/WARN:
+// prefixes), as they always have.
+func (rt *Runtime) SetStdout(w io.Writer) {
+	rt.console.SetOutput(w)
+}
+
+// SetStderr redirects runtime diagnostics: transpile warnings, timer callback
+// errors, cron callback errors, file stream and files.tail errors, HTTP server
+// errors, slow-task warnings and EventEmitter leak warnings.
+func (rt *Runtime) SetStderr(w io.Writer) {
+	rt.stderr = w
+	rt.timers.SetErrorOutput(w)
+	rt.files.SetErrorOutput(w)
+	rt.http.SetErrorOutput(w)
+	rt.schedule.SetErrorOutput(w)
+	rt.events.SetWarningOutput(w)
+	rt.loop.SetWarningOutput(w)
+}
+
+// SetGlobal makes value available to scripts as the global name, so
+// embedders can pass in configuration. Call it before Execute.
+//
+// The value is converted the way encoding/json encodes it:
+//   - structs become plain objects with their exported fields, named by
+//     their json tags; nested structs and pointers to them nest
+//   - maps with string (or integer) keys become objects
+//   - slices and arrays become arrays ([]byte becomes a base64 string)
+//   - numbers, strings, booleans and nil become their JS counterparts
+//     (integers beyond 2^53 lose precision)
+//   - types implementing json.Marshaler (e.g. time.Time) use their JSON form
+//
+// SetFieldNameMapper changes how struct fields are named, e.g. to camelCase.
+//
+// Functions and channels are not supported and make SetGlobal return an
+// error. Scripts get a copy: later changes to value are not seen.
+func (rt *Runtime) SetGlobal(name string, value any) error {
+	if rt.fieldNames != nil {
+		value = mapFields(reflect.ValueOf(value), rt.fieldNames)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("SetGlobal %s: %w", name, err)
+	}
+
+	rt.runOnLoop("setGlobal", func() {
+		parse, _ := goja.AssertFunction(rt.vm.Get("JSON").ToObject(rt.vm).Get("parse"))
+		var parsed goja.Value
+		parsed, err = parse(goja.Undefined(), rt.vm.ToValue(string(data)))
+		if err == nil {
+			rt.vm.Set(name, parsed)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("SetGlobal %s: %w", name, err)
+	}
+	return nil
+}
+
 func (rt *Runtime) transpile(source, filename string) (string, error) {
+	return transpile(source, filename, rt.target, rt.stderr)
+}
+
+// transpile lowers source to target with esbuild, printing any warnings to warnOut.
+func transpile(source, filename string, target api.Target, warnOut io.Writer) (string, error) {
 	sourcemap := api.SourceMapInline
 	if len(source) == 0 {
 		sourcemap = api.SourceMapNone
@@ -94,7 +253,7 @@ func (rt *Runtime) transpile(source, filename string) (string, error) {
 
 	result := api.Transform(source, api.TransformOptions{
 		Loader:     api.LoaderJS,
-		Target:     api.ES2017,
+		Target:     target,
 		Sourcefile: filename,
 		Format:     api.FormatDefault,
 		Sourcemap:  sourcemap,
@@ -112,7 +271,7 @@ func (rt *Runtime) transpile(source, filename string) (string, error) {
 
 	if len(result.Warnings) > 0 {
 		for _, warning := range result.Warnings {
-			fmt.Fprintf(os.Stderr, "Warning: %s:%d:%d: %s\n",
+			fmt.Fprintf(warnOut, "Warning: %s:%d:%d: %s\n",
 				warning.Location.File,
 				warning.Location.Line,
 				warning.Location.Column,
@@ -127,57 +286,89 @@ func (rt *Runtime) transpile(source, filename string) (string, error) {
 func (rt *Runtime) initializeGlobals(argv []string) {
 	console := modules.NewConsole()
 	rt.vm.Set("console", console.Export(rt.vm))
+	rt.console = console
 
 	timers := modules.NewTimers()
+	rt.timers = timers
 	timers.SetRuntime(rt)
+	timers.SetLoop(rt.loop)
 	timerObj := timers.Export(rt.vm).ToObject(rt.vm)
 	rt.vm.Set("setTimeout", timerObj.Get("setTimeout"))
 	rt.vm.Set("setInterval", timerObj.Get("setInterval"))
 	rt.vm.Set("clearTimeout", timerObj.Get("clearTimeout"))
 	rt.vm.Set("clearInterval", timerObj.Get("clearInterval"))
+	rt.vm.Set("setImmediate", timerObj.Get("setImmediate"))
+	rt.vm.Set("clearImmediate", timerObj.Get("clearImmediate"))
+	rt.vm.Set("queueMicrotask", timerObj.Get("queueMicrotask"))
+
+	abort := modules.NewAbort()
+	abort.SetLoop(rt.loop)
+	abortObj := abort.Export(rt.vm).ToObject(rt.vm)
+	rt.vm.Set("AbortController", abortObj.Get("AbortController"))
+	rt.vm.Set("AbortSignal", abortObj.Get("AbortSignal"))
 
 	path := modules.NewPath()
 	rt.vm.Set("path", path.Export(rt.vm))
 
 	files := modules.NewFiles()
   files.SetRuntime(rt)
+  files.SetLoop(rt.loop)
   rt.vm.Set("files", files.Export(rt.vm))
+	rt.files = files
 
-	httpClient := modules.NewHTTP(rt.vm)
+	httpClient := modules.NewHTTP(rt.vm, rt.loop)
   httpClient.SetRuntime(rt)
   rt.vm.Set("http", httpClient.Export(rt.vm))
+	rt.http = httpClient
 
 	modules.SetupPromise(rt.vm, rt)
+	modules.SetupJSON(rt.vm)
+
+	encoding := modules.NewEncoding().Export(rt.vm).ToObject(rt.vm)
+	rt.vm.Set("TextEncoder", encoding.Get("TextEncoder"))
+	rt.vm.Set("TextDecoder", encoding.Get("TextDecoder"))
+
+	headers := modules.NewHeaders().Export(rt.vm).ToObject(rt.vm)
+	rt.vm.Set("Headers", headers.Get("Headers"))
 
 	cryptoModule := modules.NewCrypto()
+	cryptoModule.SetRuntime(rt)
+	cryptoModule.SetLoop(rt.loop)
 	rt.vm.Set("crypto", cryptoModule.Export(rt.vm))
 
+	rt.schedule = modules.NewSchedule(rt.loop) // registered for require('schedule')
+	rt.schedule.SetRuntime(rt)
+
 	processModule := modules.NewProcess(argv)
   processModule.SetRuntime(rt)
+	processModule.AddHandleSource(timers)
+	processModule.AddHandleSource(httpClient)
+	processModule.AddHandleSource(rt.schedule)
   rt.vm.Set("process", processModule.Export(rt.vm))
+	rt.process = processModule
+
+	rt.vm.Set("permissions", modules.NewPermissions().Export(rt.vm))
+	rt.vm.Set("env", modules.NewEnv().Export(rt.vm))
 
-	rt.vm.Set("require", rt.requireFunction)
+	rt.vm.Set("Dougless", rt.douglessObject())
+
+	rt.vm.Set("require", rt.newRequire(rt.mainRequireDir))
 }
 
 func (rt *Runtime) initializeModules() {
 	rt.modules.Register("path", modules.NewPath())
+	rt.events = modules.NewEvents()
+	rt.modules.Register("events", rt.events)
+	rt.modules.Register("schedule", rt.schedule)
+	rt.modules.Register("wasm", modules.NewWasm())
+	rt.modules.Register("kv", modules.NewKV(rt.loop))
+	rt.modules.Register("os", modules.NewOS())
+	rt.modules.Register("args", modules.NewArgs(rt.process.Argv()))
 }
 
-func (rt *Runtime) requireFunction(call goja.FunctionCall) goja.Value {
-	if len(call.Arguments) == 0 {
-		panic(rt.vm.NewTypeError("require() missing module name"))
-	}
-
-	moduleName := call.Arguments[0].String()
-	module := rt.modules.Get(moduleName)
-
-	if module == nil {
-		panic(rt.vm.NewGoError(fmt.Errorf("Cannot find module '%s'", moduleName)))
-	}
-
-	return module.Export(rt.vm)
-}
-
-func (r *Runtime) Evaluate(code string) (goja.Value, error) {
-	return r.vm.RunString(code)
+func (r *Runtime) Evaluate(code string) (value goja.Value, err error) {
+	r.runOnLoop("evaluate", func() {
+		value, err = r.vm.RunString(code)
+	})
+	return value, scriptError(err)
 }
@@ -0,0 +1,163 @@
+package runtime
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/evanw/esbuild/pkg/api"
+
+	"github.com/douglasjordan2/dougless/internal/event"
+	"github.com/douglasjordan2/dougless/internal/modules"
+)
+
+// SandboxOptions selects which Dougless globals a sandbox exposes.
+// Everything is off by default, leaving only the language built-ins
+// (Object, Math, JSON, Promise, ...). Host access still goes through the
+// process-wide permission manager when Files or HTTP are enabled.
+type SandboxOptions struct {
+	Console bool // console.log and friends
+	Timers  bool // setTimeout/setInterval and their clear functions
+	Crypto  bool // crypto (hashing, random values, ciphers; hmacFile needs --allow-read)
+	Files   bool // files (subject to --allow-read/--allow-write)
+	HTTP    bool // http (subject to --allow-net)
+	Process bool // process (argv, env, exit, ...)
+
+	Argv    []string       // process.argv when Process is enabled
+	Globals map[string]any // Extra values to expose, e.g. host functions for plugins
+	Timeout time.Duration  // Interrupts synchronous evaluation running longer (0 = no limit)
+	Target  string         // Transpile target (default DefaultTarget)
+}
+
+// Sandbox runs code in its own goja VM, isolated from the main runtime and
+// from other sandboxes. Globals persist between Run calls on the same sandbox.
+//
+// Example:
+//
+//	sb, err := runtime.NewSandbox(runtime.SandboxOptions{Console: true})
+//	if err != nil { ... }
+//	defer sb.Close()
+//	result, err := sb.Run("[1, 2, 3].map(n => n * 2).join(',')")
+type Sandbox struct {
+	vm      *goja.Runtime
+	loop    *event.Loop
+	target  api.Target
+	timeout time.Duration
+	wg      sync.WaitGroup // pending async work started by sandboxed code
+}
+
+// NewSandbox creates a sandbox exposing only the globals enabled in opts.
+func NewSandbox(opts SandboxOptions) (*Sandbox, error) {
+	if opts.Target == "" {
+		opts.Target = DefaultTarget
+	}
+	target, ok := targets[opts.Target]
+	if !ok {
+		return nil, fmt.Errorf("unknown target %q (use es5, es2015, es2017 or esnext)", opts.Target)
+	}
+
+	sb := &Sandbox{
+		vm:      newVM(),
+		loop:    event.NewLoop(),
+		target:  target,
+		timeout: opts.Timeout,
+	}
+	sb.loop.Start()
+
+	vm := sb.vm
+	if opts.Console {
+		vm.Set("console", modules.NewConsole().Export(vm))
+	}
+	if opts.Timers {
+		timers := modules.NewTimers()
+		timers.SetRuntime(sb)
+		timers.SetLoop(sb.loop)
+		timerObj := timers.Export(vm).ToObject(vm)
+		for _, name := range []string{"setTimeout", "setInterval", "clearTimeout", "clearInterval"} {
+			vm.Set(name, timerObj.Get(name))
+		}
+	}
+	if opts.Crypto {
+		cryptoModule := modules.NewCrypto()
+		cryptoModule.SetRuntime(sb)
+		cryptoModule.SetLoop(sb.loop)
+		vm.Set("crypto", cryptoModule.Export(vm))
+	}
+	if opts.Files {
+		files := modules.NewFiles()
+		files.SetRuntime(sb)
+		files.SetLoop(sb.loop)
+		vm.Set("files", files.Export(vm))
+	}
+	if opts.HTTP {
+		httpClient := modules.NewHTTP(vm, sb.loop)
+		httpClient.SetRuntime(sb)
+		vm.Set("http", httpClient.Export(vm))
+	}
+	if opts.Process {
+		argv := opts.Argv
+		if argv == nil {
+			argv = []string{"dougless"}
+		}
+		process := modules.NewProcess(argv)
+		process.SetRuntime(sb)
+		vm.Set("process", process.Export(vm))
+	}
+	if opts.Files || opts.HTTP || opts.Timers || opts.Crypto {
+		// the async modules resolve through Dougless promises
+		modules.SetupPromise(vm, sb)
+	}
+
+	for name, value := range opts.Globals {
+		vm.Set(name, value)
+	}
+
+	return sb, nil
+}
+
+// KeepAlive registers pending async work; Run waits for it before returning.
+func (sb *Sandbox) KeepAlive() func() {
+	sb.wg.Add(1)
+	return func() {
+		sb.wg.Done()
+	}
+}
+
+// Run transpiles and evaluates code, waits for any async work it started and
+// returns the value of the last expression.
+func (sb *Sandbox) Run(code string) (goja.Value, error) {
+	transpiled, err := transpile(code, "sandbox.js", sb.target, os.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("transpilation error: %w", err)
+	}
+
+	if sb.timeout > 0 {
+		timer := time.AfterFunc(sb.timeout, func() {
+			sb.vm.Interrupt(fmt.Sprintf("sandbox timed out after %s", sb.timeout))
+		})
+		defer func() {
+			timer.Stop()
+			sb.vm.ClearInterrupt()
+		}()
+	}
+
+	// on the loop goroutine, like the callbacks of the async work it starts
+	var value goja.Value
+	if !sb.loop.Run("sandbox", func() { value, err = sb.vm.RunString(transpiled) }) {
+		return nil, fmt.Errorf("sandbox is closed")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("execution error: %w", scriptError(err))
+	}
+
+	sb.wg.Wait()
+	return value, nil
+}
+
+// Close stops the sandbox's event loop.
+func (sb *Sandbox) Close() {
+	sb.loop.Stop()
+	sb.loop.Wait()
+}
@@ -0,0 +1,68 @@
+package runtime
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// MaxCallStackSize is the deepest JavaScript call nesting allowed. Runaway
+// recursion fails with a "Maximum call stack size exceeded" error instead of
+// growing until the process runs out of memory, or of Go stack when the
+// calls go through native functions.
+const MaxCallStackSize = 10000
+
+// newVM returns a goja runtime with the call stack limit applied.
+func newVM() *goja.Runtime {
+	vm := goja.New()
+	vm.SetMaxCallStackSize(MaxCallStackSize)
+	return vm
+}
+
+// stackOverflowError describes an overflow where the script recursed, as
+// goja's *StackOverflowError has no message of its own. Scripts can't catch
+// it, so it ends up here however deep it was raised.
+type stackOverflowError struct {
+	site string // innermost frame, e.g. "f (main.js:1:24)"
+	err  *goja.StackOverflowError
+}
+
+func (e *stackOverflowError) Error() string {
+	if e.site == "" {
+		return "RangeError: Maximum call stack size exceeded"
+	}
+	return "RangeError: Maximum call stack size exceeded at " + e.site
+}
+
+func (e *stackOverflowError) Unwrap() error {
+	return e.err
+}
+
+// scriptError returns err, or a readable error in place of a stack overflow.
+func scriptError(err error) error {
+	var overflow *goja.StackOverflowError
+	if !errors.As(err, &overflow) {
+		return err
+	}
+	return &stackOverflowError{site: overflowSite(overflow.String()), err: overflow}
+}
+
+// overflowSite picks the innermost script frame from a full stack trace,
+// skipping native functions like Array.prototype.map.
+func overflowSite(stack string) string {
+	site := ""
+	for _, line := range strings.Split(stack, "\n") {
+		frame := strings.TrimPrefix(strings.TrimSpace(line), "at ")
+		if frame == "" {
+			continue
+		}
+		if !strings.HasSuffix(frame, "(native)") {
+			return frame
+		}
+		if site == "" {
+			site = frame
+		}
+	}
+	return site
+}
@@ -0,0 +1,51 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/douglasjordan2/dougless/internal/runtime"
+)
+
+func TestArgsParse(t *testing.T) {
+	rt := runtime.New([]string{"dougless", "test.js", "--port=8080", "-v", "public", "--name", "api"})
+	script := `
+		const args = require('args');
+		const spec = {
+			port: { type: 'number', alias: 'p', default: 3000 },
+			verbose: { type: 'boolean', alias: 'v' },
+			name: { type: 'string' },
+			color: { type: 'boolean', default: true },
+		};
+
+		const fromProcess = args.parse(spec);
+		const explicit = args.parse(['-p', '9', '--no-color', 'a', '--', '--port', 'b'], spec);
+		const defaults = args.parse([], spec);
+
+		function thrown(argv) {
+			try { args.parse(argv, spec); } catch (e) { return e.name + ': ' + e.message; }
+			return 'no error';
+		}
+	`
+	if err := rt.Execute(script, "args.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"JSON.stringify(fromProcess.flags)", `{"port":8080,"color":true,"verbose":true,"name":"api"}`},
+		{"JSON.stringify(fromProcess.positionals)", `["public"]`},
+		{"JSON.stringify(explicit.flags)", `{"port":9,"color":false}`},
+		{"JSON.stringify(explicit.positionals)", `["a","--port","b"]`},
+		{"JSON.stringify(defaults)", `{"flags":{"port":3000,"color":true},"positionals":[]}`},
+		{"thrown(['--bogus'])", "TypeError: args.parse: unknown flag --bogus"},
+		{"thrown(['--name'])", "TypeError: args.parse: flag name requires a value"},
+		{"thrown(['--port=abc'])", `TypeError: args.parse: flag port expects a number, got "abc"`},
+	}
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/douglasjordan2/dougless/internal/runtime"
+)
+
+func TestConsoleLogError(t *testing.T) {
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	var execErr error
+	out := captureStdout(t, func() {
+		execErr = rt.Execute(`
+			function parseConfig() {
+				throw new TypeError('config is missing a name');
+			}
+
+			try {
+				parseConfig();
+			} catch (err) {
+				console.log(err);
+			}
+
+			const bare = new Error('no stack');
+			bare.stack = undefined;
+			console.log('wrapped:', bare);
+		`, "console_error.js")
+	})
+	if execErr != nil {
+		t.Fatalf("Execute() error = %v", execErr)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) < 3 {
+		t.Fatalf("expected message, stack and second log, got %q", out)
+	}
+
+	if lines[0] != "TypeError: config is missing a name" {
+		t.Errorf("first line = %q, want the error name and message", lines[0])
+	}
+	if !strings.Contains(lines[1], "at parseConfig") || !strings.Contains(lines[1], "console_error.js") {
+		t.Errorf("expected a stack line for parseConfig, got %q", lines[1])
+	}
+	if last := lines[len(lines)-1]; last != "wrapped: Error: no stack" {
+		t.Errorf("error without a stack = %q, want %q", last, "wrapped: Error: no stack")
+	}
+}
+
+func TestRuntimeSetStdoutStderr(t *testing.T) {
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	var stdout, stderr bytes.Buffer
+	rt.SetStdout(&stdout)
+	rt.SetStderr(&stderr)
+
+	var execErr error
+	var leakedOut string
+	leakedErr := captureStderr(t, func() {
+		leakedOut = captureStdout(t, func() {
+			execErr = rt.Execute(`
+				console.log('hello', 42);
+				console.warn('careful');
+				setTimeout(() => { throw new Error('boom'); }, 1);
+			`, "embedded.js")
+		})
+	})
+	if execErr != nil {
+		t.Fatalf("Execute() error = %v", execErr)
+	}
+
+	if leakedOut != "" || leakedErr != "" {
+		t.Errorf("nothing should reach the process stdout/stderr, got %q / %q", leakedOut, leakedErr)
+	}
+	if got, want := stdout.String(), "hello 42\nWARN: careful\n"; got != want {
+		t.Errorf("stdout buffer = %q, want %q", got, want)
+	}
+	if got := stderr.String(); !strings.Contains(got, "setTimeout callback error") || !strings.Contains(got, "boom") {
+		t.Errorf("stderr buffer = %q, want the timer callback error", got)
+	}
+}
+
+func TestConsoleClearWhenPiped(t *testing.T) {
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	var execErr error
+	out := captureStdout(t, func() {
+		execErr = rt.Execute(`
+			console.log('before');
+			console.clear();
+			console.log('after');
+		`, "console_clear.js")
+	})
+	if execErr != nil {
+		t.Fatalf("Execute() error = %v", execErr)
+	}
+
+	if out != "before\nafter\n" {
+		t.Errorf("piped output = %q, want no escape codes from console.clear()", out)
+	}
+
+	var buf bytes.Buffer
+	rt.SetStdout(&buf)
+	if _, err := rt.Evaluate("console.clear()"); err != nil {
+		t.Fatalf("console.clear() error = %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("captured output = %q, want nothing", buf.String())
+	}
+}
+
+func TestConsoleInfoDebugTrace(t *testing.T) {
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	var execErr error
+	out := captureStdout(t, func() {
+		execErr = rt.Execute(`
+			console.info('server started on', 8080);
+			console.debug('cache miss for', 'user:42');
+
+			function handleRequest() {
+				console.trace('how did we get here?');
+			}
+			handleRequest();
+		`, "console_levels.js")
+	})
+	if execErr != nil {
+		t.Fatalf("Execute() error = %v", execErr)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) < 4 {
+		t.Fatalf("expected info, debug, trace and a stack frame, got %q", out)
+	}
+
+	if lines[0] != "server started on 8080" {
+		t.Errorf("info line = %q, want it like console.log", lines[0])
+	}
+	if lines[1] != "DEBUG: cache miss for user:42" {
+		t.Errorf("debug line = %q", lines[1])
+	}
+	if lines[2] != "TRACE: how did we get here?" {
+		t.Errorf("trace line = %q", lines[2])
+	}
+	if !strings.Contains(lines[3], "at handleRequest") || !strings.Contains(lines[3], "console_levels.js") {
+		t.Errorf("expected a stack frame for handleRequest, got %q", lines[3])
+	}
+}
@@ -0,0 +1,490 @@
+package tests
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/douglasjordan2/dougless/internal/runtime"
+)
+
+func TestCryptoAlgorithmIntrospection(t *testing.T) {
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := `
+		var hashes = crypto.getHashes();
+		var ciphers = crypto.getCiphers();
+	`
+
+	if err := rt.Execute(script, "introspection.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"hashes.indexOf('sha256') !== -1", "true"},
+		{"ciphers.indexOf('aes-256-gcm') !== -1", "true"},
+		{"crypto.constants.keyLengths['aes-256-gcm']", "32"},
+		{"crypto.constants.digestLengths.sha256", "32"},
+		{"crypto.constants.ivLength", "12"},
+		// every listed hash must actually be usable
+		{"hashes.every(function(h) { return crypto.createHash(h).update('x').digest('hex').length > 0; })", "true"},
+	}
+
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestCryptoCipherivRoundTrip(t *testing.T) {
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := `
+		var key = crypto.random(32, 'raw');
+		var iv = crypto.random(12, 'raw');
+
+		var cipher = crypto.createCipheriv('aes-256-gcm', key, iv);
+		var encrypted = cipher.update('attack at dawn', 'utf8', 'hex') + cipher.final('hex');
+		var tag = cipher.getAuthTag('hex');
+
+		var decipher = crypto.createDecipheriv('aes-256-gcm', key, iv);
+		decipher.setAuthTag(tag, 'hex');
+		var decrypted = decipher.update(encrypted, 'hex', 'utf8') + decipher.final('utf8');
+
+		var tampered;
+		try {
+			var bad = crypto.createDecipheriv('aes-256-gcm', key, iv);
+			bad.setAuthTag(tag, 'hex');
+			var flipped = (encrypted[0] === '0' ? '1' : '0') + encrypted.slice(1);
+			bad.update(flipped, 'hex', 'utf8');
+			bad.final('utf8');
+			tampered = 'accepted';
+		} catch (e) {
+			tampered = 'rejected';
+		}
+
+		var badKey;
+		try {
+			crypto.createCipheriv('aes-256-gcm', 'too-short', iv);
+		} catch (e) {
+			badKey = e instanceof TypeError;
+		}
+	`
+
+	if err := rt.Execute(script, "cipheriv.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	if got := evalString(t, rt, "decrypted"); got != "attack at dawn" {
+		t.Errorf("decrypted = %q, want %q", got, "attack at dawn")
+	}
+	if got := evalString(t, rt, "tampered"); got != "rejected" {
+		t.Errorf("tampered ciphertext was %s", got)
+	}
+	if got := evalString(t, rt, "badKey"); got != "true" {
+		t.Errorf("expected TypeError for wrong key length, got %s", got)
+	}
+}
+
+func TestCryptoEncryptDecrypt(t *testing.T) {
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := `
+		var key = crypto.random(32, 'raw');
+
+		var sealed = crypto.encrypt('aes-256-gcm', key, 'attack at dawn');
+		var decrypted = crypto.decrypt('aes-256-gcm', key, sealed);
+
+		var sealed64 = crypto.encrypt('aes-256-gcm', key, 'attack at dawn', 'base64');
+		var decrypted64 = crypto.decrypt('aes-256-gcm', key, sealed64, 'base64');
+
+		var freshNonce = crypto.encrypt('aes-256-gcm', key, 'attack at dawn') !== sealed;
+
+		var tampered;
+		try {
+			var i = sealed.length - 1;
+			crypto.decrypt('aes-256-gcm', key, sealed.slice(0, i) + (sealed[i] === '0' ? '1' : '0'));
+			tampered = 'accepted';
+		} catch (e) {
+			tampered = e.message;
+		}
+
+		var wrongKey;
+		try {
+			crypto.decrypt('aes-256-gcm', crypto.random(32, 'raw'), sealed);
+			wrongKey = 'accepted';
+		} catch (e) {
+			wrongKey = 'rejected';
+		}
+
+		var badKey;
+		try {
+			crypto.encrypt('aes-256-gcm', 'too-short', 'secret');
+		} catch (e) {
+			badKey = e instanceof TypeError;
+		}
+	`
+
+	if err := rt.Execute(script, "encrypt.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"decrypted", "attack at dawn"},
+		{"decrypted64", "attack at dawn"},
+		// 12-byte nonce + 14 bytes of ciphertext + 16-byte tag, hex encoded
+		{"sealed.length", "84"},
+		{"freshNonce", "true"},
+		{"tampered", "unsupported state or unable to authenticate data"},
+		{"wrongKey", "rejected"},
+		{"badKey", "true"},
+	}
+
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestCryptoCipherivAAD(t *testing.T) {
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := `
+		var key = crypto.random(32, 'raw');
+		var iv = crypto.random(12, 'raw');
+
+		var cipher = crypto.createCipheriv('aes-256-gcm', key, iv);
+		cipher.setAAD('token-header-v1');
+		var encrypted = cipher.update('secret payload', 'utf8', 'hex') + cipher.final('hex');
+		var tag = cipher.getAuthTag('hex');
+
+		function decrypt(aad) {
+			var decipher = crypto.createDecipheriv('aes-256-gcm', key, iv);
+			decipher.setAuthTag(tag, 'hex');
+			if (aad !== undefined) {
+				decipher.setAAD(aad);
+			}
+			try {
+				return decipher.update(encrypted, 'hex', 'utf8') + decipher.final('utf8');
+			} catch (e) {
+				return 'auth failed';
+			}
+		}
+
+		var sameAAD = decrypt('token-header-v1');
+		var otherAAD = decrypt('token-header-v2');
+		var missingAAD = decrypt();
+	`
+
+	if err := rt.Execute(script, "aad.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"sameAAD", "secret payload"},
+		{"otherAAD", "auth failed"},
+		{"missingAAD", "auth failed"},
+	}
+
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestCryptoRandomString(t *testing.T) {
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := `
+		var id = crypto.randomString(32);
+		var other = crypto.randomString(32);
+		var pin = crypto.randomString(500, { alphabet: 'abc' });
+
+		function rejects(fn) {
+			try { fn(); return false; } catch (e) { return e instanceof TypeError; }
+		}
+		var badLength = rejects(function() { crypto.randomString(0); });
+		var badAlphabet = rejects(function() { crypto.randomString(8, { alphabet: '' }); });
+	`
+
+	if err := rt.Execute(script, "random_string.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"id.length", "32"},
+		{"/^[A-Za-z0-9]+$/.test(id)", "true"},
+		{"id !== other", "true"},
+		{"pin.length", "500"},
+		{"/^[abc]+$/.test(pin)", "true"},
+		// with 500 draws every character of a 3-letter alphabet shows up
+		{"pin.indexOf('a') !== -1 && pin.indexOf('b') !== -1 && pin.indexOf('c') !== -1", "true"},
+		{"badLength", "true"},
+		{"badAlphabet", "true"},
+	}
+
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestCryptoHashIncremental(t *testing.T) {
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := `
+		var whole = crypto.createHash('sha256').update('ab').digest('hex');
+		var chained = crypto.createHash('sha256').update('a').update('b').digest('hex');
+
+		var h = crypto.createHash('sha256').update('a');
+		var snapshot = h.copy();
+		h.update('b');
+		var afterCopy = h.digest('hex');
+		var fromSnapshot = snapshot.update('b').digest('hex');
+
+		var reused;
+		try {
+			h.update('c');
+		} catch (e) {
+			reused = 'rejected';
+		}
+		var afterReset = h.reset().update('ab').digest('hex');
+
+		var hmacWhole = crypto.createHmac('sha256', 'key').update('ab').digest('hex');
+		var hmacChained = crypto.createHmac('sha256', 'key').update('a').update('b').digest('hex');
+		var hexInput = crypto.createHash('sha256').update('6162', 'hex').digest('hex');
+	`
+
+	if err := rt.Execute(script, "hash_incremental.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	// sha256("ab")
+	const want = "fb8e20fc2e4c3f248c60c39bd652f3c1347298bb977b8b4d5903b85055620603"
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"whole", want},
+		{"chained", want},
+		{"afterCopy", want},
+		{"fromSnapshot", want},
+		{"reused", "rejected"},
+		{"afterReset", want},
+		{"hexInput", want},
+		{"hmacWhole === hmacChained", "true"},
+	}
+
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestCryptoHashChain(t *testing.T) {
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := `
+		var doubled = crypto.hashChain(['sha256', 'sha256'], 'hello');
+		var doubled64 = crypto.hashChain(['sha256', 'sha256'], 'hello', 'base64');
+		var single = crypto.hashChain(['sha256'], 'hello') === crypto.createHash('sha256').update('hello').digest('hex');
+
+		var invalid;
+		try {
+			crypto.hashChain(['sha256', 'md4'], 'hello');
+		} catch (e) {
+			invalid = (e instanceof TypeError) + ': ' + e.message;
+		}
+	`
+
+	if err := rt.Execute(script, "hash_chain.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	first := sha256.Sum256([]byte("hello"))
+	second := sha256.Sum256(first[:])
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"doubled", hex.EncodeToString(second[:])},
+		{"doubled64", base64.StdEncoding.EncodeToString(second[:])},
+		{"single", "true"},
+		{"invalid", "true: unsupported algorithm: md4"},
+	}
+
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestCryptoUpdateMatchesIncrementalWrites(t *testing.T) {
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := `
+		var h = crypto.createHash('sha256');
+		h.update('first');
+		h.update('second');
+		var hashed = h.digest('hex');
+
+		var m = crypto.createHmac('sha256', 'key');
+		m.update('first');
+		m.update('second');
+		var signed = m.digest('hex');
+	`
+	if err := rt.Execute(script, "update.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	h := sha256.New()
+	io.WriteString(h, "first")
+	io.WriteString(h, "second")
+	mac := hmac.New(sha256.New, []byte("key"))
+	io.WriteString(mac, "first")
+	io.WriteString(mac, "second")
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"hashed", hex.EncodeToString(h.Sum(nil))},
+		{"signed", hex.EncodeToString(mac.Sum(nil))},
+	}
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestCryptoHmacFile(t *testing.T) {
+	dir := t.TempDir()
+	grantFiles(t, dir)
+
+	// a few MB so the file is hashed in many chunks
+	data := bytes.Repeat([]byte("dougless signs large files\n"), 200000)
+	path := filepath.Join(dir, "release.bin")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	deniedPath := filepath.Join(filepath.Dir(dir), "outside.bin")
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	mac := hmac.New(sha256.New, []byte("secret"))
+	if _, err := io.Copy(mac, f); err != nil {
+		t.Fatal(err)
+	}
+	sum := mac.Sum(nil)
+
+	rt := runtime.New([]string{"dougless", "test.js"})
+	script := fmt.Sprintf(`
+		var cbDigest, cbErr, promiseDigest, deniedErr, deniedDigest;
+
+		crypto.hmacFile('sha256', 'secret', %q, 'hex', (err, digest) => {
+			cbErr = err;
+			cbDigest = digest;
+		});
+		crypto.hmacFile('sha256', 'secret', %q, 'base64')
+			.then((digest) => { promiseDigest = digest; });
+		crypto.hmacFile('sha256', 'secret', %q, (err, digest) => {
+			deniedErr = err;
+			deniedDigest = digest;
+		});
+	`, path, path, deniedPath)
+	if err := rt.Execute(script, "hmac_file.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"cbErr", "null"},
+		{"cbDigest", hex.EncodeToString(sum)},
+		{"promiseDigest", base64.StdEncoding.EncodeToString(sum)},
+		{"typeof deniedErr === 'string' && deniedErr.indexOf('Permission denied') !== -1", "true"},
+		{"deniedDigest", "undefined"},
+	}
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestCryptoECDH(t *testing.T) {
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := `
+		function agree(curve, encoding) {
+			var alice = crypto.generateECDH(curve, encoding);
+			var bob = crypto.generateECDH(curve, encoding);
+			var a = crypto.ecdh(alice.privateKey, bob.publicKey, { encoding: encoding });
+			var b = crypto.ecdh(bob.privateKey, alice.publicKey, { encoding: encoding, curve: curve });
+			var eve = crypto.generateECDH(curve, encoding);
+			var c = crypto.ecdh(eve.privateKey, bob.publicKey, { encoding: encoding });
+			return [a === b, a !== c, a.length].join(' ');
+		}
+
+		var x25519 = agree('X25519');
+		var p256 = agree('P-256', 'base64');
+		var curves = crypto.getCurves().join(',');
+
+		var unknown;
+		try { crypto.generateECDH('P-999'); } catch (e) { unknown = e.message; }
+		var badKey;
+		try { crypto.ecdh('00', 'abcd'); } catch (e) { badKey = e.message; }
+	`
+
+	if err := rt.Execute(script, "ecdh.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"x25519", "true true 64"}, // 32-byte secret in hex
+		{"p256", "true true 44"},   // 32-byte secret in base64
+		{"curves", "P-256,X25519"},
+		{"unknown", "unsupported curve: P-999 (use P-256 or X25519)"},
+		{"badKey", "cannot infer the curve of a 2-byte public key; pass { curve }"},
+	}
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/douglasjordan2/dougless/internal/runtime"
+)
+
+func TestTextDecoderStreaming(t *testing.T) {
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := `
+		// "price: €5 😀" with the euro sign and the emoji split across chunks
+		const bytes = [0x70, 0x72, 0x69, 0x63, 0x65, 0x3a, 0x20, 0xe2, 0x82, 0xac, 0x35, 0x20, 0xf0, 0x9f, 0x98, 0x80];
+		const chunks = [bytes.slice(0, 8), bytes.slice(8, 13), bytes.slice(13)];
+
+		const decoder = new TextDecoder();
+		var parts = chunks.map(function(c) { return decoder.decode(new Uint8Array(c), { stream: true }); });
+		var flushed = decoder.decode();
+		var streamed = parts.join('') + flushed;
+
+		// without stream mode each chunk is decoded on its own and the split characters break
+		const naive = new TextDecoder();
+		var broken = chunks.map(function(c) { return naive.decode(new Uint8Array(c)); }).join('');
+
+		// an incomplete sequence left at the end is replaced when flushed
+		const trailing = new TextDecoder();
+		var dangling = trailing.decode(new Uint8Array([0x61, 0xe2, 0x82]), { stream: true }) + trailing.decode();
+	`
+
+	if err := rt.Execute(script, "text_decoder.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"streamed", "price: €5 😀"},
+		{"parts[0]", "price: "},
+		{"flushed", ""},
+		{"broken === streamed", "false"},
+		{"dangling", "a\uFFFD"},
+	}
+
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestTextEncoder(t *testing.T) {
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := `
+		const encoder = new TextEncoder();
+		const bytes = encoder.encode('€5 😀');
+		var encoded = Array.prototype.join.call(bytes, ',');
+		var kind = Object.prototype.toString.call(bytes);
+		var roundTrip = new TextDecoder().decode(bytes);
+		var empty = encoder.encode().length;
+
+		// room for the euro sign and '5' but not all of the emoji
+		const dst = new Uint8Array(6);
+		const result = encoder.encodeInto('€5 😀', dst);
+		var into = result.read + ' ' + result.written;
+
+		var hashed = crypto.createHash('sha256').update(encoder.encode('abc')).digest('hex') ===
+			crypto.createHash('sha256').update('abc').digest('hex');
+		var viaBuffer = crypto.createHash('sha256').update(encoder.encode('abc').buffer).digest('hex') ===
+			crypto.createHash('sha256').update('abc').digest('hex');
+	`
+
+	if err := rt.Execute(script, "encoder.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"encoded", "226,130,172,53,32,240,159,152,128"},
+		{"kind", "[object Uint8Array]"},
+		{"roundTrip", "€5 😀"},
+		{"empty", "0"},
+		{"into", "3 5"},
+		{"encoder.encoding", "utf-8"},
+		{"hashed", "true"},
+		{"viaBuffer", "true"},
+	}
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
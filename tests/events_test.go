@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/douglasjordan2/dougless/internal/runtime"
+)
+
+// captureStderr runs fn with os.Stderr redirected and returns what was written
+func captureStderr(t *testing.T, fn func()) string {
+	t.Helper()
+	return captureOutput(t, &os.Stderr, fn)
+}
+
+// captureStdout runs fn with os.Stdout redirected and returns what was written
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	return captureOutput(t, &os.Stdout, fn)
+}
+
+func captureOutput(t *testing.T, target **os.File, fn func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe() error = %v", err)
+	}
+
+	orig := *target
+	*target = w
+	defer func() { *target = orig }()
+
+	out := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		out <- string(data)
+	}()
+
+	fn()
+	w.Close()
+	return <-out
+}
+
+func TestEventEmitterMaxListenersWarning(t *testing.T) {
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	var execErr error
+	leaky := captureStderr(t, func() {
+		execErr = rt.Execute(`
+			const EventEmitter = require('events');
+			const leaky = new EventEmitter();
+			for (let i = 0; i < 11; i++) {
+				leaky.on('data', function() {});
+			}
+		`, "leaky.js")
+	})
+	if execErr != nil {
+		t.Fatalf("Execute() error = %v", execErr)
+	}
+	if !strings.Contains(leaky, "MaxListenersExceededWarning") || !strings.Contains(leaky, "11 data listeners") {
+		t.Errorf("expected a leak warning for 11 listeners, got %q", leaky)
+	}
+
+	raised := captureStderr(t, func() {
+		execErr = rt.Execute(`
+			const raised = new EventEmitter();
+			raised.setMaxListeners(20);
+			for (let i = 0; i < 11; i++) {
+				raised.on('data', function() {});
+			}
+
+			EventEmitter.defaultMaxListeners = 15;
+			const global = new EventEmitter();
+			for (let i = 0; i < 11; i++) {
+				global.on('data', function() {});
+			}
+
+			var received = [];
+			global.once('data', function(v) { received.push('once:' + v); });
+			global.emit('data', 1);
+			global.emit('data', 2);
+		`, "raised.js")
+	})
+	if execErr != nil {
+		t.Fatalf("Execute() error = %v", execErr)
+	}
+	if raised != "" {
+		t.Errorf("raising the limit should suppress the warning, got %q", raised)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"raised.getMaxListeners()", "20"},
+		{"global.getMaxListeners()", "15"},
+		{"received.join(',')", "once:1"},
+		{"global.listenerCount('data')", "11"},
+	}
+
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,830 @@
+package tests
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/douglasjordan2/dougless/internal/permissions"
+	"github.com/douglasjordan2/dougless/internal/runtime"
+)
+
+// grantFiles installs a non-interactive manager allowing read/write under dir
+func grantFiles(t *testing.T, dir string) {
+	t.Helper()
+
+	mgr := permissions.NewManager()
+	mgr.SetPromptMode(false)
+	mgr.GrantRead([]string{dir})
+	mgr.GrantWrite([]string{dir})
+	permissions.SetGlobalManager(mgr)
+	t.Cleanup(func() { permissions.SetGlobalManager(nil) })
+}
+
+// evalString evaluates an expression after a script has finished
+func evalString(t *testing.T, rt *runtime.Runtime, expr string) string {
+	t.Helper()
+
+	val, err := rt.Evaluate(expr)
+	if err != nil {
+		t.Fatalf("Evaluate(%q) error = %v", expr, err)
+	}
+	return val.String()
+}
+
+func TestFilesExists(t *testing.T) {
+	dir := t.TempDir()
+	grantFiles(t, dir)
+
+	missingPath := filepath.Join(dir, "missing.txt")
+	deniedPath := filepath.Join(filepath.Dir(dir), "outside.txt")
+
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		var missingCb, deniedCb, missingPromise, deniedPromise;
+
+		files.exists(%[1]q, function(err, exists) {
+			missingCb = (err === null) + ':' + exists;
+		});
+
+		files.exists(%[2]q, function(err, exists) {
+			deniedCb = (typeof err === 'string' && err.indexOf('Permission denied') !== -1) + ':' + exists;
+		});
+
+		files.exists(%[1]q)
+			.then(function(exists) { missingPromise = 'resolved:' + exists; })
+			.catch(function(err) { missingPromise = 'rejected'; });
+
+		files.exists(%[2]q)
+			.then(function(exists) { deniedPromise = 'resolved:' + exists; })
+			.catch(function(err) { deniedPromise = 'rejected'; });
+	`, missingPath, deniedPath)
+
+	if err := rt.Execute(script, "exists.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"missingCb", "true:false"},
+		{"deniedCb", "true:undefined"},
+		{"missingPromise", "resolved:false"},
+		{"deniedPromise", "rejected"},
+	}
+
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestFilesWatchDirRecursive(t *testing.T) {
+	dir := t.TempDir()
+	grantFiles(t, dir)
+
+	nested := filepath.Join(dir, "nested", "deeper")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(nested, "notes.txt")
+
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		var events = [];
+		const watcher = files.watchDir(%q, { recursive: true, interval: 20, debounce: 40 }, function(err, event) {
+			if (err) {
+				events.push('error:' + err);
+				watcher.close();
+				return;
+			}
+			events.push(event.type + ':' + event.path);
+			if (event.type === 'delete') {
+				watcher.close();
+			}
+		});
+	`, dir)
+
+	errCh := executeAsync(rt, script, "watch_dir.js")
+
+	// each step waits out the debounce window so events are reported separately
+	step := func(fn func() error) {
+		t.Helper()
+		time.Sleep(200 * time.Millisecond)
+		if err := fn(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	step(func() error { return os.WriteFile(target, []byte("v1"), 0644) })
+	step(func() error { return os.WriteFile(target, []byte("version two"), 0644) })
+	step(func() error { return os.Remove(target) })
+
+	waitForExecute(t, errCh, 5*time.Second)
+
+	want := fmt.Sprintf("create:%[1]s,modify:%[1]s,delete:%[1]s", target)
+	if got := evalString(t, rt, "events.join(',')"); got != want {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+}
+
+func TestFilesEnsureDir(t *testing.T) {
+	dir := t.TempDir()
+	grantFiles(t, dir)
+
+	// "existing" is already there; "a/b/c" below it is not
+	existing := filepath.Join(dir, "existing")
+	if err := os.Mkdir(existing, 0755); err != nil {
+		t.Fatal(err)
+	}
+	nested := filepath.Join(existing, "a", "b", "c")
+
+	blocker := filepath.Join(dir, "blocker")
+	if err := os.WriteFile(blocker, []byte("not a dir"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		var first, second, blocked;
+
+		files.ensureDir(%[1]q, function(err) {
+			first = err;
+			files.ensureDir(%[1]q).then(function() {
+				second = 'ok';
+			}).catch(function(err) {
+				second = err;
+			});
+		});
+
+		files.ensureDir(%[2]q, function(err) {
+			blocked = typeof err === 'string' && err.length > 0;
+		});
+	`, nested, filepath.Join(blocker, "child"))
+
+	if err := rt.Execute(script, "ensure_dir.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"first", "null"},
+		{"second", "ok"},
+		{"blocked", "true"},
+	}
+
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+
+	if info, err := os.Stat(nested); err != nil || !info.IsDir() {
+		t.Errorf("expected %s to be a directory (err = %v)", nested, err)
+	}
+}
+
+func TestFilesAppend(t *testing.T) {
+	dir := t.TempDir()
+	grantFiles(t, dir)
+
+	logPath := filepath.Join(dir, "app.log")
+	if err := os.WriteFile(logPath, []byte("one\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	outside := filepath.Join(t.TempDir(), "denied.log")
+
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		var first, second, created, denied;
+
+		files.append(%[1]q, 'two\n', function(err) {
+			first = err;
+			files.append(%[1]q, 'three\n').then(function(err) {
+				second = err;
+			});
+		});
+
+		files.append(%[2]q, 'fresh', function(err) {
+			created = err;
+		});
+
+		files.append(%[3]q, 'nope').catch(function(err) {
+			denied = err;
+		});
+	`, logPath, filepath.Join(dir, "new.log"), outside)
+
+	if err := rt.Execute(script, "append.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"first", "null"},
+		{"second", "null"},
+		{"created", "null"},
+		{"typeof denied === 'string' && denied.includes('--allow-write')", "true"},
+	}
+
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+
+	for path, want := range map[string]string{
+		logPath:                       "one\ntwo\nthree\n",
+		filepath.Join(dir, "new.log"): "fresh",
+	} {
+		if got, err := os.ReadFile(path); err != nil || string(got) != want {
+			t.Errorf("%s = %q (err = %v), want %q", path, got, err, want)
+		}
+	}
+	if _, err := os.Stat(outside); !os.IsNotExist(err) {
+		t.Errorf("denied append created %s (err = %v)", outside, err)
+	}
+}
+
+func TestFilesCopyAndRename(t *testing.T) {
+	dir := t.TempDir()
+	grantFiles(t, dir)
+
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	big := strings.Repeat("0123456789abcdef", 64*1024) // 1 MiB
+	write(filepath.Join(dir, "big.bin"), big)
+	write(filepath.Join(dir, "existing.txt"), "old content that is longer")
+	write(filepath.Join(dir, "move.txt"), "moved")
+	write(filepath.Join(dir, "tree", "a.txt"), "a")
+	write(filepath.Join(dir, "tree", "sub", "b.txt"), "b")
+	outside := t.TempDir()
+	write(filepath.Join(outside, "secret.txt"), "secret")
+
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		var copied, overwritten, renamed, renamedDir, denied;
+		const dir = %[1]q;
+
+		files.copy(dir + '/big.bin', dir + '/big-copy.bin', function(err) { copied = err; });
+		files.copy(dir + '/move.txt', dir + '/existing.txt').then(function(err) {
+			overwritten = err;
+			files.rename(dir + '/move.txt', dir + '/renamed.txt', function(err) { renamed = err; });
+		});
+		files.rename(dir + '/tree', dir + '/tree2', function(err) { renamedDir = err; });
+		files.copy(%[2]q, dir + '/stolen.txt', function(err) { denied = err; });
+	`, dir, filepath.Join(outside, "secret.txt"))
+
+	if err := rt.Execute(script, "copy_rename.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"copied", "null"},
+		{"overwritten", "null"},
+		{"renamed", "null"},
+		{"renamedDir", "null"},
+		{"typeof denied === 'string' && denied.includes('--allow-read')", "true"},
+	}
+
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+
+	for path, want := range map[string]string{
+		"big-copy.bin":    big,
+		"existing.txt":    "moved",
+		"renamed.txt":     "moved",
+		"tree2/a.txt":     "a",
+		"tree2/sub/b.txt": "b",
+	} {
+		if got, err := os.ReadFile(filepath.Join(dir, path)); err != nil || string(got) != want {
+			t.Errorf("%s: read %d bytes (err = %v), want %d", path, len(got), err, len(want))
+		}
+	}
+	for _, gone := range []string{"move.txt", "tree", "stolen.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, gone)); !os.IsNotExist(err) {
+			t.Errorf("%s should not exist (err = %v)", gone, err)
+		}
+	}
+}
+
+func TestFilesPromiseForms(t *testing.T) {
+	dir := t.TempDir()
+	grantFiles(t, dir)
+
+	// the operations below run concurrently, so readdir gets its own directory
+	listDir := filepath.Join(dir, "list")
+	if err := os.Mkdir(listDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"b.txt", "a.txt"} {
+		if err := os.WriteFile(filepath.Join(listDir, name), []byte("hello"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		var statResult, missingStat, readdirResult, mkdirResult, mkdirAgain, mkdirRecursive;
+		const dir = %q;
+
+		files.stat(dir + '/list/b.txt')
+			.then(function(info) {
+				statResult = [info.name, info.size, info.isFile, info.isDirectory, info.mtime instanceof Date].join(':');
+			})
+			.catch(function(err) { statResult = 'rejected: ' + err; });
+
+		files.stat(dir + '/missing.txt')
+			.then(function() { missingStat = 'resolved'; })
+			.catch(function() { missingStat = 'rejected'; });
+
+		files.readdir(dir + '/list')
+			.then(function(names) { readdirResult = names.join(','); })
+			.catch(function(err) { readdirResult = 'rejected: ' + err; });
+
+		files.mkdir(dir + '/out')
+			.then(function(result) {
+				mkdirResult = 'resolved:' + result;
+				// a second plain mkdir of the same path must fail
+				return files.mkdir(dir + '/out');
+			})
+			.then(function() { mkdirAgain = 'resolved'; })
+			.catch(function() { mkdirAgain = 'rejected'; });
+
+		files.mkdir(dir + '/deep/er/path', { recursive: true })
+			.then(function() { return files.stat(dir + '/deep/er/path'); })
+			.then(function(info) { mkdirRecursive = info.isDirectory; });
+	`, dir)
+
+	if err := rt.Execute(script, "promise_forms.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"statResult", "b.txt:5:true:false:true"},
+		{"missingStat", "rejected"},
+		{"readdirResult", "a.txt,b.txt"},
+		{"mkdirResult", "resolved:null"},
+		{"mkdirAgain", "rejected"},
+		{"mkdirRecursive", "true"},
+	}
+
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestFilesStreamPipe(t *testing.T) {
+	dir := t.TempDir()
+	grantFiles(t, dir)
+
+	// several chunks' worth of data so pipe has to forward more than one write
+	var content []byte
+	for i := 0; len(content) < 200*1024; i++ {
+		content = append(content, fmt.Sprintf("line %d\n", i)...)
+	}
+	src := filepath.Join(dir, "src.txt")
+	dst := filepath.Join(dir, "dst.txt")
+	if err := os.WriteFile(src, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		var chunks = 0, ended = false, finished = false, missing;
+
+		const input = files.createReadStream(%[1]q);
+		input.on('data', function(chunk) { chunks++; });
+		input.on('end', function() { ended = true; });
+
+		const output = files.createWriteStream(%[2]q);
+		output.on('finish', function() { finished = true; });
+		input.pipe(output);
+
+		files.readStream(%[3]q).on('error', function(err) {
+			missing = typeof err === 'string' && err.length > 0;
+		});
+	`, src, dst, filepath.Join(dir, "missing.txt"))
+
+	if err := rt.Execute(script, "stream_pipe.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"chunks > 1", "true"},
+		{"ended", "true"},
+		{"finished", "true"},
+		{"missing", "true"},
+	}
+
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+
+	got, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(content) {
+		t.Errorf("piped copy differs: got %d bytes, want %d", len(got), len(content))
+	}
+}
+
+func TestFilesStreamErrorOutput(t *testing.T) {
+	dir := t.TempDir()
+	grantFiles(t, dir)
+
+	rt := runtime.New([]string{"dougless", "test.js"})
+	var stderr bytes.Buffer
+	rt.SetStderr(&stderr)
+
+	script := fmt.Sprintf(`
+		files.createReadStream(%[1]q);
+		files.createReadStream(%[1]q).on('error', function() {
+			throw new Error('listener failed');
+		});
+	`, filepath.Join(dir, "missing.txt"))
+
+	leaked := captureStderr(t, func() {
+		if err := rt.Execute(script, "stream_errors.js"); err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+	})
+
+	if leaked != "" {
+		t.Errorf("nothing should reach the process stderr, got %q", leaked)
+	}
+	got := stderr.String()
+	for _, want := range []string{"Unhandled stream error", "Error in stream 'error' listener", "listener failed"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("stderr buffer missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestFilesWriteAtomic(t *testing.T) {
+	dir := t.TempDir()
+	grantFiles(t, dir)
+
+	target := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(target, []byte(`{"version":1}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		var callbackErr, promised;
+
+		files.write(%[1]q, '{"version":2}', { atomic: true }, function(err) {
+			callbackErr = err;
+		});
+
+		files.write(%[2]q, 'fresh', { atomic: true }).then(function() {
+			promised = 'ok';
+		}).catch(function(err) {
+			promised = err;
+		});
+	`, target, filepath.Join(dir, "nested", "fresh.txt"))
+
+	if err := rt.Execute(script, "write_atomic.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	if got := evalString(t, rt, "callbackErr"); got != "null" {
+		t.Errorf("callback err = %q, want null", got)
+	}
+	if got := evalString(t, rt, "promised"); got != "ok" {
+		t.Errorf("promise form = %q, want ok", got)
+	}
+
+	got, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != `{"version":2}` {
+		t.Errorf("content = %q, want the new version", got)
+	}
+	if info, err := os.Stat(target); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expected the original 0600 mode to be kept (info = %v, err = %v)", info, err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "nested", "fresh.txt")); string(got) != "fresh" {
+		t.Errorf("fresh file content = %q", got)
+	}
+
+	// no temp files left behind
+	for _, sub := range []string{dir, filepath.Join(dir, "nested")} {
+		entries, err := os.ReadDir(sub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			if strings.Contains(e.Name(), ".tmp-") {
+				t.Errorf("leftover temp file %s", filepath.Join(sub, e.Name()))
+			}
+		}
+	}
+}
+
+func TestFilesTailFollow(t *testing.T) {
+	dir := t.TempDir()
+	grantFiles(t, dir)
+
+	logPath := filepath.Join(dir, "app.log")
+	if err := os.WriteFile(logPath, []byte("one\ntwo\nthree\nfour\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		var lines = [], tailErr = null, snapshot = [], appended = false;
+
+		// once both tails have the last two lines, so the snapshot can't see the appends
+		function appendMore() {
+			if (appended || lines.length < 2 || snapshot.length < 2) return;
+			appended = true;
+			// a line split across two writes is delivered once it is complete
+			files.append(%[1]q, 'five\nsi').then(function() {
+				setTimeout(function() { files.append(%[1]q, 'x\r\nseven\n'); }, 50);
+			});
+		}
+
+		files.tail(%[1]q, { lines: 2 }, function(err, line) {
+			snapshot.push(line);
+			appendMore();
+		});
+
+		const t = files.tail(%[1]q, { lines: 2, follow: true, interval: 10 }, function(err, line) {
+			if (err) { tailErr = err; t.close(); return; }
+			lines.push(line);
+			appendMore();
+			if (line === 'seven') t.close();
+		});
+	`, logPath)
+
+	errCh := executeAsync(rt, script, "tail.js")
+	waitForExecute(t, errCh, 5*time.Second)
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"String(tailErr)", "null"},
+		{"snapshot.join(',')", "three,four"},
+		{"lines.join(',')", "three,four,five,six,seven"},
+	}
+
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestFilesTailCallbackErrorOutput(t *testing.T) {
+	dir := t.TempDir()
+	grantFiles(t, dir)
+
+	logPath := filepath.Join(dir, "app.log")
+	if err := os.WriteFile(logPath, []byte("one\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rt := runtime.New([]string{"dougless", "test.js"})
+	var stderr bytes.Buffer
+	rt.SetStderr(&stderr)
+
+	script := fmt.Sprintf(`
+		files.tail(%q, { lines: 1 }, function(err, line) {
+			throw new Error('bad line ' + line);
+		});
+	`, logPath)
+
+	leaked := captureStderr(t, func() {
+		if err := rt.Execute(script, "tail_errors.js"); err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+	})
+
+	if leaked != "" {
+		t.Errorf("nothing should reach the process stderr, got %q", leaked)
+	}
+	if got := stderr.String(); !strings.Contains(got, "Error in files.tail callback") || !strings.Contains(got, "bad line one") {
+		t.Errorf("stderr buffer = %q, want the tail callback error", got)
+	}
+}
+
+func TestFilesReadBytesRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	grantFiles(t, dir)
+
+	// a PNG signature followed by every byte value, much of it invalid UTF-8
+	data := []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'}
+	for i := 0; i < 256; i++ {
+		data = append(data, byte(i))
+	}
+	src := filepath.Join(dir, "logo.png")
+	if err := os.WriteFile(src, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(dir, "copy.png")
+	sum := sha256.Sum256(data)
+
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		var kind, length, hash, viaCallback, denied;
+
+		files.readBytes(%[1]q).then(function(bytes) {
+			kind = Object.prototype.toString.call(bytes);
+			length = bytes.length;
+			hash = crypto.createHash('sha256').update(bytes).digest('hex');
+			return files.write(%[2]q, bytes);
+		});
+
+		files.readBytes(%[1]q, function(err, bytes) {
+			viaCallback = err === null && bytes[0] === 0x89 && bytes[1] === 0x50;
+		});
+
+		files.readBytes(%[3]q).catch(function(err) { denied = err; });
+	`, src, dst, filepath.Join(t.TempDir(), "secret.bin"))
+
+	if err := rt.Execute(script, "bytes.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"kind", "[object Uint8Array]"},
+		{"length", fmt.Sprint(len(data))},
+		{"hash", hex.EncodeToString(sum[:])},
+		{"viaCallback", "true"},
+		{"typeof denied === 'string' && denied.includes('--allow-read')", "true"},
+	}
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+
+	if got, err := os.ReadFile(dst); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("written copy differs from the original (err = %v)", err)
+	}
+}
+
+func TestFilesJSON(t *testing.T) {
+	dir := t.TempDir()
+	grantFiles(t, dir)
+
+	path := filepath.Join(dir, "config.json")
+	broken := filepath.Join(dir, "broken.json")
+	if err := os.WriteFile(broken, []byte(`{"port": 80,}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		var roundTrip, viaCallback, parseError, noJSON;
+
+		files.writeJSON(%[1]q, { name: 'api', port: 8080, tags: ['a', 'b'] })
+			.then(function() { return files.readJSON(%[1]q); })
+			.then(function(cfg) {
+				roundTrip = JSON.stringify(cfg);
+				files.readJSON(%[1]q, function(err, cfg) {
+					viaCallback = err === null && cfg.port === 8080;
+				});
+			});
+
+		files.readJSON(%[2]q).catch(function(err) { parseError = err; });
+
+		try {
+			files.writeJSON(%[1]q, undefined);
+		} catch (e) {
+			noJSON = e instanceof TypeError;
+		}
+	`, path, broken)
+
+	if err := rt.Execute(script, "json.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"roundTrip", `{"name":"api","port":8080,"tags":["a","b"]}`},
+		{"viaCallback", "true"},
+		{"parseError.startsWith('invalid JSON in ' + " + fmt.Sprintf("%q", broken) + " + ': SyntaxError')", "true"},
+		{"noJSON", "true"},
+	}
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+
+	want := "{\n  \"name\": \"api\",\n  \"port\": 8080,\n  \"tags\": [\n    \"a\",\n    \"b\"\n  ]\n}\n"
+	if got, err := os.ReadFile(path); err != nil || string(got) != want {
+		t.Errorf("written file = %q (err = %v), want %q", got, err, want)
+	}
+}
+
+func TestFilesVerifyHash(t *testing.T) {
+	dir := t.TempDir()
+	grantFiles(t, dir)
+
+	data := []byte("dougless release artifact\n")
+	path := filepath.Join(dir, "release.tar.gz")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	good := hex.EncodeToString(sum[:])
+	bad := strings.Repeat("0", len(good))
+
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		var correct, wrong, viaCallback, denied;
+
+		files.verifyHash(%[1]q, 'sha256', %[2]q).then(function(ok) { correct = ok; });
+		files.verifyHash(%[1]q, 'sha256', %[3]q).then(function(ok) { wrong = ok; });
+		files.verifyHash(%[1]q, 'SHA256', %[2]q, function(err, ok) {
+			viaCallback = err === null && ok;
+		});
+		files.verifyHash(%[4]q, 'sha256', %[2]q).catch(function(err) { denied = err; });
+	`, path, good, bad, filepath.Join(t.TempDir(), "other.tar.gz"))
+
+	if err := rt.Execute(script, "verify.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"correct", "true"},
+		{"wrong", "false"},
+		{"viaCallback", "true"},
+		{"typeof denied === 'string' && denied.includes('--allow-read')", "true"},
+	}
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+
+	for _, call := range []string{
+		fmt.Sprintf(`files.verifyHash(%q, 'crc32', %q)`, path, good),
+		fmt.Sprintf(`files.verifyHash(%q, 'sha256', 'not hex')`, path),
+	} {
+		if err := rt.Execute(call, "verify_bad.js"); err == nil || !strings.Contains(err.Error(), "TypeError") {
+			t.Errorf("Execute(%s) error = %v, want a TypeError", call, err)
+		}
+	}
+}
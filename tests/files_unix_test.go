@@ -0,0 +1,63 @@
+//go:build unix
+
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/douglasjordan2/dougless/internal/runtime"
+)
+
+func TestFilesReadAbortSignal(t *testing.T) {
+	dir := t.TempDir()
+	grantFiles(t, dir)
+
+	// opening a FIFO for reading blocks until a writer shows up
+	fifo := filepath.Join(dir, "slow.fifo")
+	if err := syscall.Mkfifo(fifo, 0644); err != nil {
+		t.Skipf("mkfifo unavailable: %v", err)
+	}
+	t.Cleanup(func() {
+		// release the abandoned read so its goroutine can finish
+		if f, err := os.OpenFile(fifo, os.O_RDWR, 0); err == nil {
+			f.Close()
+		}
+	})
+
+	rt := runtime.New([]string{"dougless", "test.js"})
+	script := fmt.Sprintf(`
+		const controller = new AbortController();
+		var readErr, readData = 'unset', preAborted;
+
+		files.read(%q, { signal: controller.signal }, (err, data) => {
+			readErr = err;
+			readData = data;
+		});
+		files.read(%q, { signal: AbortSignal.abort() })
+			.catch((err) => { preAborted = err; });
+
+		setTimeout(() => controller.abort(), 50);
+	`, fifo, fifo)
+
+	errCh := executeAsync(rt, script, "read_abort.js")
+	waitForExecute(t, errCh, 5*time.Second)
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"readErr", "aborted: This operation was aborted"},
+		{"readData", "undefined"},
+		{"preAborted", "aborted: This operation was aborted"},
+	}
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
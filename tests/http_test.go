@@ -0,0 +1,2454 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"mime/multipart"
+	"net"
+	netHttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/net/http2"
+
+	"github.com/douglasjordan2/dougless/internal/modules"
+	"github.com/douglasjordan2/dougless/internal/permissions"
+	"github.com/douglasjordan2/dougless/internal/runtime"
+)
+
+// freePort asks the kernel for an unused TCP port on the loopback interface
+func freePort(t *testing.T) int {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to find free port: %v", err)
+	}
+	defer ln.Close()
+
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+// grantNet installs a non-interactive manager allowing all network access
+func grantNet(t *testing.T) {
+	t.Helper()
+
+	mgr := permissions.NewManager()
+	mgr.SetPromptMode(false)
+	mgr.GrantNet([]string{})
+	permissions.SetGlobalManager(mgr)
+	t.Cleanup(func() { permissions.SetGlobalManager(nil) })
+}
+
+// waitForServer polls until the script's server answers requests
+func waitForServer(t *testing.T, baseURL string) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		resp, err := netHttp.Get(baseURL + "/__ping")
+		if err == nil {
+			resp.Body.Close()
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server at %s did not start: %v", baseURL, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// closeScriptServer asks a test script's server to shut itself down via
+// its /__close route, which lets the runtime become idle. Idle client
+// connections are dropped too: Shutdown waits out a connection that never
+// sent a request (such as a spare the transport dialed) for 5 seconds.
+func closeScriptServer(baseURL string) {
+	resp, err := netHttp.Get(baseURL + "/__close")
+	if err == nil {
+		resp.Body.Close()
+	}
+	netHttp.DefaultClient.CloseIdleConnections()
+}
+
+// executeAsync runs a script in the background and returns a channel
+// that receives the result of Execute once the runtime is idle
+func executeAsync(rt *runtime.Runtime, script, filename string) <-chan error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- rt.Execute(script, filename)
+	}()
+	return errCh
+}
+
+// waitForExecute fails the test if the script does not finish in time
+func waitForExecute(t *testing.T, errCh <-chan error, timeout time.Duration) {
+	t.Helper()
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+	case <-time.After(timeout):
+		t.Fatal("script did not finish in time")
+	}
+}
+
+// waitForScript polls until expr, evaluated on the running script's loop,
+// is true; handlers set flags this way to say a request has reached them
+func waitForScript(t *testing.T, rt *runtime.Runtime, expr string) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for evalString(t, rt, expr) != "true" {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s did not become true", expr)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// dialWebSocket retries until the script's server is accepting connections
+func dialWebSocket(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err == nil {
+			return conn
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("failed to dial %s: %v", url, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWebSocketJSONRoundTrip(t *testing.T) {
+	grantNet(t)
+
+	port := freePort(t)
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		const server = http.createServer((req, res) => res.end('ok'));
+		let sock;
+
+		server.websocket('/ws', {
+			open: (ws) => { sock = ws; },
+			message: (msg) => {
+				sock.sendJSON({ echo: msg.data, kind: typeof msg.data, next: msg.data.n + 1 });
+			},
+			close: () => server.close(),
+		}, { json: true });
+
+		server.listen(%d, '127.0.0.1');
+	`, port)
+
+	errCh := executeAsync(rt, script, "ws_json.js")
+
+	conn := dialWebSocket(t, fmt.Sprintf("ws://127.0.0.1:%d/ws", port))
+
+	if err := conn.WriteJSON(map[string]any{"n": 41, "name": "dougless"}); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+
+	var reply struct {
+		Echo struct {
+			N    float64 `json:"n"`
+			Name string  `json:"name"`
+		} `json:"echo"`
+		Kind string  `json:"kind"`
+		Next float64 `json:"next"`
+	}
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := conn.ReadJSON(&reply); err != nil {
+		t.Fatalf("ReadJSON() error = %v", err)
+	}
+
+	if reply.Kind != "object" {
+		t.Errorf("expected message data to be parsed into an object, got %q", reply.Kind)
+	}
+	if reply.Echo.N != 41 || reply.Echo.Name != "dougless" {
+		t.Errorf("unexpected echo payload: %+v", reply.Echo)
+	}
+	if reply.Next != 42 {
+		t.Errorf("expected next = 42, got %v", reply.Next)
+	}
+
+	closeMsg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(time.Second))
+	conn.Close()
+
+	waitForExecute(t, errCh, 5*time.Second)
+}
+
+func TestAbortSignalTimeoutHTTPGet(t *testing.T) {
+	grantNet(t)
+
+	slow := httptest.NewServer(netHttp.HandlerFunc(func(w netHttp.ResponseWriter, r *netHttp.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+			w.Write([]byte("too late"))
+		}
+	}))
+	defer slow.Close()
+
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		var outcome = 'pending';
+		const signal = AbortSignal.timeout(50);
+
+		http.get('%s', { signal: signal })
+			.then(function() { outcome = 'resolved'; })
+			.catch(function(err) { outcome = 'rejected: ' + err.message; });
+	`, slow.URL)
+
+	start := time.Now()
+	if err := rt.Execute(script, "abort_timeout.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("request was not aborted promptly: took %v", elapsed)
+	}
+
+	outcome, err := rt.Evaluate("outcome")
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if !strings.HasPrefix(outcome.String(), "rejected:") || !strings.Contains(outcome.String(), "timeout") {
+		t.Errorf("expected rejection with a timeout reason, got %q", outcome.String())
+	}
+
+	reason, err := rt.Evaluate("signal.aborted + ':' + signal.reason.name")
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if reason.String() != "true:TimeoutError" {
+		t.Errorf("expected aborted signal with TimeoutError reason, got %q", reason.String())
+	}
+}
+
+func TestServerDefaultContentType(t *testing.T) {
+	grantNet(t)
+
+	tests := []struct {
+		name    string
+		options string
+		path    string
+		want    string
+	}{
+		{"default applied", "undefined", "/plain", "text/plain; charset=utf-8"},
+		{"explicit header wins", "undefined", "/json", "application/json"},
+		{"configured default", "{ defaultContentType: 'text/html; charset=utf-8' }", "/plain", "text/html; charset=utf-8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port := freePort(t)
+			baseURL := fmt.Sprintf("http://127.0.0.1:%d", port)
+			rt := runtime.New([]string{"dougless", "test.js"})
+
+			script := fmt.Sprintf(`
+				const server = http.createServer((req, res) => {
+					if (req.url === '/__close') {
+						res.end('bye');
+						setTimeout(() => server.close(), 10);
+						return;
+					}
+					if (req.url === '/json') {
+						res.setHeader('Content-Type', 'application/json');
+						res.end('{"ok":true}');
+						return;
+					}
+					res.end('hello');
+				}, %s);
+
+				server.listen(%d, '127.0.0.1');
+			`, tt.options, port)
+
+			errCh := executeAsync(rt, script, "content_type.js")
+			waitForServer(t, baseURL)
+
+			resp, err := netHttp.Get(baseURL + tt.path)
+			if err != nil {
+				t.Fatalf("GET %s error = %v", tt.path, err)
+			}
+			resp.Body.Close()
+
+			if got := resp.Header.Get("Content-Type"); got != tt.want {
+				t.Errorf("Content-Type = %q, want %q", got, tt.want)
+			}
+
+			closeScriptServer(baseURL)
+
+			waitForExecute(t, errCh, 5*time.Second)
+		})
+	}
+}
+
+func TestServerRequestRawBody(t *testing.T) {
+	grantNet(t)
+
+	port := freePort(t)
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", port)
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		const server = http.createServer((req, res) => {
+			if (req.url === '/__close') {
+				res.end('bye');
+				setTimeout(() => server.close(), 10);
+				return;
+			}
+
+			let hex = '';
+			for (let i = 0; i < req.rawBody.length; i++) {
+				hex += ('0' + req.rawBody[i].toString(16)).slice(-2);
+			}
+			res.end(req.rawBody.length + ':' + hex);
+		});
+
+		server.listen(%d, '127.0.0.1');
+	`, port)
+
+	errCh := executeAsync(rt, script, "raw_body.js")
+	waitForServer(t, baseURL)
+
+	payload := make([]byte, 256)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+
+	resp, err := netHttp.Post(baseURL+"/upload", "application/octet-stream", bytes.NewReader(payload))
+	if err != nil {
+		t.Fatalf("POST error = %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	want := fmt.Sprintf("%d:%s", len(payload), hex.EncodeToString(payload))
+	if string(body) != want {
+		t.Errorf("rawBody mismatch:\n got  %s\n want %s", body, want)
+	}
+
+	closeScriptServer(baseURL)
+	waitForExecute(t, errCh, 5*time.Second)
+}
+
+func TestServerRequestSignalAbortsOnDisconnect(t *testing.T) {
+	grantNet(t)
+
+	port := freePort(t)
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", port)
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		var observed = 'not run', listenerFired = false;
+
+		const server = http.createServer((req, res) => {
+			if (req.url === '/__close') {
+				res.end('bye');
+				setTimeout(() => server.close(), 10);
+				return;
+			}
+
+			if (req.url !== '/slow') {
+				res.end(observed + ':' + listenerFired);
+				return;
+			}
+
+			req.signal.addEventListener('abort', () => { listenerFired = true; });
+
+			// a slow handler that checks for cancellation as it works
+			const start = Date.now();
+			while (!req.signal.aborted && Date.now() - start < 3000) {}
+
+			observed = req.signal.aborted ? 'aborted:' + req.signal.reason.name : 'completed';
+			res.end('too late');
+		});
+
+		server.listen(%d, '127.0.0.1');
+	`, port)
+
+	errCh := executeAsync(rt, script, "request_signal.js")
+	waitForServer(t, baseURL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	req, _ := netHttp.NewRequestWithContext(ctx, "GET", baseURL+"/slow", nil)
+	if resp, err := netHttp.DefaultClient.Do(req); err == nil {
+		resp.Body.Close()
+		t.Fatal("expected the client request to be cancelled")
+	}
+
+	// the abort listener is dispatched on the loop after the handler returns
+	var status string
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		resp, err := netHttp.Get(baseURL + "/status")
+		if err == nil {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			status = string(body)
+			if status == "aborted:AbortError:true" {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	closeScriptServer(baseURL)
+	waitForExecute(t, errCh, 5*time.Second)
+
+	if status != "aborted:AbortError:true" {
+		t.Errorf("handler status = %q, want aborted:AbortError:true", status)
+	}
+}
+
+func TestServerListenUnix(t *testing.T) {
+	// unix socket paths are length-limited, so keep the directory short
+	dir, err := os.MkdirTemp("", "dl-sock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	grantFiles(t, dir)
+
+	socketPath := filepath.Join(dir, "app.sock")
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		var listening = false;
+
+		const server = http.createServer((req, res) => {
+			if (req.url === '/__close') {
+				res.end('bye');
+				setTimeout(() => server.close(), 10);
+				return;
+			}
+			res.end('hello over ' + req.url);
+		});
+
+		server.listenUnix(%q, () => { listening = true; });
+	`, socketPath)
+
+	errCh := executeAsync(rt, script, "listen_unix.js")
+
+	client := &netHttp.Client{
+		Transport: &netHttp.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socketPath)
+			},
+		},
+	}
+
+	var body string
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		resp, err := client.Get("http://unix/ipc")
+		if err == nil {
+			data, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			body = string(data)
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if body != "hello over /ipc" {
+		t.Errorf("body = %q, want %q", body, "hello over /ipc")
+	}
+
+	if resp, err := client.Get("http://unix/__close"); err == nil {
+		resp.Body.Close()
+	}
+	waitForExecute(t, errCh, 5*time.Second)
+
+	if got := evalString(t, rt, "listening"); got != "true" {
+		t.Errorf("listen callback ran = %s, want true", got)
+	}
+	if _, err := os.Stat(socketPath); !os.IsNotExist(err) {
+		t.Errorf("socket file should be removed on close, stat err = %v", err)
+	}
+}
+
+func TestServerResponseRedirect(t *testing.T) {
+	grantNet(t)
+
+	port := freePort(t)
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", port)
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		var badStatus;
+
+		const server = http.createServer((req, res) => {
+			if (req.url === '/__close') {
+				res.end('bye');
+				setTimeout(() => server.close(), 10);
+				return;
+			}
+			if (req.url === '/moved') {
+				res.redirect('/new-home', 301);
+				return;
+			}
+			if (req.url === '/bad') {
+				try {
+					res.redirect('/x', 200);
+				} catch (e) {
+					badStatus = String(e);
+				}
+				res.end('not redirected');
+				return;
+			}
+			if (req.url === '/account') {
+				res.redirect('/login');
+				return;
+			}
+			res.end('ok');
+		});
+
+		server.listen(%d, '127.0.0.1');
+	`, port)
+
+	errCh := executeAsync(rt, script, "redirect.js")
+	waitForServer(t, baseURL)
+
+	client := &netHttp.Client{
+		CheckRedirect: func(*netHttp.Request, []*netHttp.Request) error {
+			return netHttp.ErrUseLastResponse
+		},
+	}
+
+	tests := []struct {
+		path     string
+		status   int
+		location string
+	}{
+		{"/account", 302, "/login"},
+		{"/moved", 301, "/new-home"},
+		{"/bad", 200, ""},
+	}
+
+	for _, tt := range tests {
+		resp, err := client.Get(baseURL + tt.path)
+		if err != nil {
+			t.Fatalf("GET %s error = %v", tt.path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != tt.status {
+			t.Errorf("GET %s status = %d, want %d", tt.path, resp.StatusCode, tt.status)
+		}
+		if got := resp.Header.Get("Location"); got != tt.location {
+			t.Errorf("GET %s Location = %q, want %q", tt.path, got, tt.location)
+		}
+		if tt.location != "" && len(body) != 0 {
+			t.Errorf("GET %s redirect body = %q, want empty", tt.path, body)
+		}
+	}
+
+	closeScriptServer(baseURL)
+	waitForExecute(t, errCh, 5*time.Second)
+
+	if got := evalString(t, rt, "badStatus"); !strings.Contains(got, "must be 3xx") {
+		t.Errorf("non-3xx redirect error = %q", got)
+	}
+}
+
+func TestHTTPUserAgent(t *testing.T) {
+	grantNet(t)
+
+	echo := httptest.NewServer(netHttp.HandlerFunc(func(w netHttp.ResponseWriter, r *netHttp.Request) {
+		w.Write([]byte(r.UserAgent()))
+	}))
+	defer echo.Close()
+
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		var defaultUA, postUA, clientUA, perRequestUA;
+		const client = http.createClient({ userAgent: 'my-bot/1.0' });
+
+		http.get('%[1]s').then(function(res) { defaultUA = res.body; });
+		http.post('%[1]s', { ok: true }).then(function(res) { postUA = res.body; });
+		client.get('%[1]s').then(function(res) { clientUA = res.body; });
+		client.get('%[1]s', { headers: { 'User-Agent': 'one-off/2.0' } })
+			.then(function(res) { perRequestUA = res.body; });
+	`, echo.URL)
+
+	if err := rt.Execute(script, "user_agent.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	want := "Dougless/" + modules.Version
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"defaultUA", want},
+		{"postUA", want},
+		{"clientUA", "my-bot/1.0"},
+		{"perRequestUA", "one-off/2.0"},
+	}
+
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestHTTPGetStreamingBody(t *testing.T) {
+	grantNet(t)
+
+	chunks := []string{"first chunk|", "second chunk|", "third chunk"}
+	server := httptest.NewServer(netHttp.HandlerFunc(func(w netHttp.ResponseWriter, r *netHttp.Request) {
+		flusher := w.(netHttp.Flusher)
+		for _, chunk := range chunks {
+			w.Write([]byte(chunk))
+			flusher.Flush()
+			time.Sleep(30 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		var readerChunks = 0, readerText = '', iteratedText = '', status;
+
+		async function viaReader() {
+			const res = await http.get('%[1]s', { stream: true });
+			status = res.statusCode;
+			const reader = res.body.getReader();
+			const decoder = new TextDecoder();
+			while (true) {
+				const { value, done } = await reader.read();
+				if (done) break;
+				readerChunks++;
+				readerText += decoder.decode(value, { stream: true });
+			}
+		}
+
+		async function viaIterator() {
+			const res = await http.get('%[1]s', { stream: true });
+			const decoder = new TextDecoder();
+			for await (const chunk of res.body) {
+				iteratedText += decoder.decode(chunk, { stream: true });
+			}
+		}
+
+		viaReader().catch(function(e) { readerText = 'error: ' + e; });
+		viaIterator().catch(function(e) { iteratedText = 'error: ' + e; });
+	`, server.URL)
+
+	if err := rt.Execute(script, "stream_body.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	want := strings.Join(chunks, "")
+	if got := evalString(t, rt, "readerText"); got != want {
+		t.Errorf("reader text = %q, want %q", got, want)
+	}
+	if got := evalString(t, rt, "iteratedText"); got != want {
+		t.Errorf("iterated text = %q, want %q", got, want)
+	}
+	if got := evalString(t, rt, "readerChunks > 1"); got != "true" {
+		t.Errorf("expected the body to arrive in several chunks, got %s", evalString(t, rt, "readerChunks"))
+	}
+	if got := evalString(t, rt, "status"); got != "200" {
+		t.Errorf("status = %s, want 200", got)
+	}
+}
+
+func TestHTTPMaxRedirects(t *testing.T) {
+	grantNet(t)
+
+	// two servers bouncing every request to each other
+	var hops atomic.Int32
+	var a, b *httptest.Server
+	a = httptest.NewServer(netHttp.HandlerFunc(func(w netHttp.ResponseWriter, r *netHttp.Request) {
+		hops.Add(1)
+		netHttp.Redirect(w, r, b.URL+"/ping", netHttp.StatusFound)
+	}))
+	defer a.Close()
+	b = httptest.NewServer(netHttp.HandlerFunc(func(w netHttp.ResponseWriter, r *netHttp.Request) {
+		hops.Add(1)
+		netHttp.Redirect(w, r, a.URL+"/pong", netHttp.StatusFound)
+	}))
+	defer b.Close()
+
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		var loop = 'pending', capped = 'pending', noFollow = 'pending';
+
+		http.get('%[1]s')
+			.then(function() { loop = 'resolved'; })
+			.catch(function(err) { loop = err.message; });
+
+		http.createClient({ maxRedirects: 3 }).get('%[1]s')
+			.then(function() { capped = 'resolved'; })
+			.catch(function(err) { capped = err.message; });
+
+		http.get('%[1]s', { maxRedirects: 0 })
+			.then(function(res) { noFollow = res.statusCode + ' ' + res.headers.Location; })
+			.catch(function(err) { noFollow = 'rejected: ' + err.message; });
+	`, a.URL)
+
+	if err := rt.Execute(script, "redirects.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	if got := evalString(t, rt, "loop"); !strings.Contains(got, "too many redirects (max 10)") {
+		t.Errorf("default limit: expected too many redirects error, got %q", got)
+	}
+	if got := evalString(t, rt, "capped"); !strings.Contains(got, "too many redirects (max 3)") {
+		t.Errorf("client limit: expected too many redirects error, got %q", got)
+	}
+	if got, want := evalString(t, rt, "noFollow"), "302 "+b.URL+"/ping"; got != want {
+		t.Errorf("maxRedirects 0: got %q, want %q", got, want)
+	}
+
+	// 11 + 4 + 1 requests: each limit allows max redirects plus the first request
+	if got := hops.Load(); got != 16 {
+		t.Errorf("servers saw %d requests, want 16", got)
+	}
+}
+
+func TestServerJSONReviverReplacer(t *testing.T) {
+	grantNet(t)
+
+	port := freePort(t)
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", port)
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		const isoDate = /^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?Z$/;
+
+		const server = http.createServer((req, res) => {
+			if (req.url === '/__close') {
+				res.end('bye');
+				setTimeout(() => server.close(), 10);
+				return;
+			}
+			if (req.url !== '/events') {
+				res.end('ok');
+				return;
+			}
+
+			const data = req.json((key, value) =>
+				typeof value === 'string' && isoDate.test(value) ? new Date(value) : value);
+
+			res.statusCode = 201;
+			res.json({
+				name: data.name,
+				isDate: data.when instanceof Date,
+				year: data.when.getUTCFullYear(),
+				password: 'hunter2',
+				nested: { password: 'hunter2', kept: true },
+			}, (key, value) => key === 'password' ? undefined : value);
+		});
+
+		server.listen(%d, '127.0.0.1');
+	`, port)
+
+	errCh := executeAsync(rt, script, "json_helpers.js")
+	waitForServer(t, baseURL)
+
+	resp, err := netHttp.Post(baseURL+"/events", "application/json",
+		strings.NewReader(`{"name":"launch","when":"2024-05-01T12:00:00Z"}`))
+	if err != nil {
+		t.Fatalf("POST error = %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	closeScriptServer(baseURL)
+	waitForExecute(t, errCh, 5*time.Second)
+
+	if resp.StatusCode != 201 {
+		t.Errorf("status = %d, want 201", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	want := `{"name":"launch","isDate":true,"year":2024,"nested":{"kept":true}}`
+	if string(body) != want {
+		t.Errorf("body = %s, want %s", body, want)
+	}
+}
+
+func TestWebSocketCloseCodeAndReason(t *testing.T) {
+	grantNet(t)
+
+	port := freePort(t)
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		const server = http.createServer((req, res) => res.end('ok'));
+		var closed, errors = 0;
+
+		server.websocket('/ws', {
+			error: () => { errors++; },
+			close: (event) => {
+				closed = event;
+				server.close();
+			},
+		});
+
+		server.listen(%d, '127.0.0.1');
+	`, port)
+
+	errCh := executeAsync(rt, script, "ws_close.js")
+
+	conn := dialWebSocket(t, fmt.Sprintf("ws://127.0.0.1:%d/ws", port))
+	closeMsg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "client shutting down")
+	if err := conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(time.Second)); err != nil {
+		t.Fatalf("WriteControl() error = %v", err)
+	}
+
+	// the server answers the close frame before dropping the connection
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err := conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseGoingAway {
+		t.Errorf("expected the server to echo close code 1001, got %v", err)
+	}
+	conn.Close()
+
+	waitForExecute(t, errCh, 5*time.Second)
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"closed.code", "1001"},
+		{"closed.reason", "client shutting down"},
+		{"closed.wasClean", "true"},
+		{"errors", "0"},
+	}
+
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestHTTPGetConditional(t *testing.T) {
+	grantNet(t)
+
+	const etag = `"v42"`
+	lastModified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	server := httptest.NewServer(netHttp.HandlerFunc(func(w netHttp.ResponseWriter, r *netHttp.Request) {
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", lastModified.Format(netHttp.TimeFormat))
+
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(netHttp.StatusNotModified)
+			return
+		}
+		if since, err := netHttp.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !lastModified.After(since) {
+			w.WriteHeader(netHttp.StatusNotModified)
+			return
+		}
+		w.Write([]byte("fresh data"))
+	}))
+	defer server.Close()
+
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		var first, matched, stale, sinceDate, sinceOld;
+
+		async function poll() {
+			first = await http.get('%[1]s');
+			matched = await http.get('%[1]s', { ifNoneMatch: first.headers.Etag });
+			stale = await http.get('%[1]s', { ifNoneMatch: '"v41"' });
+			sinceDate = await http.get('%[1]s', { ifModifiedSince: new Date(Date.UTC(2024, 5, 1)) });
+			sinceOld = await http.get('%[1]s', { ifModifiedSince: 'Mon, 01 Jan 2024 00:00:00 GMT' });
+		}
+		poll().catch(function(e) { first = { statusCode: 'error: ' + e }; });
+	`, server.URL)
+
+	if err := rt.Execute(script, "conditional.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"first.statusCode", "200"},
+		{"first.body", "fresh data"},
+		{"first.notModified", "false"},
+		{"matched.statusCode", "304"},
+		{"matched.body", ""},
+		{"matched.notModified", "true"},
+		{"stale.statusCode", "200"},
+		{"sinceDate.statusCode", "304"},
+		{"sinceOld.statusCode", "200"},
+		{"sinceOld.body", "fresh data"},
+	}
+
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestServerRequestForm(t *testing.T) {
+	grantNet(t)
+
+	port := freePort(t)
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", port)
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		var urlencoded, multipart;
+
+		const server = http.createServer((req, res) => {
+			if (req.url === '/__close') {
+				res.end('bye');
+				setTimeout(() => server.close(), 10);
+				return;
+			}
+			if (req.url === '/signup') {
+				urlencoded = req.form();
+			} else if (req.url === '/upload') {
+				multipart = req.form();
+			}
+			res.end('ok');
+		});
+
+		server.listen(%d, '127.0.0.1');
+	`, port)
+
+	errCh := executeAsync(rt, script, "form.js")
+	waitForServer(t, baseURL)
+
+	resp, err := netHttp.PostForm(baseURL+"/signup", url.Values{
+		"name": {"Ada Lovelace"},
+		"tag":  {"math", "poetry"},
+	})
+	if err != nil {
+		t.Fatalf("POST /signup error = %v", err)
+	}
+	resp.Body.Close()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("title", "notes")
+	fw, _ := mw.CreateFormFile("attachment", "notes.txt")
+	fw.Write([]byte("hello file"))
+	mw.Close()
+
+	resp, err = netHttp.Post(baseURL+"/upload", mw.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatalf("POST /upload error = %v", err)
+	}
+	resp.Body.Close()
+
+	closeScriptServer(baseURL)
+	waitForExecute(t, errCh, 5*time.Second)
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"urlencoded.name", "Ada Lovelace"},
+		{"urlencoded.tag.join(',')", "math,poetry"},
+		{"multipart.title", "notes"},
+		{"multipart.attachment.filename", "notes.txt"},
+		{"multipart.attachment.size", "10"},
+		{"new TextDecoder().decode(multipart.attachment.data)", "hello file"},
+	}
+
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestWebSocketCompression(t *testing.T) {
+	grantNet(t)
+
+	port := freePort(t)
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		const server = http.createServer((req, res) => res.end('ok'));
+		var compressed = [], sock;
+
+		server.websocket('/ws', {
+			open: (ws) => { sock = ws; compressed.push(ws.compressed); },
+			message: (msg) => { sock.send(msg.data); },
+			close: () => {
+				if (compressed.length === 2) server.close();
+			},
+		}, { compression: true });
+
+		server.listen(%d, '127.0.0.1');
+	`, port)
+
+	errCh := executeAsync(rt, script, "ws_compression.js")
+
+	url := fmt.Sprintf("ws://127.0.0.1:%d/ws", port)
+	dialer := websocket.Dialer{EnableCompression: true}
+
+	var conn *websocket.Conn
+	var resp *netHttp.Response
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		var err error
+		conn, resp, err = dialer.Dial(url, nil)
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("failed to dial %s: %v", url, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if ext := resp.Header.Get("Sec-Websocket-Extensions"); !strings.Contains(ext, "permessage-deflate") {
+		t.Errorf("Sec-WebSocket-Extensions = %q, want permessage-deflate", ext)
+	}
+
+	message := strings.Repeat("dougless compresses well. ", 8192)
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
+		t.Fatalf("WriteMessage() error = %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, echoed, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage() error = %v", err)
+	}
+	if string(echoed) != message {
+		t.Errorf("echoed %d bytes, want the %d byte message back intact", len(echoed), len(message))
+	}
+	conn.Close()
+
+	// a client that doesn't offer the extension gets an uncompressed socket
+	plain := dialWebSocket(t, url)
+	plain.Close()
+
+	waitForExecute(t, errCh, 5*time.Second)
+
+	if got := evalString(t, rt, "compressed.join()"); got != "true,false" {
+		t.Errorf("ws.compressed per connection = %s, want true,false", got)
+	}
+}
+
+func TestWebSocketWriteTimeout(t *testing.T) {
+	grantNet(t)
+
+	port := freePort(t)
+	rt := runtime.New([]string{"dougless", "test.js"})
+	rt.SetStderr(io.Discard) // the blocked send trips the slow-task warning
+
+	script := fmt.Sprintf(`
+		const server = http.createServer((req, res) => res.end('ok'));
+		var errors = [], sent = 0, sendFailed = false, closeCode = 0;
+
+		server.websocket('/ws', {
+			open: (ws) => {
+				// the client never reads, so the socket buffers fill up
+				const chunk = 'x'.repeat(1 << 20);
+				for (let i = 0; i < 256; i++) {
+					try {
+						ws.send(chunk);
+						sent++;
+					} catch (e) {
+						sendFailed = true;
+						break;
+					}
+				}
+			},
+			error: (msg) => errors.push(msg),
+			close: (event) => {
+				closeCode = event.code;
+				server.close();
+			},
+		}, { writeTimeout: 200 });
+
+		server.listen(%d, '127.0.0.1');
+	`, port)
+
+	errCh := executeAsync(rt, script, "ws_write_timeout.js")
+
+	conn := dialWebSocket(t, fmt.Sprintf("ws://127.0.0.1:%d/ws", port))
+	defer conn.Close()
+
+	waitForExecute(t, errCh, 10*time.Second)
+
+	if got := evalString(t, rt, "closeCode"); got != "1006" {
+		t.Errorf("close code = %s, want 1006", got)
+	}
+	if got := evalString(t, rt, "sendFailed && sent < 256"); got != "true" {
+		t.Errorf("expected sends to stop once the write timed out (sent %s)", evalString(t, rt, "sent"))
+	}
+	if got := evalString(t, rt, "errors.join('; ')"); !strings.Contains(got, "timeout") {
+		t.Errorf("errors = %q, want a write timeout", got)
+	}
+}
+
+func TestWebSocketClientConnect(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	echo := httptest.NewServer(netHttp.HandlerFunc(func(w netHttp.ResponseWriter, r *netHttp.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			messageType, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(messageType, append([]byte("echo: "), message...))
+		}
+	}))
+	defer echo.Close()
+	wsURL := "ws" + strings.TrimPrefix(echo.URL, "http")
+
+	t.Run("round trip", func(t *testing.T) {
+		grantNet(t)
+		rt := runtime.New([]string{"dougless", "test.js"})
+
+		script := fmt.Sprintf(`
+			var states = [], received = [], closeEvent, sendWhileConnecting;
+			const ws = http.connect(%q, {
+				open: (sock) => {
+					states.push(sock.readyState);
+					sock.send('hello');
+					sock.sendJSON({ n: 1 });
+				},
+				message: (msg) => {
+					received.push(msg.data);
+					if (received.length === 2) ws.close(1000, 'done');
+				},
+				close: (event) => {
+					states.push(ws.readyState);
+					closeEvent = event.code + ':' + event.reason + ':' + event.wasClean;
+				},
+			});
+			states.push(ws.readyState);
+			try { ws.send('too early'); } catch (e) { sendWhileConnecting = String(e); }
+		`, wsURL)
+
+		if err := rt.Execute(script, "ws_client.js"); err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+
+		tests := []struct {
+			expr string
+			want string
+		}{
+			{"states.join()", "0,1,3"},
+			{"received.join('|')", `echo: hello|echo: {"n":1}`},
+			{"closeEvent", "1000:done:true"},
+			{"sendWhileConnecting", "websocket connection is not open"},
+			{"[ws.CONNECTING, ws.OPEN, ws.CLOSING, ws.CLOSED].join()", "0,1,2,3"},
+		}
+		for _, tt := range tests {
+			if got := evalString(t, rt, tt.expr); got != tt.want {
+				t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+			}
+		}
+	})
+
+	t.Run("permission denied", func(t *testing.T) {
+		mgr := permissions.NewManager()
+		mgr.SetPromptMode(false)
+		permissions.SetGlobalManager(mgr)
+		t.Cleanup(func() { permissions.SetGlobalManager(nil) })
+
+		rt := runtime.New([]string{"dougless", "test.js"})
+		script := fmt.Sprintf(`
+			var errors = [], closed = false, opened = false;
+			http.connect(%q, {
+				open: () => { opened = true; },
+				error: (err) => errors.push(err),
+				close: (event) => { closed = event.code; },
+			});
+		`, wsURL)
+
+		if err := rt.Execute(script, "ws_client_denied.js"); err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+
+		if got := evalString(t, rt, "opened + ':' + closed"); got != "false:1006" {
+			t.Errorf("opened:closed = %s, want false:1006", got)
+		}
+		if got := evalString(t, rt, "errors.join()"); !strings.Contains(got, "--allow-net") {
+			t.Errorf("errors = %q, want a net permission error", got)
+		}
+	})
+}
+
+func TestWebSocketClientReconnect(t *testing.T) {
+	grantNet(t)
+
+	// the first connection is dropped without a close frame; later ones
+	// send their number and echo
+	var connections atomic.Int32
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(netHttp.HandlerFunc(func(w netHttp.ResponseWriter, r *netHttp.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		n := connections.Add(1)
+		if n == 1 {
+			conn.WriteMessage(websocket.TextMessage, []byte("first"))
+			conn.UnderlyingConn().Close()
+			return
+		}
+		conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf("connection %d", n)))
+		for {
+			messageType, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(messageType, append([]byte("echo: "), message...))
+		}
+	}))
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	rt := runtime.New([]string{"dougless", "test.js"})
+	script := fmt.Sprintf(`
+		var opens = 0, received = [], reconnects = [], closeCode;
+		const ws = http.connect(%q, {
+			open: (sock) => { opens++; if (opens === 2) sock.send('resumed'); },
+			message: (msg) => {
+				received.push(msg.data);
+				if (msg.data === 'echo: resumed') ws.close();
+			},
+			reconnecting: (e) => reconnects.push(e.attempt + ':' + e.delayMs + ':' + e.code),
+			close: (event) => { closeCode = event.code; },
+		}, { reconnect: { maxRetries: 3, backoffMs: 10 } });
+	`, wsURL)
+
+	if err := rt.Execute(script, "ws_reconnect.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"opens", "2"},
+		{"received.join('|')", "first|connection 2|echo: resumed"},
+		{"reconnects.join()", "1:10:1006"},
+		{"closeCode", "1000"},
+	}
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+
+	t.Run("gives up after maxRetries", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		deadURL := "ws://" + ln.Addr().String()
+		ln.Close() // nothing listens there any more
+
+		rt := runtime.New([]string{"dougless", "test.js"})
+		script := fmt.Sprintf(`
+			var reconnects = [], errors = [], closeCode;
+			http.connect(%q, {
+				reconnecting: (e) => reconnects.push(e.attempt + ':' + e.delayMs),
+				error: (err) => errors.push(err),
+				close: (event) => { closeCode = event.code; },
+			}, { reconnect: { maxRetries: 2, backoffMs: 5 } });
+		`, deadURL)
+
+		if err := rt.Execute(script, "ws_reconnect_fail.js"); err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+
+		if got := evalString(t, rt, "reconnects.join()"); got != "1:5,2:10" {
+			t.Errorf("reconnects = %q, want %q", got, "1:5,2:10")
+		}
+		if got := evalString(t, rt, "errors.length + ':' + closeCode"); got != "1:1006" {
+			t.Errorf("errors:closeCode = %q, want 1:1006", got)
+		}
+	})
+}
+
+func TestServerMaxConnections(t *testing.T) {
+	grantNet(t)
+
+	port := freePort(t)
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		const server = http.createServer((req, res) => {
+			if (req.url === '/__close') {
+				res.end('closing');
+				setTimeout(() => server.close(), 10);
+				return;
+			}
+			res.end('ok');
+		}, { maxConnections: 2 });
+		server.listen(%d, '127.0.0.1');
+	`, port)
+
+	errCh := executeAsync(rt, script, "max_connections.js")
+	addr := fmt.Sprintf("127.0.0.1:%d", port)
+	baseURL := "http://" + addr
+	waitForServer(t, baseURL)
+	netHttp.DefaultClient.CloseIdleConnections()
+
+	// request sends a keep-alive request on conn and waits up to timeout for
+	// the status line
+	request := func(conn net.Conn, timeout time.Duration) (string, error) {
+		if _, err := fmt.Fprintf(conn, "GET / HTTP/1.1\r\nHost: %s\r\n\r\n", addr); err != nil {
+			return "", err
+		}
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		return strings.TrimSpace(line), err
+	}
+
+	var held []net.Conn
+	for i := 0; i < 2; i++ {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatalf("Dial() error = %v", err)
+		}
+		defer conn.Close()
+		if line, err := request(conn, 2*time.Second); err != nil || line != "HTTP/1.1 200 OK" {
+			t.Fatalf("connection %d: got %q, %v", i+1, line, err)
+		}
+		held = append(held, conn)
+	}
+
+	// the third connection waits in the backlog while both slots are taken
+	extra, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer extra.Close()
+	if line, err := request(extra, 300*time.Millisecond); err == nil {
+		t.Fatalf("expected the third connection to wait, got %q", line)
+	}
+
+	held[0].Close()
+	extra.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if line, err := bufio.NewReader(extra).ReadString('\n'); err != nil || strings.TrimSpace(line) != "HTTP/1.1 200 OK" {
+		t.Fatalf("third connection after a slot freed: got %q, %v", line, err)
+	}
+
+	held[1].Close()
+	extra.Close()
+	closeScriptServer(baseURL)
+	waitForExecute(t, errCh, 5*time.Second)
+}
+
+func TestServerStreamBodyChunked(t *testing.T) {
+	grantNet(t)
+
+	port := freePort(t)
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		var received = [];
+		const server = http.createServer((req, res) => {
+			if (req.url === '/__close') {
+				res.end('closing');
+				setTimeout(() => server.close(), 10);
+				return;
+			}
+			req.on('data', (chunk) => {
+				received.push(String.fromCharCode.apply(null, chunk));
+			});
+			req.on('end', () => res.end(received.join('|')));
+		}, { streamBody: true });
+		server.listen(%d, '127.0.0.1');
+	`, port)
+
+	errCh := executeAsync(rt, script, "stream_body.js")
+	addr := fmt.Sprintf("127.0.0.1:%d", port)
+	baseURL := "http://" + addr
+	waitForServer(t, baseURL)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+
+	fmt.Fprintf(conn, "POST /upload HTTP/1.1\r\nHost: %s\r\nTransfer-Encoding: chunked\r\nConnection: close\r\n\r\n", addr)
+	fmt.Fprint(conn, "5\r\nhello\r\n")
+
+	// the first chunk reaches the handler while the upload is still open
+	deadline := time.Now().Add(2 * time.Second)
+	for evalString(t, rt, "received.join('|')") != "hello" {
+		if time.Now().After(deadline) {
+			t.Fatalf("first chunk not delivered before the body completed, got %q", evalString(t, rt, "received.join('|')"))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	fmt.Fprint(conn, "6\r\n world\r\n0\r\n\r\n")
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	resp, err := netHttp.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatalf("ReadResponse() error = %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "hello| world" {
+		t.Errorf("body = %q, want %q", body, "hello| world")
+	}
+
+	closeScriptServer(baseURL)
+	waitForExecute(t, errCh, 5*time.Second)
+}
+
+func TestServerRateLimit(t *testing.T) {
+	grantNet(t)
+
+	port := freePort(t)
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		var seen = [];
+		const server = http.createServer((req, res) => {
+			if (req.url === '/__close') {
+				res.end('closing');
+				setTimeout(() => server.close(), 10);
+				return;
+			}
+			res.end('ok');
+		});
+
+		server.use((req, res, next) => {
+			seen.push(req.remoteAddress);
+			next();
+		});
+		server.rateLimit({
+			windowMs: 300,
+			max: 2,
+			keyBy: (req) => req.url === '/__close' ? 'admin' : (req.headers['X-Api-Key'] || req.remoteAddress),
+		});
+		server.listen(%d, '127.0.0.1');
+	`, port)
+
+	errCh := executeAsync(rt, script, "rate_limit.js")
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", port)
+	waitForServer(t, baseURL) // counts against the 127.0.0.1 key
+
+	get := func(key string) *netHttp.Response {
+		t.Helper()
+		req, _ := netHttp.NewRequest(netHttp.MethodGet, baseURL+"/", nil)
+		if key != "" {
+			req.Header.Set("X-Api-Key", key)
+		}
+		resp, err := netHttp.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET error = %v", err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return resp
+	}
+
+	if resp := get("alice"); resp.StatusCode != 200 {
+		t.Fatalf("first request status = %d, want 200", resp.StatusCode)
+	}
+	if resp := get("alice"); resp.StatusCode != 200 {
+		t.Fatalf("second request status = %d, want 200", resp.StatusCode)
+	}
+	resp := get("alice")
+	if resp.StatusCode != netHttp.StatusTooManyRequests {
+		t.Fatalf("third request status = %d, want 429", resp.StatusCode)
+	}
+	if resp.Header.Get("Retry-After") != "1" {
+		t.Errorf("Retry-After = %q, want 1", resp.Header.Get("Retry-After"))
+	}
+	if resp := get("bob"); resp.StatusCode != 200 {
+		t.Errorf("other key status = %d, want 200", resp.StatusCode)
+	}
+
+	time.Sleep(350 * time.Millisecond)
+	if resp := get("alice"); resp.StatusCode != 200 {
+		t.Errorf("status after the window = %d, want 200", resp.StatusCode)
+	}
+
+	closeScriptServer(baseURL)
+	waitForExecute(t, errCh, 5*time.Second)
+
+	if got := evalString(t, rt, "seen.length > 0 && seen.every(ip => ip === '127.0.0.1')"); got != "true" {
+		t.Errorf("req.remoteAddress values = %s", evalString(t, rt, "JSON.stringify(seen)"))
+	}
+}
+
+func TestHTTPClientLocalAddr(t *testing.T) {
+	grantNet(t)
+
+	server := httptest.NewServer(netHttp.HandlerFunc(func(w netHttp.ResponseWriter, r *netHttp.Request) {
+		host, _, _ := net.SplitHostPort(r.RemoteAddr)
+		w.Write([]byte(host))
+	}))
+	defer server.Close()
+
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		var viaGet, viaClient, unassigned, invalid;
+
+		async function run() {
+			viaGet = (await http.get('%[1]s', { localAddr: '127.0.0.1' })).body;
+			viaClient = (await http.createClient({ localAddr: '127.0.0.1:0' }).get('%[1]s')).body;
+			try {
+				// TEST-NET-1 is never assigned to a local interface
+				await http.get('%[1]s', { localAddr: '192.0.2.1' });
+				unassigned = 'resolved';
+			} catch (e) {
+				unassigned = String(e);
+			}
+		}
+		run().catch(function(e) { viaGet = 'error: ' + e; });
+
+		try {
+			http.get('%[1]s', { localAddr: 'eth0' });
+		} catch (e) {
+			invalid = e.name + ': ' + e.message;
+		}
+	`, server.URL)
+
+	if err := rt.Execute(script, "local_addr.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"viaGet", "127.0.0.1"},
+		{"viaClient", "127.0.0.1"},
+		{"unassigned !== 'resolved' && unassigned.length > 0", "true"},
+		{"invalid", `TypeError: invalid localAddr "eth0": expected an IP address`},
+	}
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+// selfSignedCert returns a PEM certificate and key for 127.0.0.1, plus a
+// pool trusting it
+func selfSignedCert(t *testing.T) (certPEM, keyPEM string, pool *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	pool = x509.NewCertPool()
+	pool.AddCert(cert)
+
+	certPEM = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	keyPEM = string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	return certPEM, keyPEM, pool
+}
+
+func TestServerListenTLSNegotiatesHTTP2(t *testing.T) {
+	grantNet(t)
+
+	certPEM, keyPEM, pool := selfSignedCert(t)
+	certJSON, _ := json.Marshal(certPEM)
+	keyJSON, _ := json.Marshal(keyPEM)
+
+	port := freePort(t)
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		const server = http.createServer((req, res) => {
+			if (req.url === '/__close') {
+				res.end('closing');
+				setTimeout(() => server.close(), 10);
+				return;
+			}
+			res.end(req.httpVersion + ' ' + req.url);
+		});
+		server.listenTLS(%d, '127.0.0.1', { cert: %s, key: %s });
+	`, port, certJSON, keyJSON)
+
+	errCh := executeAsync(rt, script, "listen_tls.js")
+
+	client := &netHttp.Client{Transport: &netHttp.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: pool},
+		ForceAttemptHTTP2: true,
+	}}
+	baseURL := fmt.Sprintf("https://127.0.0.1:%d", port)
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		resp, err := client.Get(baseURL + "/__ping")
+		if err == nil {
+			resp.Body.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("TLS server did not start: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// concurrent requests share the one connection as multiplexed streams
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := client.Get(fmt.Sprintf("%s/stream/%d", baseURL, i))
+			if err != nil {
+				t.Errorf("request %d: %v", i, err)
+				return
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+
+			if resp.ProtoMajor != 2 || resp.TLS == nil || resp.TLS.NegotiatedProtocol != "h2" {
+				t.Errorf("request %d: proto = %s, want HTTP/2 negotiated as h2", i, resp.Proto)
+			}
+			if want := fmt.Sprintf("2.0 /stream/%d", i); string(body) != want {
+				t.Errorf("request %d: body = %q, want %q", i, body, want)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	resp, err := client.Get(baseURL + "/__close")
+	if err == nil {
+		resp.Body.Close()
+	}
+	waitForExecute(t, errCh, 3*time.Second)
+}
+
+func TestServerH2C(t *testing.T) {
+	grantNet(t)
+
+	port := freePort(t)
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		const server = http.createServer((req, res) => {
+			if (req.url === '/__close') {
+				res.end('closing');
+				setTimeout(() => server.close(), 10);
+				return;
+			}
+			res.end(req.httpVersion);
+		}, { h2c: true });
+		server.listen(%d, '127.0.0.1');
+	`, port)
+
+	errCh := executeAsync(rt, script, "h2c.js")
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", port)
+	waitForServer(t, baseURL)
+
+	// prior-knowledge HTTP/2 over plain TCP
+	client := &netHttp.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}}
+	resp, err := client.Get(baseURL + "/h2c")
+	if err != nil {
+		t.Fatalf("h2c request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.ProtoMajor != 2 || string(body) != "2.0" {
+		t.Errorf("proto = %s, body = %q; want HTTP/2 and \"2.0\"", resp.Proto, body)
+	}
+
+	closeScriptServer(baseURL)
+	waitForExecute(t, errCh, 3*time.Second)
+}
+
+func TestHeaders(t *testing.T) {
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := `
+		const headers = new Headers({ 'Content-Type': 'text/plain' });
+		headers.append('Accept', 'text/html');
+		headers.append('accept', 'application/json');
+		headers.set('X-Trace', ' abc ');
+		headers.append('X-Remove', 'gone');
+		headers.delete('x-remove');
+
+		var combined = headers.get('ACCEPT');
+		var missing = headers.get('x-missing');
+		var has = headers.has('content-type') + ',' + headers.has('x-remove');
+		var entries = [...headers].map(([k, v]) => k + '=' + v).join(';');
+		var keys = [...headers.keys()].join(',');
+		var copied = new Headers(headers).get('x-trace');
+		var fromPairs = new Headers([['a', '1'], ['A', '2']]).get('a');
+		var isHeaders = headers instanceof Headers;
+
+		var invalid;
+		try { headers.set('bad name', 'x'); } catch (e) { invalid = e instanceof TypeError; }
+	`
+
+	if err := rt.Execute(script, "headers.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"combined", "text/html, application/json"},
+		{"String(missing)", "null"},
+		{"has", "true,false"},
+		{"entries", "accept=text/html, application/json;content-type=text/plain;x-trace=abc"},
+		{"keys", "accept,content-type,x-trace"},
+		{"copied", "abc"},
+		{"fromPairs", "1, 2"},
+		{"String(isHeaders)", "true"},
+		{"String(invalid)", "true"},
+	}
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestHTTPGetWithHeaders(t *testing.T) {
+	grantNet(t)
+
+	server := httptest.NewServer(netHttp.HandlerFunc(func(w netHttp.ResponseWriter, r *netHttp.Request) {
+		w.Header().Add("X-Seen", strings.Join(r.Header.Values("X-Tag"), "|"))
+		w.Header().Add("Vary", "Accept")
+		w.Header().Add("Vary", "Origin")
+	}))
+	defer server.Close()
+
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		var seen, vary, legacy, isHeaders;
+		const headers = new Headers();
+		headers.append('X-Tag', 'one');
+		headers.append('X-Tag', 'two');
+
+		http.get('%s', { headers }).then(function(res) {
+			seen = res.headers.get('x-seen');
+			vary = res.headers.get('vary');
+			legacy = res.headers['X-Seen'];
+			isHeaders = res.headers instanceof Headers;
+		});
+	`, server.URL)
+
+	if err := rt.Execute(script, "get_headers.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"seen", "one|two"},
+		{"vary", "Accept, Origin"},
+		{"legacy", "one|two"},
+		{"String(isHeaders)", "true"},
+	}
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestHTTPRequestMethodsAndContentType(t *testing.T) {
+	grantNet(t)
+
+	server := httptest.NewServer(netHttp.HandlerFunc(func(w netHttp.ResponseWriter, r *netHttp.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		w.Header().Set("X-Content-Type", r.Header.Get("Content-Type"))
+		w.WriteHeader(netHttp.StatusAccepted)
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		var put, del, text, posted, status;
+		http.request('%[1]s', {
+			method: 'put',
+			headers: { 'Content-Type': 'application/merge-patch+json' },
+			body: { name: 'x' },
+		}).then(function(res) {
+			put = [res.status, res.headers.get('x-method'), res.headers.get('x-content-type'), res.body].join(' ');
+		});
+		http.request('%[1]s', { method: 'DELETE' }).then(function(res) {
+			del = res.headers.get('x-method') + ' ' + res.headers.get('x-content-type');
+		});
+		http.request('%[1]s', { method: 'POST', body: 'plain' }).then(function(res) {
+			text = res.headers.get('x-content-type') + ' ' + res.body;
+		});
+		http.post('%[1]s', { a: 1 }, { headers: { 'content-type': 'application/vnd.api+json' } }).then(function(res) {
+			posted = res.headers.get('x-content-type') + ' ' + res.body;
+			status = res.status;
+		});
+	`, server.URL)
+
+	if err := rt.Execute(script, "request.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"put", `202 PUT application/merge-patch+json {"name":"x"}`},
+		{"del", "DELETE "},
+		{"text", "text/plain; charset=utf-8 plain"},
+		{"posted", `application/vnd.api+json {"a":1}`},
+		{"String(status)", "202"},
+	}
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestHTTPRequestTimeout(t *testing.T) {
+	grantNet(t)
+
+	server := httptest.NewServer(netHttp.HandlerFunc(func(w netHttp.ResponseWriter, r *netHttp.Request) {
+		if r.URL.Path == "/slow" {
+			select {
+			case <-time.After(2 * time.Second):
+			case <-r.Context().Done():
+			}
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		var slow = 'pending', clientSlow = 'pending', fast = 'pending';
+
+		http.get('%[1]s/slow', { timeoutMs: 50 })
+			.then(function() { slow = 'resolved'; })
+			.catch(function(err) { slow = err.message; });
+
+		http.createClient({ timeoutMs: 50 }).get('%[1]s/slow')
+			.then(function() { clientSlow = 'resolved'; })
+			.catch(function(err) { clientSlow = err.message; });
+
+		http.get('%[1]s/fast', { timeoutMs: 1000 })
+			.then(function(res) { fast = res.body; })
+			.catch(function(err) { fast = 'rejected: ' + err.message; });
+	`, server.URL)
+
+	start := time.Now()
+	if err := rt.Execute(script, "timeout.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("timed out requests took %v to fail", elapsed)
+	}
+
+	want := "request to " + server.URL + "/slow timed out after 50ms"
+	if got := evalString(t, rt, "slow"); got != want {
+		t.Errorf("slow = %q, want %q", got, want)
+	}
+	if got := evalString(t, rt, "clientSlow"); got != want {
+		t.Errorf("clientSlow = %q, want %q", got, want)
+	}
+	if got := evalString(t, rt, "fast"); got != "ok" {
+		t.Errorf("fast = %q, want %q", got, "ok")
+	}
+}
+
+func TestServerCloseDrainsConnections(t *testing.T) {
+	grantNet(t)
+
+	port := freePort(t)
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		var closed = [], slowStarted = false;
+		const server = http.createServer((req, res) => {
+			if (req.url === '/slow') {
+				slowStarted = true;
+				setTimeout(() => res.end('slow done'), 300);
+				return;
+			}
+			if (req.url === '/__close') {
+				res.end('closing');
+				server.close((err) => closed.push('first ' + err));
+				server.close((err) => closed.push('second ' + err));
+				return;
+			}
+			res.end('ok');
+		}, { streamBody: true });
+		server.listen(%d, '127.0.0.1');
+	`, port)
+
+	errCh := executeAsync(rt, script, "drain.js")
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", port)
+	waitForServer(t, baseURL)
+
+	type result struct {
+		body string
+		err  error
+	}
+	slow := make(chan result, 1)
+	go func() {
+		resp, err := netHttp.Get(baseURL + "/slow")
+		if err != nil {
+			slow <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		slow <- result{string(body), err}
+	}()
+	waitForScript(t, rt, "slowStarted")
+
+	closeScriptServer(baseURL)
+
+	// new connections are refused while the slow request drains; close()
+	// shuts the listener down in the background, so allow it a moment
+	client := &netHttp.Client{Transport: &netHttp.Transport{DisableKeepAlives: true}}
+	deadline := time.Now().Add(time.Second)
+	for {
+		resp, err := client.Get(baseURL + "/")
+		if err != nil {
+			break
+		}
+		resp.Body.Close()
+		if time.Now().After(deadline) {
+			t.Error("server accepted a new connection after close()")
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	select {
+	case r := <-slow:
+		if r.err != nil || r.body != "slow done" {
+			t.Errorf("in-flight request = %q, %v; want it to finish", r.body, r.err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("in-flight request did not finish")
+	}
+
+	waitForExecute(t, errCh, 5*time.Second)
+	if got := evalString(t, rt, "closed.join(', ')"); got != "first null, second null" {
+		t.Errorf("close callbacks = %q, want both called with null", got)
+	}
+}
+
+func TestServerCloseGracePeriod(t *testing.T) {
+	grantNet(t)
+
+	port := freePort(t)
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		var closeErr = 'pending', hanging = false;
+		const server = http.createServer((req, res) => {
+			if (req.url === '/__close') {
+				res.end('closing');
+				server.close({ gracePeriodMs: 100 }, (err) => { closeErr = err && err.message; });
+				return;
+			}
+			if (req.url !== '/hang') {
+				res.end('ok');
+				return;
+			}
+			hanging = true; // /hang never ends its response
+		}, { streamBody: true });
+		server.listen(%d, '127.0.0.1');
+	`, port)
+
+	errCh := executeAsync(rt, script, "grace.js")
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", port)
+	waitForServer(t, baseURL)
+
+	hung := make(chan error, 1)
+	go func() {
+		// a fresh connection, so the dropped request isn't retried on another
+		client := &netHttp.Client{Transport: &netHttp.Transport{DisableKeepAlives: true}}
+		resp, err := client.Get(baseURL + "/hang")
+		if err == nil {
+			resp.Body.Close()
+		}
+		hung <- err
+	}()
+	waitForScript(t, rt, "hanging")
+
+	closeScriptServer(baseURL)
+	waitForExecute(t, errCh, 5*time.Second)
+
+	if got := evalString(t, rt, "closeErr"); !strings.Contains(got, "grace period of 100ms expired") {
+		t.Errorf("closeErr = %q, want the grace period to expire", got)
+	}
+	select {
+	case err := <-hung:
+		if err == nil {
+			t.Error("hung request got a response, want its connection dropped")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("hung request was not dropped")
+	}
+}
+
+func TestServerBinaryResponse(t *testing.T) {
+	grantNet(t)
+
+	port := freePort(t)
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		const all = new Uint8Array(256);
+		for (let i = 0; i < 256; i++) all[i] = i;
+
+		const server = http.createServer((req, res) => {
+			if (req.url === '/__close') {
+				res.end('closing');
+				server.close();
+				return;
+			}
+			if (req.url === '/bytes') {
+				res.end(all);
+			} else if (req.url === '/png') {
+				res.setHeader('Content-Type', 'image/png');
+				res.end(all.buffer);
+			} else if (req.url === '/chunks') {
+				res.write('head:');
+				res.write(all.subarray(250));
+				res.end(new TextEncoder().encode(':tail'));
+			}
+		});
+		server.listen(%d, '127.0.0.1');
+	`, port)
+
+	errCh := executeAsync(rt, script, "binary.js")
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", port)
+	waitForServer(t, baseURL)
+
+	all := make([]byte, 256)
+	for i := range all {
+		all[i] = byte(i)
+	}
+	chunks := append(append([]byte("head:"), all[250:]...), ":tail"...)
+
+	tests := []struct {
+		path        string
+		want        []byte
+		contentType string
+	}{
+		{"/bytes", all, "application/octet-stream"},
+		{"/png", all, "image/png"},
+		{"/chunks", chunks, "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		resp, err := netHttp.Get(baseURL + tt.path)
+		if err != nil {
+			t.Fatalf("GET %s error = %v", tt.path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if !bytes.Equal(body, tt.want) {
+			t.Errorf("GET %s body = %v, want %v", tt.path, body, tt.want)
+		}
+		if got := resp.Header.Get("Content-Type"); got != tt.contentType {
+			t.Errorf("GET %s Content-Type = %q, want %q", tt.path, got, tt.contentType)
+		}
+	}
+
+	closeScriptServer(baseURL)
+	waitForExecute(t, errCh, 5*time.Second)
+}
+
+func TestServerRouting(t *testing.T) {
+	grantNet(t)
+
+	tests := []struct {
+		name    string
+		server  string // how the script creates the server
+		method  string
+		path    string
+		status  int
+		body    string
+		headers string
+	}{
+		{"param", "http.createServer()", "GET", "/users/42", 200, "user 42", "via middleware"},
+		{"escaped param", "http.createServer()", "GET", "/users/ada%20lovelace", 200, "user ada lovelace", ""},
+		{"trailing slash", "http.createServer()", "GET", "/users/42/", 200, "user 42", ""},
+		{"method", "http.createServer()", "POST", "/users", 201, "created", ""},
+		{"head answers get routes", "http.createServer()", "HEAD", "/users/42", 200, "", ""},
+		{"wildcard", "http.createServer()", "GET", "/static/css/site.css", 200, "file css/site.css", ""},
+		{"all methods", "http.createServer()", "DELETE", "/anything", 200, "any DELETE", ""},
+		{"no route", "http.createServer()", "GET", "/missing", 404, "Not Found", ""},
+		{"wrong method", "http.createServer()", "PUT", "/users", 404, "Not Found", ""},
+		{"fallback handler", "http.createServer((req, res) => res.end('fallback ' + req.url))", "GET", "/missing", 200, "fallback /missing", ""},
+		{"routes before fallback", "http.createServer((req, res) => res.end('fallback'))", "GET", "/users/7", 200, "user 7", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port := freePort(t)
+			rt := runtime.New([]string{"dougless", "test.js"})
+
+			script := fmt.Sprintf(`
+				const server = %s;
+				server.use((req, res, next) => {
+					if (req.url === '/__close') {
+						res.end('closing');
+						server.close();
+						return;
+					}
+					res.setHeader('X-Seen', 'via middleware');
+					next();
+				});
+				server
+					.get('/users/:id', (req, res) => res.end('user ' + req.params.id))
+					.post('/users', (req, res) => { res.statusCode = 201; res.end('created'); })
+					.get('/static/*', (req, res) => res.end('file ' + req.params['*']));
+				server.all('/anything', (req, res) => res.end('any ' + req.method));
+				server.listen(%d, '127.0.0.1');
+			`, tt.server, port)
+
+			errCh := executeAsync(rt, script, "routes.js")
+			baseURL := fmt.Sprintf("http://127.0.0.1:%d", port)
+			waitForServer(t, baseURL)
+
+			req, _ := netHttp.NewRequest(tt.method, baseURL+tt.path, nil)
+			resp, err := netHttp.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("%s %s error = %v", tt.method, tt.path, err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.status || string(body) != tt.body {
+				t.Errorf("%s %s = %d %q, want %d %q", tt.method, tt.path, resp.StatusCode, body, tt.status, tt.body)
+			}
+			if tt.headers != "" && resp.Header.Get("X-Seen") != tt.headers {
+				t.Errorf("X-Seen = %q, want %q", resp.Header.Get("X-Seen"), tt.headers)
+			}
+
+			closeScriptServer(baseURL)
+			waitForExecute(t, errCh, 5*time.Second)
+		})
+	}
+
+	rt := runtime.New([]string{"dougless", "test.js"})
+	err := rt.Execute(`http.createServer().get('users', () => {});`, "bad_route.js")
+	if err == nil || !strings.Contains(err.Error(), "route pattern must start with /") {
+		t.Errorf("Execute(bad pattern) error = %v", err)
+	}
+}
+
+func TestHTTPUploadBodyFile(t *testing.T) {
+	dir := t.TempDir()
+	mgr := permissions.NewManager()
+	mgr.SetPromptMode(false)
+	mgr.GrantNet([]string{})
+	mgr.GrantRead([]string{dir})
+	permissions.SetGlobalManager(mgr)
+	t.Cleanup(func() { permissions.SetGlobalManager(nil) })
+
+	// large enough to span many reads
+	data := bytes.Repeat([]byte("0123456789abcdef"), 256*1024)
+	path := filepath.Join(dir, "backup.bin")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	received := map[string]string{}
+	server := httptest.NewServer(netHttp.HandlerFunc(func(w netHttp.ResponseWriter, r *netHttp.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received[r.Method] = fmt.Sprintf("%d %d %s %v", r.ContentLength, len(body), r.Header.Get("Content-Type"), bytes.Equal(body, data))
+		mu.Unlock()
+		fmt.Fprintf(w, "%d", len(body))
+	}))
+	defer server.Close()
+
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		var posted, put, denied;
+		http.post(%[1]q, { bodyFile: %[2]q }).then(function(res) { posted = res.body; });
+		http.request(%[1]q, {
+			method: 'PUT',
+			bodyFile: %[2]q,
+			headers: { 'Content-Type': 'application/x-tar' },
+		}).then(function(res) { put = res.body; });
+		http.post(%[1]q, { bodyFile: %[3]q }).catch(function(err) { denied = err.message; });
+	`, server.URL, path, filepath.Join(t.TempDir(), "secret.bin"))
+
+	if err := rt.Execute(script, "upload.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	size := fmt.Sprint(len(data))
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"posted", size},
+		{"put", size},
+		{"denied.includes('--allow-read')", "true"},
+	}
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := size + " " + size + " application/octet-stream true"; received["POST"] != want {
+		t.Errorf("POST received %q, want %q", received["POST"], want)
+	}
+	if want := size + " " + size + " application/x-tar true"; received["PUT"] != want {
+		t.Errorf("PUT received %q, want %q", received["PUT"], want)
+	}
+
+	err := rt.Execute(fmt.Sprintf(`http.request(%q, { body: 'x', bodyFile: %q })`, server.URL, path), "both.js")
+	if err == nil || !strings.Contains(err.Error(), "not both") {
+		t.Errorf("Execute(body and bodyFile) error = %v", err)
+	}
+}
+
+func TestServerSendFileRange(t *testing.T) {
+	dir := t.TempDir()
+	mgr := permissions.NewManager()
+	mgr.SetPromptMode(false)
+	mgr.GrantNet([]string{})
+	mgr.GrantRead([]string{dir})
+	permissions.SetGlobalManager(mgr)
+	t.Cleanup(func() { permissions.SetGlobalManager(nil) })
+
+	data := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	path := filepath.Join(dir, "video.txt")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	port := freePort(t)
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		const server = http.createServer((req, res) => {
+			if (req.url === '/__close') {
+				res.end('closing');
+				server.close();
+				return;
+			}
+			if (req.url === '/missing') {
+				res.sendFile(%q, (err) => {
+					res.statusCode = 410;
+					res.end('gone: ' + err.message);
+				});
+				return;
+			}
+			res.sendFile(req.url === '/secret' ? %q : %q);
+		});
+		server.listen(%d, '127.0.0.1');
+	`, filepath.Join(dir, "missing.txt"), filepath.Join(t.TempDir(), "secret.txt"), path, port)
+
+	errCh := executeAsync(rt, script, "send_file.js")
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", port)
+	waitForServer(t, baseURL)
+
+	req, _ := netHttp.NewRequest("GET", baseURL+"/video", nil)
+	req.Header.Set("Range", "bytes=0-9")
+	resp, err := netHttp.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET with Range error = %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != netHttp.StatusPartialContent || string(body) != "0123456789" {
+		t.Errorf("GET bytes=0-9 = %d %q, want 206 %q", resp.StatusCode, body, "0123456789")
+	}
+	wantRange := fmt.Sprintf("bytes 0-9/%d", len(data))
+	if got := resp.Header.Get("Content-Range"); got != wantRange {
+		t.Errorf("Content-Range = %q, want %q", got, wantRange)
+	}
+
+	resp, err = netHttp.Get(baseURL + "/video")
+	if err != nil {
+		t.Fatalf("GET error = %v", err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 200 || string(body) != string(data) {
+		t.Errorf("GET = %d %q, want 200 %q", resp.StatusCode, body, data)
+	}
+	if resp.Header.Get("Accept-Ranges") != "bytes" {
+		t.Errorf("Accept-Ranges = %q, want bytes", resp.Header.Get("Accept-Ranges"))
+	}
+
+	resp, err = netHttp.Get(baseURL + "/secret")
+	if err != nil {
+		t.Fatalf("GET /secret error = %v", err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 403 || !strings.Contains(string(body), "--allow-read") {
+		t.Errorf("GET /secret = %d %q, want 403 with a permission error", resp.StatusCode, body)
+	}
+
+	resp, err = netHttp.Get(baseURL + "/missing")
+	if err != nil {
+		t.Fatalf("GET /missing error = %v", err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 410 || !strings.HasPrefix(string(body), "gone: ") {
+		t.Errorf("GET /missing = %d %q, want the callback's 410", resp.StatusCode, body)
+	}
+
+	closeScriptServer(baseURL)
+	waitForExecute(t, errCh, 5*time.Second)
+}
+
+func TestHTTPClientCircuitBreaker(t *testing.T) {
+	grantNet(t)
+
+	var healthy atomic.Bool
+	var hits atomic.Int32
+	server := httptest.NewServer(netHttp.HandlerFunc(func(w netHttp.ResponseWriter, r *netHttp.Request) {
+		if r.URL.Path == "/heal" {
+			healthy.Store(true)
+			return
+		}
+		hits.Add(1)
+		if !healthy.Load() {
+			netHttp.Error(w, "down", netHttp.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("up"))
+	}))
+	defer server.Close()
+
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		var results = [];
+		const client = http.createClient({ circuitBreaker: { failureThreshold: 3, resetMs: 200 } });
+
+		async function attempt() {
+			try {
+				results.push((await client.get('%[1]s')).statusCode);
+			} catch (e) {
+				results.push(e.message.includes('circuit open') ? 'fast-fail' : 'error: ' + e.message);
+			}
+		}
+
+		async function run() {
+			for (var i = 0; i < 4; i++) await attempt();
+			await http.get('%[1]s/heal'); // a client without a breaker still gets through
+			await attempt();
+			await new Promise(function(resolve) { setTimeout(resolve, 250); });
+			await attempt();
+			await attempt();
+		}
+		run().catch(function(e) { results.push('error: ' + e); });
+	`, server.URL)
+
+	if err := rt.Execute(script, "circuit_breaker.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	if got := evalString(t, rt, "results.join()"); got != "503,503,503,fast-fail,fast-fail,200,200" {
+		t.Errorf("results = %q, want 503,503,503,fast-fail,fast-fail,200,200", got)
+	}
+	if got := hits.Load(); got != 5 {
+		t.Errorf("server saw %d requests, want 5 (fast-failed ones never sent)", got)
+	}
+
+	_, err := rt.Evaluate(`http.createClient({ circuitBreaker: { failureThreshold: 0 } })`)
+	if err == nil || !strings.Contains(err.Error(), "failureThreshold must be at least 1") {
+		t.Errorf("failureThreshold: 0 error = %v", err)
+	}
+}
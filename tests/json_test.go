@@ -0,0 +1,44 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/douglasjordan2/dougless/internal/runtime"
+)
+
+func TestJSONParseBig(t *testing.T) {
+	rt := runtime.New([]string{"dougless", "test.js"})
+	if err := rt.Execute(`
+		var text = '{"id": 9223372036854775807, "neg": -9007199254740993, "safe": 42, "ratio": 1.5, "list": [1, 18446744073709551615], "name": "x", "none": null, "ok": true}';
+		var big = JSON.parseBig(text);
+		var lossy = JSON.parse(text);
+		var revived = JSON.parseBig('{"a": 1, "b": 2}', (k, v) => k === 'a' ? undefined : v);
+		var parseError = '';
+		try { JSON.parseBig('{"a": 1} trailing'); } catch (e) { parseError = e.name; }
+	`, "test.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"String(big.id)", "9223372036854775807"},
+		{"String(lossy.id) === '9223372036854775807'", "false"},
+		{"String(big.neg)", "-9007199254740993"},
+		{"typeof big.safe", "number"},
+		{"big.safe", "42"},
+		{"big.ratio", "1.5"},
+		{"String(big.list[1])", "18446744073709551615"},
+		{"big.list[0]", "1"},
+		{"big.name + ',' + big.none + ',' + big.ok", "x,null,true"},
+		{"Object.keys(big).join()", "id,neg,safe,ratio,list,name,none,ok"},
+		{"JSON.stringify(revived)", `{"b":2}`},
+		{"parseError", "SyntaxError"},
+	}
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+}
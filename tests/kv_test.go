@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/douglasjordan2/dougless/internal/runtime"
+)
+
+func TestKVExpiry(t *testing.T) {
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := `
+		const kv = require('kv');
+		var before, after, hasAfter, kept, renewed, shared, deleted, deletedAgain;
+
+		kv.set('session', { user: 'ada' }, { ttlMs: 50 });
+		kv.set('config', 'forever');
+		kv.set('renewed', 'old', { ttlMs: 50 });
+		kv.set('renewed', 'new'); // replaces the value and drops the TTL
+		before = kv.get('session').user;
+		shared = require('kv').get('config'); // every require sees the same store
+
+		setTimeout(() => {
+			after = kv.get('session');
+			hasAfter = kv.has('session');
+			kept = kv.get('config');
+			renewed = kv.get('renewed');
+			deleted = kv.delete('config');
+			deletedAgain = kv.delete('config');
+		}, 120);
+	`
+
+	if err := rt.Execute(script, "kv.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"before", "ada"},
+		{"shared", "forever"},
+		{"String(after)", "undefined"},
+		{"hasAfter", "false"},
+		{"kept", "forever"},
+		{"renewed", "new"},
+		{"deleted", "true"},
+		{"deletedAgain", "false"},
+		{"String(require('kv').get('config'))", "undefined"},
+	}
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestKVExpiryDeterministic(t *testing.T) {
+	rt := runtime.NewDeterministic([]string{"dougless", "test.js"})
+
+	script := `
+		const kv = require('kv');
+		var halfway, later;
+
+		kv.set('token', 'abc', { ttlMs: 60 * 60 * 1000 });
+		setTimeout(() => { halfway = kv.has('token'); }, 30 * 60 * 1000);
+		setTimeout(() => { later = kv.has('token'); }, 2 * 60 * 60 * 1000);
+	`
+
+	start := time.Now()
+	if err := rt.Execute(script, "kv_clock.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Execute() took %s; TTLs should run on the virtual clock", elapsed)
+	}
+
+	if got := evalString(t, rt, "halfway + ',' + later"); got != "true,false" {
+		t.Errorf("has before/after the TTL = %q, want %q", got, "true,false")
+	}
+}
@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	goruntime "runtime"
+	"strings"
+	"testing"
+
+	"github.com/douglasjordan2/dougless/internal/permissions"
+	"github.com/douglasjordan2/dougless/internal/runtime"
+)
+
+func TestOSModule(t *testing.T) {
+	mgr := permissions.NewManager()
+	mgr.SetPromptMode(false)
+	mgr.GrantSys([]string{"totalmem", "freemem"})
+	permissions.SetGlobalManager(mgr)
+	t.Cleanup(func() { permissions.SetGlobalManager(nil) })
+
+	rt := runtime.New([]string{"dougless", "test.js"})
+	script := `
+		const os = require('os');
+		var homedirDenied;
+		try { os.homedir(); } catch (e) { homedirDenied = e.message; }
+	`
+	if err := rt.Execute(script, "os.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"os.platform()", goruntime.GOOS},
+		{"os.arch()", goruntime.GOARCH},
+		{"os.tmpdir()", os.TempDir()},
+		{"os.cpus().length", fmt.Sprint(goruntime.NumCPU())},
+		{"homedirDenied.includes('--allow-sys=homedir')", "true"},
+	}
+	if goruntime.GOOS == "linux" {
+		tests = append(tests, []struct {
+			expr string
+			want string
+		}{
+			{"os.totalmem() > 0", "true"},
+			{"os.freemem() > 0 && os.freemem() <= os.totalmem()", "true"},
+		}...)
+	}
+
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestOSHostnameNeedsSys(t *testing.T) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Skipf("os.Hostname() error = %v", err)
+	}
+
+	script := `
+		var name, denied;
+		try { name = require('os').hostname(); } catch (e) { denied = e.message; }
+	`
+
+	t.Run("denied without --allow-sys", func(t *testing.T) {
+		mgr := permissions.NewManager()
+		mgr.SetPromptMode(false)
+		permissions.SetGlobalManager(mgr)
+		t.Cleanup(func() { permissions.SetGlobalManager(nil) })
+
+		rt := runtime.New([]string{"dougless", "test.js"})
+		if err := rt.Execute(script, "os.js"); err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if got := evalString(t, rt, "typeof name"); got != "undefined" {
+			t.Errorf("typeof name = %q, want undefined", got)
+		}
+		if got := evalString(t, rt, "denied"); !strings.Contains(got, "--allow-sys=hostname") {
+			t.Errorf("denied = %q, want a hint to use --allow-sys=hostname", got)
+		}
+	})
+
+	t.Run("allowed with --allow-sys=hostname", func(t *testing.T) {
+		mgr := permissions.NewManager()
+		mgr.SetPromptMode(false)
+		mgr.GrantSys([]string{"hostname"})
+		permissions.SetGlobalManager(mgr)
+		t.Cleanup(func() { permissions.SetGlobalManager(nil) })
+
+		rt := runtime.New([]string{"dougless", "test.js"})
+		if err := rt.Execute(script, "os.js"); err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if got := evalString(t, rt, "name"); got != hostname {
+			t.Errorf("name = %q, want %q", got, hostname)
+		}
+	})
+}
@@ -0,0 +1,171 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/douglasjordan2/dougless/internal/permissions"
+	"github.com/douglasjordan2/dougless/internal/runtime"
+)
+
+func TestProcessArgvIncludesScriptArgs(t *testing.T) {
+	// mirror what main does with: dougless --allow-read app.js input.txt --verbose
+	_, remaining, err := permissions.ParseFlags([]string{"--allow-read", "app.js", "input.txt", "--verbose"})
+	if err != nil {
+		t.Fatalf("ParseFlags() error = %v", err)
+	}
+
+	rt := runtime.New(append([]string{"dougless"}, remaining...))
+
+	if err := rt.Execute(`var args = process.argv.slice(2);`, "argv.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"process.argv.length", "4"},
+		{"process.argv[1]", "app.js"},
+		{"args.join(' ')", "input.txt --verbose"},
+	}
+
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestProcessGetActiveHandles(t *testing.T) {
+	grantNet(t)
+
+	port := freePort(t)
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := fmt.Sprintf(`
+		const server = http.createServer((req, res) => res.end('ok'));
+		server.listen(%d, '127.0.0.1');
+		const timer = setTimeout(() => {}, 5000);
+
+		const handles = process.getActiveHandles();
+		var serverHandle = handles.filter(h => h.type === 'server')[0];
+		var timerHandle = handles.filter(h => h.type === 'timeout')[0];
+
+		clearTimeout(timer);
+		server.close();
+	`, port)
+
+	if err := rt.Execute(script, "handles.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"serverHandle.address", fmt.Sprintf("127.0.0.1:%d", port)},
+		{"timerHandle.delay", "5000"},
+		{"timerHandle.id === timer", "true"},
+		// once cleared and closed, nothing is left
+		{"process.getActiveHandles().length", "0"},
+	}
+
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestProcessEnvChecksPermissions(t *testing.T) {
+	t.Setenv("DOUGLESS_TOKEN", "abc123")
+	t.Setenv("DOUGLESS_SECRET", "s3cr3t")
+	t.Setenv("DOUGLESS_MODE", "")
+
+	mgr := permissions.NewManager()
+	mgr.SetPromptMode(false)
+	mgr.GrantEnv([]string{"DOUGLESS_TOKEN", "DOUGLESS_MODE", "DOUGLESS_UNSET"})
+	permissions.SetGlobalManager(mgr)
+	t.Cleanup(func() { permissions.SetGlobalManager(nil) })
+
+	rt := runtime.New([]string{"dougless", "env.js"})
+
+	script := `
+		var token = process.env.DOUGLESS_TOKEN;
+		var unset = process.env.DOUGLESS_UNSET;
+		var secret;
+		try { secret = process.env.DOUGLESS_SECRET; } catch (e) { secret = 'denied: ' + e.message; }
+		var setDenied;
+		try { process.env.DOUGLESS_SECRET = 'x'; } catch (e) { setDenied = e.message; }
+
+		process.env.DOUGLESS_MODE = 'production';
+		var keys = Object.keys(process.env).sort().join(',');
+		var hasToken = 'DOUGLESS_TOKEN' in process.env;
+		var json = JSON.stringify(process.env);
+	`
+	if err := rt.Execute(script, "env.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"token", "abc123"},
+		{"typeof unset", "undefined"},
+		{"secret.indexOf('denied: Permission denied') === 0 && secret.includes('--allow-env=DOUGLESS_SECRET')", "true"},
+		{"setDenied.includes('--allow-env=DOUGLESS_SECRET')", "true"},
+		{"process.env.DOUGLESS_MODE", "production"},
+		{"keys", "DOUGLESS_MODE,DOUGLESS_TOKEN"},
+		{"hasToken", "true"},
+		{"json", `{"DOUGLESS_MODE":"production","DOUGLESS_TOKEN":"abc123"}`},
+		{"typeof process.cwd()", "string"},
+	}
+
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestProcessMemoryUsageAndExposeGC(t *testing.T) {
+	opts, _, err := runtime.ParseFlags([]string{"--expose-gc", "app.js"})
+	if err != nil || !opts.ExposeGC {
+		t.Fatalf("ParseFlags(--expose-gc) = %+v, %v", opts, err)
+	}
+
+	rt := runtime.New([]string{"dougless", "test.js"})
+	if got := evalString(t, rt, "typeof gc"); got != "undefined" {
+		t.Errorf("typeof gc without --expose-gc = %q, want undefined", got)
+	}
+	rt.SetExposeGC(opts.ExposeGC)
+
+	script := `
+		gc();
+		var before = process.memoryUsage();
+		var big = new Array(1000000);
+		for (var i = 0; i < big.length; i++) big[i] = { n: i };
+		var after = process.memoryUsage();
+	`
+	if err := rt.Execute(script, "memory.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"typeof gc", "function"},
+		{"before.heapUsed > 0", "true"},
+		{"after.heapUsed > before.heapUsed", "true"},
+		{"after.heapTotal >= after.heapUsed && after.rss >= after.heapTotal", "true"},
+		{"after.external", "0"},
+	}
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package tests
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/douglasjordan2/dougless/internal/runtime"
+)
+
+func TestPromiseCombinatorsSettleWithNonThenables(t *testing.T) {
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := `
+		var allResult, anyResult, settledResult, raceResult;
+
+		// an object whose then is not callable is a plain value, not a thenable
+		const notThenable = { then: 42, label: 'plain' };
+		const sparse = [Promise.resolve(1), notThenable];
+		sparse[3] = Promise.resolve(4); // leaves a hole at index 2
+
+		Promise.all(sparse).then(function(values) {
+			allResult = values.length + ':' + values[0] + ':' + values[1].label + ':' + values[2] + ':' + values[3];
+		});
+
+		Promise.any([notThenable, Promise.reject('nope')]).then(function(value) {
+			anyResult = value.label;
+		});
+
+		Promise.allSettled(sparse).then(function(results) {
+			settledResult = results.map(function(r) { return r.status; }).join(',');
+		});
+
+		Promise.race([notThenable]).then(function(value) {
+			raceResult = value.label;
+		});
+	`
+
+	if err := rt.Execute(script, "combinators.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"allResult", "4:1:plain:undefined:4"},
+		{"anyResult", "plain"},
+		{"settledResult", "fulfilled,fulfilled,fulfilled,fulfilled"},
+		{"raceResult", "plain"},
+	}
+
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestMicrotaskAndImmediateOrdering(t *testing.T) {
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := `
+		var order = [];
+
+		setTimeout(() => order.push('timeout'), 0);
+		setImmediate(() => {
+			order.push('immediate 1');
+			Promise.resolve().then(() => order.push('promise in immediate'));
+			queueMicrotask(() => order.push('microtask in immediate'));
+		});
+		const cleared = setImmediate(() => order.push('cleared immediate'));
+		setImmediate((label) => order.push(label), 'immediate 2');
+		clearImmediate(cleared);
+
+		Promise.resolve().then(() => {
+			order.push('promise 1');
+			queueMicrotask(() => order.push('microtask from promise'));
+		}).then(() => order.push('promise 2'));
+		queueMicrotask(() => order.push('microtask'));
+
+		order.push('sync');
+	`
+
+	if err := rt.Execute(script, "ordering.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	want := "sync,promise 1,microtask,microtask from promise,promise 2," +
+		"immediate 1,promise in immediate,microtask in immediate,immediate 2,timeout"
+	if got := evalString(t, rt, "order.join(',')"); got != want {
+		t.Errorf("order = %q, want %q", got, want)
+	}
+}
+
+func TestUnhandledRejectionReportsSite(t *testing.T) {
+	rt := runtime.New([]string{"dougless", "test.js"})
+	var stderr bytes.Buffer
+	rt.SetStderr(&stderr)
+
+	script := `
+		function loadConfig() {
+			return new Promise((resolve, reject) => reject('missing config'));
+		}
+		function connectDatabase() {
+			return Promise.reject(new Error('connection refused'));
+		}
+		loadConfig();
+		connectDatabase();
+
+		// handled before the check runs, so not reported
+		const later = Promise.reject('handled later');
+		queueMicrotask(() => later.catch(() => {}));
+	`
+
+	if err := rt.Execute(script, "rejections.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	out := stderr.String()
+	for _, want := range []string{
+		"Unhandled promise rejection: missing config",
+		"loadConfig (rejections.js:",
+		"Unhandled promise rejection: Error: connection refused",
+		"connectDatabase (rejections.js:",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("stderr missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "handled later") {
+		t.Errorf("handled rejection was reported:\n%s", out)
+	}
+}
+
+func TestUnhandledRejectionEvent(t *testing.T) {
+	rt := runtime.New([]string{"dougless", "test.js"})
+	var stderr bytes.Buffer
+	rt.SetStderr(&stderr)
+
+	script := `
+		var seen = [], isPromise;
+		process.on('unhandledRejection', (reason, promise) => {
+			seen.push(reason instanceof Error ? reason.message : reason);
+			isPromise = typeof promise.then === 'function';
+		});
+
+		Promise.reject('first');
+		new Promise((resolve, reject) => reject(new Error('second')));
+		Promise.reject('handled').catch(() => {});
+	`
+
+	if err := rt.Execute(script, "rejections.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	if got := evalString(t, rt, "seen.join(',')"); got != "first,second" {
+		t.Errorf("seen = %q, want %q", got, "first,second")
+	}
+	if got := evalString(t, rt, "isPromise"); got != "true" {
+		t.Errorf("isPromise = %q, want true", got)
+	}
+	// listeners replace the default warning
+	if out := stderr.String(); strings.Contains(out, "Unhandled promise rejection") {
+		t.Errorf("warning printed despite a listener:\n%s", out)
+	}
+}
+
+func TestDeterministicTimersResolvePromise(t *testing.T) {
+	rt := runtime.NewDeterministic([]string{"dougless", "test.js"})
+
+	script := `
+		var log = [];
+		function delay(ms, value) {
+			return new Promise(resolve => setTimeout(() => resolve(value), ms));
+		}
+
+		delay(60 * 60 * 1000, 'an hour later').then(v => log.push(v));
+		delay(1000, 'a second later').then(v => log.push(v));
+
+		const ticker = setInterval(() => {
+			log.push('tick');
+			if (log.filter(e => e === 'tick').length === 3) clearInterval(ticker);
+		}, 400);
+	`
+
+	start := time.Now()
+	if err := rt.Execute(script, "delays.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Execute() took %s; timers should run on the virtual clock", elapsed)
+	}
+
+	want := "tick,tick,a second later,tick,an hour later"
+	if got := evalString(t, rt, "log.join(',')"); got != want {
+		t.Errorf("log = %q, want %q", got, want)
+	}
+}
+
+func TestPromiseFinally(t *testing.T) {
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := `
+		var calls = [], fulfilled, rejected, thrown, chained, afterThen;
+
+		new Promise((resolve) => resolve('value'))
+			.finally(() => { calls.push('resolve'); return 'ignored'; })
+			.then((v) => { fulfilled = v; });
+
+		new Promise((resolve, reject) => reject('reason'))
+			.finally(() => { calls.push('reject'); })
+			.catch((r) => { rejected = r; });
+
+		Promise.resolve('value')
+			.finally(() => { throw new Error('cleanup failed'); })
+			.catch((e) => { thrown = String(e); });
+
+		Promise.resolve(1)
+			.finally(() => Promise.resolve('waited'))
+			.finally(() => { calls.push('second'); })
+			.then((v) => { chained = v; });
+
+		new Promise((resolve) => resolve(2))
+			.then((v) => v * 10)
+			.finally(() => { calls.push('after then'); })
+			.then((v) => { afterThen = v; });
+	`
+
+	if err := rt.Execute(script, "finally.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	if got := evalString(t, rt, "calls.slice().sort().join(',')"); got != "after then,reject,resolve,second" {
+		t.Errorf("finally callbacks = %q", got)
+	}
+	for expr, want := range map[string]string{
+		"fulfilled": "value",
+		"rejected":  "reason",
+		"chained":   "1",
+		"afterThen": "20",
+	} {
+		if got := evalString(t, rt, "String("+expr+")"); got != want {
+			t.Errorf("%s = %q, want %q", expr, got, want)
+		}
+	}
+	if got := evalString(t, rt, "thrown"); !strings.Contains(got, "cleanup failed") {
+		t.Errorf("thrown = %q, want the error from the finally callback", got)
+	}
+}
+
+func TestQueueMicrotaskNestingAndErrors(t *testing.T) {
+	rt := runtime.New([]string{"dougless", "test.js"})
+	var stderr bytes.Buffer
+	rt.SetStderr(&stderr)
+
+	script := `
+		var order = [];
+
+		setTimeout(() => order.push('timeout'), 0);
+		setImmediate(() => order.push('immediate'));
+
+		queueMicrotask(() => {
+			order.push('outer');
+			queueMicrotask(() => {
+				order.push('nested');
+				queueMicrotask(() => order.push('nested again'));
+			});
+			throw new Error('microtask failed');
+		});
+		queueMicrotask(() => order.push('after throw'));
+	`
+
+	if err := rt.Execute(script, "microtasks.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	want := "outer,after throw,nested,nested again,immediate,timeout"
+	if got := evalString(t, rt, "order.join(',')"); got != want {
+		t.Errorf("order = %q, want %q", got, want)
+	}
+	if out := stderr.String(); !strings.Contains(out, "queueMicrotask callback error") || !strings.Contains(out, "microtask failed") {
+		t.Errorf("thrown microtask error not reported, stderr = %q", out)
+	}
+}
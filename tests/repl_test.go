@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/douglasjordan2/dougless/internal/repl"
+	"github.com/douglasjordan2/dougless/internal/runtime"
+)
+
+func TestREPLExitsOnEOF(t *testing.T) {
+	rt := runtime.New([]string{"dougless"})
+	var out bytes.Buffer
+
+	// no trailing .exit: the input just ends
+	input := strings.NewReader("const x = 20;\nfunction double(n) {\n  return n * 2;\n}\ndouble(x) + 2\n")
+	r := repl.New(rt, input, &out)
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- r.Run() }()
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run() did not return after EOF")
+	}
+
+	got := out.String()
+	if !strings.Contains(got, "42\n") {
+		t.Errorf("expected evaluated result in output, got %q", got)
+	}
+	if !strings.HasSuffix(got, "see ya\n") {
+		t.Errorf("expected a clean goodbye on EOF, got %q", got)
+	}
+}
+
+func TestREPLAwaitsPromises(t *testing.T) {
+	rt := runtime.New([]string{"dougless"})
+	var out bytes.Buffer
+
+	input := strings.NewReader(strings.Join([]string{
+		"Promise.resolve(41).then(n => n + 1)",
+		"await new Promise(resolve => setTimeout(() => resolve('later'), 20))",
+		"Promise.reject(new Error('nope'))",
+		"new Promise(() => {})",
+		"throw new Error('boom')",
+		"'still ' + 'responsive'",
+	}, "\n") + "\n")
+	r := repl.New(rt, input, &out)
+	r.SetAwaitTimeout(100 * time.Millisecond)
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- r.Run() }()
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() did not return")
+	}
+
+	got := out.String()
+	for _, want := range []string{
+		"> 42\n",
+		"> later\n",
+		"> Error: Uncaught (in promise) Error: nope",
+		"> Promise still pending after 100ms; it keeps running in the background\n",
+		"> Error: Error: boom",
+		"> still responsive\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestREPLHistoryFile(t *testing.T) {
+	runREPL := func(t *testing.T, input, historyFile string) string {
+		t.Helper()
+		var out bytes.Buffer
+		r := repl.New(runtime.New([]string{"dougless"}), strings.NewReader(input), &out)
+		r.SetHistoryFile(historyFile)
+		r.SetHistorySize(3)
+		if err := r.Run(); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		return out.String()
+	}
+
+	t.Run("saved, capped and de-duplicated", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), ".dougless_history")
+		if err := os.WriteFile(path, []byte("1 + 1\n2 + 2\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		runREPL(t, "3 + 3\n3 + 3\n.help\n4 + 4\n", path)
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(data), "2 + 2\n3 + 3\n4 + 4\n"; got != want {
+			t.Errorf("history file = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("unwritable location is ignored", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "missing", "dir", ".dougless_history")
+
+		out := runREPL(t, "6 * 7\n", path)
+		if !strings.Contains(out, "42\n") || strings.Contains(out, "Error") {
+			t.Errorf("output = %q, want the result and no error", out)
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("history file was created: %v", err)
+		}
+	})
+}
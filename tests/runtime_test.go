@@ -0,0 +1,691 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"github.com/douglasjordan2/dougless/internal/permissions"
+	"github.com/douglasjordan2/dougless/internal/runtime"
+)
+
+func TestTranspileTarget(t *testing.T) {
+	script := `
+		var double = (n) => n * 2;
+		var source = double.toString();
+		var result = double(21);
+	`
+
+	tests := []struct {
+		target    string
+		wantArrow bool
+	}{
+		{"esnext", true},
+		{"es5", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			opts, rest, err := runtime.ParseFlags([]string{"--target=" + tt.target, "app.js"})
+			if err != nil {
+				t.Fatalf("ParseFlags() error = %v", err)
+			}
+			if len(rest) != 1 || rest[0] != "app.js" {
+				t.Fatalf("remaining args = %v, want [app.js]", rest)
+			}
+
+			rt := runtime.New([]string{"dougless", "app.js"})
+			if err := rt.SetTarget(opts.Target); err != nil {
+				t.Fatalf("SetTarget() error = %v", err)
+			}
+
+			if err := rt.Execute(script, "target.js"); err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+
+			if got := evalString(t, rt, "result"); got != "42" {
+				t.Errorf("double(21) = %s, want 42", got)
+			}
+			if src := evalString(t, rt, "source"); strings.Contains(src, "=>") != tt.wantArrow {
+				t.Errorf("arrow preserved = %v, want %v (source %q)", !tt.wantArrow, tt.wantArrow, src)
+			}
+		})
+	}
+
+	if _, _, err := runtime.ParseFlags([]string{"--target=es3", "app.js"}); err == nil {
+		t.Error("expected an error for an unknown target")
+	}
+}
+
+func TestSandboxRestrictsGlobals(t *testing.T) {
+	sb, err := runtime.NewSandbox(runtime.SandboxOptions{
+		Globals: map[string]any{"double": func(n int) int { return n * 2 }},
+	})
+	if err != nil {
+		t.Fatalf("NewSandbox() error = %v", err)
+	}
+	defer sb.Close()
+
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"typeof files", "undefined"},
+		{"typeof process", "undefined"},
+		{"typeof http", "undefined"},
+		{"typeof require", "undefined"},
+		{"[1, 2, 3].map(n => n * n).reduce((a, b) => a + b, 0)", "14"},
+		{"JSON.stringify({ sum: Math.max(3, 7) + double(4) })", `{"sum":15}`},
+	}
+
+	for _, tt := range tests {
+		got, err := sb.Run(tt.code)
+		if err != nil {
+			t.Fatalf("Run(%q) error = %v", tt.code, err)
+		}
+		if got.String() != tt.want {
+			t.Errorf("Run(%q) = %q, want %q", tt.code, got.String(), tt.want)
+		}
+	}
+
+	// globals persist between runs, but never leak into the main runtime
+	if _, err := sb.Run("var pluginState = 'sandboxed';"); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	rt := runtime.New([]string{"dougless", "test.js"})
+	if got := evalString(t, rt, "typeof pluginState"); got != "undefined" {
+		t.Errorf("sandbox global leaked into the runtime: typeof pluginState = %q", got)
+	}
+
+	if _, err := sb.Run("files.read('/etc/passwd')"); err == nil {
+		t.Error("expected a ReferenceError for files in the sandbox")
+	}
+}
+
+func TestSandboxOptInGlobalsAndTimeout(t *testing.T) {
+	sb, err := runtime.NewSandbox(runtime.SandboxOptions{
+		Process: true,
+		Timers:  true,
+		Argv:    []string{"dougless", "plugin.js"},
+		Timeout: 100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewSandbox() error = %v", err)
+	}
+	defer sb.Close()
+
+	got, err := sb.Run("var fired = false; setTimeout(() => { fired = true; }, 10); process.argv[1]")
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if got.String() != "plugin.js" {
+		t.Errorf("process.argv[1] = %q, want plugin.js", got.String())
+	}
+	if got, _ := sb.Run("fired"); got == nil || got.String() != "true" {
+		t.Errorf("expected Run to wait for the timer, fired = %v", got)
+	}
+
+	if _, err := sb.Run("while (true) {}"); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected a timeout error, got %v", err)
+	}
+	if got, err := sb.Run("1 + 1"); err != nil || got.String() != "2" {
+		t.Errorf("sandbox unusable after a timeout: %v, %v", got, err)
+	}
+}
+
+func TestDouglessFeatures(t *testing.T) {
+	rt := runtime.New([]string{"dougless", "test.js"})
+	if err := rt.Execute(`
+		var names = Object.keys(Dougless.features);
+		var allBoolean = names.every(n => typeof Dougless.features[n] === 'boolean');
+		var proxyWorks = false;
+		try { proxyWorks = new Proxy({}, { get: () => 1 }).x === 1; } catch (e) {}
+	`, "test.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	if got := evalString(t, rt, "names.length > 0 && allBoolean"); got != "true" {
+		t.Errorf("Dougless.features = %s, want non-empty boolean entries", evalString(t, rt, "JSON.stringify(Dougless.features)"))
+	}
+
+	for _, name := range runtime.Features() {
+		if got, want := evalString(t, rt, "Dougless.features."+name), fmt.Sprint(rt.Supports(name)); got != want {
+			t.Errorf("Dougless.features.%s = %s, Supports() = %s", name, got, want)
+		}
+	}
+
+	if got, want := evalString(t, rt, "proxyWorks"), fmt.Sprint(rt.Supports("Proxy")); got != want {
+		t.Errorf("Proxy usable = %s, Supports(\"Proxy\") = %s", got, want)
+	}
+	if rt.Supports("teleportation") {
+		t.Error("Supports() = true for an unknown feature")
+	}
+	if got := evalString(t, rt, "Object.isFrozen(Dougless) && Object.isFrozen(Dougless.features)"); got != "true" {
+		t.Error("expected Dougless and Dougless.features to be frozen")
+	}
+}
+
+func TestRequireFile(t *testing.T) {
+	dir := t.TempDir()
+	grantFiles(t, dir)
+
+	modules := map[string]string{
+		"dep.js": `
+			globalThis.depLoads = (globalThis.depLoads || 0) + 1;
+			exports.name = 'dep';
+		`,
+		"data.json":    `{"n": 1}`,
+		"lib/index.js": `module.exports = require('../dep').name + '-lib';`,
+	}
+	for name, source := range modules {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rt := runtime.New([]string{"dougless", "main.js"})
+	script := `
+		var dep = require('./dep');
+		var again = require('./dep.js');
+		var data = require('./data');
+		var lib = require('./lib');
+	`
+	if err := rt.Execute(script, filepath.Join(dir, "main.js")); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"depLoads", "1"},
+		{"dep === again", "true"},
+		{"data.n", "1"},
+		{"lib", "dep-lib"},
+	}
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestRequireFileDenied(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "dep.js"), []byte("exports.ok = true;"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mgr := permissions.NewManager()
+	mgr.SetPromptMode(false)
+	permissions.SetGlobalManager(mgr)
+	t.Cleanup(func() { permissions.SetGlobalManager(nil) })
+
+	rt := runtime.New([]string{"dougless", "main.js"})
+	err := rt.Execute("require('./dep');", filepath.Join(dir, "main.js"))
+	if err == nil {
+		t.Fatal("Execute() error = nil, want a permission error")
+	}
+	if !strings.Contains(err.Error(), "Permission denied") {
+		t.Errorf("Execute() error = %v, want a permission error", err)
+	}
+}
+
+func TestRequirePreserveSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	grantFiles(t, dir)
+	pkgDir := filepath.Join(dir, "packages", "util")
+	if err := os.MkdirAll(pkgDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(pkgDir, "index.js"), []byte(`
+		globalThis.loads = (globalThis.loads || 0) + 1;
+		module.exports = { file: __filename };
+	`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(pkgDir, filepath.Join(dir, "linked")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	realFile, err := filepath.EvalSymlinks(filepath.Join(pkgDir, "index.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	script := `
+		var viaLink = require('./linked');
+		var direct = require('./packages/util/index.js');
+	`
+
+	tests := []struct {
+		name      string
+		preserve  bool
+		wantLoads string
+		wantFile  string
+	}{
+		{"default", false, "1", realFile},
+		{"preserve-symlinks", true, "2", filepath.Join(dir, "linked", "index.js")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := runtime.New([]string{"dougless", "main.js"})
+			rt.SetPreserveSymlinks(tt.preserve)
+			if err := rt.Execute(script, filepath.Join(dir, "main.js")); err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+
+			if got := evalString(t, rt, "loads"); got != tt.wantLoads {
+				t.Errorf("module evaluated %s times, want %s", got, tt.wantLoads)
+			}
+			if got := evalString(t, rt, "viaLink.file"); got != tt.wantFile {
+				t.Errorf("__filename via link = %q, want %q", got, tt.wantFile)
+			}
+		})
+	}
+
+	opts, _, err := runtime.ParseFlags([]string{"--preserve-symlinks", "app.js"})
+	if err != nil || !opts.PreserveSymlinks {
+		t.Errorf("ParseFlags(--preserve-symlinks) = %+v, %v", opts, err)
+	}
+}
+
+func TestRequireResolveAndCache(t *testing.T) {
+	dir := t.TempDir()
+	grantFiles(t, dir)
+	if err := os.WriteFile(filepath.Join(dir, "dep.js"), []byte(`
+		globalThis.depLoads = (globalThis.depLoads || 0) + 1;
+		module.exports = { resolvedFromDep: require.resolve('./dep') };
+	`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	depPath, err := filepath.EvalSymlinks(filepath.Join(dir, "dep.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rt := runtime.New([]string{"dougless", "main.js"})
+	script := `
+		var builtin = require.resolve('path');
+		var resolved = require.resolve('./dep');
+		var loadsBeforeRequire = globalThis.depLoads || 0;
+		var cachedBefore = resolved in require.cache;
+		var dep = require('./dep');
+		var cachedAfter = require.cache[resolved].exports === dep;
+		var missing;
+		try { require.resolve('./nope'); } catch (e) { missing = e.message; }
+	`
+	if err := rt.Execute(script, filepath.Join(dir, "main.js")); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"builtin", "path"},
+		{"resolved", depPath},
+		{"dep.resolvedFromDep", depPath},
+		{"loadsBeforeRequire", "0"},
+		{"cachedBefore", "false"},
+		{"cachedAfter", "true"},
+		{"require.cache[resolved].filename", depPath},
+		{"missing", "Cannot find module './nope'"},
+		// deleting the entry makes the next require evaluate the file again
+		{"delete require.cache[resolved], require('./dep'), depLoads", "2"},
+	}
+
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+// countingPrompter answers every permission prompt with grant and counts them
+type countingPrompter struct {
+	grant bool
+	calls int
+}
+
+func (p *countingPrompter) Prompt(ctx context.Context, desc permissions.PermissionDescriptor) (permissions.PromptResponse, error) {
+	p.calls++
+	return permissions.PromptResponse{Granted: p.grant, Scope: permissions.ScopeSession}, nil
+}
+
+func TestPermissionsQueryAndRequest(t *testing.T) {
+	prompter := &countingPrompter{grant: true}
+	mgr := permissions.NewManager()
+	mgr.SetPromptMode(true)
+	mgr.SetPrompter(prompter)
+	mgr.GrantRead([]string{"/srv/data"})
+	permissions.SetGlobalManager(mgr)
+	t.Cleanup(func() { permissions.SetGlobalManager(nil) })
+
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	script := `
+		var granted = permissions.query({ name: 'read', resource: '/srv/data/a.txt' });
+		var before = permissions.query({ name: 'net', resource: 'example.com' });
+		var requested = permissions.request({ name: 'net', resource: 'example.com' });
+		var after = permissions.query({ name: 'net', resource: 'example.com' });
+
+		var unknown;
+		try { permissions.query({ name: 'sudo' }); } catch (e) { unknown = e instanceof TypeError; }
+	`
+
+	if err := rt.Execute(script, "permissions.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"granted", "granted"},
+		{"before", "prompt"},
+		{"String(requested)", "true"},
+		{"after", "granted"},
+		{"String(unknown)", "true"},
+	}
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+	if prompter.calls != 1 {
+		t.Errorf("prompted %d times, want once (only by request)", prompter.calls)
+	}
+
+	mgr.SetPromptMode(false)
+	if got := evalString(t, rt, "permissions.query({ name: 'env', resource: 'HOME' })"); got != "denied" {
+		t.Errorf("query without prompts = %q, want denied", got)
+	}
+}
+
+func TestSetGlobal(t *testing.T) {
+	type database struct {
+		Host string `json:"host"`
+		Port int    `json:"port"`
+	}
+	type appConfig struct {
+		Name     string            `json:"name"`
+		Debug    bool              `json:"debug"`
+		Database database          `json:"database"`
+		Replicas []*database       `json:"replicas"`
+		Labels   map[string]string `json:"labels"`
+		Timeout  time.Duration     // untagged fields keep their Go name
+		secret   string
+	}
+
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	config := appConfig{
+		Name:     "billing",
+		Debug:    true,
+		Database: database{Host: "db.internal", Port: 5432},
+		Replicas: []*database{{Host: "r1", Port: 5433}, {Host: "r2", Port: 5434}},
+		Labels:   map[string]string{"team": "payments"},
+		Timeout:  time.Second,
+		secret:   "hidden",
+	}
+	if err := rt.SetGlobal("config", config); err != nil {
+		t.Fatalf("SetGlobal() error = %v", err)
+	}
+
+	script := `
+		var summary = [
+			config.name,
+			config.debug,
+			config.database.host + ':' + config.database.port,
+			config.replicas.map(r => r.host).join('+'),
+			config.labels.team,
+			config.Timeout,
+			'secret' in config,
+		].join(',');
+	`
+	if err := rt.Execute(script, "config.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	want := "billing,true,db.internal:5432,r1+r2,payments,1000000000,false"
+	if got := evalString(t, rt, "summary"); got != want {
+		t.Errorf("summary = %q, want %q", got, want)
+	}
+
+	if err := rt.SetGlobal("bad", map[string]any{"fn": func() {}}); err == nil {
+		t.Error("SetGlobal() with a function succeeded, want an error")
+	}
+}
+
+func TestStackOverflowIsReturned(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		site   string
+	}{
+		{
+			name:   "direct recursion",
+			script: "function recurse(n) { return recurse(n + 1) + 1; }\nrecurse(0);",
+			site:   "at recurse (overflow.js:1:",
+		},
+		{
+			// each level goes through a native function and so the Go stack
+			name:   "recursion through native calls",
+			script: "function nest(n) { return [n].map(nest); }\nnest(0);",
+			site:   "at nest (overflow.js:1:",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := runtime.New([]string{"dougless", "test.js"})
+
+			err := rt.Execute(tt.script, "overflow.js")
+			if err == nil {
+				t.Fatal("Execute() error = nil, want a stack overflow error")
+			}
+			if !strings.Contains(err.Error(), "RangeError: Maximum call stack size exceeded "+tt.site) {
+				t.Errorf("Execute() error = %q, want a stack overflow %s", err, tt.site)
+			}
+
+			// the runtime keeps working afterwards
+			if got := evalString(t, rt, "1 + 1"); got != "2" {
+				t.Errorf("1 + 1 = %q after the overflow", got)
+			}
+		})
+	}
+}
+
+func TestEnvFile(t *testing.T) {
+	for _, name := range []string{"DOUGLESS_TOKEN", "DOUGLESS_SECRET", "DOUGLESS_GREETING", "DOUGLESS_KEPT"} {
+		t.Setenv(name, "") // restored when the test ends
+		os.Unsetenv(name)
+	}
+	os.Setenv("DOUGLESS_KEPT", "from environment")
+
+	envFile := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(envFile, []byte(`# app settings
+DOUGLESS_TOKEN=abc123 # inline comment
+export DOUGLESS_SECRET='s3cr3t #1'
+DOUGLESS_GREETING="hello\nworld"
+DOUGLESS_KEPT=from file
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts, rest, err := runtime.ParseFlags([]string{"--env-file=" + envFile, "--allow-env=DOUGLESS_TOKEN,DOUGLESS_GREETING,DOUGLESS_KEPT", "app.js"})
+	if err != nil {
+		t.Fatalf("ParseFlags() error = %v", err)
+	}
+	if opts.EnvFile != envFile {
+		t.Fatalf("EnvFile = %q, want %q", opts.EnvFile, envFile)
+	}
+	if err := runtime.LoadEnvFile(opts.EnvFile); err != nil {
+		t.Fatalf("LoadEnvFile() error = %v", err)
+	}
+
+	mgr, _, err := permissions.ParseFlags(rest)
+	if err != nil {
+		t.Fatalf("permissions.ParseFlags() error = %v", err)
+	}
+	mgr.SetPromptMode(false)
+	permissions.SetGlobalManager(mgr)
+	t.Cleanup(func() { permissions.SetGlobalManager(nil) })
+
+	rt := runtime.New([]string{"dougless", "app.js"})
+
+	script := `
+		var token = env.get('DOUGLESS_TOKEN');
+		var greeting = env.get('DOUGLESS_GREETING');
+		var kept = env.get('DOUGLESS_KEPT');
+		var secret;
+		try { secret = env.get('DOUGLESS_SECRET'); } catch (e) { secret = 'denied: ' + e.message; }
+	`
+	if err := rt.Execute(script, "app.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"token", "abc123"},
+		{"greeting", "hello\nworld"},
+		{"kept", "from environment"}, // the real environment wins over the file
+	}
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+
+	// loaded, but not granted
+	if got := os.Getenv("DOUGLESS_SECRET"); got != "s3cr3t #1" {
+		t.Errorf("DOUGLESS_SECRET = %q, want it loaded", got)
+	}
+	if got := evalString(t, rt, "secret"); !strings.HasPrefix(got, "denied: ") || !strings.Contains(got, "--allow-env") {
+		t.Errorf("secret = %q, want a permission error naming --allow-env", got)
+	}
+
+	if err := runtime.LoadEnvFile(filepath.Join(t.TempDir(), "missing.env")); err == nil {
+		t.Error("LoadEnvFile(missing) error = nil")
+	}
+	if _, _, err := runtime.ParseFlags([]string{"--env-file=", "app.js"}); err == nil {
+		t.Error("ParseFlags(--env-file=) error = nil")
+	}
+}
+
+func TestSetGlobalFieldNameMapper(t *testing.T) {
+	type Retry struct {
+		MaxRetries int
+		BackoffMs  int `json:"backoff"`
+	}
+	type clientConfig struct {
+		Retry
+		BaseURL     string
+		HTTPTimeout time.Duration
+		APIKey      string   `json:"-"`
+		Tags        []string `json:",omitempty"`
+		Headers     map[string]string
+		StartedAt   time.Time
+	}
+
+	config := clientConfig{
+		Retry:       Retry{MaxRetries: 3, BackoffMs: 250},
+		BaseURL:     "https://api.example.com",
+		HTTPTimeout: time.Second,
+		APIKey:      "hidden",
+		Headers:     map[string]string{"X-Team": "payments"},
+		StartedAt:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name   string
+		mapper goja.FieldNameMapper
+		expr   string
+		want   string
+	}{
+		{
+			name:   "camelCase",
+			mapper: runtime.CamelCaseFieldNames(),
+			expr:   "[config.maxRetries, config.backoff, config.BaseURL === undefined, config.baseURL, config.httpTimeout, 'apiKey' in config, 'tags' in config, config.headers['X-Team'], config.startedAt].join(',')",
+			want:   "3,250,true,https://api.example.com,1000000000,false,false,payments,2024-01-02T03:04:05Z",
+		},
+		{
+			name:   "json tags only",
+			mapper: goja.TagFieldNameMapper("json", true),
+			expr:   "Object.keys(config).join(',')",
+			want:   "backoff",
+		},
+		{
+			name: "default",
+			expr: "[config.MaxRetries, config.backoff, config.BaseURL].join(',')",
+			want: "3,250,https://api.example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := runtime.New([]string{"dougless", "test.js"})
+			rt.SetFieldNameMapper(tt.mapper)
+			if err := rt.SetGlobal("config", config); err != nil {
+				t.Fatalf("SetGlobal() error = %v", err)
+			}
+			if got := evalString(t, rt, tt.expr); got != tt.want {
+				t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProfileCountsCalls(t *testing.T) {
+	opts, _, err := runtime.ParseFlags([]string{"--profile", "app.js"})
+	if err != nil || !opts.Profile {
+		t.Fatalf("ParseFlags(--profile) = %+v, %v", opts, err)
+	}
+
+	var profile strings.Builder
+	rt := runtime.New([]string{"dougless", "test.js"})
+	rt.SetProfile(&profile)
+
+	script := `
+		function hot(n) { 'use strict'; return n * 2 + (this === undefined ? 0 : 1); }
+		function fib(n) { return n < 2 ? n : fib(n - 1) + fib(n - 2); }
+		const wrap = (k) => ({ k });
+		var sum = 0;
+		for (var i = 0; i < 2500; i++) sum += hot(i) + wrap(i).k;
+		var fib10 = fib(10);
+	`
+	if err := rt.Execute(script, "profile.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	// the instrumented script must behave as before
+	if got := evalString(t, rt, "sum + ':' + fib10"); got != "9371250:55" {
+		t.Errorf("sum:fib10 = %q, want 9371250:55", got)
+	}
+
+	calls := map[string]string{}
+	for _, line := range strings.Split(profile.String(), "\n") {
+		if fields := strings.Fields(line); len(fields) >= 4 && strings.Contains(line, "profile.js") {
+			calls[fields[3]] = fields[1]
+		}
+	}
+	want := map[string]string{"hot": "2500", "fib": "177", "wrap": "2500"}
+	for name, count := range want {
+		if calls[name] != count {
+			t.Errorf("calls to %s = %q, want %s\nprofile:%s", name, calls[name], count, profile.String())
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package tests
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/douglasjordan2/dougless/internal/runtime"
+)
+
+func TestScheduleCronFiresAndCancels(t *testing.T) {
+	rt := runtime.New([]string{"dougless", "test.js"})
+
+	// Execute returns once the job is cancelled, since jobs keep the runtime alive.
+	err := rt.Execute(`
+		const schedule = require('schedule');
+		var fired = [];
+		var job = schedule.cron('* * * * * *', (firedAt) => {
+			fired.push(firedAt.getTime());
+			if (fired.length === 3) job.cancel();
+		});
+		var expression = job.expression;
+	`, "test.js")
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	if got := evalString(t, rt, "expression"); got != "* * * * * *" {
+		t.Errorf("job.expression = %q", got)
+	}
+	if got := evalString(t, rt, "fired.length"); got != "3" {
+		t.Fatalf("fired %s times, want 3", got)
+	}
+	for i := 1; i < 3; i++ {
+		gap := evalString(t, rt, "fired["+strconv.Itoa(i)+"] - fired["+strconv.Itoa(i-1)+"]")
+		if gap != "1000" {
+			t.Errorf("gap between runs %d and %d = %sms, want 1000", i-1, i, gap)
+		}
+	}
+	if got := evalString(t, rt, "fired.every(ms => ms % 1000 === 0)"); got != "true" {
+		t.Errorf("fire times not aligned to whole seconds: %s", evalString(t, rt, "fired.join(',')"))
+	}
+	if got := evalString(t, rt, "job.next()"); got != "null" {
+		t.Errorf("job.next() after cancel = %s, want null", got)
+	}
+}
+
+func TestScheduleCronDeterministic(t *testing.T) {
+	rt := runtime.NewDeterministic([]string{"dougless", "test.js"})
+
+	start := time.Now()
+	err := rt.Execute(`
+		const schedule = require('schedule');
+		var fired = [];
+		var job = schedule.cron('@hourly', (firedAt) => {
+			fired.push(firedAt.getTime());
+			if (fired.length === 3) job.cancel();
+		});
+	`, "test.js")
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Execute() took %s; jobs should run on the virtual clock", elapsed)
+	}
+
+	if got := evalString(t, rt, "fired.length"); got != "3" {
+		t.Fatalf("fired %s times, want 3", got)
+	}
+	if got := evalString(t, rt, "fired[2] - fired[0]"); got != "7200000" {
+		t.Errorf("first to third run = %sms, want two hours", got)
+	}
+}
+
+func TestScheduleCronExpressions(t *testing.T) {
+	rt := runtime.New([]string{"dougless", "test.js"})
+	if err := rt.Execute(`
+		const schedule = require('schedule');
+		function nextOf(expr) {
+			const job = schedule.cron(expr, () => {});
+			const next = job.next();
+			job.cancel();
+			return next;
+		}
+		function errorOf(expr) {
+			try { schedule.cron(expr, () => {}); return ''; } catch (e) { return e.message; }
+		}
+		var yearly = nextOf('@yearly');
+		var weekday = nextOf('30 9 * * mon-fri');
+		var stepped = nextOf('*/15 * * * *');
+	`, "test.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"[yearly.getMonth(), yearly.getDate(), yearly.getHours(), yearly.getMinutes()].join()", "0,1,0,0"},
+		{"[weekday.getHours(), weekday.getMinutes()].join()", "9,30"},
+		{"weekday.getDay() >= 1 && weekday.getDay() <= 5", "true"},
+		{"stepped.getMinutes() % 15 === 0 && stepped.getSeconds() === 0", "true"},
+		{"stepped - Date.now() <= 15 * 60 * 1000", "true"},
+	}
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+
+	for expr, want := range map[string]string{
+		"* * * *":     "expected 5 or 6 fields",
+		"60 * * * *":  "invalid minute",
+		"* * * 13 *":  "invalid month",
+		"*/0 * * * *": "invalid step",
+		"0 0 30 2 *":  "never fires",
+		"5-1 * * * *": "invalid range",
+	} {
+		if got := evalString(t, rt, "errorOf('"+expr+"')"); !strings.Contains(got, want) {
+			t.Errorf("schedule.cron(%q) error = %q, want it to mention %q", expr, got, want)
+		}
+	}
+}
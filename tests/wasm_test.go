@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/douglasjordan2/dougless/internal/runtime"
+)
+
+// mathWasm is a hand-assembled module equivalent to:
+//
+//	(module
+//	  (import "env" "mul" (func $mul (param i32 i32) (result i32)))
+//	  (func (export "add") (param i32 i32) (result i32)
+//	    (i32.add (local.get 0) (local.get 1)))
+//	  (func (export "mul") (param i32 i32) (result i32)
+//	    (call $mul (local.get 0) (local.get 1))))
+var mathWasm = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00, // magic, version
+	0x01, 0x07, 0x01, 0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7f, // type: (i32, i32) -> i32
+	0x02, 0x0b, 0x01, 0x03, 'e', 'n', 'v', 0x03, 'm', 'u', 'l', 0x00, 0x00, // import env.mul
+	0x03, 0x03, 0x02, 0x00, 0x00, // two functions of type 0
+	0x07, 0x0d, 0x02, 0x03, 'a', 'd', 'd', 0x00, 0x01, 0x03, 'm', 'u', 'l', 0x00, 0x02, // exports
+	0x0a, 0x12, 0x02, // code
+	0x07, 0x00, 0x20, 0x00, 0x20, 0x01, 0x6a, 0x0b, // add: local.get 0, local.get 1, i32.add
+	0x08, 0x00, 0x20, 0x00, 0x20, 0x01, 0x10, 0x00, 0x0b, // mul: local.get 0, local.get 1, call 0
+}
+
+func TestWasmInstantiate(t *testing.T) {
+	dir := t.TempDir()
+	grantFiles(t, dir)
+
+	path := filepath.Join(dir, "math.wasm")
+	if err := os.WriteFile(path, mathWasm, 0644); err != nil {
+		t.Fatal(err)
+	}
+	deniedPath := filepath.Join(filepath.Dir(dir), "outside.wasm")
+
+	byteList := make([]string, len(mathWasm))
+	for i, b := range mathWasm {
+		byteList[i] = fmt.Sprint(b)
+	}
+
+	rt := runtime.New([]string{"dougless", "test.js"})
+	script := fmt.Sprintf(`
+		const wasm = require('wasm');
+		const imports = { env: { mul: (a, b) => a * b } };
+
+		const instance = wasm.instantiate(new Uint8Array([%s]), imports);
+		var sum = instance.exports.add(2, 3);
+		var negative = instance.exports.add(-10, 4);
+		var product = instance.exports.mul(6, 7);
+		instance.close();
+
+		var fromFile = wasm.instantiateFile(%q, imports).exports.add(40, 2);
+
+		function errorOf(fn) {
+			try { fn(); return ''; } catch (e) { return String(e.message || e); }
+		}
+		var deniedErr = errorOf(() => wasm.instantiateFile(%q, imports));
+		var missingImportErr = errorOf(() => wasm.instantiate(new Uint8Array([%s])));
+		var invalidErr = errorOf(() => wasm.instantiate(new Uint8Array([1, 2, 3])));
+	`, strings.Join(byteList, ","), path, deniedPath, strings.Join(byteList, ","))
+	if err := rt.Execute(script, "wasm.js"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"sum", "5"},
+		{"negative", "-6"},
+		{"product", "42"},
+		{"fromFile", "42"},
+		{"deniedErr.indexOf('Permission denied') !== -1", "true"},
+		{"missingImportErr.indexOf('missing import env.mul') !== -1", "true"},
+		{"invalidErr.indexOf('wasm:') !== -1", "true"},
+	}
+	for _, tt := range tests {
+		if got := evalString(t, rt, tt.expr); got != tt.want {
+			t.Errorf("%s = %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+}